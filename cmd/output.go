@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"os"
+)
+
+// ANSI escape sequences used for terminal styling
+const (
+	ansiReset  = "\033[0m"
+	ansiBold   = "\033[1m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+)
+
+// colorEnabled reports whether terminal styling should be used.
+// Styling is disabled by --no-color, the NO_COLOR environment variable
+// (https://no-color.org), TERM=dumb, or when stdout is not a terminal.
+func colorEnabled() bool {
+	if noColor {
+		return false
+	}
+	if _, set := os.LookupEnv("NO_COLOR"); set {
+		return false
+	}
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return isTerminal(os.Stdout)
+}
+
+// isTerminal reports whether f is connected to a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// style wraps s in the given ANSI code when styling is enabled
+func style(code, s string) string {
+	if !colorEnabled() {
+		return s
+	}
+	return code + s + ansiReset
+}
+
+// bold renders s in bold
+func bold(s string) string {
+	return style(ansiBold, s)
+}
+
+// success renders s in green
+func success(s string) string {
+	return style(ansiGreen, s)
+}
+
+// warning renders s in yellow
+func warning(s string) string {
+	return style(ansiYellow, s)
+}
+
+// failure renders s in red
+func failure(s string) string {
+	return style(ansiRed, s)
+}
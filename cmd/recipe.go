@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"pdfdarkmode/converter/colors"
+)
+
+// recipeVersion is the format version written to recipe files
+const recipeVersion = 1
+
+// recipeFlags are the flags that shape the output and are saved in a recipe. The scheme
+// flags (--scheme, --scheme-from-pdf, --bg-color, --text-color, --style, --dark-level,
+// --target-contrast) are saved as the scheme and remaps they resolved to instead.
+var recipeFlags = []string{
+	"mode", "dpi", "snap-near-white", "snap-near-black", "clean-edges", "protect-ink", "auto-orient", "text-regions-only",
+	"flatten-transparency", "cmyk", "tint-strength", "min-color-lightness", "max-color-lightness",
+	"normalize-rotation", "tag-icc", "icc-profile", "layers", "sanitize", "incremental", "compat-operators", "no-recompress",
+	"preserve-images", "keep-structure", "single-pass", "no-viewer-hints", "pdf-version", "pdfa",
+	"only-colorspace", "skip-colorspace", "preserve-white-above", "stripe-aware", "map-primary-text", "gradient-background", "background-image", "color-tolerance",
+	"respect-existing-dark-background", "color-metric", "match-tolerance",
+	"background-margin", "sample-pages", "default-color-apply", "text-only-pages", "intensity",
+	"watermark", "watermark-opacity",
+}
+
+// intensityFlags are recipe flags whose defaults come from --intensity
+var intensityFlags = map[string]bool{"snap-near-white": true, "snap-near-black": true}
+
+// schemeFlags select the colors; given on the command line they replace a recipe's colors
+var schemeFlags = []string{"scheme", "scheme-from-pdf", "bg-color", "text-color", "style", "dark-level", "target-contrast"}
+
+// Recipe is a snapshot of the settings behind one conversion, to reuse or share
+type Recipe struct {
+	Version int               `json:"version"`
+	Scheme  recipeScheme      `json:"scheme"`
+	Remaps  []recipeRemap     `json:"remaps,omitempty"`
+	Flags   map[string]string `json:"flags"`
+}
+
+type recipeScheme struct {
+	Name       string       `json:"name"`
+	Background colors.Color `json:"background"`
+	Text       colors.Color `json:"text"`
+}
+
+type recipeRemap struct {
+	From colors.Color `json:"from"`
+	To   colors.Color `json:"to"`
+}
+
+// newRecipe captures the effective scheme, remaps and recipe flags of cmd
+func newRecipe(cmd *cobra.Command, scheme colors.Scheme, remaps []colors.Remap) Recipe {
+	recipe := Recipe{
+		Version: recipeVersion,
+		Scheme:  recipeScheme{Name: scheme.Name, Background: scheme.Background, Text: scheme.Text},
+		Flags:   make(map[string]string, len(recipeFlags)),
+	}
+	for _, remap := range remaps {
+		recipe.Remaps = append(recipe.Remaps, recipeRemap{From: remap.From, To: remap.To})
+	}
+	for _, name := range recipeFlags {
+		// Snap cutoffs not given follow the recorded intensity
+		if intensityFlags[name] && !cmd.Flags().Changed(name) {
+			continue
+		}
+		recipe.Flags[name] = cmd.Flags().Lookup(name).Value.String()
+	}
+	return recipe
+}
+
+// saveRecipe writes recipe to path as JSON
+func saveRecipe(path string, recipe Recipe) error {
+	data, err := json.MarshalIndent(recipe, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write recipe: %w", err)
+	}
+	return nil
+}
+
+// loadRecipe reads a recipe from path and sets its flags on cmd, except those given on
+// the command line, which take precedence
+func loadRecipe(cmd *cobra.Command, path string) (*Recipe, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recipe: %w", err)
+	}
+
+	var recipe Recipe
+	if err := json.Unmarshal(data, &recipe); err != nil {
+		return nil, fmt.Errorf("invalid recipe %s: %w", path, err)
+	}
+	if recipe.Version != recipeVersion {
+		return nil, fmt.Errorf("unsupported recipe version %d in %s (expected %d)", recipe.Version, path, recipeVersion)
+	}
+
+	known := make(map[string]bool, len(recipeFlags))
+	for _, name := range recipeFlags {
+		known[name] = true
+	}
+	for name, value := range recipe.Flags {
+		if !known[name] {
+			return nil, fmt.Errorf("invalid recipe %s: unknown setting %q", path, name)
+		}
+		if cmd.Flags().Changed(name) {
+			continue
+		}
+		if err := cmd.Flags().Set(name, value); err != nil {
+			return nil, fmt.Errorf("invalid recipe %s: %s: %w", path, name, err)
+		}
+	}
+
+	return &recipe, nil
+}
+
+// schemeAndRemaps returns the recipe's scheme and remaps
+func (r *Recipe) schemeAndRemaps() (colors.Scheme, []colors.Remap) {
+	scheme := colors.Scheme{Name: "custom", Background: r.Scheme.Background, Text: r.Scheme.Text}
+	if r.Scheme.Name != "" {
+		scheme.Name = r.Scheme.Name
+	}
+
+	var remaps []colors.Remap
+	for _, remap := range r.Remaps {
+		remaps = append(remaps, colors.Remap{From: remap.From, To: remap.To})
+	}
+	return scheme, remaps
+}
+
+// anyChanged reports whether any of the named flags was given on the command line
+func anyChanged(cmd *cobra.Command, names []string) bool {
+	for _, name := range names {
+		if cmd.Flags().Changed(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,15 +2,31 @@ package cmd
 
 import (
 	"bufio"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
 	"github.com/spf13/cobra"
 
 	"pdfdarkmode/converter"
+	"pdfdarkmode/converter/colormath"
 	"pdfdarkmode/converter/colors"
+	"pdfdarkmode/converter/direct"
+	"pdfdarkmode/converter/gallery"
+	"pdfdarkmode/converter/icc"
+	"pdfdarkmode/converter/intensity"
+	"pdfdarkmode/converter/nup"
+	"pdfdarkmode/converter/parity"
+	"pdfdarkmode/converter/pdfversion"
+	"pdfdarkmode/converter/raster"
+	"pdfdarkmode/converter/sample"
+	"pdfdarkmode/converter/selftest"
 )
 
 var (
@@ -21,6 +37,76 @@ var (
 	colorScheme    string
 	bgColor        string
 	textColor      string
+	schemeFromPDF  string
+	pdfVersion     string
+	keepStructure  bool
+	noColor        bool
+	singlePass     bool
+	styleFile      string
+	colorMetric    string
+	matchTol       float64
+	sampleRate     float64
+	sampleStrategy string
+	sampleSeed     int64
+	noViewerHints  bool
+	snapNearWhite  float64
+	snapNearBlack  float64
+	autoOrient     bool
+	cleanEdges     bool
+	gradient       bool
+	bgImage        string
+	respectDarkBg  bool
+	bgMargin       float64
+	protectInks    string
+	tintStrength   float64
+	intensityLevel int
+	colorTol       float64
+	targetContrast float64
+	darkLevel      string
+	minColorL      float64
+	maxColorL      float64
+	preserveWhite  float64
+	stripeAware    bool
+	mapPrimary     bool
+	readability    bool
+	detectScanned  bool
+	samplePages    int
+	defaultApply   string
+	normalizeRot   bool
+	textRegions    bool
+	textPages      bool
+	checkPoppler   bool
+	cmyk           bool
+	flatten        bool
+	dpiWarn        string
+	maxOutputSize  string
+	maxRenderTime  time.Duration
+	cacheDir       string
+	assumeYes      bool
+	tagICC         bool
+	iccProfile     string
+	layers         bool
+	sanitizeOut    bool
+	incremental    bool
+	watermark      string
+	watermarkAlpha float64
+	compatOps      bool
+	noRecompress   bool
+	strict         bool
+	pdfA           bool
+	ifAlreadyDark  string
+	reportDir      string
+	htmlReport     string
+	alsoLight      bool
+	compareList    string
+	rtl            bool
+	recipeFile     string
+	saveRecipeFile string
+	galleryDir     string
+	onlySpaces     string
+	skipSpaces     string
+	onlyPages      string
+	splitNUp       string
 
 	// Version info
 	version   = "dev"
@@ -40,12 +126,15 @@ var rootCmd = &cobra.Command{
 	Short: "Convert PDFs to dark mode",
 	Long: `A CLI tool to convert PDF documents to dark mode.
 
-Supports two conversion modes:
+Supports three conversion modes:
   - raster: Converts pages to images, inverts colors, reassembles (reliable)
   - direct: Modifies PDF color operators directly (preserves vectors/text)
+  - hybrid: Inverted raster backgrounds with selectable direct-mode text on top
 
-Available color schemes: dark, sepia, nord, solarized, gruvbox, dracula, monokai
-Or use --bg-color and --text-color for custom colors (hex format: #1a1a1a)`,
+Available color schemes: dark, sepia, nord, solarized, gruvbox, dracula, monokai,
+and reading-light (keeps a white page and only softens dark text)
+Or use --bg-color and --text-color for custom colors (hex format: #1a1a1a),
+or --style with a stylesheet such as "text: #e0e0e0; background: #1a1a1a; link: #8ab4f8;"`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		inputFile := args[0]
@@ -55,27 +144,239 @@ Or use --bg-color and --text-color for custom colors (hex format: #1a1a1a)`,
 			return fmt.Errorf("input file does not exist: %s", inputFile)
 		}
 
+		// Load saved settings; flags given on the command line take precedence
+		var recipe *Recipe
+		if recipeFile != "" {
+			loaded, err := loadRecipe(cmd, recipeFile)
+			if err != nil {
+				return err
+			}
+			recipe = loaded
+		}
+
 		// Set default output file if not specified
-		if outputFile == "" {
+		if outputFile == "" && compareList != "" {
+			outputFile = strings.TrimSuffix(inputFile, ".pdf") + "_compare.pdf"
+		} else if outputFile == "" {
 			outputFile = strings.TrimSuffix(inputFile, ".pdf") + "_dark.pdf"
 		}
 
+		// Scanned documents get raster mode and scan-tuned settings
+		if detectScanned {
+			if err := applyScannedPreset(cmd, inputFile); err != nil {
+				return err
+			}
+		}
+
 		// If mode not specified, ask user interactively
 		if mode == "" {
 			mode = selectModeInteractively()
 		}
 
+		// Name the light version after the dark output
+		lightOutput := ""
+		if alsoLight {
+			lightOutput = lightOutputPath(outputFile)
+			if lightOutput == inputFile {
+				return fmt.Errorf("--also-light would overwrite the input file %s", inputFile)
+			}
+		}
+		if alsoLight && compareList != "" {
+			return fmt.Errorf("--also-light cannot be combined with --compare-schemes")
+		}
+
 		// Validate mode
-		if mode != "raster" && mode != "direct" {
-			return fmt.Errorf("invalid mode: %s (must be 'raster' or 'direct')", mode)
+		if mode != "raster" && mode != "direct" && mode != "hybrid" {
+			return fmt.Errorf("invalid mode: %s (must be 'raster', 'direct' or 'hybrid')", mode)
+		}
+
+		// Validate output PDF version
+		if pdfVersion != "" {
+			target, err := pdfversion.Parse(pdfVersion)
+			if err != nil {
+				return err
+			}
+			// Both add optional content groups
+			if target < model.V15 && (layers || watermark != "") {
+				return fmt.Errorf("--layers and --watermark add optional content, which needs --pdf-version 1.5 or later")
+			}
+		}
+		switch ifAlreadyDark {
+		case converter.AlreadyDarkWarn, converter.AlreadyDarkSkip, converter.AlreadyDarkProceed:
+		default:
+			return fmt.Errorf("invalid --if-already-dark: %s (must be 'warn', 'skip' or 'proceed')", ifAlreadyDark)
+		}
+		if pdfA && incremental {
+			return fmt.Errorf("--pdfa rewrites the whole output and cannot be combined with --incremental")
+		}
+		if watermark != "" && incremental {
+			return fmt.Errorf("--watermark rewrites the whole output and cannot be combined with --incremental")
+		}
+		if watermarkAlpha <= 0 || watermarkAlpha > 1 {
+			return fmt.Errorf("invalid --watermark-opacity: %g (must be above 0 and at most 1)", watermarkAlpha)
+		}
+
+		// Validate snap cutoffs
+		if snapNearWhite <= 0 || snapNearWhite > 1 || snapNearBlack <= 0 || snapNearBlack > 1 {
+			return fmt.Errorf("snap cutoffs must be between 0 and 1")
+		}
+		if snapNearBlack >= snapNearWhite {
+			return fmt.Errorf("--snap-near-black (%g) must be below --snap-near-white (%g)", snapNearBlack, snapNearWhite)
+		}
+
+		// Validate target contrast and tint strength
+		if targetContrast != 0 && (targetContrast < 1 || targetContrast > colors.MaxContrastRatio) {
+			return fmt.Errorf("invalid target contrast: %g (must be between 1 and %d)", targetContrast, colors.MaxContrastRatio)
+		}
+		if tintStrength < 0 || tintStrength > 1 {
+			return fmt.Errorf("invalid tint strength: %g (must be between 0 and 1)", tintStrength)
+		}
+		if err := intensity.Validate(intensityLevel); err != nil {
+			return err
+		}
+		// The intensity sets the snap cutoffs unless they are given
+		snapWhite, snapBlack := snapNearWhite, snapNearBlack
+		if !cmd.Flags().Changed("snap-near-white") {
+			snapWhite = 0
+		}
+		if !cmd.Flags().Changed("snap-near-black") {
+			snapBlack = 0
+		}
+		// Without --tint-strength the engine keeps its default
+		var strength *float64
+		if cmd.Flags().Changed("tint-strength") {
+			strength = &tintStrength
+		}
+		if colorTol < 0 || colorTol > colormath.DocumentSaturation {
+			return fmt.Errorf("invalid color tolerance: %g (must be between 0 and %g)", colorTol, colormath.DocumentSaturation)
+		}
+
+		// Validate remap matching
+		metric, err := colors.ParseMetric(colorMetric)
+		if err != nil {
+			return fmt.Errorf("invalid --color-metric: %w", err)
+		}
+		if matchTol < 0 {
+			return fmt.Errorf("invalid --match-tolerance: %g (must be positive, 0 for the default)", matchTol)
+		}
+
+		// Validate colorful lightness range
+		if minColorL < 0 || minColorL > 1 || maxColorL < 0 || maxColorL > 1 {
+			return fmt.Errorf("color lightness limits must be between 0 and 1")
+		}
+		if minColorL > 0 && maxColorL > 0 && minColorL >= maxColorL {
+			return fmt.Errorf("--min-color-lightness (%g) must be below --max-color-lightness (%g)", minColorL, maxColorL)
+		}
+
+		// Validate the preserved white threshold
+		if preserveWhite < 0 || preserveWhite >= 1 {
+			return fmt.Errorf("invalid --preserve-white-above: %g (must be between 0 and 1, 0 disables)", preserveWhite)
+		}
+
+		// Validate analysis sampling and the page default colors
+		if samplePages < 0 {
+			return fmt.Errorf("invalid --sample-pages: %d (must be positive, 0 analyzes all pages)", samplePages)
+		}
+		defaultTarget, err := direct.ParseDefaultColorApply(defaultApply)
+		if err != nil {
+			return fmt.Errorf("invalid --default-color-apply: %w", err)
+		}
+
+		// Validate output size limits
+		warnSize, err := parseSize(dpiWarn)
+		if err != nil {
+			return fmt.Errorf("invalid --dpi-warn: %w", err)
+		}
+		maxSize, err := parseSize(maxOutputSize)
+		if err != nil {
+			return fmt.Errorf("invalid --max-output-size: %w", err)
+		}
+
+		// Validate the page selection
+		var nupGrid [2]int
+		if splitNUp != "" {
+			rows, cols, err := nup.Parse(splitNUp)
+			if err != nil {
+				return err
+			}
+			nupGrid = [2]int{rows, cols}
+		}
+		onlyParity, err := parity.Parse(onlyPages)
+		if err != nil {
+			return fmt.Errorf("invalid --only: %w", err)
+		}
+
+		// Validate color space filters
+		onlyList, err := direct.ParseColorSpaces(onlySpaces)
+		if err != nil {
+			return fmt.Errorf("invalid --only-colorspace: %w", err)
+		}
+		skipList, err := direct.ParseColorSpaces(skipSpaces)
+		if err != nil {
+			return fmt.Errorf("invalid --skip-colorspace: %w", err)
+		}
+
+		// Validate protected inks
+		var inks []colors.Color
+		for _, hex := range strings.Split(protectInks, ",") {
+			if hex = strings.TrimSpace(hex); hex == "" {
+				continue
+			}
+			ink, err := colors.NewColorFromHex(hex)
+			if err != nil {
+				return fmt.Errorf("invalid --protect-ink: %w", err)
+			}
+			if ink.R8 == ink.G8 && ink.G8 == ink.B8 {
+				return fmt.Errorf("invalid --protect-ink: %s is a gray and has no hue to protect", hex)
+			}
+			inks = append(inks, ink)
 		}
 
-		// Determine color scheme
-		scheme, err := resolveColorScheme()
+		// Validate proof sampling
+		sampling := sample.Options{Rate: sampleRate, Strategy: sampleStrategy, Seed: sampleSeed}
+		if err := sampling.Validate(); err != nil {
+			return err
+		}
+
+		// Determine color scheme and remaps, from the recipe unless scheme flags are given
+		var scheme colors.Scheme
+		var remaps []colors.Remap
+		var compared []colors.Scheme
+		if compareList != "" {
+			compared, err = parseCompareSchemes(compareList)
+			if err == nil {
+				scheme = compared[0]
+			}
+		} else if recipe != nil && !anyChanged(cmd, schemeFlags) {
+			scheme, remaps = recipe.schemeAndRemaps()
+		} else {
+			scheme, remaps, err = resolveColors()
+		}
 		if err != nil {
 			return err
 		}
 
+		// Load ICC profiles for color-managed output
+		var iccProfiles []icc.Profile
+		if tagICC || iccProfile != "" {
+			iccProfiles = icc.Defaults()
+			if iccProfile != "" {
+				profile, err := icc.Load(iccProfile)
+				if err != nil {
+					return err
+				}
+				iccProfiles = icc.With(iccProfiles, profile)
+			}
+		}
+
+		// Load the background texture
+		var texture []byte
+		if bgImage != "" {
+			if texture, err = direct.LoadBackgroundImage(bgImage); err != nil {
+				return err
+			}
+		}
+
 		// Create converter options
 		opts := converter.Options{
 			InputFile:      inputFile,
@@ -84,20 +385,204 @@ Or use --bg-color and --text-color for custom colors (hex format: #1a1a1a)`,
 			DPI:            dpi,
 			PreserveImages: preserveImages,
 			ColorScheme:    scheme,
+			PDFVersion:     pdfVersion,
+			KeepStructure:  keepStructure,
+			SinglePass:     singlePass,
+			Remaps:         remaps,
+			ColorMetric:    metric,
+			MetricTol:      matchTol,
+			Sample:         sampling,
+			ViewerHints:    !noViewerHints,
+			SnapNearWhite:  snapWhite,
+			SnapNearBlack:  snapBlack,
+			AutoOrient:     autoOrient,
+			CleanEdges:     cleanEdges,
+			ProtectInks:    inks,
+			Gradient:       gradient,
+			Texture:        texture,
+			RespectDarkBg:  respectDarkBg,
+			BgMargin:       bgMargin,
+			TintStrength:   strength,
+			Intensity:      &intensityLevel,
+			ColorTolerance: colorTol,
+			MinColorL:      minColorL,
+			MaxColorL:      maxColorL,
+			PreserveWhite:  preserveWhite,
+			StripeAware:    stripeAware,
+			MapPrimary:     mapPrimary,
+			Readability:    readability,
+			SamplePages:    samplePages,
+			DefaultApply:   defaultTarget,
+			NormalizeRot:   normalizeRot,
+			TextRegions:    textRegions,
+			TextPages:      textPages,
+			CheckPoppler:   checkPoppler,
+			CMYK:           cmyk,
+			Flatten:        flatten,
+			WarnSize:       warnSize,
+			MaxSize:        maxSize,
+			ConfirmSize:    confirmSize(),
+			MaxRenderTime:  maxRenderTime,
+			CacheDir:       cacheDir,
+			ICCProfiles:    iccProfiles,
+			Layers:         layers,
+			Sanitize:       sanitizeOut,
+			Incremental:    incremental,
+			Watermark:      watermark,
+			WatermarkAlpha: watermarkAlpha,
+			CompatOps:      compatOps,
+			NoRecompress:   noRecompress,
+			Strict:         strict,
+			PDFA:           pdfA,
+			IfAlreadyDark:  ifAlreadyDark,
+			ReportDir:      reportDir,
+			HTMLReport:     htmlReport,
+			LightOutput:    lightOutput,
+			Compare:        compared,
+			RTL:            rtl,
+			OnlySpaces:     onlyList,
+			SkipSpaces:     skipList,
+			OnlyPages:      onlyParity,
+			SplitNUp:       nupGrid,
+			Progress:       &etaProgress{},
 		}
 
 		// Run conversion
-		fmt.Printf("Converting %s to dark mode using %s mode...\n", inputFile, mode)
-		fmt.Printf("Color scheme: %s (bg: %s, text: %s)\n", scheme.Name, scheme.Background.Hex(), scheme.Text.Hex())
-		if err := converter.Convert(opts); err != nil {
+		fmt.Println(bold(fmt.Sprintf("Converting %s to dark mode using %s mode...", inputFile, mode)))
+		shown := compared
+		if len(shown) == 0 {
+			shown = []colors.Scheme{scheme}
+		}
+		for _, s := range shown {
+			fmt.Printf("Color scheme: %s (bg: %s, text: %s)\n", s.Name, s.Background.Hex(), s.Text.Hex())
+		}
+		if err := converter.Convert(opts); errors.Is(err, converter.ErrAlreadyDark) {
+			fmt.Println(warning(fmt.Sprintf("Skipped: %v", err)))
+			return nil
+		} else if err != nil {
 			return fmt.Errorf("conversion failed: %w", err)
 		}
 
-		fmt.Printf("Successfully created: %s\n", outputFile)
+		fmt.Println(success(fmt.Sprintf("Successfully created: %s", outputFile)))
+		if lightOutput != "" {
+			fmt.Println(success(fmt.Sprintf("Successfully created: %s", lightOutput)))
+		}
+
+		if saveRecipeFile != "" {
+			if err := saveRecipe(saveRecipeFile, newRecipe(cmd, scheme, remaps)); err != nil {
+				return err
+			}
+			fmt.Printf("Saved recipe: %s\n", saveRecipeFile)
+		}
+
+		// A missing thumbnail does not undo a successful conversion
+		if galleryDir != "" {
+			index, err := gallery.Add(galleryDir, inputFile, outputFile, nil)
+			if err != nil {
+				fmt.Println(warning(fmt.Sprintf("Could not add to gallery: %v", err)))
+			} else {
+				fmt.Printf("Updated gallery: %s\n", index)
+			}
+		}
 		return nil
 	},
 }
 
+// progressInterval is how often a long pass over the pages prints the time left
+const progressInterval = 5 * time.Second
+
+// etaProgress prints the pages done and the estimated time left, the average time per
+// page so far times the pages remaining, every progressInterval of a pass
+type etaProgress struct {
+	shown time.Duration // Elapsed time of the pass when progress was last printed
+}
+
+// OnPage implements report.Progress
+func (p *etaProgress) OnPage(page, total int, elapsed time.Duration) {
+	if page == 1 || elapsed < p.shown {
+		p.shown = 0 // A new pass
+	}
+	if page >= total || elapsed-p.shown < progressInterval {
+		return
+	}
+	p.shown = elapsed
+	left := elapsed / time.Duration(page) * time.Duration(total-page)
+	fmt.Printf("        %d/%d pages, about %s left\n", page, total, left.Round(time.Second))
+}
+
+// lightOutputPath names the light version after the dark output: doc_dark.pdf becomes
+// doc_light.pdf, and other names get _light before the extension
+func lightOutputPath(output string) string {
+	if base, found := strings.CutSuffix(output, "_dark.pdf"); found {
+		return base + "_light.pdf"
+	}
+	return strings.TrimSuffix(output, ".pdf") + "_light.pdf"
+}
+
+// parseCompareSchemes parses a comma-separated list of at least two schemes, each a
+// name or a background/text pair
+func parseCompareSchemes(list string) ([]colors.Scheme, error) {
+	var schemes []colors.Scheme
+	for _, spec := range strings.Split(list, ",") {
+		if spec = strings.TrimSpace(spec); spec == "" {
+			continue
+		}
+		scheme, err := colors.ParseSchemeSpec(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --compare-schemes: %w", err)
+		}
+		schemes = append(schemes, scheme)
+	}
+	if len(schemes) < 2 {
+		return nil, fmt.Errorf("invalid --compare-schemes: %q (needs at least two schemes)", list)
+	}
+	return schemes, nil
+}
+
+// confirmSize returns the prompt asked before a large raster conversion, or nil to
+// proceed without asking under --yes or when stdin is not a terminal
+func confirmSize() func(int64) bool {
+	if assumeYes || !isTerminal(os.Stdin) {
+		return nil
+	}
+	return func(estimate int64) bool {
+		fmt.Print(warning(fmt.Sprintf("Output may be about %s. Continue? [y/N]: ", raster.FormatSize(estimate))))
+		reader := bufio.NewReader(os.Stdin)
+		input, _ := reader.ReadString('\n')
+		input = strings.TrimSpace(strings.ToLower(input))
+		return input == "y" || input == "yes"
+	}
+}
+
+// parseSize parses a byte size such as "500MB", "2G" or "1048576". Empty means no limit.
+func parseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "" {
+		return 0, nil
+	}
+
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{
+		{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"TB", 1 << 40},
+		{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"T", 1 << 40}, {"B", 1},
+	} {
+		if strings.HasSuffix(s, unit.suffix) {
+			multiplier = unit.size
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			break
+		}
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("not a size: %q (e.g. 500MB, 2GB)", s)
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
 func selectModeInteractively() string {
 	fmt.Println("\nSelect conversion mode:")
 	fmt.Println("  [1] raster  - Converts pages to images, then inverts")
@@ -106,7 +591,10 @@ func selectModeInteractively() string {
 	fmt.Println("  [2] direct  - Modifies PDF color operators directly")
 	fmt.Println("                + Preserves vectors, text, small file size")
 	fmt.Println("                - May not work with complex PDFs")
-	fmt.Print("\nEnter choice (1 or 2): ")
+	fmt.Println("  [3] hybrid  - Raster backgrounds with direct-mode text on top")
+	fmt.Println("                + Selectable, crisp text over complex graphics")
+	fmt.Println("                - Larger file size, graphics become images")
+	fmt.Print("\nEnter choice (1, 2 or 3): ")
 
 	reader := bufio.NewReader(os.Stdin)
 	input, _ := reader.ReadString('\n')
@@ -117,14 +605,66 @@ func selectModeInteractively() string {
 		return "raster"
 	case "2", "direct":
 		return "direct"
+	case "3", "hybrid":
+		return "hybrid"
 	default:
-		fmt.Println("Invalid choice, defaulting to 'raster' mode")
+		fmt.Println(warning("Invalid choice, defaulting to 'raster' mode"))
 		return "raster"
 	}
 }
 
+// resolveColors determines the color scheme and remaps from the scheme flags, the
+// stylesheet and the target contrast
+func resolveColors() (colors.Scheme, []colors.Remap, error) {
+	scheme, err := resolveColorScheme()
+	if err != nil {
+		return colors.Scheme{}, nil, err
+	}
+
+	// Apply stylesheet overrides and remaps
+	var remaps []colors.Remap
+	if styleFile != "" {
+		sheet, err := colors.LoadStylesheet(styleFile)
+		if err != nil {
+			return colors.Scheme{}, nil, err
+		}
+		scheme = sheet.Apply(scheme)
+		remaps = sheet.Remaps
+	}
+
+	// Darken or lighten the background
+	if darkLevel != "" {
+		level, err := colors.ParseDarkLevel(darkLevel)
+		if err != nil {
+			return colors.Scheme{}, nil, err
+		}
+		if scheme.IsLight() {
+			return colors.Scheme{}, nil, fmt.Errorf("--dark-level needs a dark scheme, %s has a light background", scheme.Name)
+		}
+		scheme = scheme.WithDarkLevel(level)
+	}
+
+	// Derive the text color from the background
+	if targetContrast > 0 {
+		text, err := colors.TextForContrast(scheme.Background, targetContrast)
+		if err != nil {
+			return colors.Scheme{}, nil, err
+		}
+		scheme.Text = text
+		fmt.Printf("Text color %s reaches %.2f:1 contrast (target %g:1)\n",
+			text.Hex(), colors.ContrastRatio(scheme.Background, text), targetContrast)
+	}
+
+	return scheme, remaps, nil
+}
+
 // resolveColorScheme determines the color scheme based on flags
 func resolveColorScheme() (colors.Scheme, error) {
+	// A reference PDF takes precedence
+	if schemeFromPDF != "" {
+		return raster.SchemeFromPDF(schemeFromPDF, nil)
+	}
+
 	// Custom colors take precedence
 	if bgColor != "" || textColor != "" {
 		// If only one is specified, use defaults for the other
@@ -139,13 +679,16 @@ func resolveColorScheme() (colors.Scheme, error) {
 		return colors.NewCustomScheme(bg, text)
 	}
 
-	// If no scheme specified, prompt interactively
+	// If no scheme specified, prompt interactively (a stylesheet starts from the default)
+	if colorScheme == "" && styleFile != "" {
+		return colors.DefaultScheme(), nil
+	}
 	if colorScheme == "" {
 		return selectColorSchemeInteractively(), nil
 	}
 
-	// Try to get the named scheme
-	return colors.GetScheme(colorScheme)
+	// Named scheme, hex pair or bg:/text: spec
+	return colors.ParseSchemeSpec(colorScheme)
 }
 
 func selectColorSchemeInteractively() colors.Scheme {
@@ -182,12 +725,12 @@ func selectColorSchemeInteractively() colors.Scheme {
 		}
 	}
 
-	// Try to parse as scheme name
-	if scheme, err := colors.GetScheme(input); err == nil {
+	// Try to parse as scheme name or spec
+	if scheme, err := colors.ParseSchemeSpec(input); err == nil {
 		return scheme
 	}
 
-	fmt.Println("Invalid choice, using default 'dark' scheme")
+	fmt.Println(warning("Invalid choice, using default 'dark' scheme"))
 	return colors.DefaultScheme()
 }
 
@@ -204,7 +747,8 @@ func promptCustomColors() colors.Scheme {
 
 	scheme, err := colors.NewCustomScheme(bgInput, textInput)
 	if err != nil {
-		fmt.Printf("Invalid colors: %v\nUsing default 'dark' scheme\n", err)
+		fmt.Println(failure(fmt.Sprintf("Invalid colors: %v", err)))
+		fmt.Println(warning("Using default 'dark' scheme"))
 		return colors.DefaultScheme()
 	}
 
@@ -223,29 +767,136 @@ var versionCmd = &cobra.Command{
 
 func init() {
 	rootCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output PDF file (default: <input>_dark.pdf)")
-	rootCmd.Flags().StringVarP(&mode, "mode", "m", "", "Conversion mode: 'raster' or 'direct'")
+	rootCmd.Flags().StringVar(&compareList, "compare-schemes", "", fmt.Sprintf("Comma-separated schemes (e.g. nord,dracula,gruvbox) to show the first %d pages under side by side in one comparison PDF", converter.ComparePages))
+	rootCmd.Flags().BoolVar(&rtl, "rtl", false, "Right-to-left document (Arabic, Hebrew): place added notices and labels on the right and set them right to left")
+	rootCmd.Flags().BoolVar(&alsoLight, "also-light", false, "Also write a light version in the reading-light scheme, named after the output with _light instead of _dark")
+	rootCmd.Flags().StringVarP(&mode, "mode", "m", "", "Conversion mode: 'raster', 'direct' or 'hybrid'")
 	rootCmd.Flags().IntVar(&dpi, "dpi", 150, "DPI for raster mode (default: 150)")
+	rootCmd.Flags().StringVar(&dpiWarn, "dpi-warn", "500MB", "Raster: ask before continuing when the estimated output is larger than this (0 disables)")
+	rootCmd.Flags().StringVar(&maxOutputSize, "max-output-size", "", "Raster: refuse to convert when the estimated output is larger than this, e.g. 2GB")
+	rootCmd.Flags().BoolVar(&checkPoppler, "verify-poppler-version", true, "Raster and hybrid: print the poppler version and warn if it is older than "+raster.MinPopplerVersion+" (fails with --strict)")
+	rootCmd.Flags().DurationVar(&maxRenderTime, "max-render-time", 0, "Raster: render page by page and skip a page that takes longer than this, e.g. 2m (0 renders in one run without a limit)")
+	rootCmd.Flags().StringVar(&cacheDir, "cache-dir", "", "Raster: keep rendered pages in this directory so converting the same input again (e.g. with another scheme) skips rendering")
+	rootCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Do not ask for confirmation (also implied when stdin is not a terminal)")
+	rootCmd.Flags().Float64Var(&snapNearWhite, "snap-near-white", raster.DefaultSnapNearWhite, "Raster: lightness above which gray pixels snap to the background (lower removes halos)")
+	rootCmd.Flags().Float64Var(&snapNearBlack, "snap-near-black", raster.DefaultSnapNearBlack, "Raster: lightness below which gray pixels snap to the text color")
+	rootCmd.Flags().BoolVar(&cleanEdges, "clean-edges", false, "Raster: snap light anti-aliased pixels around text to the background to remove gray halos")
+	rootCmd.Flags().BoolVar(&gradient, "gradient-background", false, "Fill pages with a subtle top-to-bottom gradient around the background color instead of a flat color")
+	rootCmd.Flags().StringVar(&bgImage, "background-image", "", "Direct: draw this PNG or JPEG texture behind the content, tiled if smaller than the page, else scaled to cover it")
+	rootCmd.Flags().Float64Var(&bgMargin, "background-margin", 0, "Direct: extend the background this many points past the page to bleed, or inset it by a negative value to leave a paper border")
+	rootCmd.Flags().BoolVar(&respectDarkBg, "respect-existing-dark-background", false, "Direct: add no background to pages that already start by filling the page with a dark color (slides, dark designs)")
+	rootCmd.Flags().StringVar(&protectInks, "protect-ink", "", "Raster: keep colors in the hue of these inks as they are, e.g. #0000ff for blue ink signatures (comma-separated)")
+	rootCmd.Flags().BoolVar(&autoOrient, "auto-orient", false, "Raster: detect sideways or upside-down pages and rotate them upright (best effort)")
+	rootCmd.Flags().BoolVar(&textPages, "text-only-pages", false, "Hybrid: convert pages with only text and simple vector graphics directly and render the other pages whole")
+	rootCmd.Flags().BoolVar(&detectScanned, "detect-scanned", false, "If most pages are one full-page image without visible text, convert in raster mode with scan-tuned snapping, clean edges and text-regions-only")
+	rootCmd.Flags().BoolVar(&textRegions, "text-regions-only", false, "Raster: invert only detected text regions, keeping photos and logos in their colors")
+	rootCmd.Flags().BoolVar(&flatten, "flatten-transparency", false, "Raster: flatten transparency with Ghostscript before rendering, for the same output with any poppler version")
+	rootCmd.Flags().BoolVar(&cmyk, "cmyk", false, "Raster: render and invert in CMYK with Ghostscript and embed CMYK pages (print proofing)")
+	rootCmd.Flags().StringVar(&darkLevel, "dark-level", "", "How dark the background is, keeping its hue: 0 (black) to 100 (dark gray), or black, dark or dim")
+	rootCmd.Flags().Float64Var(&targetContrast, "target-contrast", 0, "Pick the text color that reaches this contrast ratio against the background, e.g. 7 (WCAG AAA)")
+	rootCmd.Flags().IntVar(&intensityLevel, "intensity", intensity.Default, "How strongly colors are adapted, from 0 (gentle) to 100 (punchy colors, hard contrast); sets the color lightness range, saturation boost and snap cutoffs not given")
+	rootCmd.Flags().Float64Var(&tintStrength, "tint-strength", 1, "Direct: how much of a tinted scheme's tint converted grays keep (0 neutral, 1 full)")
+	rootCmd.Flags().Float64Var(&colorTol, "color-tolerance", 0, "Blend gray and colorful handling for colors within this saturation of the 0.15 boundary, e.g. 0.03, so near-identical colors map alike")
+	rootCmd.Flags().Float64Var(&minColorL, "min-color-lightness", 0, "Lightness floor for colorful colors (default: 0.55 direct, 0.3 raster)")
+	rootCmd.Flags().Float64Var(&maxColorL, "max-color-lightness", 0, "Lightness above which colorful colors are toned down (default: 0.85 direct, 0.7 raster)")
+	rootCmd.Flags().BoolVar(&mapPrimary, "map-primary-text", false, "Direct: find the document's most common dark text color and map it exactly to the scheme text, shifting other grays in proportion")
+	rootCmd.Flags().BoolVar(&readability, "check-readability", false, "Direct and hybrid: warn about pages whose text is mostly very small or in a thin font, which may be hard to read light on dark")
+	rootCmd.Flags().StringVar(&defaultApply, "default-color-apply", direct.DefaultColorBoth, "Direct: set the page's default fill (uncolored text), stroke (uncolored lines and borders) or both colors to the text color")
+	rootCmd.Flags().IntVar(&samplePages, "sample-pages", 0, "Analyze only this many pages, the first, the last and evenly spread between, e.g. for --map-primary-text (0 analyzes all; every page is still converted)")
+	rootCmd.Flags().BoolVar(&stripeAware, "stripe-aware", false, "Direct: map light gray fills such as zebra-striped table rows to a stripe slightly apart from the background")
+	rootCmd.Flags().Float64Var(&preserveWhite, "preserve-white-above", 0, "Keep document colors lighter than this lightness (e.g. 0.95) white instead of darkening them (raster: enclosed areas only)")
+	rootCmd.Flags().BoolVar(&normalizeRot, "normalize-rotation", false, "Direct: bake /Rotate into page content so pages are upright everywhere (raster output already is)")
+	rootCmd.Flags().BoolVar(&tagICC, "tag-icc", false, "Direct: tag default gray and RGB with built-in sRGB-based ICC profiles (color-managed output)")
+	rootCmd.Flags().StringVar(&iccProfile, "icc-profile", "", "Direct: gray or RGB ICC profile to tag instead of the built-in one (implies --tag-icc)")
+	rootCmd.Flags().BoolVar(&layers, "layers", false, "Direct: keep the original as a second layer so viewers can toggle between original and dark")
+	rootCmd.Flags().BoolVar(&sanitizeOut, "sanitize", false, "Direct: strip JavaScript, /OpenAction and /AA actions from the output")
+	rootCmd.Flags().StringVar(&ifAlreadyDark, "if-already-dark", converter.AlreadyDarkWarn, "What to do with input an earlier conversion marked: warn, skip or proceed")
+	rootCmd.Flags().BoolVar(&pdfA, "pdfa", false, "Rewrite the output as PDF/A-2b for archiving and report anything that keeps it from conforming")
+	rootCmd.Flags().BoolVar(&incremental, "incremental", false, "Direct: append the changes to the original file as an incremental update instead of rewriting it")
+	rootCmd.Flags().StringVar(&watermark, "watermark", "", "Stamp this text diagonally across every output page, e.g. \"DARK MODE COPY\"")
+	rootCmd.Flags().Float64Var(&watermarkAlpha, "watermark-opacity", converter.DefaultWatermarkOpacity, "Opacity of the --watermark text, above 0 up to 1")
+	rootCmd.Flags().BoolVar(&noRecompress, "no-recompress", false, "Direct: re-encode changed streams with their original filters and keep the source's use of object streams")
+	rootCmd.Flags().BoolVar(&strict, "strict", false, "Fail with no output if the conversion records any warning, such as undecodable streams or unhandled color spaces")
+	rootCmd.Flags().BoolVar(&compatOps, "compat-operators", false, "Direct: write transformed colors with cs/sc in an explicit device color space instead of rg, g and k")
+	rootCmd.Flags().StringVar(&onlySpaces, "only-colorspace", "", "Direct: transform only operators in these color spaces, e.g. rgb,cmyk (gray, rgb, cmyk)")
+	rootCmd.Flags().StringVar(&skipSpaces, "skip-colorspace", "", "Direct: leave operators in these color spaces unchanged, e.g. gray")
+	rootCmd.Flags().StringVar(&reportDir, "report-dir", "", "Write <output>.report.json with the run's outcome and warnings into this directory")
+	rootCmd.Flags().StringVar(&htmlReport, "report", "", "Write a self-contained HTML summary (scheme, per-page counts, before/after thumbnails, warnings) to this file")
+	rootCmd.Flags().StringVar(&saveRecipeFile, "save-recipe", "", "Save the effective scheme, remaps and output settings to this JSON recipe after converting")
+	rootCmd.Flags().StringVar(&galleryDir, "gallery", "", "Add a thumbnail of the output's first page to an index.html gallery in this directory")
+	rootCmd.Flags().StringVar(&recipeFile, "recipe", "", "Load settings from a recipe saved with --save-recipe (flags given explicitly take precedence)")
 	rootCmd.Flags().BoolVar(&preserveImages, "preserve-images", true, "Preserve images in direct mode (default: true)")
+	rootCmd.Flags().BoolVar(&keepStructure, "keep-structure", false, "Copy the tagged structure tree onto raster output (accessibility)")
+	rootCmd.Flags().BoolVar(&singlePass, "single-pass", false, "Free decoded streams after each page in direct mode (lower memory for large files)")
+	rootCmd.Flags().Float64Var(&sampleRate, "sample-rate", 0, "Convert only this fraction of pages (0-1) into a proof PDF")
+	rootCmd.Flags().StringVar(&sampleStrategy, "sample-strategy", sample.StrategyRandom, "Proof page selection: 'random' or 'first'")
+	rootCmd.Flags().Int64Var(&sampleSeed, "sample-seed", 1, "Seed for random proof sampling (same seed, same pages)")
+	rootCmd.Flags().StringVar(&splitNUp, "split-nup", "", "Cut each page into ROWSxCOLS pages before converting, for imposed n-up documents, e.g. 2x2")
+	rootCmd.Flags().StringVar(&onlyPages, "only", "", "Convert only the 'odd' or 'even' pages, leaving the others as they are")
+	rootCmd.Flags().BoolVar(&noViewerHints, "no-viewer-hints", false, "Do not write the dark theme XMP hint or remove script /OpenAction")
+	rootCmd.Flags().StringVar(&pdfVersion, "pdf-version", "", "Output PDF version, e.g. 1.5 (default: keep pdfcpu default)")
 
 	// Color options
-	rootCmd.Flags().StringVarP(&colorScheme, "scheme", "s", "", "Color scheme: dark, sepia, nord, solarized, gruvbox, dracula, monokai")
-	rootCmd.Flags().StringVar(&bgColor, "bg-color", "", "Custom background color (hex, e.g., #1a1a1a)")
-	rootCmd.Flags().StringVar(&textColor, "text-color", "", "Custom text color (hex, e.g., #e0e0e0)")
+	rootCmd.Flags().StringVarP(&colorScheme, "scheme", "s", "", "Color scheme: dark, sepia, nord, solarized, gruvbox, dracula, monokai, reading-light, or '#bg/#text'")
+	rootCmd.Flags().StringVar(&bgColor, "bg-color", "", "Custom background color (hex or CSS name, e.g., #1a1a1a or midnightblue)")
+	rootCmd.Flags().StringVar(&textColor, "text-color", "", "Custom text color (hex or CSS name, e.g., #e0e0e0 or ivory)")
+	rootCmd.Flags().StringVar(&schemeFromPDF, "scheme-from-pdf", "", "Take the background and text colors from the first page of a reference PDF (needs poppler)")
+	rootCmd.Flags().StringVar(&styleFile, "style", "", "CSS-like stylesheet with text, background, link, accent and #rrggbb remaps")
+	rootCmd.Flags().StringVar(&colorMetric, "color-metric", colors.MetricRGB, "How colors are matched to remap sources: rgb (per channel) or cielab (perceptual ΔE)")
+	rootCmd.Flags().Float64Var(&matchTol, "match-tolerance", 0, "Distance within which a color matches a remap source, in 8-bit steps for rgb or ΔE for cielab (default: rgb 2 direct, 8 raster; cielab 2.3 direct, 5 raster)")
+
+	// Output options
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "Disable colored terminal output (also honors NO_COLOR)")
 
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(schemesCmd)
+	rootCmd.AddCommand(selftestCmd)
+	rootCmd.AddCommand(transformStreamCmd)
+
+	schemesCmd.Flags().BoolVar(&schemesAsJSON, "json", false, "Print the schemes as a JSON array of name, background and text colors")
+
+	// transform-stream takes the scheme options of the main command
+	transformStreamCmd.Flags().StringVarP(&colorScheme, "scheme", "s", "", "Color scheme: dark, sepia, nord, solarized, gruvbox, dracula, monokai, reading-light, or '#bg/#text'")
+	transformStreamCmd.Flags().StringVar(&bgColor, "bg-color", "", "Custom background color (hex or CSS name)")
+	transformStreamCmd.Flags().StringVar(&textColor, "text-color", "", "Custom text color (hex or CSS name)")
+	transformStreamCmd.Flags().StringVar(&styleFile, "style", "", "CSS-like stylesheet with text, background, link, accent and #rrggbb remaps")
+	transformStreamCmd.Flags().BoolVar(&compatOps, "compat-operators", false, "Write transformed colors with cs/sc in an explicit device color space instead of rg, g and k")
+}
+
+// schemesAsJSON selects the machine-readable schemes listing
+var schemesAsJSON bool
+
+// schemeEntry is a scheme in the JSON schemes listing
+type schemeEntry struct {
+	Name       string       `json:"name"`
+	Background colors.Color `json:"background"`
+	Text       colors.Color `json:"text"`
 }
 
 var schemesCmd = &cobra.Command{
 	Use:   "schemes",
 	Short: "List available color schemes",
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("Available color schemes:")
-		fmt.Println()
-
+	RunE: func(cmd *cobra.Command, args []string) error {
 		schemeNames := colors.ListSchemes()
 		sort.Strings(schemeNames)
 
+		if schemesAsJSON {
+			entries := make([]schemeEntry, 0, len(schemeNames))
+			for _, name := range schemeNames {
+				scheme := colors.AvailableSchemes[name]
+				entries = append(entries, schemeEntry{Name: name, Background: scheme.Background, Text: scheme.Text})
+			}
+			data, err := json.MarshalIndent(entries, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+
+		fmt.Println("Available color schemes:")
+		fmt.Println()
+
 		for _, name := range schemeNames {
 			scheme := colors.AvailableSchemes[name]
 			fmt.Printf("  %-10s  Background: %s  Text: %s\n", name, scheme.Background.Hex(), scheme.Text.Hex())
@@ -254,7 +905,30 @@ var schemesCmd = &cobra.Command{
 		fmt.Println()
 		fmt.Println("Usage:")
 		fmt.Println("  pdfdarkmode --scheme nord input.pdf")
+		fmt.Println("  pdfdarkmode --scheme '#282a36/#f8f8f2' input.pdf")
 		fmt.Println("  pdfdarkmode --bg-color '#282a36' --text-color '#f8f8f2' input.pdf")
+		return nil
+	},
+}
+
+var selftestCmd = &cobra.Command{
+	Use:    "selftest",
+	Short:  "Check the color conversions the direct and raster engines share",
+	Hidden: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		failed := 0
+		for _, check := range selftest.Run() {
+			status := "ok  "
+			if !check.Passed() {
+				status = "FAIL"
+				failed++
+			}
+			fmt.Printf("  %s  %-52s max error %.3g (tolerance %.3g) at %s\n", status, check.Name, check.MaxError, check.Tolerance, check.Worst)
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d color conversion check(s) failed", failed)
+		}
+		return nil
 	},
 }
 
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"pdfdarkmode/converter/direct"
+
+	"github.com/spf13/cobra"
+)
+
+// scannedPreset holds the settings --detect-scanned gives a scanned document: raster
+// mode, early snapping that removes the paper tint and halos around text, and inversion
+// limited to text regions so photos keep their colors
+var scannedPreset = map[string]string{
+	"mode":              "raster",
+	"snap-near-white":   "0.8",
+	"snap-near-black":   "0.25",
+	"clean-edges":       "true",
+	"text-regions-only": "true",
+}
+
+// applyScannedPreset checks whether inputPath is a scanned document and, if it is, sets
+// the scanned preset on cmd, except for flags given on the command line or by a recipe
+func applyScannedPreset(cmd *cobra.Command, inputPath string) error {
+	ctx, err := direct.ReadContext(inputPath)
+	if err != nil {
+		return fmt.Errorf("--detect-scanned: %w", err)
+	}
+	scan := direct.DetectScanned(ctx, max(samplePages, 0))
+	why := fmt.Sprintf("%d of %d analyzed page(s) are one full-page image without visible text", scan.Scanned, scan.Analyzed)
+	if !scan.IsScanned() {
+		fmt.Printf("Not a scanned document: %s\n", why)
+		return nil
+	}
+	fmt.Printf("Detected a scanned document: %s\n", why)
+
+	names := make([]string, 0, len(scannedPreset))
+	for name := range scannedPreset {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var applied []string
+	for _, name := range names {
+		if cmd.Flags().Changed(name) {
+			continue
+		}
+		if err := cmd.Flags().Set(name, scannedPreset[name]); err != nil {
+			return err
+		}
+		if scannedPreset[name] == "true" {
+			applied = append(applied, "--"+name)
+		} else {
+			applied = append(applied, fmt.Sprintf("--%s %s", name, scannedPreset[name]))
+		}
+	}
+	if len(applied) > 0 {
+		fmt.Printf("  Using %s\n", strings.Join(applied, ", "))
+	}
+	return nil
+}
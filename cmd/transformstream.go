@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"pdfdarkmode/converter/colors"
+	"pdfdarkmode/converter/direct"
+
+	"github.com/spf13/cobra"
+)
+
+var transformStreamCmd = &cobra.Command{
+	Use:   "transform-stream [snippet...]",
+	Short: "Transform the colors of raw content stream snippets with the direct engine",
+	Long: `Reads raw PDF content stream snippets from the given files, or from stdin without
+any, transforms their color operators as direct mode does for a page without color
+space resources, and prints the result. The number of operators changed goes to
+stderr. Takes the scheme options of the main command.`,
+	Hidden: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Stdin holds the snippet, so the default scheme is used instead of asking
+		if colorScheme == "" {
+			colorScheme = colors.DefaultScheme().Name
+		}
+		scheme, remaps, err := resolveColors()
+		if err != nil {
+			return err
+		}
+		engine := direct.NewEngine(true, scheme)
+		engine.SetRemaps(remaps)
+		engine.SetCompatOperators(compatOps)
+
+		if len(args) == 0 {
+			content, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return fmt.Errorf("failed to read stdin: %w", err)
+			}
+			return transformSnippet(engine, "stdin", content)
+		}
+		for i, path := range args {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if len(args) > 1 {
+				if i > 0 {
+					fmt.Println()
+				}
+				fmt.Printf("%% %s\n", path)
+			}
+			if err := transformSnippet(engine, path, content); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// transformSnippet prints content transformed by engine and reports the count to stderr
+func transformSnippet(engine *direct.Engine, name string, content []byte) error {
+	result, count := engine.TransformContent(string(content))
+	if _, err := io.WriteString(os.Stdout, result); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%s: transformed %d color operation(s)\n", name, count)
+	return nil
+}
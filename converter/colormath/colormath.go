@@ -0,0 +1,181 @@
+// Package colormath holds the RGB, HSL, CMYK and CIELAB conversions shared by the
+// direct and raster engines. All values are in the range 0-1, except CIELAB's.
+package colormath
+
+import "math"
+
+// DocumentSaturation is the saturation below which a color is a document color (text,
+// paper, rules) that gets inverted, rather than a colorful one that keeps its hue
+const DocumentSaturation = 0.15
+
+// NeutralSpread is the largest difference between the channels of a scheme color that
+// counts as neutral gray, telling tinted schemes from plain ones. It measures channel
+// spread rather than saturation because saturation is unstable near black and white:
+// #050000 is fully saturated yet reads as black, and a scheme color never sits near the
+// document color boundary, so the two thresholds do not interact.
+const NeutralSpread = 0.02
+
+// IsNeutral reports whether the channels of an RGB color are within NeutralSpread
+func IsNeutral(r, g, b float64) bool {
+	return math.Abs(r-g) < NeutralSpread && math.Abs(g-b) < NeutralSpread && math.Abs(r-b) < NeutralSpread
+}
+
+// DocumentWeight returns how much a color with saturation s is treated as a document
+// color: 1 below DocumentSaturation-tolerance, 0 above DocumentSaturation+tolerance,
+// and linear in between, so colors near the boundary blend both treatments instead of
+// flipping between them. With tolerance 0 the boundary is a hard threshold.
+func DocumentWeight(s, tolerance float64) float64 {
+	if tolerance <= 0 {
+		if s < DocumentSaturation {
+			return 1
+		}
+		return 0
+	}
+	return math.Max(0, math.Min(1, (DocumentSaturation+tolerance-s)/(2*tolerance)))
+}
+
+// Lightness returns the HSL lightness of an RGB color
+func Lightness(r, g, b float64) float64 {
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	return (max + min) / 2
+}
+
+// Saturation returns the HSL saturation of an RGB color
+func Saturation(r, g, b float64) float64 {
+	_, s, _ := RGBToHSL(r, g, b)
+	return s
+}
+
+// RGBToHSL converts RGB to HSL
+func RGBToHSL(r, g, b float64) (h, s, l float64) {
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+
+	l = (max + min) / 2
+
+	if max == min {
+		return 0, 0, l
+	}
+
+	d := max - min
+	if l > 0.5 {
+		s = d / (2 - max - min)
+	} else {
+		s = d / (max + min)
+	}
+
+	switch max {
+	case r:
+		h = (g - b) / d
+		if g < b {
+			h += 6
+		}
+	case g:
+		h = (b-r)/d + 2
+	case b:
+		h = (r-g)/d + 4
+	}
+
+	h /= 6
+	return
+}
+
+// HSLToRGB converts HSL to RGB
+func HSLToRGB(h, s, l float64) (r, g, b float64) {
+	if s == 0 {
+		return l, l, l
+	}
+
+	var q float64
+	if l < 0.5 {
+		q = l * (1 + s)
+	} else {
+		q = l + s - l*s
+	}
+	p := 2*l - q
+
+	r = hueToRGB(p, q, h+1.0/3.0)
+	g = hueToRGB(p, q, h)
+	b = hueToRGB(p, q, h-1.0/3.0)
+
+	return
+}
+
+func hueToRGB(p, q, t float64) float64 {
+	if t < 0 {
+		t += 1
+	}
+	if t > 1 {
+		t -= 1
+	}
+	if t < 1.0/6.0 {
+		return p + (q-p)*6*t
+	}
+	if t < 1.0/2.0 {
+		return q
+	}
+	if t < 2.0/3.0 {
+		return p + (q-p)*(2.0/3.0-t)*6
+	}
+	return p
+}
+
+// RGBToCMYK converts RGB to CMYK
+func RGBToCMYK(r, g, b float64) (c, m, y, k float64) {
+	k = 1 - math.Max(r, math.Max(g, b))
+	if k == 1 {
+		return 0, 0, 0, 1
+	}
+	c = (1 - r - k) / (1 - k)
+	m = (1 - g - k) / (1 - k)
+	y = (1 - b - k) / (1 - k)
+	return
+}
+
+// CMYKToRGB converts CMYK to RGB
+func CMYKToRGB(c, m, y, k float64) (r, g, b float64) {
+	return (1 - c) * (1 - k), (1 - m) * (1 - k), (1 - y) * (1 - k)
+}
+
+// D65 reference white in CIE XYZ, for CIELAB
+const (
+	whiteX = 0.95047
+	whiteY = 1.0
+	whiteZ = 1.08883
+)
+
+// RGBToLab converts sRGB to CIELAB under D65: L from 0 to 100, a and b roughly -128
+// to 127
+func RGBToLab(r, g, b float64) (l, a, bb float64) {
+	r, g, b = linearSRGB(r), linearSRGB(g), linearSRGB(b)
+	x := (0.4124*r + 0.3576*g + 0.1805*b) / whiteX
+	y := (0.2126*r + 0.7152*g + 0.0722*b) / whiteY
+	z := (0.0193*r + 0.1192*g + 0.9505*b) / whiteZ
+
+	fx, fy, fz := labF(x), labF(y), labF(z)
+	return 116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)
+}
+
+// DeltaE returns the CIE76 color difference between two CIELAB colors, where about
+// 2.3 is just noticeable
+func DeltaE(l1, a1, b1, l2, a2, b2 float64) float64 {
+	return math.Sqrt((l1-l2)*(l1-l2) + (a1-a2)*(a1-a2) + (b1-b2)*(b1-b2))
+}
+
+// linearSRGB removes the sRGB transfer curve from a channel
+func linearSRGB(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// labF is the CIELAB companding function
+func labF(t float64) float64 {
+	const delta = 6.0 / 29
+	if t > delta*delta*delta {
+		return math.Cbrt(t)
+	}
+	return t/(3*delta*delta) + 4.0/29
+}
@@ -1,6 +1,7 @@
 package colors
 
 import (
+	"encoding/json"
 	"fmt"
 	"image/color"
 	"strconv"
@@ -63,6 +64,41 @@ func (c Color) Hex() string {
 	return fmt.Sprintf("#%02x%02x%02x", c.R8, c.G8, c.B8)
 }
 
+// String returns the hex string representation, so colors print as e.g. #1a1a1a
+func (c Color) String() string {
+	return c.Hex()
+}
+
+// MarshalJSON encodes the color as its hex string
+func (c Color) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.Hex())
+}
+
+// UnmarshalJSON decodes a hex string or CSS color name
+func (c *Color) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("color must be a hex string or CSS name: %w", err)
+	}
+	parsed, err := ParseColor(s)
+	if err != nil {
+		return err
+	}
+	*c = parsed
+	return nil
+}
+
+// IsLight reports whether the background is lighter than the text. Light schemes keep
+// the page light and only soften dark ink instead of inverting it.
+func (s Scheme) IsLight() bool {
+	return s.Background.lightness() > s.Text.lightness()
+}
+
+// lightness returns the HSL lightness (0-1)
+func (c Color) lightness() float64 {
+	return (max(c.R, c.G, c.B) + min(c.R, c.G, c.B)) / 2
+}
+
 // Predefined color schemes
 var (
 	// SchemeDark is the default dark mode scheme (#1a1a1a background, #e0e0e0 text)
@@ -114,15 +150,24 @@ var (
 		Text:       NewColorFromRGB8(248, 248, 240), // #f8f8f0
 	}
 
+	// SchemeReadingLight keeps a near-white page and softens black text to a dark gray,
+	// for screen reading of documents that are still printed on white paper
+	SchemeReadingLight = Scheme{
+		Name:       "reading-light",
+		Background: NewColorFromRGB8(250, 249, 246), // #faf9f6
+		Text:       NewColorFromRGB8(58, 58, 60),    // #3a3a3c
+	}
+
 	// AvailableSchemes maps scheme names to their definitions
 	AvailableSchemes = map[string]Scheme{
-		"dark":      SchemeDark,
-		"sepia":     SchemeSepia,
-		"nord":      SchemeNord,
-		"solarized": SchemeSolarized,
-		"gruvbox":   SchemeGruvbox,
-		"dracula":   SchemeDracula,
-		"monokai":   SchemeMonokai,
+		"dark":          SchemeDark,
+		"sepia":         SchemeSepia,
+		"nord":          SchemeNord,
+		"solarized":     SchemeSolarized,
+		"gruvbox":       SchemeGruvbox,
+		"dracula":       SchemeDracula,
+		"monokai":       SchemeMonokai,
+		"reading-light": SchemeReadingLight,
 	}
 )
 
@@ -144,13 +189,13 @@ func ListSchemes() []string {
 	return names
 }
 
-// NewCustomScheme creates a custom scheme from hex colors
+// NewCustomScheme creates a custom scheme from hex colors or CSS color names
 func NewCustomScheme(bgHex, textHex string) (Scheme, error) {
-	bg, err := NewColorFromHex(bgHex)
+	bg, err := ParseColor(bgHex)
 	if err != nil {
 		return Scheme{}, fmt.Errorf("invalid background color: %w", err)
 	}
-	text, err := NewColorFromHex(textHex)
+	text, err := ParseColor(textHex)
 	if err != nil {
 		return Scheme{}, fmt.Errorf("invalid text color: %w", err)
 	}
@@ -0,0 +1,81 @@
+package colors
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestColorJSONRoundTrip(t *testing.T) {
+	for _, hex := range []string{"#000000", "#ffffff", "#1a1a1a", "#e0e0e0", "#0a7bc2", "#ff79c6"} {
+		c, err := NewColorFromHex(hex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.String() != hex {
+			t.Errorf("String() = %q, want %q", c.String(), hex)
+		}
+
+		data, err := json.Marshal(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != `"`+hex+`"` {
+			t.Errorf("MarshalJSON = %s, want %q", data, hex)
+		}
+
+		var back Color
+		if err := json.Unmarshal(data, &back); err != nil {
+			t.Fatal(err)
+		}
+		if back != c {
+			t.Errorf("%s round-tripped to %+v, want %+v", hex, back, c)
+		}
+	}
+}
+
+func TestSchemeJSONRoundTrip(t *testing.T) {
+	data, err := json.Marshal(SchemeSepia)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"Name":"sepia","Background":"#1e1914","Text":"#e6dac8"}`
+	if string(data) != want {
+		t.Errorf("scheme marshals to %s, want %s", data, want)
+	}
+
+	var back Scheme
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back != SchemeSepia {
+		t.Errorf("scheme round-tripped to %+v, want %+v", back, SchemeSepia)
+	}
+}
+
+func TestColorUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		json string
+		want string
+	}{
+		{`"1a1a1a"`, "#1a1a1a"},
+		{`"#E0E0E0"`, "#e0e0e0"},
+		{`"MidnightBlue"`, "#191970"},
+	}
+	for _, tt := range tests {
+		var c Color
+		if err := json.Unmarshal([]byte(tt.json), &c); err != nil {
+			t.Errorf("unmarshal %s: %v", tt.json, err)
+			continue
+		}
+		if c.Hex() != tt.want {
+			t.Errorf("unmarshal %s = %s, want %s", tt.json, c, tt.want)
+		}
+	}
+
+	for _, bad := range []string{`"#12345"`, `"notacolor"`, `123`, `{"R8":1}`} {
+		var c Color
+		if err := json.Unmarshal([]byte(bad), &c); err == nil {
+			t.Errorf("unmarshal %s succeeded, want an error", bad)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package colors
+
+import (
+	"fmt"
+	"math"
+)
+
+// MaxContrastRatio is the contrast ratio of black on white
+const MaxContrastRatio = 21
+
+// Luminance returns the WCAG relative luminance, from 0 (black) to 1 (white)
+func (c Color) Luminance() float64 {
+	linear := func(v float64) float64 {
+		if v <= 0.04045 {
+			return v / 12.92
+		}
+		return math.Pow((v+0.055)/1.055, 2.4)
+	}
+	return 0.2126*linear(c.R) + 0.7152*linear(c.G) + 0.0722*linear(c.B)
+}
+
+// ContrastRatio returns the WCAG contrast ratio between a and b, from 1 to 21
+func ContrastRatio(a, b Color) float64 {
+	la, lb := a.Luminance(), b.Luminance()
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+// TextForContrast returns the gray text color closest to bg that has at least the
+// target contrast ratio against it: lighter than bg when white contrasts more, darker
+// otherwise. Returns an error if even white or black text falls short.
+func TextForContrast(bg Color, target float64) (Color, error) {
+	white, black := NewColorFromRGB8(255, 255, 255), NewColorFromRGB8(0, 0, 0)
+	light := ContrastRatio(bg, white) >= ContrastRatio(bg, black)
+
+	for i := 0; i <= 255; i++ {
+		v := uint8(i)
+		if !light {
+			v = uint8(255 - i)
+		}
+		text := NewColorFromRGB8(v, v, v)
+		if (text.Luminance() > bg.Luminance()) != light {
+			continue
+		}
+		if ContrastRatio(bg, text) >= target {
+			return text, nil
+		}
+	}
+
+	best := black
+	if light {
+		best = white
+	}
+	return Color{}, fmt.Errorf("contrast %.1f:1 is out of reach on background %s (at most %.1f:1)",
+		target, bg.Hex(), ContrastRatio(bg, best))
+}
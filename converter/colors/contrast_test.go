@@ -0,0 +1,49 @@
+package colors
+
+import "testing"
+
+func TestTextForContrast(t *testing.T) {
+	tests := []struct {
+		bg     string
+		target float64
+	}{
+		{"#1e1e1e", 4.5},
+		{"#1e1e1e", 7},
+		{"#3a3a4a", 4.5},
+		{"#f5f0e6", 4.5},
+		{"#f5f0e6", 12},
+		{"#808080", 3},
+	}
+
+	for _, tt := range tests {
+		bg, err := NewColorFromHex(tt.bg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text, err := TextForContrast(bg, tt.target)
+		if err != nil {
+			t.Errorf("%s at %g:1: %v", tt.bg, tt.target, err)
+			continue
+		}
+		if ratio := ContrastRatio(bg, text); ratio < tt.target {
+			t.Errorf("%s at %g:1: text %s reaches only %.2f:1", tt.bg, tt.target, text.Hex(), ratio)
+		}
+
+		// One gray step closer to the background must fall short
+		step := uint8(1)
+		if text.Luminance() > bg.Luminance() {
+			step = 255
+		}
+		closer := NewColorFromRGB8(text.R8+step, text.G8+step, text.B8+step)
+		if ContrastRatio(bg, closer) >= tt.target {
+			t.Errorf("%s at %g:1: %s also reaches the target, want the closest gray", tt.bg, tt.target, closer.Hex())
+		}
+	}
+}
+
+func TestTextForContrastOutOfReach(t *testing.T) {
+	bg, _ := NewColorFromHex("#808080")
+	if text, err := TextForContrast(bg, 10); err == nil {
+		t.Errorf("got %s for 10:1 on mid gray, want an error", text.Hex())
+	}
+}
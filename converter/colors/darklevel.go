@@ -0,0 +1,43 @@
+package colors
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"pdfdarkmode/converter/colormath"
+)
+
+// MaxDarkLightness is the background lightness of dark level 100, the lightest gray that
+// still reads as a dark page
+const MaxDarkLightness = 0.4
+
+// darkLevels are the named dark levels: true black, the default scheme's darkness and
+// a softer gray
+var darkLevels = map[string]float64{
+	"black": 0,
+	"dark":  25,
+	"dim":   50,
+}
+
+// ParseDarkLevel parses a dark level: a number from 0 (black) to 100 or one of the
+// names black, dark and dim
+func ParseDarkLevel(s string) (float64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if level, ok := darkLevels[s]; ok {
+		return level, nil
+	}
+	level, err := strconv.ParseFloat(s, 64)
+	if err != nil || level < 0 || level > 100 {
+		return 0, fmt.Errorf("invalid dark level: %s (must be 0-100, black, dark or dim)", s)
+	}
+	return level, nil
+}
+
+// WithDarkLevel returns s with its background at a lightness of level (0-100) percent of
+// MaxDarkLightness, keeping the background's hue and saturation. Level 0 is black.
+func (s Scheme) WithDarkLevel(level float64) Scheme {
+	h, sat, _ := colormath.RGBToHSL(s.Background.R, s.Background.G, s.Background.B)
+	s.Background = NewColorFromRGB(colormath.HSLToRGB(h, sat, clamp01(level/100)*MaxDarkLightness))
+	return s
+}
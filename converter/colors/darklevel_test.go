@@ -0,0 +1,64 @@
+package colors
+
+import (
+	"math"
+	"testing"
+
+	"pdfdarkmode/converter/colormath"
+)
+
+func TestWithDarkLevel(t *testing.T) {
+	for _, scheme := range []Scheme{SchemeDark, SchemeNord, SchemeSolarized, SchemeDracula} {
+		level, err := ParseDarkLevel("black")
+		if err != nil {
+			t.Fatal(err)
+		}
+		black := scheme.WithDarkLevel(level)
+		if got := black.Background.Hex(); got != "#000000" {
+			t.Errorf("%s at dark level black: background %s, want #000000", scheme.Name, got)
+		}
+		if black.Text != scheme.Text {
+			t.Errorf("%s at dark level black: text changed to %s", scheme.Name, black.Text.Hex())
+		}
+
+		h, s, _ := colormath.RGBToHSL(scheme.Background.R, scheme.Background.G, scheme.Background.B)
+		for _, level := range []float64{25, 50, 100} {
+			bg := scheme.WithDarkLevel(level).Background
+			gotH, gotS, gotL := colormath.RGBToHSL(bg.R, bg.G, bg.B)
+			if want := level / 100 * MaxDarkLightness; math.Abs(gotL-want) > 0.01 {
+				t.Errorf("%s at dark level %g: lightness %.3f, want %.3f", scheme.Name, level, gotL, want)
+			}
+			if s > 0 && (math.Abs(gotH-h) > 0.01 || math.Abs(gotS-s) > 0.02) {
+				t.Errorf("%s at dark level %g: hue %.3f, saturation %.2f, want the scheme's %.3f, %.2f",
+					scheme.Name, level, gotH, gotS, h, s)
+			}
+		}
+	}
+}
+
+func TestParseDarkLevel(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    float64
+		wantErr bool
+	}{
+		{"black", 0, false},
+		{"Dark", 25, false},
+		{"dim", 50, false},
+		{"75", 75, false},
+		{"101", 0, true},
+		{"-1", 0, true},
+		{"dusk", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDarkLevel(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: error %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %g, want %g", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package colors
+
+import (
+	"fmt"
+	"strings"
+
+	"pdfdarkmode/converter/colormath"
+)
+
+// Distance metrics for matching colors to remap sources
+const (
+	MetricRGB    = "rgb"    // Largest difference of the 8-bit channels
+	MetricCIELAB = "cielab" // CIE76 ΔE in CIELAB, closer to how different colors look
+)
+
+// ParseMetric checks a distance metric name, returning it in lower case
+func ParseMetric(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case MetricRGB, MetricCIELAB:
+		return name, nil
+	}
+	return "", fmt.Errorf("unknown color metric: %s (must be %s or %s)", name, MetricRGB, MetricCIELAB)
+}
+
+// Matcher finds the remap whose source a color matches under a distance metric
+type Matcher struct {
+	remaps    []Remap
+	metric    string
+	tolerance float64
+	labs      [][3]float64 // Remap sources in CIELAB, for MetricCIELAB
+}
+
+// NewMatcher matches colors within tolerance of a remap source: the 8-bit channel
+// difference for MetricRGB, ΔE for MetricCIELAB
+func NewMatcher(remaps []Remap, metric string, tolerance float64) *Matcher {
+	m := &Matcher{remaps: remaps, metric: metric, tolerance: tolerance}
+	if metric == MetricCIELAB {
+		m.labs = make([][3]float64, len(remaps))
+		for i, r := range remaps {
+			l, a, b := colormath.RGBToLab(r.From.R, r.From.G, r.From.B)
+			m.labs[i] = [3]float64{l, a, b}
+		}
+	}
+	return m
+}
+
+// Lookup returns the remap target for an 8-bit RGB color, if any. Under MetricRGB the
+// first remap within tolerance on every channel matches, as with the package Lookup;
+// under MetricCIELAB the closest one within tolerance does.
+func (m *Matcher) Lookup(r, g, b uint8) (Color, bool) {
+	if m == nil || len(m.remaps) == 0 {
+		return Color{}, false
+	}
+	if m.metric != MetricCIELAB {
+		return Lookup(m.remaps, r, g, b, uint8(min(m.tolerance, 255)))
+	}
+
+	l, a, bb := colormath.RGBToLab(float64(r)/255, float64(g)/255, float64(b)/255)
+	best, bestDist := -1, 0.0
+	for i, lab := range m.labs {
+		d := colormath.DeltaE(l, a, bb, lab[0], lab[1], lab[2])
+		if d <= m.tolerance && (best < 0 || d < bestDist) {
+			best, bestDist = i, d
+		}
+	}
+	if best < 0 {
+		return Color{}, false
+	}
+	return m.remaps[best].To, true
+}
@@ -0,0 +1,70 @@
+package colors
+
+import "testing"
+
+func TestMatcherMetrics(t *testing.T) {
+	blue := NewColorFromRGB8(0x8a, 0xb4, 0xf8)
+	green := NewColorFromRGB8(0x50, 0xfa, 0x7b)
+	remaps := []Remap{
+		{From: NewColorFromRGB8(0, 0, 255), To: blue},
+		{From: NewColorFromRGB8(0, 255, 0), To: green},
+	}
+
+	tests := []struct {
+		name    string
+		r, g, b uint8
+		rgb     bool // matches under MetricRGB with tolerance 8
+		cielab  bool // matches under MetricCIELAB with tolerance 3
+	}{
+		{"exact", 0, 0, 255, true, true},
+		{"one channel off", 0, 0, 247, true, false},
+		{"red added to blue", 40, 0, 255, false, true},
+		{"pale green", 24, 255, 24, false, true},
+		{"dark blue", 0, 0, 200, false, false},
+		{"unrelated", 255, 0, 0, false, false},
+	}
+
+	rgb := NewMatcher(remaps, MetricRGB, 8)
+	cielab := NewMatcher(remaps, MetricCIELAB, 3)
+	for _, tt := range tests {
+		if _, ok := rgb.Lookup(tt.r, tt.g, tt.b); ok != tt.rgb {
+			t.Errorf("%s: rgb match %t, want %t", tt.name, ok, tt.rgb)
+		}
+		if _, ok := cielab.Lookup(tt.r, tt.g, tt.b); ok != tt.cielab {
+			t.Errorf("%s: cielab match %t, want %t", tt.name, ok, tt.cielab)
+		}
+	}
+}
+
+func TestMatcherPicksRemap(t *testing.T) {
+	first := NewColorFromRGB8(1, 1, 1)
+	second := NewColorFromRGB8(2, 2, 2)
+	remaps := []Remap{
+		{From: NewColorFromRGB8(0, 0, 240), To: first},
+		{From: NewColorFromRGB8(0, 0, 255), To: second},
+	}
+
+	// MetricRGB takes the first remap within tolerance, MetricCIELAB the closest
+	if to, ok := NewMatcher(remaps, MetricRGB, 16).Lookup(0, 0, 252); !ok || to != first {
+		t.Errorf("rgb lookup = %s, %t, want %s", to.Hex(), ok, first.Hex())
+	}
+	if to, ok := NewMatcher(remaps, MetricCIELAB, 10).Lookup(0, 0, 252); !ok || to != second {
+		t.Errorf("cielab lookup = %s, %t, want %s", to.Hex(), ok, second.Hex())
+	}
+
+	var m *Matcher
+	if _, ok := m.Lookup(0, 0, 255); ok {
+		t.Error("nil matcher matched")
+	}
+}
+
+func TestParseMetric(t *testing.T) {
+	for in, want := range map[string]string{"rgb": MetricRGB, " CIELAB ": MetricCIELAB} {
+		if got, err := ParseMetric(in); err != nil || got != want {
+			t.Errorf("ParseMetric(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseMetric("lab"); err == nil {
+		t.Error("ParseMetric(\"lab\") succeeded, want an error")
+	}
+}
@@ -0,0 +1,52 @@
+package colors
+
+import "math"
+
+// NewColorFromRGB creates a Color from normalized RGB values, clamped to 0-1
+func NewColorFromRGB(r, g, b float64) Color {
+	r, g, b = clamp01(r), clamp01(g), clamp01(b)
+	return Color{
+		R8: uint8(math.Round(r * 255)),
+		G8: uint8(math.Round(g * 255)),
+		B8: uint8(math.Round(b * 255)),
+		R:  r, G: g, B: b,
+	}
+}
+
+// Mix returns the color a fraction t (0-1) of the way from c to other. Mixing works on
+// the gamma-encoded sRGB values, which are spaced roughly evenly to the eye, so the
+// midpoint of black and white is the 50% gray a reader would pick rather than the
+// much lighter gray that mixing light intensities gives.
+func (c Color) Mix(other Color, t float64) Color {
+	t = clamp01(t)
+	return NewColorFromRGB(
+		c.R+t*(other.R-c.R),
+		c.G+t*(other.G-c.G),
+		c.B+t*(other.B-c.B),
+	)
+}
+
+// Lighten mixes c towards white by amount (0-1), keeping its hue
+func (c Color) Lighten(amount float64) Color {
+	return c.Mix(NewColorFromRGB(1, 1, 1), amount)
+}
+
+// Darken mixes c towards black by amount (0-1), keeping its hue
+func (c Color) Darken(amount float64) Color {
+	return c.Mix(NewColorFromRGB(0, 0, 0), amount)
+}
+
+// gradientSpread is how far the ends of a background gradient are mixed away from the
+// background, little enough that the page still reads as the scheme's color
+const gradientSpread = 0.06
+
+// BackgroundGradient returns the top and bottom colors of a gradient page background
+// around the scheme's background: slightly lighter at the top, slightly darker at the
+// bottom
+func (s Scheme) BackgroundGradient() (top, bottom Color) {
+	return s.Background.Lighten(gradientSpread), s.Background.Darken(gradientSpread)
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
@@ -0,0 +1,47 @@
+package colors
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMix(t *testing.T) {
+	black := NewColorFromRGB8(0, 0, 0)
+	white := NewColorFromRGB8(255, 255, 255)
+	red := NewColorFromRGB8(255, 0, 0)
+	blue := NewColorFromRGB8(0, 0, 255)
+
+	tests := []struct {
+		name  string
+		got   Color
+		want  string
+		wantR float64
+	}{
+		{"start", black.Mix(white, 0), "#000000", 0},
+		{"end", black.Mix(white, 1), "#ffffff", 1},
+		{"midpoint", black.Mix(white, 0.5), "#808080", 0.5},
+		{"quarter", black.Mix(white, 0.25), "#404040", 0.25},
+		{"hue midpoint", red.Mix(blue, 0.5), "#800080", 0.5},
+		{"below range", red.Mix(blue, -1), "#ff0000", 1},
+		{"above range", red.Mix(blue, 2), "#0000ff", 0},
+		{"lighten none", red.Lighten(0), "#ff0000", 1},
+		{"lighten half", red.Lighten(0.5), "#ff8080", 1},
+		{"lighten full", red.Lighten(1), "#ffffff", 1},
+		{"darken none", red.Darken(0), "#ff0000", 1},
+		{"darken half", red.Darken(0.5), "#800000", 0.5},
+		{"darken full", red.Darken(1), "#000000", 0},
+	}
+
+	for _, tt := range tests {
+		if tt.got.Hex() != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, tt.got.Hex(), tt.want)
+		}
+		if math.Abs(tt.got.R-tt.wantR) > 1e-9 {
+			t.Errorf("%s: R = %v, want %v", tt.name, tt.got.R, tt.wantR)
+		}
+		// The 8-bit fields follow the normalized ones
+		if tt.got.R8 != uint8(math.Round(tt.got.R*255)) || tt.got.B8 != uint8(math.Round(tt.got.B*255)) {
+			t.Errorf("%s: 8-bit fields %+v out of sync", tt.name, tt.got)
+		}
+	}
+}
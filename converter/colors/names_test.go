@@ -0,0 +1,64 @@
+package colors
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewColorFromName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"navy", "#000080"},
+		{"rebeccapurple", "#663399"},
+		{"RebeccaPurple", "#663399"},
+		{"  MidnightBlue ", "#191970"},
+		{"grey", "#808080"},
+	}
+
+	for _, tt := range tests {
+		c, err := NewColorFromName(tt.name)
+		if err != nil {
+			t.Errorf("%q: %v", tt.name, err)
+			continue
+		}
+		if c.Hex() != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.name, c.Hex(), tt.want)
+		}
+	}
+}
+
+func TestNewColorFromNameUnknown(t *testing.T) {
+	_, err := NewColorFromName("navvy")
+	if err == nil {
+		t.Fatal("got no error for an unknown name")
+	}
+	if !strings.Contains(err.Error(), "did you mean navy?") {
+		t.Errorf("error %q does not suggest navy", err)
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"Navy", "#000080", false},
+		{"#1e1e1e", "#1e1e1e", false},
+		{"ffffff", "#ffffff", false},
+		{"notacolor", "", true},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseColor(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: error %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && c.Hex() != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.in, c.Hex(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package colors
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseSchemeSpec parses a scheme from a flexible spec string:
+//   - a named scheme: "nord"
+//   - a background/text pair: "#1a1a1a/#e0e0e0"
+//   - key/value pairs: "bg:#1a1a1a,text:#e0e0e0" (also "background" and "fg");
+//     a missing color falls back to the default scheme
+//
+// Colors may also be CSS color names, e.g. "midnightblue/ivory".
+func ParseSchemeSpec(spec string) (Scheme, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return Scheme{}, fmt.Errorf("empty color scheme")
+	}
+
+	switch {
+	case strings.Contains(spec, ":"):
+		return parseSchemeKeyValues(spec)
+	case strings.Contains(spec, "/"):
+		bg, text, _ := strings.Cut(spec, "/")
+		return NewCustomScheme(strings.TrimSpace(bg), strings.TrimSpace(text))
+	}
+
+	return GetScheme(spec)
+}
+
+// parseSchemeKeyValues parses "bg:#..,text:#.." specs
+func parseSchemeKeyValues(spec string) (Scheme, error) {
+	bg := DefaultScheme().Background.Hex()
+	text := DefaultScheme().Text.Hex()
+
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(pair, ":")
+		if !ok {
+			return Scheme{}, fmt.Errorf("invalid color scheme entry: %q (expected key:#rrggbb)", pair)
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "bg", "background":
+			bg = value
+		case "text", "fg":
+			text = value
+		default:
+			return Scheme{}, fmt.Errorf("unknown color scheme key: %q (expected bg or text)", key)
+		}
+	}
+
+	return NewCustomScheme(bg, text)
+}
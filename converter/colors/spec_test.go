@@ -0,0 +1,50 @@
+package colors
+
+import "testing"
+
+func TestParseSchemeSpec(t *testing.T) {
+	tests := []struct {
+		spec     string
+		name     string
+		bg, text string
+	}{
+		{"nord", "nord", "#2e3440", "#eceff4"},
+		{"  Sepia ", "sepia", "#1e1914", "#e6dac8"},
+		{"#101010/#f0f0f0", "custom", "#101010", "#f0f0f0"},
+		{"101010 / f0f0f0", "custom", "#101010", "#f0f0f0"},
+		{"midnightblue/ivory", "custom", "#191970", "#fffff0"},
+		{"bg:#101010,text:#f0f0f0", "custom", "#101010", "#f0f0f0"},
+		{"background: #101010, fg: #f0f0f0", "custom", "#101010", "#f0f0f0"},
+		{"text:#f0f0f0", "custom", "#1a1a1a", "#f0f0f0"},
+		{"BG:navy,", "custom", "#000080", "#e0e0e0"},
+	}
+
+	for _, tt := range tests {
+		scheme, err := ParseSchemeSpec(tt.spec)
+		if err != nil {
+			t.Errorf("ParseSchemeSpec(%q): %v", tt.spec, err)
+			continue
+		}
+		if scheme.Name != tt.name || scheme.Background.Hex() != tt.bg || scheme.Text.Hex() != tt.text {
+			t.Errorf("ParseSchemeSpec(%q) = %s %s/%s, want %s %s/%s", tt.spec,
+				scheme.Name, scheme.Background.Hex(), scheme.Text.Hex(), tt.name, tt.bg, tt.text)
+		}
+	}
+}
+
+func TestParseSchemeSpecErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"  ",
+		"nosuchscheme",
+		"#1010/#f0f0f0",
+		"#101010/notacolor",
+		"bg:#101010,color:#f0f0f0",
+		"bg:#101010,#f0f0f0",
+		"bg:#zzzzzz",
+	} {
+		if _, err := ParseSchemeSpec(spec); err == nil {
+			t.Errorf("ParseSchemeSpec(%q) succeeded, want an error", spec)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package colors
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Remap replaces one specific source color with a target color
+type Remap struct {
+	From Color // Source color as it appears in the document
+	To   Color // Color to use in the dark output
+}
+
+// Stylesheet is a scheme plus remap table parsed from a CSS-like file
+type Stylesheet struct {
+	Background *Color  // Overrides the scheme background when set
+	Text       *Color  // Overrides the scheme text color when set
+	Remaps     []Remap // Exact color remaps applied before the scheme
+}
+
+// namedSelectors map friendly property names to the source color they remap
+var namedSelectors = map[string]Color{
+	"link":   NewColorFromRGB8(0, 0, 255), // #0000ff, the conventional hyperlink blue
+	"accent": NewColorFromRGB8(255, 0, 0), // #ff0000, the conventional highlight red
+}
+
+var (
+	// commentPattern matches /* ... */ comments
+	commentPattern = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	// blockPattern matches a selector and its opening brace, or a closing brace
+	blockPattern = regexp.MustCompile(`[^;{}]*\{|\}`)
+)
+
+// LoadStylesheet reads and parses a stylesheet file
+func LoadStylesheet(path string) (Stylesheet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Stylesheet{}, fmt.Errorf("failed to read stylesheet: %w", err)
+	}
+	return ParseStylesheet(string(data))
+}
+
+// ParseStylesheet parses declarations such as
+//
+//	text: #e0e0e0; background: #1a1a1a; link: #8ab4f8; #ff0000: #ff79c6;
+//
+// "text" and "background" set the scheme colors, "link" and "accent" remap
+// pure blue and pure red, and a hex property remaps that exact source color.
+// Declarations may be wrapped in a selector block, which is ignored.
+// Unknown properties are reported together in a single error.
+func ParseStylesheet(src string) (Stylesheet, error) {
+	var sheet Stylesheet
+	var unknown []string
+
+	src = commentPattern.ReplaceAllString(src, "")
+	src = blockPattern.ReplaceAllString(src, ";")
+
+	for _, decl := range strings.Split(src, ";") {
+		decl = strings.TrimSpace(decl)
+		if decl == "" {
+			continue
+		}
+
+		prop, value, ok := strings.Cut(decl, ":")
+		if !ok {
+			return Stylesheet{}, fmt.Errorf("invalid declaration: %q (expected property: value)", decl)
+		}
+		prop = strings.ToLower(strings.TrimSpace(prop))
+		value = strings.TrimSpace(value)
+
+		target, err := NewColorFromHex(value)
+		if err != nil {
+			return Stylesheet{}, fmt.Errorf("invalid value for %s: %w", prop, err)
+		}
+
+		switch prop {
+		case "background":
+			sheet.Background = &target
+		case "text":
+			sheet.Text = &target
+		default:
+			if from, ok := namedSelectors[prop]; ok {
+				sheet.Remaps = append(sheet.Remaps, Remap{From: from, To: target})
+			} else if strings.HasPrefix(prop, "#") {
+				from, err := NewColorFromHex(prop)
+				if err != nil {
+					return Stylesheet{}, fmt.Errorf("invalid selector: %w", err)
+				}
+				sheet.Remaps = append(sheet.Remaps, Remap{From: from, To: target})
+			} else {
+				unknown = append(unknown, prop)
+			}
+		}
+	}
+
+	if len(unknown) > 0 {
+		known := []string{"background", "text"}
+		for name := range namedSelectors {
+			known = append(known, name)
+		}
+		sort.Strings(known)
+		return Stylesheet{}, fmt.Errorf("unknown stylesheet properties: %s (expected %s or a #rrggbb color)",
+			strings.Join(unknown, ", "), strings.Join(known, ", "))
+	}
+
+	return sheet, nil
+}
+
+// Apply returns scheme with the stylesheet's background and text overrides
+func (s Stylesheet) Apply(scheme Scheme) Scheme {
+	if s.Background == nil && s.Text == nil {
+		return scheme
+	}
+	if s.Background != nil {
+		scheme.Background = *s.Background
+	}
+	if s.Text != nil {
+		scheme.Text = *s.Text
+	}
+	scheme.Name = "custom"
+	return scheme
+}
+
+// Lookup returns the remap target for an 8-bit RGB color, if any.
+// Colors within tolerance of a remap source on every channel match.
+func Lookup(remaps []Remap, r, g, b uint8, tolerance uint8) (Color, bool) {
+	for _, m := range remaps {
+		if near(m.From.R8, r, tolerance) && near(m.From.G8, g, tolerance) && near(m.From.B8, b, tolerance) {
+			return m.To, true
+		}
+	}
+	return Color{}, false
+}
+
+// near reports whether a and b differ by at most tolerance
+func near(a, b, tolerance uint8) bool {
+	if a > b {
+		return a-b <= tolerance
+	}
+	return b-a <= tolerance
+}
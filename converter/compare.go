@@ -0,0 +1,116 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"pdfdarkmode/converter/colors"
+	"pdfdarkmode/converter/notice"
+	"pdfdarkmode/converter/sample"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// ComparePages is how many leading pages a scheme comparison shows
+const ComparePages = 3
+
+// compareSchemes converts the first ComparePages pages of the input under each of
+// opts.Compare and writes them to opts.OutputFile with one output page per source page,
+// showing it under every scheme side by side, each labeled with its scheme's name
+func compareSchemes(opts Options) error {
+	tempDir, err := os.MkdirTemp("", "pdfdarkmode-compare-")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	pageCount, err := api.PageCountFile(opts.InputFile)
+	if err != nil {
+		return fmt.Errorf("failed to count pages: %w", err)
+	}
+	pages := min(pageCount, ComparePages)
+
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationRelaxed
+	conf.CreateBookmarks = false // No bookmark per merged file
+
+	input := filepath.Join(tempDir, "input.pdf")
+	if err := api.TrimFile(opts.InputFile, input, []string{"1-" + strconv.Itoa(pages)}, conf); err != nil {
+		return fmt.Errorf("failed to extract pages: %w", err)
+	}
+
+	outputs := make([]string, len(opts.Compare))
+	for i, scheme := range opts.Compare {
+		fmt.Printf("  Scheme %d/%d: %s\n", i+1, len(opts.Compare), scheme.Name)
+		outputs[i] = filepath.Join(tempDir, fmt.Sprintf("scheme%d.pdf", i+1))
+		if err := convertStrict(schemeOptions(opts, input, outputs[i], scheme)); err != nil {
+			return fmt.Errorf("%s: %w", scheme.Name, err)
+		}
+		if err := labelScheme(outputs[i], scheme, opts.RTL); err != nil {
+			return fmt.Errorf("failed to label %s: %w", scheme.Name, err)
+		}
+	}
+
+	// Merge the schemes' outputs, then gather each source page's versions in scheme order
+	merged := filepath.Join(tempDir, "merged.pdf")
+	if err := api.MergeCreateFile(outputs, merged, false, conf); err != nil {
+		return fmt.Errorf("pdfcpu merge failed: %w", err)
+	}
+	var order []string
+	for page := 1; page <= pages; page++ {
+		for i := range outputs {
+			order = append(order, strconv.Itoa(i*pages+page))
+		}
+	}
+	collected := filepath.Join(tempDir, "collected.pdf")
+	if err := api.CollectFile(merged, collected, order, conf); err != nil {
+		return fmt.Errorf("pdfcpu collect failed: %w", err)
+	}
+
+	return sideBySide(collected, opts.OutputFile, len(outputs), conf)
+}
+
+// schemeOptions returns opts for converting input to output under one compared scheme.
+// Extra outputs, reports and page sampling are left out; they belong to the comparison.
+func schemeOptions(opts Options, input, output string, scheme colors.Scheme) Options {
+	opts.InputFile = input
+	opts.OutputFile = output
+	opts.ColorScheme = scheme
+	opts.Compare = nil
+	opts.LightOutput = ""
+	opts.Sample = sample.Options{}
+	opts.PDFA = false
+	return opts
+}
+
+// labelScheme stamps the scheme's name in its text color at the top of every page
+func labelScheme(path string, scheme colors.Scheme, rtl bool) error {
+	desc := fmt.Sprintf("%s, points:14, fillcolor:%s, rotation:0, scalefactor:1 abs, opacity:1",
+		notice.Layout{Position: "tc", DY: -12}.Desc(scheme.Name, rtl), scheme.Text.Hex())
+	return api.AddTextWatermarksFile(path, "", nil, true, scheme.Name, desc, nil)
+}
+
+// sideBySide lays the pages of input out in rows of cols pages, one row per output
+// page, each cell the size of input's first page
+func sideBySide(input, output string, cols int, conf *model.Configuration) error {
+	dims, err := api.PageDimsFile(input)
+	if err != nil {
+		return fmt.Errorf("failed to read page size: %w", err)
+	}
+	if len(dims) == 0 {
+		return fmt.Errorf("no pages to compare")
+	}
+
+	nup, err := api.PDFGridConfig(1, cols, "border:off, margin:0", conf)
+	if err != nil {
+		return err
+	}
+	nup.PageDim = &dims[0]
+	if err := api.NUpFile([]string{input}, output, nil, nup, conf); err != nil {
+		return fmt.Errorf("pdfcpu n-up failed: %w", err)
+	}
+	return nil
+}
@@ -1,21 +1,102 @@
 package converter
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	"pdfdarkmode/converter/colors"
 	"pdfdarkmode/converter/direct"
+	"pdfdarkmode/converter/htmlreport"
+	"pdfdarkmode/converter/hybrid"
+	"pdfdarkmode/converter/icc"
+	"pdfdarkmode/converter/intensity"
+	"pdfdarkmode/converter/nup"
+	"pdfdarkmode/converter/pdfa"
+	"pdfdarkmode/converter/pdfversion"
 	"pdfdarkmode/converter/raster"
+	"pdfdarkmode/converter/report"
+	"pdfdarkmode/converter/sample"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
 )
 
+// PostProcessor makes further changes to a converted document before it is written, such
+// as setting permissions or adding attachments
+type PostProcessor func(ctx *model.Context) error
+
 // Options holds the configuration for PDF conversion
 type Options struct {
 	InputFile      string
 	OutputFile     string
-	Mode           string        // "raster" or "direct"
-	DPI            int           // DPI for raster mode
-	PreserveImages bool          // Preserve images in direct mode
-	ColorScheme    colors.Scheme // Color scheme for dark mode
+	Mode           string           // "raster", "direct" or "hybrid"
+	DPI            int              // DPI for raster mode
+	PreserveImages bool             // Preserve images in direct mode
+	ColorScheme    colors.Scheme    // Color scheme for dark mode
+	PDFVersion     string           // Output PDF version (e.g. "1.5"), empty keeps the default
+	KeepStructure  bool             // Copy the tagged structure tree in raster mode
+	SinglePass     bool             // Bound direct mode memory by freeing decoded streams per page
+	Remaps         []colors.Remap   // Exact color remaps applied before the color scheme
+	ColorMetric    string           // colors.MetricRGB or colors.MetricCIELAB for matching remap sources, empty for RGB
+	MetricTol      float64          // Remap match tolerance in ColorMetric's units, 0 for the mode's default
+	Sample         sample.Options   // Convert only a subset of pages as a proof
+	Renderer       raster.Renderer  // Optional renderer preferred in raster mode
+	CheckPoppler   bool             // Raster and hybrid modes: warn when poppler is older than raster.MinPopplerVersion
+	ViewerHints    bool             // Write a dark theme hint into the output metadata
+	SnapNearWhite  float64          // Raster lightness above which pixels snap to the background, 0 for default
+	SnapNearBlack  float64          // Raster lightness below which pixels snap to the text color, 0 for default
+	AutoOrient     bool             // Rotate raster pages upright based on their content
+	CleanEdges     bool             // Raster mode: snap light anti-aliased edge pixels to the background
+	ProtectInks    []colors.Color   // Raster mode: inks (e.g. blue signatures) whose hues keep their color
+	Gradient       bool             // Fill pages with a top-to-bottom gradient around the background color
+	Texture        []byte           // Direct mode: PNG or JPEG image drawn over the page background, nil for none
+	TintStrength   *float64         // Direct mode: share of a tinted scheme's tint applied to grays (0-1), nil for full
+	Intensity      *int             // 0 (gentle) to 100 (aggressive) preset for the color lightness, saturation boost and snap knobs left at 0, nil for 50 (the defaults)
+	RespectDarkBg  bool             // Direct mode: add no background to pages that already start with a full-page dark fill
+	BgMargin       float64          // Direct mode: points the background extends past the page, negative to inset
+	ColorTolerance float64          // Saturation band around the document/colorful boundary blending both mappings, 0 for none
+	MinColorL      float64          // Lightness floor for colorful colors, 0 for the mode's default
+	MaxColorL      float64          // Lightness ceiling for colorful colors, 0 for the mode's default
+	MapPrimary     bool             // Direct mode: map the most common dark fill color exactly to the scheme text
+	SamplePages    int              // Pages spread over the document that analyses (e.g. MapPrimary) look at, 0 for all
+	Readability    bool             // Direct and hybrid modes: warn about pages whose text is mostly very small or in a thin font
+	DefaultApply   string           // Direct mode: direct.DefaultColorFill, DefaultColorStroke or DefaultColorBoth (empty) for the page default colors
+	StripeAware    bool             // Direct mode: keep light gray fills (zebra-striped rows) as a stripe apart from the background
+	PreserveWhite  float64          // Document colors lighter than this stay as they are (raster: enclosed areas only), 0 for none
+	NormalizeRot   bool             // Direct mode: bake /Rotate into page content (raster output is always upright)
+	TextRegions    bool             // Raster mode: invert only inside detected text regions
+	TextPages      bool             // Hybrid mode: convert text pages directly and render the others whole
+	CMYK           bool             // Raster mode: render, invert and embed pages in CMYK (needs Ghostscript)
+	Flatten        bool             // Raster mode: flatten transparency with Ghostscript before rendering
+	WarnSize       int64            // Raster mode: estimated output size (bytes) that needs confirmation, 0 for none
+	MaxSize        int64            // Raster mode: estimated output size (bytes) that is refused, 0 for none
+	ConfirmSize    func(int64) bool // Asked when WarnSize is exceeded, nil to proceed
+	MaxRenderTime  time.Duration    // Raster and hybrid modes: render time after which a page is skipped, 0 for none
+	CacheDir       string           // Raster mode: directory keeping rendered pages for later runs on the same input, empty for none
+	ICCProfiles    []icc.Profile    // Direct mode: profiles tagged as default gray/RGB, nil for untagged output
+	Layers         bool             // Direct mode: keep the original as a toggleable optional content layer
+	Sanitize       bool             // Direct mode: strip JavaScript and automatic actions from the output
+	Incremental    bool             // Direct mode: append changes as an incremental update to the original bytes
+	NoRecompress   bool             // Direct mode: keep the source's stream filters and object/xref stream use
+	CompatOps      bool             // Direct mode: write transformed colors with cs/sc instead of rg, g and k
+	Strict         bool             // Fail instead of writing output when the conversion records any warning
+	ReportDir      string           // Directory for a JSON report of the run's outcome and warnings, empty for none
+	HTMLReport     string           // Path of a self-contained HTML summary with thumbnails, empty for none
+	LightOutput    string           // Path of an additional light version in the reading-light scheme, empty for none
+	Compare        []colors.Scheme  // Write the first ComparePages pages side by side under each of these schemes instead
+	RTL            bool             // Right-to-left document: notices and labels the tool adds are placed and set for it
+	Watermark      string           // Text stamped diagonally across every output page, empty for none
+	WatermarkAlpha float64          // Opacity of the watermark, 0 for DefaultWatermarkOpacity
+	OnlySpaces     []string         // Direct mode: transform only operators in these color spaces, empty for all
+	SplitNUp       [2]int           // Rows and columns to cut each n-up sheet into before converting, zero for none
+	OnlyPages      string           // parity.Odd or parity.Even to convert only those pages, leaving the others as they are
+	SkipSpaces     []string         // Direct mode: leave operators in these color spaces unchanged
+	PDFA           bool             // Rewrite the output as PDF/A-2b and report what keeps it from conforming
+	IfAlreadyDark  string           // AlreadyDarkWarn (default), AlreadyDarkSkip or AlreadyDarkProceed for inputs already converted
+	PostProcess    PostProcessor    // Direct mode: called with the document after backgrounds are added, before writing; nil for none
+	Progress       report.Progress  // Told as each page is done, with the time elapsed, e.g. to show time left; nil for none
 }
 
 // Converter interface defines the contract for PDF conversion engines
@@ -25,16 +106,290 @@ type Converter interface {
 
 // Convert performs the PDF to dark mode conversion using the specified mode
 func Convert(opts Options) error {
+	report.SetProgress(opts.Progress)
+	defer report.SetProgress(nil)
+
+	if opts.ReportDir == "" && opts.HTMLReport == "" {
+		return convert(opts)
+	}
+
+	rep := report.Start(opts.InputFile, opts.OutputFile, opts.Mode, opts.ColorScheme.Name)
+	err := convert(opts)
+	rep.Finish(err)
+
+	if writeErr := writeReports(rep, opts); writeErr != nil && err == nil {
+		return writeErr
+	}
+	return err
+}
+
+// writeReports saves rep as the JSON and HTML reports requested in opts
+func writeReports(rep *report.Report, opts Options) error {
+	if opts.ReportDir != "" {
+		path, err := rep.Write(opts.ReportDir)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("  Report: %s\n", path)
+	}
+	if opts.HTMLReport != "" {
+		if err := htmlreport.Write(opts.HTMLReport, rep, opts.ColorScheme, opts.Renderer); err != nil {
+			return err
+		}
+		fmt.Printf("  Report: %s\n", opts.HTMLReport)
+	}
+	return nil
+}
+
+// ErrIncomplete is returned in strict mode when the conversion recorded warnings about
+// content it skipped or could not handle
+var ErrIncomplete = errors.New("conversion incomplete")
+
+// convert runs the conversion for Convert, followed by the light version if one is
+// requested
+func convert(opts Options) error {
+	if len(opts.Compare) > 0 {
+		return compareSchemes(opts)
+	}
+	if err := convertStrict(opts); err != nil || opts.LightOutput == "" {
+		return err
+	}
+	fmt.Printf("  Light version: %s\n", opts.LightOutput)
+	return convertStrict(lightOptions(opts))
+}
+
+// lightOptions returns opts for the light version: the same conversion into
+// LightOutput with the reading-light scheme, which keeps the page light and softens
+// dark ink. The remaps are left out, having been chosen for the dark scheme.
+func lightOptions(opts Options) Options {
+	opts.OutputFile = opts.LightOutput
+	opts.LightOutput = ""
+	opts.ColorScheme = colors.SchemeReadingLight
+	opts.Remaps = nil
+	return opts
+}
+
+// convertStrict runs one conversion. In strict mode any warning recorded during the
+// conversion fails it and removes the output.
+func convertStrict(opts Options) error {
+	mark := report.Count()
+	if err := convertOutput(opts); err != nil {
+		return err
+	}
+	if !opts.Strict {
+		return nil
+	}
+	if warnings := report.Since(mark); len(warnings) > 0 {
+		os.Remove(opts.OutputFile)
+		return fmt.Errorf("%w: %d warning(s) in strict mode:\n  - %s", ErrIncomplete, len(warnings), strings.Join(warnings, "\n  - "))
+	}
+	return nil
+}
+
+// convertOutput writes the converted output for convert
+func convertOutput(opts Options) error {
+	// Reject junk input before any engine reads or renders it
+	if err := validateInput(opts.InputFile); err != nil {
+		return err
+	}
+	// The input is parsed once for the checks below; unreadable input is left for the
+	// engine to report
+	var input *model.Context
+	if opts.IfAlreadyDark != AlreadyDarkProceed || !opts.PDFA {
+		input, _ = direct.ReadContext(opts.InputFile)
+	}
+	if err := checkAlreadyDark(input, opts); err != nil {
+		return err
+	}
+	checkPDFAInput(input, opts)
+	if opts.SplitNUp[0]*opts.SplitNUp[1] > 1 {
+		split, err := nup.Split(opts.InputFile, opts.SplitNUp[0], opts.SplitNUp[1])
+		if err != nil {
+			return fmt.Errorf("failed to split n-up pages: %w", err)
+		}
+		defer os.Remove(split)
+		fmt.Printf("  Split each page into %dx%d pages\n", opts.SplitNUp[0], opts.SplitNUp[1])
+		opts.InputFile = split
+	}
+	if opts.CheckPoppler && (opts.Mode == "raster" || opts.Mode == "hybrid") && !opts.CMYK && opts.Renderer == nil {
+		raster.CheckPopplerVersion()
+	}
+
 	var conv Converter
 
 	switch opts.Mode {
 	case "raster":
-		conv = raster.NewEngine(opts.DPI, opts.ColorScheme)
+		conv = newRasterEngine(opts)
 	case "direct":
-		conv = direct.NewEngine(opts.PreserveImages, opts.ColorScheme)
+		conv = newDirectEngine(opts)
+	case "hybrid":
+		engine := hybrid.NewEngine(newRasterEngine(opts), newDirectEngine(opts), opts.ColorScheme)
+		engine.SetOnlyPages(opts.OnlyPages)
+		engine.SetTextOnlyPages(opts.TextPages)
+		conv = engine
 	default:
 		return fmt.Errorf("unknown mode: %s", opts.Mode)
 	}
 
-	return conv.Convert(opts.InputFile, opts.OutputFile)
+	if err := run(conv, opts); err != nil {
+		return err
+	}
+	if opts.Watermark != "" {
+		if err := stampWatermark(opts); err != nil {
+			return err
+		}
+	}
+
+	if opts.PDFA {
+		if err := makePDFA(opts); err != nil {
+			return err
+		}
+	}
+	if opts.PDFVersion != "" {
+		return checkPDFVersion(opts)
+	}
+	return nil
+}
+
+// checkPDFVersion checks the finished output against opts.PDFVersion, once every step
+// that adds to it is done, and stamps the version into its header, which steps that
+// rewrite the file reset. An output needing a newer version is removed.
+func checkPDFVersion(opts Options) error {
+	target, err := pdfversion.Parse(opts.PDFVersion)
+	if err != nil {
+		return err
+	}
+	ctx, err := direct.ReadContext(opts.OutputFile)
+	if err != nil {
+		return fmt.Errorf("failed to read output to check its PDF version: %w", err)
+	}
+	if err := pdfversion.Check(ctx, target); err != nil {
+		os.Remove(opts.OutputFile)
+		return err
+	}
+	return pdfversion.StampHeaderFile(opts.OutputFile, target)
+}
+
+// run converts the input, or only its sampled pages into a proof PDF
+func run(conv Converter, opts Options) error {
+	if !opts.Sample.Enabled() {
+		return conv.Convert(opts.InputFile, opts.OutputFile)
+	}
+
+	// Convert only the sampled pages into a proof PDF
+	samplePath, pages, err := sample.Extract(opts.InputFile, opts.Sample)
+	if err != nil {
+		return err
+	}
+	defer sample.Cleanup(samplePath)
+
+	fmt.Printf("  Proof: sampling %d page(s) %v (%s)\n", len(pages), pages, opts.Sample.Strategy)
+	if err := conv.Convert(samplePath, opts.OutputFile); err != nil {
+		return err
+	}
+
+	return sample.Verify(opts.OutputFile, len(pages))
+}
+
+// makePDFA runs the PDF/A pass on the output and reports what keeps it from conforming
+func makePDFA(opts Options) error {
+	fmt.Println("  Making output PDF/A-2b...")
+	problems, err := pdfa.ConvertFile(opts.OutputFile, pdfa.Options{
+		PDFVersion:  opts.PDFVersion,
+		ViewerHints: opts.ViewerHints,
+		Scheme:      opts.ColorScheme,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, problem := range problems {
+		report.Warnf("PDF/A: %s", problem)
+	}
+	if len(problems) > 0 {
+		report.Warnf("the output does not conform to PDF/A-2b and is not marked as PDF/A")
+		return nil
+	}
+	fmt.Println("        Marked as PDF/A-2b")
+	return nil
+}
+
+// newRasterEngine creates a raster engine configured from opts
+func newRasterEngine(opts Options) *raster.Engine {
+	engine := raster.NewEngine(opts.DPI, opts.ColorScheme)
+	engine.SetPDFVersion(opts.PDFVersion)
+	engine.SetKeepStructure(opts.KeepStructure)
+	engine.SetRemaps(opts.Remaps)
+	if opts.ColorMetric != "" {
+		engine.SetColorMetric(opts.ColorMetric, opts.MetricTol)
+	}
+	engine.SetRenderer(opts.Renderer)
+	engine.SetViewerHints(opts.ViewerHints)
+	if opts.Intensity != nil {
+		tune := intensity.Raster(*opts.Intensity)
+		engine.SetSnap(tune.SnapWhite, tune.SnapBlack)
+		engine.SetColorLightness(tune.MinColorL, tune.MaxColorL)
+		engine.SetSaturationBoost(tune.SatBoost)
+	}
+	engine.SetSnap(opts.SnapNearWhite, opts.SnapNearBlack)
+	engine.SetAutoOrient(opts.AutoOrient)
+	engine.SetCleanEdges(opts.CleanEdges)
+	engine.SetProtectedInks(opts.ProtectInks)
+	engine.SetGradientBackground(opts.Gradient)
+	engine.SetTextRegionsOnly(opts.TextRegions)
+	engine.SetCMYK(opts.CMYK)
+	engine.SetFlattenTransparency(opts.Flatten)
+	engine.SetOutputSizeLimits(opts.WarnSize, opts.MaxSize, opts.ConfirmSize)
+	engine.SetMaxRenderTime(opts.MaxRenderTime)
+	engine.SetRenderCache(opts.CacheDir)
+	engine.SetRightToLeft(opts.RTL)
+	engine.SetColorLightness(opts.MinColorL, opts.MaxColorL)
+	engine.SetPreserveWhite(opts.PreserveWhite)
+	engine.SetColorTolerance(opts.ColorTolerance)
+	engine.SetOnlyPages(opts.OnlyPages)
+	return engine
+}
+
+// newDirectEngine creates a direct engine configured from opts
+func newDirectEngine(opts Options) *direct.Engine {
+	engine := direct.NewEngine(opts.PreserveImages, opts.ColorScheme)
+	engine.SetPDFVersion(opts.PDFVersion)
+	engine.SetSinglePass(opts.SinglePass)
+	engine.SetRemaps(opts.Remaps)
+	if opts.ColorMetric != "" {
+		engine.SetColorMetric(opts.ColorMetric, opts.MetricTol)
+	}
+	engine.SetViewerHints(opts.ViewerHints)
+	if opts.Intensity != nil {
+		tune := intensity.Direct(*opts.Intensity)
+		engine.SetColorLightness(tune.MinColorL, tune.MaxColorL)
+		engine.SetSaturationBoost(tune.SatBoost)
+	}
+	engine.SetColorLightness(opts.MinColorL, opts.MaxColorL)
+	engine.SetNormalizeRotation(opts.NormalizeRot)
+	engine.SetICCProfiles(opts.ICCProfiles)
+	engine.SetLayers(opts.Layers)
+	engine.SetGradientBackground(opts.Gradient)
+	engine.SetSanitize(opts.Sanitize)
+	engine.SetIncremental(opts.Incremental)
+	engine.SetCompatOperators(opts.CompatOps)
+	engine.SetNoRecompress(opts.NoRecompress)
+	engine.SetColorTolerance(opts.ColorTolerance)
+	engine.SetBackgroundImage(opts.Texture)
+	engine.SetRespectExistingBackground(opts.RespectDarkBg)
+	engine.SetBackgroundMargin(opts.BgMargin)
+	engine.SetStrict(opts.Strict)
+	engine.SetColorSpaceFilter(opts.OnlySpaces, opts.SkipSpaces)
+	engine.SetPreserveWhite(opts.PreserveWhite)
+	engine.SetStripeAware(opts.StripeAware)
+	engine.SetMapPrimaryText(opts.MapPrimary)
+	engine.SetCheckReadability(opts.Readability)
+	engine.SetAnalysisPages(opts.SamplePages)
+	engine.SetDefaultColorApply(opts.DefaultApply)
+	engine.SetOnlyPages(opts.OnlyPages)
+	engine.SetPostProcess(opts.PostProcess)
+	if opts.TintStrength != nil {
+		engine.SetTintStrength(*opts.TintStrength)
+	}
+	return engine
 }
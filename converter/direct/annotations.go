@@ -0,0 +1,158 @@
+package direct
+
+import (
+	"strconv"
+	"strings"
+
+	"pdfdarkmode/converter/report"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// processAnnotationAppearances transforms the colors of every page's annotations, such
+// as stamps, ink drawings and shapes: their /C and /IC colors, which viewers use when
+// they regenerate the appearance, and their normal appearance streams (/AP /N), which
+// viewers draw. Both a single appearance stream and a dictionary of appearance states
+// (e.g. a checkbox's /On and /Off) are handled. Streams shared by several annotations
+// are transformed once.
+// Returns the number of colors changed.
+func (e *Engine) processAnnotationAppearances(ctx *model.Context) int {
+	visited := make(map[int]bool)
+	count := 0
+	for pageNum := 1; pageNum <= ctx.PageCount; pageNum++ {
+		if !e.converts(pageNum) {
+			continue
+		}
+		pageDict, _, _, err := ctx.PageDict(pageNum, false)
+		if err != nil || pageDict == nil {
+			continue
+		}
+		annots, err := ctx.DereferenceArray(pageDict["Annots"])
+		if err != nil {
+			continue
+		}
+		for _, obj := range annots {
+			annot, err := ctx.DereferenceDict(obj)
+			if err != nil || annot == nil {
+				continue
+			}
+			for _, key := range []string{"C", "IC"} {
+				if e.transformAnnotColor(ctx, annot, key) {
+					count++
+				}
+			}
+			ap, err := ctx.DereferenceDict(annot["AP"])
+			if err != nil || ap == nil {
+				continue
+			}
+			count += e.processAppearance(ctx, ap["N"], visited)
+		}
+	}
+	return count
+}
+
+// annotColorOperators maps the component count of an annotation color array to the
+// stroke operator of its color space
+var annotColorOperators = map[int]string{1: "G", 3: "RG", 4: "K"}
+
+// transformAnnotColor transforms the color array under key in annot: /C, the border
+// and stroke color, as a stroke, or /IC, the interior color, as a fill. An empty array
+// (transparent) is left alone.
+// Returns true if the color was changed.
+func (e *Engine) transformAnnotColor(ctx *model.Context, annot types.Dict, key string) bool {
+	arr, err := ctx.DereferenceArray(annot[key])
+	if err != nil {
+		return false
+	}
+	operator, ok := annotColorOperators[len(arr)]
+	if !ok {
+		return false
+	}
+	if key == "IC" {
+		operator = strings.ToLower(operator)
+	}
+
+	values := make([]string, 0, len(arr)+1)
+	for _, obj := range arr {
+		switch v := obj.(type) {
+		case types.Integer:
+			values = append(values, strconv.Itoa(v.Value()))
+		case types.Float:
+			values = append(values, strconv.FormatFloat(v.Value(), 'f', -1, 64))
+		default:
+			return false
+		}
+	}
+	content, count := e.transformContent(strings.Join(append(values, operator), " "))
+	if count == 0 {
+		return false
+	}
+
+	ops := e.parser.FindColorOperators(content)
+	if len(ops) != 1 {
+		return false
+	}
+	color := make(types.Array, 0, len(ops[0].Values))
+	for _, v := range ops[0].Values {
+		color = append(color, types.Float(parseFloat(v)))
+	}
+	annot[key] = color
+	return true
+}
+
+// processAppearance transforms an appearance entry: a stream, or a dictionary mapping
+// appearance states to streams
+func (e *Engine) processAppearance(ctx *model.Context, obj types.Object, visited map[int]bool) int {
+	ref, ok := obj.(types.IndirectRef)
+	if !ok {
+		// Appearance states may sit in a direct dictionary; streams are always indirect
+		states, isDict := obj.(types.Dict)
+		if !isDict {
+			return 0
+		}
+		count := 0
+		for _, state := range states {
+			if stateRef, ok := state.(types.IndirectRef); ok {
+				count += e.processAppearanceStream(ctx, stateRef, visited)
+			}
+		}
+		return count
+	}
+
+	target, err := ctx.Dereference(ref)
+	if err != nil {
+		return 0
+	}
+	switch target := target.(type) {
+	case types.StreamDict:
+		return e.processAppearanceStream(ctx, ref, visited)
+	case types.Dict:
+		return e.processAppearance(ctx, target, visited)
+	}
+	return 0
+}
+
+// processAppearanceStream transforms one appearance stream, a form XObject whose named
+// color spaces come from its own /Resources
+func (e *Engine) processAppearanceStream(ctx *model.Context, ref types.IndirectRef, visited map[int]bool) int {
+	if visited[ref.ObjectNumber.Value()] {
+		return 0
+	}
+	visited[ref.ObjectNumber.Value()] = true
+
+	sd, _, err := ctx.DereferenceStreamDict(ref)
+	if err != nil || sd == nil {
+		return 0
+	}
+	resources, _ := ctx.DereferenceDict(sd.Dict["Resources"])
+	spaces := colorSpaces(ctx, resources)
+	state := initialColorSpaces(spaces)
+
+	count, err := e.processContentStream(ctx, ref, spaces, &state)
+	if err != nil {
+		report.Warnf("failed to process annotation appearance %d: %v", ref.ObjectNumber.Value(), err)
+		return 0
+	}
+	return count
+}
@@ -0,0 +1,98 @@
+package direct
+
+import (
+	"testing"
+
+	"pdfdarkmode/converter/colors"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// annotColor returns the components of d's color array under key
+func annotColor(t *testing.T, d types.Dict, key string) []float64 {
+	t.Helper()
+	arr, ok := d[key].(types.Array)
+	if !ok {
+		t.Fatalf("/%s is %v, want an array", key, d[key])
+	}
+	var components []float64
+	for _, obj := range arr {
+		f, ok := obj.(types.Float)
+		if !ok {
+			t.Fatalf("/%s component %v is not a transformed number", key, obj)
+		}
+		components = append(components, f.Value())
+	}
+	return components
+}
+
+// streamContent returns the decoded content of the stream at ref
+func streamContent(t *testing.T, ctx *model.Context, ref types.IndirectRef) string {
+	t.Helper()
+	sd, _, err := ctx.DereferenceStreamDict(ref)
+	if err != nil || sd == nil {
+		t.Fatalf("%v is not a stream: %v", ref, err)
+	}
+	if err := sd.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	return string(sd.Content)
+}
+
+func TestInkAnnotationColors(t *testing.T) {
+	ctx := newTestContext(t, nil, "")
+	const drawing = "0.2 G 2 w 10 10 m 50 50 l S"
+	ap := newTestStream(t, ctx, drawing)
+
+	// Two ink annotations share one appearance stream; the square has a gray interior
+	ink := types.Dict{
+		"Subtype": types.Name("Ink"),
+		"C":       types.Array{types.Integer(1), types.Integer(0), types.Integer(0)},
+		"AP":      types.Dict{"N": ap},
+	}
+	copied := types.Dict{
+		"Subtype": types.Name("Ink"),
+		"C":       types.Array{types.Float(0.8), types.Integer(0), types.Integer(0)},
+		"AP":      types.Dict{"N": ap},
+	}
+	square := types.Dict{
+		"Subtype": types.Name("Square"),
+		"C":       types.Array{},
+		"IC":      types.Array{types.Float(0.95)},
+	}
+	var annots types.Array
+	for _, annot := range []types.Dict{ink, copied, square} {
+		ref, err := ctx.IndRefForNewObject(annot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		annots = append(annots, *ref)
+	}
+	pageDict, _, _, err := ctx.PageDict(1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pageDict["Annots"] = annots
+
+	e := NewEngine(false, colors.SchemeDark)
+	// Both /C colors, the /IC color and the shared stream's one color, once
+	if count := e.processAnnotationAppearances(ctx); count != 4 {
+		t.Errorf("changed %d colors, want 4", count)
+	}
+
+	if c := annotColor(t, ink, "C"); len(c) != 3 || c[0] <= c[1] || c[0] <= c[2] || c[0] == 1 && c[1] == 0 {
+		t.Errorf("ink /C is %v, want a transformed red", c)
+	}
+	if c := annotColor(t, square, "IC"); len(c) != 1 || c[0] > 0.5 {
+		t.Errorf("square /IC is %v, want the near-white interior darkened", c)
+	}
+	if c := annotColor(t, square, "C"); len(c) != 0 {
+		t.Errorf("transparent /C became %v", c)
+	}
+
+	want, _ := NewEngine(false, colors.SchemeDark).transformContent(drawing)
+	if got := streamContent(t, ctx, ap); got != want {
+		t.Errorf("shared appearance stream is %q, want %q transformed once", got, want)
+	}
+}
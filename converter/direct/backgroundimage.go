@@ -0,0 +1,109 @@
+package direct
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg" // Register the JPEG decoder for LoadBackgroundImage
+	_ "image/png"  // Register the PNG decoder for LoadBackgroundImage
+	"os"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Resource names of the background image and the tiling pattern that repeats it
+const (
+	backgroundImageName   = "PDMTexture"
+	backgroundPatternName = "PDMTextureTile"
+)
+
+// LoadBackgroundImage reads a PNG or JPEG file for SetBackgroundImage
+func LoadBackgroundImage(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read background image: %w", err)
+	}
+	if _, _, err := image.DecodeConfig(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("background image %s is not a PNG or JPEG image: %w", path, err)
+	}
+	return data, nil
+}
+
+// SetBackgroundImage draws the image in data, e.g. a dark paper texture, over each
+// page's background and behind its content. Images smaller than the page, taking one
+// pixel as one point, are tiled from the page's lower left corner; larger ones are
+// scaled to cover the page, keeping their aspect ratio, and centered. The flat or
+// gradient background still shows through transparent areas.
+func (e *Engine) SetBackgroundImage(data []byte) {
+	e.texture = data
+}
+
+// backgroundImage is the background image embedded into one document
+type backgroundImage struct {
+	ref           types.IndirectRef
+	width, height float64
+	pattern       *types.IndirectRef // Tiling pattern, created when a page first tiles the image
+}
+
+// newBackgroundImage embeds the background image as an image XObject
+func (e *Engine) newBackgroundImage(ctx *model.Context) (*backgroundImage, error) {
+	ref, w, h, err := model.CreateImageResource(ctx.XRefTable, bytes.NewReader(e.texture))
+	if err != nil {
+		return nil, err
+	}
+	return &backgroundImage{ref: *ref, width: float64(w), height: float64(h)}, nil
+}
+
+// content returns the content that paints the image over box, adding the resources it
+// uses to the page
+func (img *backgroundImage) content(ctx *model.Context, pageDict types.Dict, inhPAttrs *model.InheritedPageAttrs, box *types.Rectangle) (string, error) {
+	if img.width < box.Width() && img.height < box.Height() {
+		if img.pattern == nil {
+			ref, err := img.newPattern(ctx)
+			if err != nil {
+				return "", err
+			}
+			img.pattern = ref
+		}
+		name, err := addResource(ctx, pageDict, inhPAttrs, "Pattern", backgroundPatternName, *img.pattern)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("q /Pattern cs /%s scn %.2f %.2f %.2f %.2f re f Q\n",
+			name, box.LL.X, box.LL.Y, box.Width(), box.Height()), nil
+	}
+
+	name, err := addResource(ctx, pageDict, inhPAttrs, "XObject", backgroundImageName, img.ref)
+	if err != nil {
+		return "", err
+	}
+	// Cover the box: scale by the larger ratio, center, and clip the overflow
+	scale := max(box.Width()/img.width, box.Height()/img.height)
+	w, h := img.width*scale, img.height*scale
+	x := box.LL.X + (box.Width()-w)/2
+	y := box.LL.Y + (box.Height()-h)/2
+	return fmt.Sprintf("q %.2f %.2f %.2f %.2f re W n %.4f 0 0 %.4f %.4f %.4f cm /%s Do Q\n",
+		box.LL.X, box.LL.Y, box.Width(), box.Height(), w, h, x, y, name), nil
+}
+
+// newPattern adds a tiling pattern that repeats the image at one point per pixel
+func (img *backgroundImage) newPattern(ctx *model.Context) (*types.IndirectRef, error) {
+	content := fmt.Sprintf("q %.0f 0 0 %.0f 0 0 cm /%s Do Q", img.width, img.height, backgroundImageName)
+	sd, err := ctx.NewStreamDictForBuf([]byte(content))
+	if err != nil {
+		return nil, err
+	}
+	sd.InsertName("Type", "Pattern")
+	sd.Insert("PatternType", types.Integer(1))
+	sd.Insert("PaintType", types.Integer(1))
+	sd.Insert("TilingType", types.Integer(1))
+	sd.Insert("BBox", types.NewNumberArray(0, 0, img.width, img.height))
+	sd.Insert("XStep", types.Float(img.width))
+	sd.Insert("YStep", types.Float(img.height))
+	sd.Insert("Resources", types.Dict{"XObject": types.Dict{backgroundImageName: img.ref}})
+	if err := sd.Encode(); err != nil {
+		return nil, err
+	}
+	return ctx.IndRefForNewObject(*sd)
+}
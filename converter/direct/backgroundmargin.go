@@ -0,0 +1,22 @@
+package direct
+
+import "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+
+// SetBackgroundMargin grows the added background past the media box by margin points
+// on every side, to bleed, or insets it by a negative margin, leaving a border of the
+// original page around it. Zero matches the media box.
+func (e *Engine) SetBackgroundMargin(margin float64) {
+	e.bgMargin = margin
+}
+
+// backgroundBox returns box grown by the background margin. An inset is limited to half
+// the box's width and height, so on pages smaller than twice the inset the background
+// shrinks to nothing rather than turning inside out.
+func (e *Engine) backgroundBox(box *types.Rectangle) *types.Rectangle {
+	if e.bgMargin == 0 {
+		return box
+	}
+	dx := max(e.bgMargin, -box.Width()/2)
+	dy := max(e.bgMargin, -box.Height()/2)
+	return types.NewRectangle(box.LL.X-dx, box.LL.Y-dy, box.UR.X+dx, box.UR.Y+dy)
+}
@@ -0,0 +1,38 @@
+package direct
+
+// maxBinaryShare is the share of a decoded stream's operator bytes (those outside
+// strings, comments and inline image data) that may be binary before the stream is
+// not taken for page content
+const maxBinaryShare = 0.05
+
+// looksLikeContent reports whether decoded stream data is a content stream rather than
+// binary data, such as a stream whose filter output was misidentified. Outside strings,
+// comments and inline image data, content is text: almost no byte is a control
+// character other than whitespace or above '~'. A literal string left open at the end
+// of the data is also taken as binary.
+func looksLikeContent(content string) bool {
+	binary, total, pos := 0, 0, 0
+	for _, r := range append(quotedRanges(content), [2]int{len(content), len(content)}) {
+		for i := pos; i < r[0]; i++ {
+			total++
+			if isBinaryByte(content[i]) {
+				binary++
+			}
+		}
+		pos = max(pos, r[1])
+		if r[0] < r[1] && r[1] == len(content) && content[r[0]] == '(' && content[r[1]-1] != ')' {
+			return false
+		}
+	}
+	return float64(binary) <= maxBinaryShare*float64(total)
+}
+
+// isBinaryByte reports whether c has no place among content stream operators and
+// operands
+func isBinaryByte(c byte) bool {
+	switch c {
+	case '\t', '\n', '\f', '\r':
+		return false
+	}
+	return c < ' ' || c > '~'
+}
@@ -0,0 +1,278 @@
+package direct
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// colorSpaceOther marks a resolved color space whose operands are not device colors
+// (Lab, Indexed, Separation, DeviceN); its sc/scn operators are left alone
+const colorSpaceOther = "other"
+
+// colorSpacePattern marks the Pattern color space of colored patterns, whose scn only
+// names the pattern. Like colorSpaceOther its operands are left alone, but pattern
+// fills are expected and not reported as unhandled.
+const colorSpacePattern = "pattern"
+
+// patternPrefix marks an uncolored tiling pattern space, e.g. [/Pattern /DeviceRGB],
+// by the parser's color space of its base: "pattern-rgb". Its scn operands are a
+// color in the base space followed by the pattern name.
+const patternPrefix = "pattern-"
+
+// patternBase returns the base color space of an uncolored pattern space, or ""
+func patternBase(space string) string {
+	base, ok := strings.CutPrefix(space, patternPrefix)
+	if !ok {
+		return ""
+	}
+	return base
+}
+
+// ColorSpaceNames are the color spaces of the operators the engine transforms
+var ColorSpaceNames = []string{"gray", "rgb", "cmyk"}
+
+// ParseColorSpaces parses a comma-separated list of ColorSpaceNames, e.g. "rgb,cmyk"
+func ParseColorSpaces(list string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		known := false
+		for _, n := range ColorSpaceNames {
+			known = known || n == name
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown color space: %s (must be one of %s)", name, strings.Join(ColorSpaceNames, ", "))
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// deviceColorSpaces maps color space family names to the parser's color space
+var deviceColorSpaces = map[string]string{
+	"DeviceGray": "gray",
+	"CalGray":    "gray",
+	"DeviceRGB":  "rgb",
+	"CalRGB":     "rgb",
+	"DeviceCMYK": "cmyk",
+	"G":          "gray", // Inline image abbreviations
+	"RGB":        "rgb",
+	"CMYK":       "cmyk",
+}
+
+// defaultColorSpaces maps device color space names to the resource names that
+// replace them on a page, e.g. /DefaultRGB for /DeviceRGB
+var defaultColorSpaces = map[string]string{
+	"DeviceGray": "DefaultGray",
+	"DeviceRGB":  "DefaultRGB",
+	"DeviceCMYK": "DefaultCMYK",
+}
+
+// ColorSpaceState holds the fill and stroke color spaces in effect, as "gray", "rgb",
+// "cmyk", an uncolored pattern space, colorSpacePattern, colorSpaceOther, or "" when
+// unknown
+type ColorSpaceState struct {
+	Fill, Stroke string
+}
+
+// initialColorSpaces returns the state a content stream starts in: DeviceGray for fill
+// and stroke, or whatever the resources' /DefaultGray replaces it with
+func initialColorSpaces(spaces map[string]string) ColorSpaceState {
+	gray := lookupColorSpace("DeviceGray", spaces)
+	return ColorSpaceState{Fill: gray, Stroke: gray}
+}
+
+// implicitColorSpaces reports whether content sets a fill or stroke color with sc/scn
+// (SC/SCN) before selecting a color space for it with cs/CS, rg, g or k, relying on
+// the initial DeviceGray. q/Q nesting is not tracked.
+func implicitColorSpaces(content string) (fill, stroke bool) {
+	fillSelected, strokeSelected := false, false
+	walkOperators(content, func(op string, operands []float64, name string, text int) {
+		switch op {
+		case "cs", "rg", "g", "k":
+			fillSelected = true
+		case "CS", "RG", "G", "K":
+			strokeSelected = true
+		case "sc", "scn":
+			fill = fill || !fillSelected
+			fillSelected = true
+		case "SC", "SCN":
+			stroke = stroke || !strokeSelected
+			strokeSelected = true
+		}
+	})
+	return fill, stroke
+}
+
+// lookupColorSpace resolves a cs/CS operand: a device color space, replaced by its
+// /Default entry in spaces if there is one, a resource name in spaces, or the Pattern
+// family, whose scn operands name a pattern (with the colors of an uncolored one)
+func lookupColorSpace(name string, spaces map[string]string) string {
+	if def, ok := defaultColorSpaces[name]; ok {
+		if space, ok := spaces[def]; ok {
+			return space
+		}
+		return deviceColorSpaces[name]
+	}
+	if space, ok := spaces[name]; ok {
+		return space
+	}
+	if name == "Pattern" {
+		return colorSpacePattern
+	}
+	return deviceColorSpaces[name]
+}
+
+// componentSpaces maps a number of color components, such as the /N of an ICC profile
+// or the operands of sc/scn, to the parser's color space
+var componentSpaces = map[int]string{
+	1: "gray",
+	3: "rgb",
+	4: "cmyk",
+}
+
+// colorSpaces resolves the named color spaces in a resource dictionary, e.g. /CS0,
+// to "gray", "rgb", "cmyk", an uncolored pattern space, colorSpacePattern or
+// colorSpaceOther. ICCBased
+// spaces are classified by the /N component count of their profile stream.
+func colorSpaces(ctx *model.Context, resources types.Dict) map[string]string {
+	if resources == nil {
+		return nil
+	}
+
+	csDict, err := ctx.DereferenceDict(resources["ColorSpace"])
+	if err != nil || csDict == nil {
+		return nil
+	}
+
+	spaces := make(map[string]string, len(csDict))
+	for name, obj := range csDict {
+		if space := resolveColorSpace(ctx, obj); space != "" {
+			spaces[name] = space
+		}
+	}
+	return spaces
+}
+
+// pageColorSpaces resolves the named color spaces of a page: those in resources, the
+// page's own or inherited /Resources, plus names defined only in the /Resources of an
+// ancestor /Pages node. Conforming readers take the nearest /Resources whole, but some
+// producers expect resource dictionaries to merge down the page tree; classifying
+// those names beats guessing from the operand count.
+func pageColorSpaces(ctx *model.Context, pageDict, resources types.Dict) map[string]string {
+	spaces := colorSpaces(ctx, resources)
+
+	visited := make(map[int]bool)
+	parent := pageDict["Parent"]
+	for parent != nil {
+		ref, ok := parent.(types.IndirectRef)
+		if !ok || visited[ref.ObjectNumber.Value()] {
+			break
+		}
+		visited[ref.ObjectNumber.Value()] = true
+
+		node, err := ctx.DereferenceDict(ref)
+		if err != nil || node == nil {
+			break
+		}
+		res, err := ctx.DereferenceDict(node["Resources"])
+		if err == nil {
+			for name, space := range colorSpaces(ctx, res) {
+				if _, found := spaces[name]; found {
+					continue
+				}
+				if spaces == nil {
+					spaces = make(map[string]string)
+				}
+				spaces[name] = space
+			}
+		}
+		parent = node["Parent"]
+	}
+
+	return spaces
+}
+
+// resolveColorSpace classifies a single color space object, or returns "" if unknown
+func resolveColorSpace(ctx *model.Context, obj types.Object) string {
+	obj, err := ctx.Dereference(obj)
+	if err != nil || obj == nil {
+		return ""
+	}
+
+	switch cs := obj.(type) {
+	case types.Name:
+		if space, ok := deviceColorSpaces[cs.Value()]; ok {
+			return space
+		}
+		if cs.Value() == "Pattern" {
+			return colorSpacePattern
+		}
+		return colorSpaceOther
+
+	case types.Array:
+		if len(cs) == 0 {
+			return ""
+		}
+		family, ok := cs[0].(types.Name)
+		if !ok {
+			return ""
+		}
+		if space, ok := deviceColorSpaces[family.Value()]; ok {
+			return space
+		}
+		if family.Value() == "Pattern" && len(cs) == 1 {
+			return colorSpacePattern
+		}
+		if family.Value() == "Pattern" {
+			// An uncolored pattern takes its colors in the base space
+			switch base := resolveColorSpace(ctx, cs[1]); base {
+			case "gray", "rgb", "cmyk":
+				return patternPrefix + base
+			}
+			return colorSpaceOther
+		}
+		if family.Value() != "ICCBased" || len(cs) < 2 {
+			return colorSpaceOther
+		}
+
+		profile, _, err := ctx.DereferenceStreamDict(cs[1])
+		if err != nil || profile == nil {
+			return ""
+		}
+		n, err := ctx.DereferenceInteger(profile.Dict["N"])
+		if err != nil || n == nil {
+			return ""
+		}
+		if space, ok := componentSpaces[n.Value()]; ok {
+			return space
+		}
+		return colorSpaceOther
+	}
+
+	return ""
+}
+
+// unhandledColorSpaces returns the names content selects with cs/CS whose operators
+// are left unchanged: spaces such as Separation, DeviceN or Lab, uncolored patterns
+// over them, and names that cannot be resolved. Colored pattern fills are not listed.
+func (p *Parser) unhandledColorSpaces(content string, spaces map[string]string) []string {
+	var names []string
+	quoted := quotedRanges(content)
+	for _, match := range p.csPattern.FindAllStringSubmatchIndex(content, -1) {
+		if overlaps(quoted, match[0], match[1]) {
+			continue
+		}
+		name := content[match[2]:match[3]]
+		if space := lookupColorSpace(name, spaces); space == "" || space == colorSpaceOther {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,153 @@
+package direct
+
+import (
+	"testing"
+
+	"pdfdarkmode/converter/colors"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+func TestICCBasedSetColorKeepsOperandCount(t *testing.T) {
+	content := "/CS1 cs 0 sc 0 0 10 10 re f\n" +
+		"/CS3 CS 0 0 0 SC 0 0 10 10 re S\n" +
+		"/CS4 cs 0 0 0 1 scn 0 0 10 10 re f\n" +
+		"/DeviceCMYK CS 0 0 0 1 SC 0 0 10 10 re S\n"
+
+	for _, name := range []string{"sepia", "reading-light", "dark"} {
+		t.Run(name, func(t *testing.T) {
+			scheme, err := colors.GetScheme(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := newTestContext(t, nil, content)
+			pageDict, _, _, err := ctx.PageDict(1, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pageDict["Resources"] = types.Dict{"ColorSpace": types.Dict{
+				"CS1": newTestICCSpace(t, ctx, 1),
+				"CS3": newTestICCSpace(t, ctx, 3),
+				"CS4": newTestICCSpace(t, ctx, 4),
+			}}
+			spaces := colorSpaces(ctx, pageDict["Resources"].(types.Dict))
+			for name, want := range map[string]string{"CS1": "gray", "CS3": "rgb", "CS4": "cmyk"} {
+				if spaces[name] != want {
+					t.Errorf("color space %s resolved to %q, want %q", name, spaces[name], want)
+				}
+			}
+
+			e := NewEngine(false, scheme)
+			count, err := e.processPage(ctx, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != 4 {
+				t.Errorf("transformed %d operators, want 4", count)
+			}
+
+			// Every color in the output must have the operands its color space takes.
+			// Gray colors may be written as rg in a tinted scheme, which selects DeviceRGB.
+			out := testPageContent(t, ctx, 1)
+			p := NewParser()
+			state := initialColorSpaces(spaces)
+			operators := p.FindColorOperators(out)
+			resolved := p.ResolveColorSpaces(out, operators, spaces, &state)
+			if len(resolved) != len(operators) {
+				t.Errorf("output has colors with the wrong operand count:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestUnhandledColorSpaces(t *testing.T) {
+	spaces := map[string]string{
+		"P0":   colorSpacePattern,
+		"P1":   patternPrefix + "rgb",
+		"Sep":  colorSpaceOther,
+		"ICC3": "rgb",
+	}
+	content := "/Pattern cs /Pat scn /P0 cs /Pat scn /P1 CS 1 0 0 /Pat SCN /ICC3 cs /Sep CS /Missing cs (/Lab cs) Tj"
+	got := NewParser().unhandledColorSpaces(content, spaces)
+	want := []string{"Sep", "Missing"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("unhandled color spaces %q, want %q", got, want)
+	}
+}
+
+// insertPagesNode moves the pages of ctx under a new intermediate /Pages node with
+// resources
+func insertPagesNode(t *testing.T, ctx *model.Context, resources types.Dict) {
+	t.Helper()
+	rootRef := ctx.RootDict["Pages"].(types.IndirectRef)
+	root, err := ctx.DereferenceDict(rootRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := types.Dict{
+		"Type":      types.Name("Pages"),
+		"Parent":    rootRef,
+		"Kids":      root["Kids"],
+		"Count":     root["Count"],
+		"Resources": resources,
+	}
+	nodeRef, err := ctx.IndRefForNewObject(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, kid := range root["Kids"].(types.Array) {
+		page, err := ctx.DereferenceDict(kid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		page["Parent"] = *nodeRef
+	}
+	root["Kids"] = types.Array{*nodeRef}
+}
+
+func TestPageColorSpacesFromPagesNode(t *testing.T) {
+	content := "/CS4 cs 0 0 0 1 sc 0 0 10 10 re f\n/CS1 CS 0 SC 0 0 10 10 re S\n"
+
+	t.Run("inherited resources", func(t *testing.T) {
+		ctx := newTestContext(t, nil, content)
+		insertPagesNode(t, ctx, types.Dict{"ColorSpace": types.Dict{
+			"CS1": newTestICCSpace(t, ctx, 1),
+			"CS4": newTestICCSpace(t, ctx, 4),
+		}})
+
+		e := NewEngine(false, colors.DefaultScheme())
+		count, err := e.processPage(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 2 {
+			t.Errorf("transformed %d operators, want 2", count)
+		}
+	})
+
+	t.Run("merged with page resources", func(t *testing.T) {
+		ctx := newTestContext(t, nil, content)
+		insertPagesNode(t, ctx, types.Dict{"ColorSpace": types.Dict{
+			"CS1": newTestICCSpace(t, ctx, 3),
+			"CS4": newTestICCSpace(t, ctx, 4),
+		}})
+		pageDict, _, _, err := ctx.PageDict(1, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pageDict["Resources"] = types.Dict{"ColorSpace": types.Dict{"CS1": newTestICCSpace(t, ctx, 1)}}
+		pageDict, _, inhPAttrs, err := ctx.PageDict(1, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		spaces := pageColorSpaces(ctx, pageDict, inhPAttrs.Resources)
+		// The page's own CS1 wins over the node's
+		for name, want := range map[string]string{"CS1": "gray", "CS4": "cmyk"} {
+			if spaces[name] != want {
+				t.Errorf("color space %s resolved to %q, want %q", name, spaces[name], want)
+			}
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package direct
+
+import (
+	"strings"
+)
+
+// deviceOperatorSpaces maps the device color operators to the color space they select
+var deviceOperatorSpaces = map[string]string{
+	"g": "DeviceGray", "G": "DeviceGray",
+	"rg": "DeviceRGB", "RG": "DeviceRGB",
+	"k": "DeviceCMYK", "K": "DeviceCMYK",
+}
+
+// SetCompatOperators writes transformed colors with the generic sc/SC operators in an
+// explicitly selected device color space (e.g. "/DeviceRGB cs 0.1 0.1 0.1 sc")
+// instead of rg, g and k. The color space is only selected where the one in effect
+// differs. Form field /DA strings keep the device operators viewers expect there.
+func (e *Engine) SetCompatOperators(enabled bool) {
+	e.compatOps = enabled
+}
+
+// selectedSpaces holds the names of the fill and stroke color spaces selected by a
+// content stream, "" when unknown
+type selectedSpaces struct {
+	fill, stroke string
+}
+
+// genericOperators rewrites a content stream's transformed device color operators in
+// generic form. It follows q/Q and cs/CS through the content, together with the color
+// operators it is given, so it knows which color space is selected at each operator.
+type genericOperators struct {
+	content string
+	quoted  [][2]int
+	pos     int // Content before pos has been followed
+	spaces  selectedSpaces
+	saved   []selectedSpaces // Saved by q
+}
+
+// newGenericOperators prepares to rewrite content. What earlier content streams
+// selected is not known, so the first color in each stream selects its space.
+func newGenericOperators(content string) *genericOperators {
+	return &genericOperators{content: content, quoted: quotedRanges(content)}
+}
+
+// write returns the replacement out for op, which must come after the previous one
+// written. Device operators in out become cs/CS plus sc/SC, unless out is op unchanged.
+func (w *genericOperators) write(op ColorOperator, out string) string {
+	w.advance(op.StartPos)
+	w.pos = op.EndPos
+
+	fields := strings.Fields(out)
+	operator := fields[len(fields)-1]
+	space, ok := deviceOperatorSpaces[operator]
+	if !ok {
+		return out // sc/scn in the color space already selected
+	}
+	current, setSpace, setColor := &w.spaces.fill, "cs", "sc"
+	if operator != strings.ToLower(operator) {
+		current, setSpace, setColor = &w.spaces.stroke, "CS", "SC"
+	}
+
+	if out == op.FullMatch {
+		*current = space
+		return out
+	}
+
+	values := strings.Join(fields[:len(fields)-1], " ")
+	if *current == space {
+		return values + " " + setColor
+	}
+	*current = space
+	return "/" + space + " " + setSpace + " " + values + " " + setColor
+}
+
+// advance follows the q, Q, cs and CS operators in content up to end, skipping
+// strings, comments and inline images
+func (w *genericOperators) advance(end int) {
+	name := ""
+	for i := w.pos; i < end; {
+		if len(w.quoted) > 0 && w.quoted[0][0] <= i {
+			if w.quoted[0][1] > i {
+				i = w.quoted[0][1]
+			}
+			w.quoted = w.quoted[1:]
+			continue
+		}
+		c := w.content[i]
+		if !isRegular(c) && c != '/' {
+			i++
+			continue
+		}
+
+		start := i
+		for i++; i < len(w.content) && isRegular(w.content[i]); i++ {
+		}
+		token := w.content[start:i]
+		if c == '/' {
+			name = token[1:]
+			continue
+		}
+
+		switch token {
+		case "q":
+			w.saved = append(w.saved, w.spaces)
+		case "Q":
+			w.spaces = selectedSpaces{}
+			if n := len(w.saved); n > 0 {
+				w.spaces = w.saved[n-1]
+				w.saved = w.saved[:n-1]
+			}
+		case "cs":
+			w.spaces.fill = name
+		case "CS":
+			w.spaces.stroke = name
+		}
+		name = ""
+	}
+	w.pos = end
+}
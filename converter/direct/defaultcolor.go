@@ -0,0 +1,59 @@
+package direct
+
+import (
+	"fmt"
+	"strings"
+
+	"pdfdarkmode/converter/colormath"
+)
+
+// Default colors set to the scheme's text color before a page's content
+const (
+	DefaultColorFill   = "fill"   // Text and shapes drawn without a fill color
+	DefaultColorStroke = "stroke" // Lines and borders drawn without a stroke color
+	DefaultColorBoth   = "both"
+)
+
+// ParseDefaultColorApply checks a DefaultColorFill, DefaultColorStroke or
+// DefaultColorBoth setting, returning it in lower case
+func ParseDefaultColorApply(target string) (string, error) {
+	target = strings.ToLower(strings.TrimSpace(target))
+	switch target {
+	case DefaultColorFill, DefaultColorStroke, DefaultColorBoth:
+		return target, nil
+	}
+	return "", fmt.Errorf("unknown default color target: %s (must be %s, %s or %s)",
+		target, DefaultColorFill, DefaultColorStroke, DefaultColorBoth)
+}
+
+// SetDefaultColorApply sets which of a page's default colors become the scheme's text
+// color: DefaultColorFill, so uncolored text turns light while uncolored rules and
+// borders keep the default black stroke, DefaultColorStroke, or DefaultColorBoth, the
+// default (also for "")
+func (e *Engine) SetDefaultColorApply(target string) {
+	e.defaultApply = target
+}
+
+// defaultColors returns the operators setting the page's default colors. With grayFill
+// or grayStroke, for pages that use sc/scn in the initial DeviceGray, that default is
+// the gray of the text color, so DeviceGray stays selected.
+func (e *Engine) defaultColors(grayFill, grayStroke bool) string {
+	txt := e.colorScheme.Text
+	gray := colormath.Lightness(txt.R, txt.G, txt.B)
+	var ops []string
+	if e.defaultApply != DefaultColorStroke {
+		if grayFill {
+			ops = append(ops, fmt.Sprintf("%.3f g", gray))
+		} else {
+			ops = append(ops, fmt.Sprintf("%.3f %.3f %.3f rg", txt.R, txt.G, txt.B))
+		}
+	}
+	if e.defaultApply != DefaultColorFill {
+		if grayStroke {
+			ops = append(ops, fmt.Sprintf("%.3f G", gray))
+		} else {
+			ops = append(ops, fmt.Sprintf("%.3f %.3f %.3f RG", txt.R, txt.G, txt.B))
+		}
+	}
+	return strings.Join(ops, " ") + "\n"
+}
@@ -0,0 +1,46 @@
+package direct
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"pdfdarkmode/converter/colors"
+)
+
+func TestDefaultColorApply(t *testing.T) {
+	txt := colors.SchemeDark.Text
+	fill := fmt.Sprintf("%.3f %.3f %.3f rg", txt.R, txt.G, txt.B)
+
+	tests := []struct {
+		apply  string
+		fill   bool // The page starts with the text color as its fill
+		stroke bool // The page sets any stroke color
+	}{
+		{DefaultColorFill, true, false},
+		{DefaultColorStroke, false, true},
+		{DefaultColorBoth, true, true},
+		{"", true, true},
+	}
+
+	for _, tt := range tests {
+		ctx := newTestContext(t, nil, "BT /F1 12 Tf (text) Tj ET 0 0 m 10 10 l S")
+		e := NewEngine(false, colors.SchemeDark)
+		e.SetDefaultColorApply(tt.apply)
+		if _, err := e.addPageBackground(ctx, 1, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		out := testPageContent(t, ctx, 1)
+		if got := strings.Contains(out, fill); got != tt.fill {
+			t.Errorf("%q: sets the text fill %v, want %v:\n%s", tt.apply, got, tt.fill, out)
+		}
+		stroke := false
+		for _, op := range NewParser().FindColorOperators(out) {
+			stroke = stroke || op.IsStroke
+		}
+		if stroke != tt.stroke {
+			t.Errorf("%q: sets a stroke color %v, want %v:\n%s", tt.apply, stroke, tt.stroke, out)
+		}
+	}
+}
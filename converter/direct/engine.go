@@ -3,8 +3,15 @@ package direct
 import (
 	"fmt"
 	"os"
+	"runtime/debug"
+	"strings"
 
 	"pdfdarkmode/converter/colors"
+	"pdfdarkmode/converter/icc"
+	"pdfdarkmode/converter/pdfversion"
+	"pdfdarkmode/converter/report"
+	"pdfdarkmode/converter/sanitize"
+	"pdfdarkmode/converter/viewerhints"
 
 	"github.com/pdfcpu/pdfcpu/pkg/api"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
@@ -17,8 +24,37 @@ type Engine struct {
 	parser         *Parser
 	transformer    *Transformer
 	colorScheme    colors.Scheme
+	pdfVersion     string                         // Target output PDF version, empty keeps the source version
+	singlePass     bool                           // Drop decoded stream buffers as soon as each page is done
+	viewerHints    bool                           // Mark the output as dark-themed for viewers
+	normalizeRot   bool                           // Bake /Rotate into the page content
+	iccProfiles    []icc.Profile                  // Profiles tagged as page default gray/RGB color spaces
+	layers         bool                           // Keep the original content as a toggleable layer
+	gradient       bool                           // Fill pages with a background gradient instead of a flat color
+	texture        []byte                         // PNG or JPEG image drawn over the page background, nil for none
+	keepExistingBg bool                           // Add no background to pages that start with a full-page dark fill
+	bgMargin       float64                        // Points the background extends past the media box, negative to inset
+	onlyPages      string                         // parity.Odd or parity.Even to convert only those pages, empty for all
+	compatOps      bool                           // Write transformed colors with cs/sc instead of rg, g and k
+	mapPrimary     bool                           // Map the most common dark fill color exactly to the scheme text
+	readability    bool                           // Warn about pages whose text is mostly very small or thin
+	analysisPages  int                            // Pages spread over the document that analyses look at, 0 for all
+	defaultApply   string                         // DefaultColorFill, DefaultColorStroke or DefaultColorBoth ("" for both)
+	noRecompress   bool                           // Keep the source's stream filters and file compression
+	strict         bool                           // Warn about everything left unchanged
+	unhandled      map[string]int                 // Selections of color spaces left unchanged, by name, in strict mode
+	unhandledIn    map[string][]int               // Content streams selecting each unhandled color space, in strict mode
+	sanitize       bool                           // Strip scripts and automatic actions from the output
+	incremental    bool                           // Append changes to the original bytes instead of rewriting
+	postProcess    func(ctx *model.Context) error // Edits the converted document just before it is written, nil for none
+	distinctColors map[string]bool                // Distinct colors transformed, for the summary
+	includeSpaces  map[string]bool                // Color spaces whose operators are transformed, nil for all
+	filtered       map[string]int                 // Operators left alone by the color space filter, by space
 }
 
+// freeMemoryInterval is how many pages are processed between returning memory to the OS in single-pass mode
+const freeMemoryInterval = 50
+
 // NewEngine creates a new direct manipulation engine
 func NewEngine(preserveImages bool, scheme colors.Scheme) *Engine {
 	return &Engine{
@@ -29,14 +65,226 @@ func NewEngine(preserveImages bool, scheme colors.Scheme) *Engine {
 	}
 }
 
+// SetPDFVersion sets the PDF version written to the output file (e.g. "1.5")
+func (e *Engine) SetPDFVersion(version string) {
+	e.pdfVersion = version
+}
+
+// SetRemaps sets exact color remaps applied before the color scheme
+func (e *Engine) SetRemaps(remaps []colors.Remap) {
+	e.transformer.SetRemaps(remaps)
+}
+
+// SetColorMetric sets the distance metric and tolerance for matching remap sources
+func (e *Engine) SetColorMetric(metric string, tolerance float64) {
+	e.transformer.SetColorMetric(metric, tolerance)
+}
+
+// SetPostProcess sets a function called with the converted document just before it is
+// written, after the backgrounds, layers and rotation are in place. An error from it
+// fails the conversion. Nil (the default) writes the document as converted.
+func (e *Engine) SetPostProcess(fn func(ctx *model.Context) error) {
+	e.postProcess = fn
+}
+
+// SetViewerHints enables writing a dark theme hint into the output metadata
+func (e *Engine) SetViewerHints(enabled bool) {
+	e.viewerHints = enabled
+}
+
+// SetICCProfiles tags the output's default gray and RGB color spaces with the given
+// profiles, making device colors color-managed. Nil leaves colors untagged.
+func (e *Engine) SetICCProfiles(profiles []icc.Profile) {
+	e.iccProfiles = profiles
+}
+
+// SetSanitize strips JavaScript and automatic actions (/OpenAction, /AA) from the output
+func (e *Engine) SetSanitize(enabled bool) {
+	e.sanitize = enabled
+}
+
+// SetIncremental writes the output as the original file followed by an incremental
+// update with only the changed objects, keeping signatures valid where the input allows
+func (e *Engine) SetIncremental(enabled bool) {
+	e.incremental = enabled
+}
+
+// SetColorSpaceFilter transforms only operators in the only color spaces (all of
+// ColorSpaceNames if empty) that are not in skip, leaving the others as they are
+func (e *Engine) SetColorSpaceFilter(only, skip []string) {
+	if len(only) == 0 && len(skip) == 0 {
+		e.includeSpaces = nil
+		return
+	}
+	if len(only) == 0 {
+		only = ColorSpaceNames
+	}
+	e.includeSpaces = make(map[string]bool, len(only))
+	for _, space := range only {
+		e.includeSpaces[space] = true
+	}
+	for _, space := range skip {
+		delete(e.includeSpaces, space)
+	}
+}
+
+// SetLayers keeps each page's original content alongside the dark content as two
+// optional content groups, so viewers can toggle between them
+func (e *Engine) SetLayers(enabled bool) {
+	e.layers = enabled
+}
+
+// SetStripeAware keeps light gray fills such as zebra-striped table rows apart from
+// the background
+func (e *Engine) SetStripeAware(enabled bool) {
+	e.transformer.SetStripeAware(enabled)
+}
+
+// SetNormalizeRotation enables baking /Rotate into page content so pages are
+// upright even in tools that ignore /Rotate
+func (e *Engine) SetNormalizeRotation(enabled bool) {
+	e.normalizeRot = enabled
+}
+
+// SetColorLightness sets the lightness floor and ceiling for colorful values
+func (e *Engine) SetColorLightness(min, max float64) {
+	e.transformer.SetColorLightness(min, max)
+}
+
+// SetPreserveWhite keeps document colors lighter than threshold as they are, so white
+// areas the content paints stay white on the dark background. Zero converts them.
+func (e *Engine) SetPreserveWhite(threshold float64) {
+	e.transformer.SetPreserveWhite(threshold)
+}
+
+// SetSaturationBoost sets the factor colorful colors' saturation is multiplied by, see
+// Transformer.SetSaturationBoost
+func (e *Engine) SetSaturationBoost(boost float64) {
+	e.transformer.SetSaturationBoost(boost)
+}
+
+// SetTintStrength sets how much of a tinted scheme's tint converted grays keep (0-1)
+func (e *Engine) SetTintStrength(strength float64) {
+	e.transformer.SetTintStrength(strength)
+}
+
+// SetColorTolerance blends the document and colorful mappings near the boundary between
+// them, see Transformer.SetColorTolerance
+func (e *Engine) SetColorTolerance(tolerance float64) {
+	e.transformer.SetColorTolerance(tolerance)
+}
+
+// SetSinglePass bounds peak memory by releasing decoded stream content right after
+// each stream is re-encoded. pdfcpu needs the whole cross-reference table to write a
+// PDF, so pages cannot be streamed to disk individually; this keeps only the encoded
+// form of each stream in memory instead.
+func (e *Engine) SetSinglePass(singlePass bool) {
+	e.singlePass = singlePass
+}
+
 // Convert performs direct PDF manipulation to convert to dark mode
 func (e *Engine) Convert(inputPath, outputPath string) error {
+	if e.incremental && e.pdfVersion != "" {
+		return fmt.Errorf("--pdf-version cannot be used with incremental output, which keeps the original header")
+	}
+
 	fmt.Println("  [1/4] Reading PDF structure...")
+	ctx, err := readContext(inputPath)
+	if err != nil {
+		return err
+	}
+
+	// Fingerprint the objects as read, before anything is repaired or transformed
+	var before objectFingerprints
+	if e.incremental {
+		if reason := incrementUnsupported(ctx); reason != "" {
+			report.Warnf("writing a full copy instead of an incremental update: %s", reason)
+		} else {
+			before = fingerprintObjects(ctx)
+		}
+	}
 
+	if err := countPages(ctx); err != nil {
+		return err
+	}
+
+	fmt.Printf("        PDF version: %s, Pages: %d\n", ctx.HeaderVersion, ctx.PageCount)
+
+	fmt.Println("  [2/4] Processing page content streams...")
+	var originals map[int]types.Array
+	if e.layers {
+		if originals, err = snapshotContents(ctx); err != nil {
+			return fmt.Errorf("failed to copy original content: %w", err)
+		}
+	}
+	e.Transform(ctx)
+
+	fmt.Println("  [3/4] Adding dark background to pages...")
+	if err := e.addDarkBackgrounds(ctx); err != nil {
+		report.Warnf("could not add backgrounds: %v", err)
+	}
+
+	if e.layers {
+		if err := addLayers(ctx, originals); err != nil {
+			return fmt.Errorf("failed to add layers: %w", err)
+		}
+		fmt.Println("        Added \"Original\" and \"Dark mode\" layers")
+	}
+
+	// Rotation is baked in last so it applies to the background and both layers
+	if e.normalizeRot {
+		if count := e.NormalizeRotations(ctx); count > 0 {
+			fmt.Printf("        Normalized rotation of %d pages\n", count)
+		}
+	}
+
+	if len(e.iccProfiles) > 0 {
+		count, err := icc.Tag(ctx, e.iccProfiles)
+		if err != nil {
+			return fmt.Errorf("failed to tag ICC profiles: %w", err)
+		}
+		fmt.Printf("        Tagged default color spaces of %d pages with ICC profiles\n", count)
+	}
+
+	if e.postProcess != nil {
+		if err := e.postProcess(ctx); err != nil {
+			return fmt.Errorf("post-processing failed: %w", err)
+		}
+	}
+
+	fmt.Println("  [4/4] Writing output PDF...")
+	if before != nil {
+		if err := e.prepareWrite(ctx); err != nil {
+			return err
+		}
+		count, err := e.writeIncrement(ctx, inputPath, outputPath, before)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("        Appended %d changed objects as an incremental update\n", count)
+		return nil
+	}
+	return e.Write(ctx, outputPath)
+}
+
+// ReadContext reads a PDF into a pdfcpu context with relaxed validation and a reliable page count
+func ReadContext(inputPath string) (*model.Context, error) {
+	ctx, err := readContext(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := countPages(ctx); err != nil {
+		return nil, err
+	}
+	return ctx, nil
+}
+
+// readContext reads a PDF into a pdfcpu context with relaxed validation
+func readContext(inputPath string) (*model.Context, error) {
 	// Read the PDF file
 	f, err := os.Open(inputPath)
 	if err != nil {
-		return fmt.Errorf("failed to open input file: %w", err)
+		return nil, fmt.Errorf("failed to open input file: %w", err)
 	}
 	defer f.Close()
 
@@ -47,39 +295,87 @@ func (e *Engine) Convert(inputPath, outputPath string) error {
 	// Parse the PDF using the api package
 	ctx, err := api.ReadContext(f, conf)
 	if err != nil {
-		return fmt.Errorf("failed to parse PDF: %w", err)
+		return nil, fmt.Errorf("failed to parse PDF: %w", err)
 	}
 
-	// Ensure page count is calculated
-	if err := ctx.EnsurePageCount(); err != nil {
+	return ctx, nil
+}
+
+// countPages ensures the page count is calculated, walking the page tree if /Count is wrong
+func countPages(ctx *model.Context) error {
+	if err := ensurePageCount(ctx); err != nil {
 		return fmt.Errorf("failed to determine page count: %w", err)
 	}
+	return nil
+}
 
-	fmt.Printf("        PDF version: %s, Pages: %d\n", ctx.HeaderVersion, ctx.PageCount)
-
-	fmt.Println("  [2/4] Processing page content streams...")
+// Transform converts the colors of every page's content streams, annotation
+// appearances and form default appearances in ctx, reporting progress as it goes
+func (e *Engine) Transform(ctx *model.Context) {
 	pagesProcessed := 0
 	colorsTransformed := 0
+	e.distinctColors = make(map[string]bool)
+	e.filtered = make(map[string]int)
+	e.unhandled = make(map[string]int)
+	e.unhandledIn = make(map[string][]int)
+
+	if e.mapPrimary {
+		e.applyPrimaryText(ctx)
+	}
+	if e.readability {
+		e.warnPoorReadability(ctx)
+	}
 
 	// Process each page
+	skipped := 0
+	report.StartPages()
 	for pageNum := 1; pageNum <= ctx.PageCount; pageNum++ {
+		if !e.converts(pageNum) {
+			skipped++
+			continue
+		}
 		count, err := e.processPage(ctx, pageNum)
+		report.PageDone(pageNum, ctx.PageCount)
 		if err != nil {
-			fmt.Printf("        Warning: failed to process page %d: %v\n", pageNum, err)
+			report.Warnf("failed to process page %d: %v", pageNum, err)
 			continue
 		}
 		pagesProcessed++
 		colorsTransformed += count
+		report.RecordTransforms(pageNum, count)
+
+		if e.singlePass && pageNum%freeMemoryInterval == 0 {
+			debug.FreeOSMemory()
+		}
 	}
 
-	fmt.Printf("        Processed %d pages, transformed %d color operations\n", pagesProcessed, colorsTransformed)
+	fmt.Printf("        Processed %d pages, transformed %d color operations (%d distinct colors)\n",
+		pagesProcessed, colorsTransformed, len(e.distinctColors))
+	if skipped > 0 {
+		fmt.Printf("        Left %d pages unchanged (converting %s pages only)\n", skipped, e.onlyPages)
+	}
+	if summary := e.filteredSummary(); summary != "" {
+		fmt.Printf("        Left %s\n", summary)
+	}
+	e.warnUnhandled(colorsTransformed)
 
-	fmt.Println("  [3/4] Adding dark background to pages...")
-	if err := e.addDarkBackgrounds(ctx); err != nil {
-		fmt.Printf("        Warning: could not add backgrounds: %v\n", err)
+	if count := e.processAnnotationAppearances(ctx); count > 0 {
+		fmt.Printf("        Transformed %d color operations in annotations\n", count)
 	}
+	if count := e.processFormDefaults(ctx); count > 0 {
+		fmt.Printf("        Transformed %d form default appearance strings\n", count)
+	}
+}
 
-	fmt.Println("  [4/4] Writing output PDF...")
+// Write writes ctx to outputPath, adding viewer hints and targeting the
+// configured PDF version
+func (e *Engine) Write(ctx *model.Context, outputPath string) error {
+	if err := e.prepareWrite(ctx); err != nil {
+		return err
+	}
+	if e.noRecompress {
+		keepFileCompression(ctx)
+	}
 
 	// Write the modified PDF
 	outFile, err := os.Create(outputPath)
@@ -92,17 +388,84 @@ func (e *Engine) Convert(inputPath, outputPath string) error {
 		return fmt.Errorf("failed to write PDF: %w", err)
 	}
 
+	if e.pdfVersion != "" {
+		if err := pdfversion.StampHeader(outFile, *ctx.HeaderVersion); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// prepareWrite sanitizes ctx, adds viewer hints and targets the configured PDF version
+func (e *Engine) prepareWrite(ctx *model.Context) error {
+	if e.sanitize {
+		if stripped := sanitize.Apply(ctx); stripped.Removed() {
+			fmt.Printf("        Sanitized: removed %s\n", describeSanitized(stripped))
+		}
+	}
+
+	if e.viewerHints {
+		removed, err := viewerhints.Apply(ctx, e.colorScheme)
+		if err != nil {
+			report.Warnf("could not add viewer hints: %v", err)
+		} else if removed {
+			fmt.Println("        Removed script /OpenAction")
+		}
+	}
+
+	// Target a specific PDF version if requested
+	if e.pdfVersion != "" {
+		target, err := pdfversion.Parse(e.pdfVersion)
+		if err != nil {
+			return err
+		}
+		if err := pdfversion.Apply(ctx, target); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// describeSanitized lists what sanitizing removed, e.g. "/OpenAction, 2 /AA entries"
+func describeSanitized(r sanitize.Report) string {
+	var parts []string
+	if r.OpenAction {
+		parts = append(parts, "/OpenAction")
+	}
+	if r.DocumentAA {
+		parts = append(parts, "document /AA")
+	}
+	if r.DocumentScripts {
+		parts = append(parts, "document JavaScript")
+	}
+	if r.AdditionalActions > 0 {
+		parts = append(parts, fmt.Sprintf("%d /AA entries", r.AdditionalActions))
+	}
+	if r.ScriptActions > 0 {
+		parts = append(parts, fmt.Sprintf("%d JavaScript link actions", r.ScriptActions))
+	}
+	return strings.Join(parts, ", ")
+}
+
 // processPage processes a single page's content streams
 func (e *Engine) processPage(ctx *model.Context, pageNum int) (int, error) {
 	// Get the page dictionary
-	pageDict, _, _, err := ctx.PageDict(pageNum, false)
+	pageDict, _, inhPAttrs, err := ctx.PageDict(pageNum, false)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get page dict: %w", err)
 	}
 
+	// Resolve named color spaces so sc/scn operands are classified correctly
+	var resources types.Dict
+	if inhPAttrs != nil {
+		resources = inhPAttrs.Resources
+	}
+	spaces := pageColorSpaces(ctx, pageDict, resources)
+	// The page's content streams share one graphics state
+	state := initialColorSpaces(spaces)
+
 	// Get the Contents entry
 	contentsEntry, found := pageDict.Find("Contents")
 	if !found {
@@ -115,7 +478,7 @@ func (e *Engine) processPage(ctx *model.Context, pageNum int) (int, error) {
 	switch contents := contentsEntry.(type) {
 	case types.IndirectRef:
 		// Single content stream
-		count, err := e.processContentStream(ctx, contents)
+		count, err := e.processContentStream(ctx, contents, spaces, &state)
 		if err != nil {
 			return 0, err
 		}
@@ -125,7 +488,7 @@ func (e *Engine) processPage(ctx *model.Context, pageNum int) (int, error) {
 		// Array of content streams
 		for _, item := range contents {
 			if ref, ok := item.(types.IndirectRef); ok {
-				count, err := e.processContentStream(ctx, ref)
+				count, err := e.processContentStream(ctx, ref, spaces, &state)
 				if err != nil {
 					continue
 				}
@@ -137,11 +500,14 @@ func (e *Engine) processPage(ctx *model.Context, pageNum int) (int, error) {
 	return totalTransformed, nil
 }
 
-// processContentStream processes a single content stream
-func (e *Engine) processContentStream(ctx *model.Context, ref types.IndirectRef) (int, error) {
+// processContentStream processes a single content stream.
+// spaces maps the page's named color spaces to their device color space, and state
+// carries the color spaces in effect from the page's previous content stream.
+func (e *Engine) processContentStream(ctx *model.Context, ref types.IndirectRef, spaces map[string]string, state *ColorSpaceState) (int, error) {
 	// Get the stream object
 	obj, err := ctx.Dereference(ref)
 	if err != nil {
+		*state = ColorSpaceState{}
 		return 0, err
 	}
 
@@ -150,8 +516,12 @@ func (e *Engine) processContentStream(ctx *model.Context, ref types.IndirectRef)
 		return 0, nil
 	}
 
-	// Decode the stream content
+	// Decode the stream content; what a skipped stream selects is unknown
 	if err := sd.Decode(); err != nil {
+		*state = ColorSpaceState{}
+		if e.strict {
+			report.Warnf("content stream %d could not be decoded and was left unchanged: %v", ref.ObjectNumber.Value(), err)
+		}
 		return 0, nil // Skip streams we can't decode
 	}
 
@@ -159,38 +529,33 @@ func (e *Engine) processContentStream(ctx *model.Context, ref types.IndirectRef)
 	if content == nil {
 		return 0, nil
 	}
-
-	// Find and transform color operators
-	operators := e.parser.FindColorOperators(string(content))
-	if len(operators) == 0 {
+	if !looksLikeContent(string(content)) {
+		*state = ColorSpaceState{}
+		report.Warnf("stream %d does not look like page content (binary data) and was left unchanged", ref.ObjectNumber.Value())
 		return 0, nil
 	}
+	e.noteUnhandled(string(content), spaces, ref.ObjectNumber.Value())
 
-	// Build replacement map
-	replacements := make(map[string]string)
-	for _, op := range operators {
-		newOp := e.transformer.TransformOperator(op)
-		if newOp != op.FullMatch {
-			replacements[op.FullMatch] = newOp
-		}
-	}
-
-	if len(replacements) == 0 {
+	// Find and transform color operators
+	newContent, count := e.transformContentIn(string(content), spaces, state, e.compatOps)
+	if count == 0 {
 		return 0, nil
 	}
 
-	// Apply replacements
-	newContent := e.parser.ReplaceColorOperators(string(content), replacements)
-
-	// Re-encode the stream using pdfcpu's Encode method
+	// Re-encode the stream
 	sd.Content = []byte(newContent)
-	if err := sd.Encode(); err != nil {
+	if err := e.encodeStream(&sd); err != nil {
 		return 0, fmt.Errorf("failed to encode stream: %w", err)
 	}
 
 	// Update length in dictionary
 	sd.Dict["Length"] = types.Integer(len(sd.Raw))
 
+	// Only the encoded form is needed for writing
+	if e.singlePass {
+		sd.Content = nil
+	}
+
 	// Update the object in the context
 	entry, found := ctx.FindTableEntryForIndRef(&ref)
 	if !found {
@@ -198,25 +563,140 @@ func (e *Engine) processContentStream(ctx *model.Context, ref types.IndirectRef)
 	}
 	entry.Object = sd
 
-	return len(replacements), nil
+	return count, nil
+}
+
+// transformContent transforms all color operators in content.
+// Returns the new content and the number of operators replaced.
+func (e *Engine) transformContent(content string) (string, int) {
+	state := initialColorSpaces(nil)
+	return e.transformContentIn(content, nil, &state, false)
+}
+
+// TransformContent transforms content as a page content stream without color space
+// resources, writing colors as SetCompatOperators selects. It runs the core transform
+// without a PDF, for debugging schemes and for examples.
+func (e *Engine) TransformContent(content string) (string, int) {
+	state := initialColorSpaces(nil)
+	return e.transformContentIn(content, nil, &state, e.compatOps)
+}
+
+// transformContentIn is like transformContent, using spaces to classify sc/scn operators
+// and continuing from the color spaces in state. With generic, transformed colors are
+// written with cs/sc as set by SetCompatOperators.
+func (e *Engine) transformContentIn(content string, spaces map[string]string, state *ColorSpaceState, generic bool) (string, int) {
+	operators := e.parser.ResolveColorSpaces(content, e.parser.FindColorOperators(content), spaces, state)
+	if len(operators) == 0 {
+		return content, 0
+	}
+
+	var writer *genericOperators
+	if generic {
+		writer = newGenericOperators(content)
+	}
+
+	count := 0
+	result := e.parser.RewriteColorOperators(content, operators, func(op ColorOperator) string {
+		newOp := e.transformOperator(op)
+		if newOp != op.FullMatch {
+			count++
+			if e.distinctColors != nil {
+				e.distinctColors[colorKey(op)] = true
+			}
+		}
+		if writer != nil {
+			return writer.write(op, newOp)
+		}
+		return newOp
+	})
+
+	if count == 0 {
+		return content, 0
+	}
+	return result, count
+}
+
+// transformOperator transforms op unless the color space filter excludes it
+func (e *Engine) transformOperator(op ColorOperator) string {
+	if e.includeSpaces != nil && !e.includeSpaces[op.ColorSpace] {
+		if e.filtered != nil {
+			e.filtered[op.ColorSpace]++
+		}
+		return op.FullMatch
+	}
+	return e.transformer.TransformOperator(op)
+}
+
+// filteredSummary describes the operators the color space filter left alone, e.g.
+// "12 operators in excluded color spaces unchanged (gray 10, cmyk 2)", or "" if none
+func (e *Engine) filteredSummary() string {
+	total := 0
+	var parts []string
+	for _, space := range ColorSpaceNames {
+		if n := e.filtered[space]; n > 0 {
+			total += n
+			parts = append(parts, fmt.Sprintf("%s %d", space, n))
+		}
+	}
+	if total == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d operators in excluded color spaces unchanged (%s)", total, strings.Join(parts, ", "))
+}
+
+// AddBackgrounds adds the dark background to every page selected for conversion, as
+// Convert does after Transform
+func (e *Engine) AddBackgrounds(ctx *model.Context) error {
+	return e.addDarkBackgrounds(ctx)
 }
 
-// addDarkBackgrounds adds a dark background rectangle to each page
+// addDarkBackgrounds adds a dark background rectangle, or gradient, to each page
 func (e *Engine) addDarkBackgrounds(ctx *model.Context) error {
+	var shading *types.IndirectRef
+	if e.gradient {
+		ref, err := e.newGradientShading(ctx)
+		if err != nil {
+			return err
+		}
+		shading = ref
+	}
+	var texture *backgroundImage
+	if e.texture != nil {
+		img, err := e.newBackgroundImage(ctx)
+		if err != nil {
+			report.Warnf("could not embed the background image, using a plain background: %v", err)
+		}
+		texture = img
+	}
+
+	kept := 0
 	for pageNum := 1; pageNum <= ctx.PageCount; pageNum++ {
-		if err := e.addPageBackground(ctx, pageNum); err != nil {
-			fmt.Printf("        Warning: page %d background failed: %v\n", pageNum, err)
+		if !e.converts(pageNum) {
 			continue
 		}
+		existing, err := e.addPageBackground(ctx, pageNum, shading, texture)
+		if err != nil {
+			report.Warnf("page %d background failed: %v", pageNum, err)
+			continue
+		}
+		if existing {
+			kept++
+		}
+	}
+	if kept > 0 {
+		fmt.Printf("        Kept the existing dark background of %d pages\n", kept)
 	}
 	return nil
 }
 
-// addPageBackground adds a dark background to a single page by PREPENDING to content
-func (e *Engine) addPageBackground(ctx *model.Context, pageNum int) error {
+// addPageBackground adds a dark background to a single page in a new content stream
+// drawn before the others. With a shading, the page is filled with it instead of the
+// flat background color; a texture is drawn over either. Reports whether the page's own
+// dark background was kept instead, see SetRespectExistingBackground.
+func (e *Engine) addPageBackground(ctx *model.Context, pageNum int, shading *types.IndirectRef, texture *backgroundImage) (bool, error) {
 	pageDict, _, inhPAttrs, err := ctx.PageDict(pageNum, false)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	// Get MediaBox - try page dict first, then inherited attributes
@@ -239,76 +719,80 @@ func (e *Engine) addPageBackground(ctx *model.Context, pageNum int) error {
 		mediaBox = types.NewRectangle(0, 0, 612, 792)
 	}
 
-	// Create background content - this will be PREPENDED to draw behind existing content
-	// 1. Draw dark background rectangle using configured colors
+	// Create background content - this is drawn first, behind existing content
+	// 1. Draw dark background rectangle using configured colors, unless the page
+	//    already starts with its own dark one
 	// 2. Set default text/fill color using configured text color
 	// 3. Set default stroke color to text color
 	// This ensures any text without explicit color uses light color on dark background
-	bg := e.colorScheme.Background
-	txt := e.colorScheme.Text
-	bgContent := fmt.Sprintf("q %.3f %.3f %.3f rg %.2f %.2f %.2f %.2f re f Q %.3f %.3f %.3f rg %.3f %.3f %.3f RG\n",
-		bg.R, bg.G, bg.B,
-		mediaBox.LL.X, mediaBox.LL.Y, mediaBox.Width(), mediaBox.Height(),
-		txt.R, txt.G, txt.B,
-		txt.R, txt.G, txt.B)
-
-	// Get the Contents entry
-	contentsEntry, found := pageDict.Find("Contents")
-	if !found {
-		// No content - just add background
-		return ctx.AppendContent(pageDict, []byte(bgContent))
-	}
-
-	// Handle single content stream - prepend background to it
-	switch contents := contentsEntry.(type) {
-	case types.IndirectRef:
-		return e.prependToStream(ctx, contents, []byte(bgContent))
-	case types.Array:
-		if len(contents) > 0 {
-			if ref, ok := contents[0].(types.IndirectRef); ok {
-				return e.prependToStream(ctx, ref, []byte(bgContent))
+	// (see SetDefaultColorApply)
+	existing := e.keepExistingBg && e.hasDarkBackground(ctx, pageDict, mediaBox)
+	grayFill, grayStroke := implicitColorSpaces(pageContent(ctx, pageDict))
+	bgContent := ""
+	if !existing {
+		content, err := e.backgroundContent(ctx, pageDict, inhPAttrs, e.backgroundBox(mediaBox), shading, texture)
+		if err != nil {
+			return false, err
+		}
+		bgContent = content
+		if group := knockoutGroup(ctx, pageDict); group != nil {
+			if err := wrapKnockoutContent(ctx, pageDict, inhPAttrs, group, mediaBox); err != nil {
+				return false, fmt.Errorf("failed to move knockout group content: %w", err)
 			}
 		}
 	}
+	bgContent += e.defaultColors(grayFill, grayStroke)
 
-	return nil
-}
-
-// prependToStream prepends content to a stream
-func (e *Engine) prependToStream(ctx *model.Context, ref types.IndirectRef, prefix []byte) error {
-	obj, err := ctx.Dereference(ref)
+	// The background gets its own stream in front of the page's content, so existing
+	// streams are left as they are even if one does not end on an operator boundary
+	ref, err := ctx.StreamDictIndRef([]byte(bgContent))
 	if err != nil {
-		return err
+		return false, err
 	}
-
-	sd, ok := obj.(types.StreamDict)
-	if !ok {
-		return fmt.Errorf("not a stream dict")
+	switch contents := pageDict["Contents"].(type) {
+	case types.IndirectRef:
+		// The reference may be to an array of streams rather than to a stream
+		obj, err := ctx.Dereference(contents)
+		if err != nil {
+			return false, err
+		}
+		if arr, ok := obj.(types.Array); ok {
+			pageDict["Contents"] = append(types.Array{*ref}, arr...)
+		} else {
+			pageDict["Contents"] = types.Array{*ref, contents}
+		}
+	case types.Array:
+		pageDict["Contents"] = append(types.Array{*ref}, contents...)
+	default:
+		pageDict["Contents"] = *ref
 	}
 
-	// Decode existing content
-	if err := sd.Decode(); err != nil {
-		return err
-	}
+	return existing, nil
+}
 
-	// Prepend the background
-	newContent := append(prefix, sd.Content...)
+// backgroundContent returns the content filling box with the background color or
+// shading, with the texture drawn over it
+func (e *Engine) backgroundContent(ctx *model.Context, pageDict types.Dict, inhPAttrs *model.InheritedPageAttrs, box *types.Rectangle, shading *types.IndirectRef, texture *backgroundImage) (string, error) {
+	bg := e.colorScheme.Background
+	bgContent := fmt.Sprintf("q %.3f %.3f %.3f rg %.2f %.2f %.2f %.2f re f Q\n",
+		bg.R, bg.G, bg.B,
+		box.LL.X, box.LL.Y, box.Width(), box.Height())
 
-	// Re-encode
-	sd.Content = newContent
-	if err := sd.Encode(); err != nil {
-		return err
+	if shading != nil {
+		name, err := addResource(ctx, pageDict, inhPAttrs, "Shading", gradientName, *shading)
+		if err != nil {
+			return "", err
+		}
+		// The shading spans the unit square, so scale it to the media box and clip to it
+		bgContent = fmt.Sprintf("q %.2f 0 0 %.2f %.2f %.2f cm 0 0 1 1 re W n /%s sh Q\n",
+			box.Width(), box.Height(), box.LL.X, box.LL.Y, name)
 	}
-
-	// Update length
-	sd.Dict["Length"] = types.Integer(len(sd.Raw))
-
-	// Update in context
-	entry, found := ctx.FindTableEntryForIndRef(&ref)
-	if !found {
-		return fmt.Errorf("could not find xref entry")
+	if texture != nil {
+		content, err := texture.content(ctx, pageDict, inhPAttrs, box)
+		if err != nil {
+			return "", err
+		}
+		bgContent += content
 	}
-	entry.Object = sd
-
-	return nil
+	return bgContent, nil
 }
@@ -0,0 +1,173 @@
+package direct
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pdfdarkmode/converter/colors"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+func TestAddPageBackgroundContents(t *testing.T) {
+	tests := []struct {
+		name    string
+		content func(t *testing.T, ctx *model.Context) types.Object
+		streams int // Content streams after the background is added
+	}{
+		{"stream", func(t *testing.T, ctx *model.Context) types.Object {
+			return newTestStream(t, ctx, "0 g")
+		}, 2},
+		{"array", func(t *testing.T, ctx *model.Context) types.Object {
+			return types.Array{newTestStream(t, ctx, "0 g"), newTestStream(t, ctx, "1 g")}
+		}, 3},
+		{"reference to array", func(t *testing.T, ctx *model.Context) types.Object {
+			ref, err := ctx.IndRefForNewObject(types.Array{newTestStream(t, ctx, "0 g"), newTestStream(t, ctx, "1 g")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return *ref
+		}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t, nil, "")
+			pageDict, _, _, err := ctx.PageDict(1, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pageDict["Contents"] = tt.content(t, ctx)
+
+			e := NewEngine(false, colors.SchemeDark)
+			if _, err := e.addPageBackground(ctx, 1, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			contents, ok := pageDict["Contents"].(types.Array)
+			if !ok {
+				t.Fatalf("/Contents is %T, want an array", pageDict["Contents"])
+			}
+			if len(contents) != tt.streams {
+				t.Errorf("/Contents has %d entries, want %d", len(contents), tt.streams)
+			}
+			for i, obj := range contents {
+				if sd, _, err := ctx.DereferenceStreamDict(obj); err != nil || sd == nil {
+					t.Errorf("/Contents entry %d is not a stream: %v", i, err)
+				}
+			}
+		})
+	}
+}
+
+func TestBackgroundKeepsInitialGray(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string // Default colors in the background stream
+	}{
+		{"0.5 sc 0 0 10 10 re f", "0.878 g 0.878 0.878 0.878 RG"},
+		{"0.5 SC 0 0 m 10 10 l S", "0.878 0.878 0.878 rg 0.878 G"},
+		{"/DeviceRGB cs 0 0 1 sc", "0.878 0.878 0.878 rg 0.878 0.878 0.878 RG"},
+	}
+
+	for _, tt := range tests {
+		ctx := newTestContext(t, nil, tt.content)
+		e := NewEngine(false, colors.SchemeDark)
+		if _, err := e.addPageBackground(ctx, 1, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		out := testPageContent(t, ctx, 1)
+		if !strings.Contains(out, tt.want+"\n") {
+			t.Errorf("background for %q does not set %q:\n%s", tt.content, tt.want, out)
+		}
+		if len(resolvedOperators(out, nil)) != len(NewParser().FindColorOperators(out)) {
+			t.Errorf("sc/scn for %q no longer fit the color space in effect:\n%s", tt.content, out)
+		}
+	}
+}
+
+func TestSanitizedOutputHasNoJavaScript(t *testing.T) {
+	ctx := newTestContext(t, nil, "0 g 72 72 100 100 re f")
+	script := types.Dict{"S": types.Name("JavaScript"), "JS": types.StringLiteral("app.alert('hi')")}
+	ctx.RootDict["OpenAction"] = script
+	ctx.RootDict["AA"] = types.Dict{"WC": script.Clone()}
+	jsTree := types.Dict{"Names": types.Array{types.StringLiteral("init"), script.Clone()}}
+	ctx.RootDict["Names"] = types.Dict{"JavaScript": jsTree}
+	pageDict, _, _, err := ctx.PageDict(1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pageDict["AA"] = types.Dict{"O": script.Clone()}
+	input := writeTestPDF(t, ctx)
+
+	output := filepath.Join(t.TempDir(), "out.pdf")
+	e := NewEngine(false, colors.SchemeDark)
+	e.SetSanitize(true)
+	if err := e.Convert(input, output); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := ReadContext(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"OpenAction", "AA"} {
+		if _, found := out.RootDict[key]; found {
+			t.Errorf("output catalog still has /%s", key)
+		}
+	}
+	if names, err := out.DereferenceDict(out.RootDict["Names"]); err == nil && names != nil {
+		if _, found := names["JavaScript"]; found {
+			t.Error("output still has the /JavaScript name tree")
+		}
+	}
+	page, _, _, err := out.PageDict(1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found := page["AA"]; found {
+		t.Error("output page still has /AA")
+	}
+	for objNum, entry := range out.Table {
+		if entry == nil || entry.Free {
+			continue
+		}
+		if d, ok := entry.Object.(types.Dict); ok {
+			if s := d.NameEntry("S"); s != nil && *s == "JavaScript" {
+				t.Errorf("output object %d is a JavaScript action", objNum)
+			}
+		}
+	}
+}
+
+func BenchmarkProcessPages(b *testing.B) {
+	contents := make([]string, 10)
+	for i := range contents {
+		contents[i] = benchmarkContent(500)
+	}
+
+	for _, singlePass := range []bool{false, true} {
+		name := "buffered"
+		if singlePass {
+			name = "single-pass"
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				ctx := newTestContext(b, nil, contents...)
+				e := NewEngine(false, colors.SchemeDark)
+				e.SetSinglePass(singlePass)
+				b.StartTimer()
+				for pageNum := 1; pageNum <= ctx.PageCount; pageNum++ {
+					if _, err := e.processPage(ctx, pageNum); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
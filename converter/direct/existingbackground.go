@@ -0,0 +1,156 @@
+package direct
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"pdfdarkmode/converter/colormath"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Existing background detection
+const (
+	leadingOperators  = 16  // Operators at the start of a page searched for a full-page fill
+	fullPageTolerance = 1.0 // Points a fill may fall short of the media box on each side
+)
+
+// strokeOperators set stroke colors and line styles, which do not affect a fill
+var strokeOperators = map[string]bool{
+	"G": true, "RG": true, "K": true, "SC": true, "SCN": true,
+	"w": true, "J": true, "j": true, "M": true, "d": true, "ri": true, "i": true,
+}
+
+// SetRespectExistingBackground leaves out the added background rectangle, gradient and
+// texture on pages whose content starts by filling the whole page with a dark color,
+// such as slide decks and dark-designed pages. The default text colors are still set.
+func (e *Engine) SetRespectExistingBackground(enabled bool) {
+	e.keepExistingBg = enabled
+}
+
+// hasDarkBackground reports whether the page's first content stream, as transformed,
+// fills mediaBox with a color closer to the scheme's background than to its text
+// before drawing anything else
+func (e *Engine) hasDarkBackground(ctx *model.Context, pageDict types.Dict, mediaBox *types.Rectangle) bool {
+	var ref types.IndirectRef
+	switch contents := pageDict["Contents"].(type) {
+	case types.IndirectRef:
+		ref = contents
+	case types.Array:
+		if len(contents) == 0 {
+			return false
+		}
+		r, ok := contents[0].(types.IndirectRef)
+		if !ok {
+			return false
+		}
+		ref = r
+	default:
+		return false
+	}
+
+	sd, _, err := ctx.DereferenceStreamDict(ref)
+	if err != nil || sd == nil || sd.Decode() != nil {
+		return false
+	}
+	r, g, b, ok := leadingFill(string(sd.Content), mediaBox)
+	if !ok {
+		return false
+	}
+
+	l := colormath.Lightness(r, g, b)
+	bg, txt := e.colorScheme.Background, e.colorScheme.Text
+	return math.Abs(l-colormath.Lightness(bg.R, bg.G, bg.B)) < math.Abs(l-colormath.Lightness(txt.R, txt.G, txt.B))
+}
+
+// leadingFill returns the fill color of content's first painting operator if it fills a
+// rectangle covering box. Only graphics state, color and rectangle operators may come
+// before it; anything else, such as text, images, other paths or a transparency
+// setting, means the page does not start with a plain full-page fill.
+func leadingFill(content string, box *types.Rectangle) (r, g, b float64, ok bool) {
+	type graphicsState struct {
+		ctm       matrix
+		r, g, b   float64
+		fillSpace string
+	}
+	ctm := matrix{1, 0, 0, 1, 0, 0}
+	var saved []graphicsState
+	fillSpace := "DeviceGray"
+	var rect *types.Rectangle
+	var operands []float64
+
+	fields := strings.Fields(content)
+	for i, ops := 0, 0; i < len(fields) && ops < leadingOperators; i++ {
+		token := fields[i]
+		if v, err := strconv.ParseFloat(token, 64); err == nil {
+			operands = append(operands, v)
+			continue
+		}
+		if strings.HasPrefix(token, "/") {
+			if len(fields) > i+1 && (fields[i+1] == "cs" || fields[i+1] == "CS") {
+				if fields[i+1] == "cs" {
+					fillSpace = token[1:]
+					r, g, b = 0, 0, 0
+				}
+				i++
+				ops++
+				operands = nil
+				continue
+			}
+			return 0, 0, 0, false
+		}
+
+		ops++
+		n := len(operands)
+		switch {
+		case token == "q":
+			saved = append(saved, graphicsState{ctm, r, g, b, fillSpace})
+		case token == "Q":
+			if len(saved) == 0 {
+				return 0, 0, 0, false
+			}
+			gs := saved[len(saved)-1]
+			ctm, r, g, b, fillSpace = gs.ctm, gs.r, gs.g, gs.b, gs.fillSpace
+			saved = saved[:len(saved)-1]
+		case token == "cm" && n == 6:
+			ctm = matrix{operands[0], operands[1], operands[2], operands[3], operands[4], operands[5]}.concat(ctm)
+		case token == "g" && n == 1:
+			r, g, b = operands[0], operands[0], operands[0]
+			fillSpace = "DeviceGray"
+		case token == "rg" && n == 3:
+			r, g, b = operands[0], operands[1], operands[2]
+			fillSpace = "DeviceRGB"
+		case token == "k" && n == 4:
+			r, g, b = colormath.CMYKToRGB(operands[0], operands[1], operands[2], operands[3])
+			fillSpace = "DeviceCMYK"
+		case (token == "sc" || token == "scn") && fillSpace == "DeviceGray" && n == 1:
+			r, g, b = operands[0], operands[0], operands[0]
+		case (token == "sc" || token == "scn") && fillSpace == "DeviceRGB" && n == 3:
+			r, g, b = operands[0], operands[1], operands[2]
+		case (token == "sc" || token == "scn") && fillSpace == "DeviceCMYK" && n == 4:
+			r, g, b = colormath.CMYKToRGB(operands[0], operands[1], operands[2], operands[3])
+		case token == "re" && n == 4 && rect == nil:
+			x, y, w, h := operands[0], operands[1], operands[2], operands[3]
+			rect = ctm.transformRect(types.NewRectangle(min(x, x+w), min(y, y+h), max(x, x+w), max(y, y+h)))
+		case token == "f" || token == "F" || token == "f*":
+			if rect == nil || !covers(rect, box) {
+				return 0, 0, 0, false
+			}
+			return r, g, b, true
+		case strokeOperators[token]:
+			// Leaves the fill as it is
+		default:
+			return 0, 0, 0, false
+		}
+		operands = nil
+	}
+	return 0, 0, 0, false
+}
+
+// covers reports whether rect covers box within fullPageTolerance on every side
+func covers(rect, box *types.Rectangle) bool {
+	return rect.LL.X <= box.LL.X+fullPageTolerance && rect.LL.Y <= box.LL.Y+fullPageTolerance &&
+		rect.UR.X >= box.UR.X-fullPageTolerance && rect.UR.Y >= box.UR.Y-fullPageTolerance
+}
@@ -0,0 +1,85 @@
+package direct
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"pdfdarkmode/converter/colors"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+func TestLeadingFill(t *testing.T) {
+	box := types.NewRectangle(0, 0, 612, 792)
+	tests := []struct {
+		name    string
+		content string
+		ok      bool
+		r       float64
+	}{
+		{"rgb", "0.1 0.1 0.1 rg 0 0 612 792 re f", true, 0.1},
+		{"gray in q", "q 0.2 g 0 0 612 792 re f Q BT (x) Tj ET", true, 0.2},
+		{"cmyk", "0 0 0 1 k 0 0 612 792 re f", true, 0},
+		{"cs and sc", "/DeviceRGB cs 0.3 0.3 0.3 sc 0 0 612 792 re f", true, 0.3},
+		{"negative size", "0.1 g 612 792 -612 -792 re f", true, 0.1},
+		{"scaled", "q 2 0 0 2 0 0 cm 0.1 g 0 0 306 396 re f Q", true, 0.1},
+		{"stroke settings first", "1 w 0 G 0.1 g 0 0 612 792 re f", true, 0.1},
+		{"within tolerance", "0.1 g 0.5 0.5 611.5 791.5 re f", true, 0.1},
+		{"partial fill", "0.1 g 0 0 300 792 re f", false, 0},
+		{"text first", "BT /F1 12 Tf (x) Tj ET 0.1 g 0 0 612 792 re f", false, 0},
+		{"transparency first", "/GS0 gs 0.1 g 0 0 612 792 re f", false, 0},
+		{"named color space", "/CS0 cs 0.1 sc 0 0 612 792 re f", false, 0},
+		{"stroked", "0.1 G 0 0 612 792 re S", false, 0},
+		{"empty", "", false, 0},
+	}
+
+	for _, tt := range tests {
+		r, _, _, ok := leadingFill(tt.content, box)
+		if ok != tt.ok || r != tt.r {
+			t.Errorf("%s: leadingFill = %v, %t, want %v, %t", tt.name, r, ok, tt.r, tt.ok)
+		}
+	}
+}
+
+func TestRespectExistingBackground(t *testing.T) {
+	bg := colors.SchemeDark.Background
+	background := fmt.Sprintf("q %.3f %.3f %.3f rg", bg.R, bg.G, bg.B)
+
+	tests := []struct {
+		name     string
+		content  string
+		respect  bool
+		existing bool
+	}{
+		{"dark page", "0.05 0.05 0.1 rg 0 0 612 792 re f BT (x) Tj ET", true, true},
+		{"dark page, option off", "0.05 0.05 0.1 rg 0 0 612 792 re f BT (x) Tj ET", false, false},
+		{"light page", "1 g 0 0 612 792 re f BT (x) Tj ET", true, false},
+		{"dark box", "0.05 g 72 72 200 200 re f", true, false},
+		{"no fill", "BT (x) Tj ET", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t, nil, tt.content)
+			e := NewEngine(false, colors.SchemeDark)
+			e.SetRespectExistingBackground(tt.respect)
+			existing, err := e.addPageBackground(ctx, 1, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if existing != tt.existing {
+				t.Errorf("addPageBackground reports existing %t, want %t", existing, tt.existing)
+			}
+
+			out := testPageContent(t, ctx, 1)
+			if added := strings.Contains(out, background); added == tt.existing {
+				t.Errorf("background added %t, want %t:\n%s", added, !tt.existing, out)
+			}
+			// The default text colors are set either way
+			if !strings.Contains(out, "0.878 0.878 0.878 rg") {
+				t.Errorf("default text colors missing:\n%s", out)
+			}
+		})
+	}
+}
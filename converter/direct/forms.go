@@ -0,0 +1,110 @@
+package direct
+
+import (
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// processFormDefaults transforms the colors in AcroForm default appearance (/DA) strings.
+// /DA strings such as "0 g /Helv 12 Tf" set the default text color of form fields
+// and live outside page content streams, so they need their own pass.
+// Returns the number of /DA strings changed.
+func (e *Engine) processFormDefaults(ctx *model.Context) int {
+	if ctx.RootDict == nil {
+		return 0
+	}
+
+	acroForm, err := ctx.DereferenceDict(ctx.RootDict["AcroForm"])
+	if err != nil || acroForm == nil {
+		return 0
+	}
+
+	count := 0
+	if e.transformDA(ctx, acroForm) {
+		count++
+	}
+
+	fields, err := ctx.DereferenceArray(acroForm["Fields"])
+	if err != nil {
+		return count
+	}
+
+	visited := make(map[int]bool)
+	for _, field := range fields {
+		count += e.processFieldDA(ctx, field, visited)
+	}
+
+	return count
+}
+
+// processFieldDA transforms the /DA of a field and, recursively, of its kids and widgets
+func (e *Engine) processFieldDA(ctx *model.Context, obj types.Object, visited map[int]bool) int {
+	if ref, ok := obj.(types.IndirectRef); ok {
+		if visited[ref.ObjectNumber.Value()] {
+			return 0
+		}
+		visited[ref.ObjectNumber.Value()] = true
+	}
+
+	field, err := ctx.DereferenceDict(obj)
+	if err != nil || field == nil {
+		return 0
+	}
+
+	count := 0
+	if e.transformDA(ctx, field) {
+		count++
+	}
+
+	kids, err := ctx.DereferenceArray(field["Kids"])
+	if err != nil {
+		return count
+	}
+	for _, kid := range kids {
+		count += e.processFieldDA(ctx, kid, visited)
+	}
+
+	return count
+}
+
+// transformDA rewrites the color operators in d's /DA string, which may be an indirect
+// object; the rewritten string is stored directly in d. The font it names is looked up
+// in the form's /DR (default resources) by viewers and is left as it is.
+// Returns true if the string was changed.
+func (e *Engine) transformDA(ctx *model.Context, d types.Dict) bool {
+	obj, err := ctx.Dereference(d["DA"])
+	if err != nil {
+		return false
+	}
+
+	var da string
+	switch s := obj.(type) {
+	case types.StringLiteral:
+		b, err := types.Unescape(s.Value())
+		if err != nil {
+			return false
+		}
+		da = string(b)
+	case types.HexLiteral:
+		b, err := s.Bytes()
+		if err != nil {
+			return false
+		}
+		da = string(b)
+	default:
+		return false
+	}
+
+	newDA, count := e.transformContent(da)
+	if count == 0 {
+		return false
+	}
+
+	escaped, err := types.Escape(newDA)
+	if err != nil {
+		return false
+	}
+	d["DA"] = types.StringLiteral(*escaped)
+
+	return true
+}
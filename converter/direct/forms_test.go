@@ -0,0 +1,90 @@
+package direct
+
+import (
+	"strings"
+	"testing"
+
+	"pdfdarkmode/converter/colors"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// daString returns d's /DA, or "" if it has none
+func daString(t *testing.T, ctx *model.Context, d types.Dict) string {
+	t.Helper()
+	obj, err := ctx.Dereference(d["DA"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, ok := obj.(types.StringLiteral)
+	if !ok {
+		return ""
+	}
+	b, err := types.Unescape(s.Value())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestFormFieldsInheritDocumentDA(t *testing.T) {
+	ctx := newTestContext(t, nil, "")
+	font := types.Dict{"Type": types.Name("Font"), "Subtype": types.Name("Type1"), "BaseFont": types.Name("Helvetica")}
+	fontRef, err := ctx.IndRefForNewObject(font)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dr := types.Dict{"Font": types.Dict{"Helv": *fontRef}}
+
+	// Two fields rely on the document /DA, one directly and one through its parent
+	plain := types.Dict{"FT": types.Name("Tx"), "T": types.StringLiteral("name")}
+	kid := types.Dict{"FT": types.Name("Tx"), "T": types.StringLiteral("street")}
+	kidRef, err := ctx.IndRefForNewObject(kid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent := types.Dict{"T": types.StringLiteral("address"), "Kids": types.Array{*kidRef}}
+	own := types.Dict{"FT": types.Name("Tx"), "T": types.StringLiteral("note"), "DA": types.StringLiteral("/Helv 10 Tf 1 0 0 rg")}
+	var fields types.Array
+	for _, field := range []types.Dict{plain, parent, own} {
+		ref, err := ctx.IndRefForNewObject(field)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fields = append(fields, *ref)
+	}
+
+	acroForm := types.Dict{"Fields": fields, "DA": types.StringLiteral("/Helv 0 Tf 0 g"), "DR": dr}
+	ctx.RootDict["AcroForm"] = acroForm
+
+	e := NewEngine(false, colors.SchemeDark)
+	if count := e.processFormDefaults(ctx); count != 2 {
+		t.Errorf("changed %d /DA strings, want 2", count)
+	}
+
+	da := daString(t, ctx, acroForm)
+	if !strings.HasPrefix(da, "/Helv 0 Tf ") || strings.HasSuffix(da, " 0 g") {
+		t.Errorf("document /DA is %q, want the Helv font with a light color", da)
+	}
+	ops := NewParser().FindColorOperators(da)
+	if len(ops) != 1 {
+		t.Fatalf("document /DA %q has %d colors, want 1", da, len(ops))
+	}
+	if r, g, b, _ := operatorRGB(ops[0]); r < 0.5 || g < 0.5 || b < 0.5 {
+		t.Errorf("document /DA %q sets a dark color", da)
+	}
+
+	// Fields without their own /DA still inherit it, and the default font still resolves
+	for _, field := range []types.Dict{plain, parent, kid} {
+		if _, found := field["DA"]; found {
+			t.Errorf("field %v was given its own /DA", field["T"])
+		}
+	}
+	if daString(t, ctx, own) == "/Helv 10 Tf 1 0 0 rg" {
+		t.Error("field /DA was not transformed")
+	}
+	if acroForm["DR"].(types.Dict)["Font"].(types.Dict)["Helv"] != *fontRef {
+		t.Error("/DR fonts changed")
+	}
+}
@@ -0,0 +1,36 @@
+package direct
+
+import (
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// gradientName is the Shading resource name of the gradient page background
+const gradientName = "PDMGradient"
+
+// SetGradientBackground fills pages with a top-to-bottom gradient between two colors
+// close to the scheme's background instead of a flat rectangle
+func (e *Engine) SetGradientBackground(enabled bool) {
+	e.gradient = enabled
+}
+
+// newGradientShading adds the background gradient as an axial shading over the unit
+// square, from the top (y = 1) to the bottom (y = 0). Pages draw it scaled to their
+// media box, so one shading serves all page sizes.
+func (e *Engine) newGradientShading(ctx *model.Context) (*types.IndirectRef, error) {
+	top, bottom := e.colorScheme.BackgroundGradient()
+	shading := types.Dict{
+		"ShadingType": types.Integer(2),
+		"ColorSpace":  types.Name("DeviceRGB"),
+		"Coords":      types.NewNumberArray(0, 1, 0, 0),
+		"Extend":      types.Array{types.Boolean(true), types.Boolean(true)},
+		"Function": types.Dict{
+			"FunctionType": types.Integer(2),
+			"Domain":       types.NewNumberArray(0, 1),
+			"C0":           types.NewNumberArray(top.R, top.G, top.B),
+			"C1":           types.NewNumberArray(bottom.R, bottom.G, bottom.B),
+			"N":            types.Integer(1),
+		},
+	}
+	return ctx.IndRefForNewObject(shading)
+}
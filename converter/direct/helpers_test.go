@@ -0,0 +1,121 @@
+package direct
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// newTestContext returns an in-memory US Letter document with one page per content
+// string, each page using resources
+func newTestContext(t testing.TB, resources types.Dict, contents ...string) *model.Context {
+	t.Helper()
+	ctx, err := pdfcpu.CreateContextWithXRefTable(nil, types.PaperSize["Letter"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pagesRef := ctx.RootDict["Pages"].(types.IndirectRef)
+	pages, err := ctx.DereferenceDict(pagesRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var kids types.Array
+	for _, content := range contents {
+		page := types.Dict{
+			"Type":     types.Name("Page"),
+			"Parent":   pagesRef,
+			"Contents": newTestStream(t, ctx, content),
+		}
+		if resources != nil {
+			page["Resources"] = resources
+		}
+		ref, err := ctx.IndRefForNewObject(page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		kids = append(kids, *ref)
+	}
+	pages["Kids"] = kids
+	pages["Count"] = types.Integer(len(kids))
+	ctx.PageCount = len(kids)
+	return ctx
+}
+
+// newTestStream adds a Flate-encoded stream holding content to ctx
+func newTestStream(t testing.TB, ctx *model.Context, content string) types.IndirectRef {
+	t.Helper()
+	sd, err := ctx.NewStreamDictForBuf([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sd.Encode(); err != nil {
+		t.Fatal(err)
+	}
+	ref, err := ctx.IndRefForNewObject(*sd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *ref
+}
+
+// newTestICCSpace adds an ICCBased color space with n components to ctx. The profile
+// data is not read, only its /N.
+func newTestICCSpace(t *testing.T, ctx *model.Context, n int) types.Array {
+	t.Helper()
+	sd, err := ctx.NewStreamDictForBuf([]byte("profile"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sd.Dict["N"] = types.Integer(n)
+	if err := sd.Encode(); err != nil {
+		t.Fatal(err)
+	}
+	ref, err := ctx.IndRefForNewObject(*sd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return types.Array{types.Name("ICCBased"), *ref}
+}
+
+// testPageContent returns the decoded, concatenated content streams of page pageNum
+func testPageContent(t *testing.T, ctx *model.Context, pageNum int) string {
+	t.Helper()
+	pageDict, _, _, err := ctx.PageDict(pageNum, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var refs types.Array
+	switch contents := pageDict["Contents"].(type) {
+	case types.IndirectRef:
+		refs = types.Array{contents}
+	case types.Array:
+		refs = contents
+	}
+	content := ""
+	for _, ref := range refs {
+		sd, _, err := ctx.DereferenceStreamDict(ref)
+		if err != nil || sd == nil {
+			t.Fatalf("page %d content %v is not a stream: %v", pageNum, ref, err)
+		}
+		if err := sd.Decode(); err != nil {
+			t.Fatal(err)
+		}
+		content += string(sd.Content) + "\n"
+	}
+	return content
+}
+
+// writeTestPDF writes ctx to a file in a temporary directory and returns its path
+func writeTestPDF(t *testing.T, ctx *model.Context) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "in.pdf")
+	if err := api.WriteContextFile(ctx, path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,134 @@
+package direct
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// objectFingerprints holds a hash of every object in a context by object number
+type objectFingerprints map[int][sha256.Size]byte
+
+// fingerprintObjects hashes every object in ctx, so objects changed later can be found.
+// Objects packed in object streams are decoded first.
+func fingerprintObjects(ctx *model.Context) objectFingerprints {
+	prints := make(objectFingerprints, len(ctx.Table))
+	for objNr, entry := range ctx.Table {
+		if entry == nil || entry.Free || entry.Generation == nil {
+			continue
+		}
+		obj, err := ctx.Dereference(*types.NewIndirectRef(objNr, *entry.Generation))
+		if err != nil || obj == nil {
+			continue
+		}
+		prints[objNr] = fingerprint(obj)
+	}
+	return prints
+}
+
+// fingerprint hashes an object's serialization, including the encoded data of streams
+func fingerprint(obj types.Object) [sha256.Size]byte {
+	h := sha256.New()
+	if sd, ok := obj.(types.StreamDict); ok {
+		h.Write([]byte(sd.Dict.PDFString()))
+		h.Write(sd.Raw)
+	} else {
+		h.Write([]byte(obj.PDFString()))
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// changedObjects returns the numbers of objects that are new or differ from before, in order
+func changedObjects(ctx *model.Context, before objectFingerprints) []int {
+	var objNrs []int
+	for objNr, entry := range ctx.Table {
+		if entry == nil || entry.Free || entry.Object == nil {
+			continue
+		}
+		if sum, found := before[objNr]; found && sum == fingerprint(entry.Object) {
+			continue
+		}
+		objNrs = append(objNrs, objNr)
+	}
+	sort.Ints(objNrs)
+	return objNrs
+}
+
+// incrementUnsupported returns why ctx cannot be written as an incremental update, or ""
+func incrementUnsupported(ctx *model.Context) string {
+	switch {
+	case ctx.Encrypt != nil:
+		return "the input is encrypted"
+	case ctx.HeaderVersion != nil && *ctx.HeaderVersion < model.V14:
+		return "the input is older than PDF 1.4"
+	case ctx.Write.OffsetPrevXRef == nil:
+		return "the input's cross-reference table had to be rebuilt"
+	}
+	return ""
+}
+
+// writeIncrement writes outputPath as a byte-for-byte copy of inputPath followed by an
+// incremental update holding only the objects that changed since before was taken.
+// The original bytes, and with them any digital signatures, are left intact.
+// Returns the number of objects in the update.
+func (e *Engine) writeIncrement(ctx *model.Context, inputPath, outputPath string, before objectFingerprints) (int, error) {
+	objNrs := changedObjects(ctx, before)
+
+	in, err := os.Open(inputPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer in.Close()
+
+	outFile, err := os.Create(outputPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer outFile.Close()
+
+	size, err := io.Copy(outFile, in)
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy original PDF: %w", err)
+	}
+	if len(objNrs) == 0 {
+		return 0, nil
+	}
+
+	// The update must start on a new line after the original %%EOF
+	last := make([]byte, 1)
+	if _, err := in.ReadAt(last, size-1); err == nil && last[0] != '\n' && last[0] != '\r' {
+		if _, err := outFile.WriteString("\n"); err != nil {
+			return 0, err
+		}
+		size++
+	}
+
+	// Changed objects packed in object streams are written out on their own
+	for _, objNr := range objNrs {
+		entry := ctx.Table[objNr]
+		entry.Compressed = false
+		entry.ObjectStream = nil
+		entry.ObjectStreamInd = nil
+		ctx.Write.IncrementWithObjNr(objNr)
+	}
+
+	ctx.Write.Increment = true
+	ctx.Write.Offset = size
+	ctx.Write.WriteToObjectStream = false
+	// Keep the kind of cross-reference section the original uses
+	ctx.WriteXRefStream = ctx.Read.UsingXRefStreams
+
+	if err := api.WriteIncrement(ctx, outFile); err != nil {
+		return 0, fmt.Errorf("failed to write incremental update: %w", err)
+	}
+
+	return len(objNrs), nil
+}
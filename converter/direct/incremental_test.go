@@ -0,0 +1,58 @@
+package direct
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pdfdarkmode/converter/colors"
+)
+
+func TestIncrementalUpdateKeepsOriginalBytes(t *testing.T) {
+	ctx := newTestContext(t, nil, "0 g 72 72 100 100 re f", "1 0 0 rg 72 72 100 100 re f")
+	input := writeTestPDF(t, ctx)
+	in, err := os.ReadFile(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(t.TempDir(), "out.pdf")
+	e := NewEngine(false, colors.SchemeDark)
+	e.SetIncremental(true)
+	if err := e.Convert(input, output); err != nil {
+		t.Fatal(err)
+	}
+	out, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(out) <= len(in) || !bytes.Equal(out[:len(in)], in) {
+		t.Fatal("output does not start with the original bytes")
+	}
+	update := string(out[len(in):])
+	for _, want := range []string{"/Prev", "startxref", "%%EOF"} {
+		if !strings.Contains(update, want) {
+			t.Errorf("update has no %s", want)
+		}
+	}
+
+	// The updated document reads back with the converted pages
+	updated, err := ReadContext(output)
+	if err != nil {
+		t.Fatalf("output does not read back: %v", err)
+	}
+	if updated.PageCount != 2 {
+		t.Errorf("output has %d pages, want 2", updated.PageCount)
+	}
+	bg := colors.SchemeDark.Background
+	background := fmt.Sprintf("%.3f %.3f %.3f rg", bg.R, bg.G, bg.B)
+	for pageNum := 1; pageNum <= updated.PageCount; pageNum++ {
+		if content := testPageContent(t, updated, pageNum); !strings.Contains(content, background) {
+			t.Errorf("page %d has no background:\n%s", pageNum, content)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package direct
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// knockoutContentName is the XObject name of the form holding a knockout page's content
+const knockoutContentName = "PDMContent"
+
+// knockoutGroup returns the page's transparency group if it is a knockout group (/K
+// true), in which each object is composited against the group's initial backdrop rather
+// than against what was drawn before it
+func knockoutGroup(ctx *model.Context, pageDict types.Dict) types.Dict {
+	group, err := ctx.DereferenceDict(pageDict["Group"])
+	if err != nil || group == nil {
+		return nil
+	}
+	if s := group.NameEntry("S"); s == nil || *s != "Transparency" {
+		return nil
+	}
+	if k := group.BooleanEntry("K"); k == nil || !*k {
+		return nil
+	}
+	return group
+}
+
+// wrapKnockoutContent moves the content of a page with a knockout transparency group
+// into a form XObject carrying that group, and drops the knockout flag from the page's
+// own group. Otherwise the background drawn first would not be the backdrop of the
+// page's objects: semi-transparent content would be composited against the paper and
+// show a light fringe. The form's group is not isolated, so the background becomes
+// its backdrop, and the page's objects still knock each other out.
+func wrapKnockoutContent(ctx *model.Context, pageDict types.Dict, inhPAttrs *model.InheritedPageAttrs, group types.Dict, mediaBox *types.Rectangle) error {
+	var refs types.Array
+	switch contents := pageDict["Contents"].(type) {
+	case types.IndirectRef:
+		refs = types.Array{contents}
+	case types.Array:
+		refs = contents
+	}
+
+	var content bytes.Buffer
+	for _, item := range refs {
+		sd, _, err := ctx.DereferenceStreamDict(item)
+		if err != nil || sd == nil {
+			continue
+		}
+		if err := sd.Decode(); err != nil {
+			return fmt.Errorf("failed to decode content stream: %w", err)
+		}
+		content.Write(sd.Content)
+		content.WriteByte('\n')
+	}
+	if content.Len() == 0 {
+		return nil
+	}
+
+	// The form gets its own copy of the resources, which are about to name the form itself
+	resources := types.Dict{}
+	if inhPAttrs != nil && inhPAttrs.Resources != nil {
+		resources = inhPAttrs.Resources.Clone().(types.Dict)
+	}
+	formGroup := group.Clone().(types.Dict)
+	formGroup.Delete("I")
+
+	sd, err := ctx.NewStreamDictForBuf(content.Bytes())
+	if err != nil {
+		return err
+	}
+	sd.InsertName("Type", "XObject")
+	sd.InsertName("Subtype", "Form")
+	sd.Insert("BBox", mediaBox.Array())
+	sd.Insert("Resources", resources)
+	sd.Insert("Group", formGroup)
+	if err := sd.Encode(); err != nil {
+		return err
+	}
+	formRef, err := ctx.IndRefForNewObject(*sd)
+	if err != nil {
+		return err
+	}
+
+	name, err := addResource(ctx, pageDict, inhPAttrs, "XObject", knockoutContentName, *formRef)
+	if err != nil {
+		return err
+	}
+	ref, err := ctx.StreamDictIndRef([]byte(fmt.Sprintf("q /%s Do Q\n", name)))
+	if err != nil {
+		return err
+	}
+	pageDict["Contents"] = *ref
+
+	pageGroup := group.Clone().(types.Dict)
+	pageGroup.Delete("K")
+	pageDict["Group"] = pageGroup
+	return nil
+}
@@ -0,0 +1,106 @@
+package direct
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"pdfdarkmode/converter/colors"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+func TestKnockoutGroupBackdrop(t *testing.T) {
+	content := "/GS0 gs 0 0 1 rg 0 0 100 100 re f"
+	resources := types.Dict{"ExtGState": types.Dict{"GS0": types.Dict{"ca": types.Float(0.5)}}}
+
+	tests := []struct {
+		name  string
+		group types.Dict
+		wraps bool
+	}{
+		{"knockout", types.Dict{"S": types.Name("Transparency"), "K": types.Boolean(true), "I": types.Boolean(true), "CS": types.Name("DeviceRGB")}, true},
+		{"not knockout", types.Dict{"S": types.Name("Transparency"), "K": types.Boolean(false), "CS": types.Name("DeviceRGB")}, false},
+		{"isolated only", types.Dict{"S": types.Name("Transparency"), "I": types.Boolean(true)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newTestContext(t, resources.Clone().(types.Dict), content)
+			pageDict, _, _, err := ctx.PageDict(1, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pageDict["Group"] = tt.group.Clone()
+
+			e := NewEngine(false, colors.SchemeDark)
+			if _, err := e.addPageBackground(ctx, 1, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			// The background comes first and nothing light is drawn before the content
+			bg := colors.SchemeDark.Background
+			out := testPageContent(t, ctx, 1)
+			first := NewParser().FindColorOperators(out)
+			if len(first) == 0 {
+				t.Fatalf("page draws no colors:\n%s", out)
+			}
+			if want := fmt.Sprintf("%.3f %.3f %.3f rg", bg.R, bg.G, bg.B); first[0].FullMatch != want {
+				t.Errorf("page starts with %q, want the background color:\n%s", first[0].FullMatch, out)
+			}
+
+			group := pageDict["Group"].(types.Dict)
+			if !tt.wraps {
+				if !strings.Contains(out, content) {
+					t.Errorf("page content moved:\n%s", out)
+				}
+				if group.PDFString() != tt.group.PDFString() {
+					t.Errorf("page group changed to %v", group)
+				}
+				return
+			}
+
+			// The page's own group no longer knocks the content out of the background
+			if k := group.BooleanEntry("K"); k != nil && *k {
+				t.Errorf("page group %v is still a knockout group", group)
+			}
+			if !strings.Contains(out, "/"+knockoutContentName+" Do") {
+				t.Fatalf("page content was not moved into a form:\n%s", out)
+			}
+
+			pageRes, err := ctx.DereferenceDict(pageDict["Resources"])
+			if err != nil {
+				t.Fatal(err)
+			}
+			xobjects, err := ctx.DereferenceDict(pageRes["XObject"])
+			if err != nil {
+				t.Fatal(err)
+			}
+			form, _, err := ctx.DereferenceStreamDict(xobjects[knockoutContentName])
+			if err != nil || form == nil {
+				t.Fatalf("form %s not found: %v", knockoutContentName, err)
+			}
+			formGroup, err := ctx.DereferenceDict(form.Dict["Group"])
+			if err != nil || formGroup == nil {
+				t.Fatalf("form has no group: %v", err)
+			}
+			if k := formGroup.BooleanEntry("K"); k == nil || !*k {
+				t.Errorf("form group %v is not a knockout group", formGroup)
+			}
+			// An isolated group would composite against transparent black, not the background
+			if i := formGroup.BooleanEntry("I"); i != nil && *i {
+				t.Errorf("form group %v is isolated", formGroup)
+			}
+			if err := form.Decode(); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(form.Content), content) {
+				t.Errorf("form content is %q, want the page content", form.Content)
+			}
+			formRes := form.Dict["Resources"].(types.Dict)
+			if _, found := formRes["ExtGState"]; !found {
+				t.Error("form resources lack the page's ExtGState")
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package direct
+
+import (
+	"fmt"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Property names of the optional content groups in page resources
+const (
+	originalLayer = "PDMOriginal"
+	darkLayer     = "PDMDark"
+)
+
+// snapshotContents copies every page's content streams before they are transformed.
+// Streams shared between pages are copied once. Returns the copies by page number.
+func snapshotContents(ctx *model.Context) (map[int]types.Array, error) {
+	copies := make(map[int]types.IndirectRef)
+	originals := make(map[int]types.Array, ctx.PageCount)
+
+	for pageNum := 1; pageNum <= ctx.PageCount; pageNum++ {
+		pageDict, _, _, err := ctx.PageDict(pageNum, false)
+		if err != nil {
+			return nil, err
+		}
+
+		var refs types.Array
+		switch contents := pageDict["Contents"].(type) {
+		case types.IndirectRef:
+			refs = types.Array{contents}
+		case types.Array:
+			refs = contents
+		}
+
+		var snapshot types.Array
+		for _, item := range refs {
+			ref, ok := item.(types.IndirectRef)
+			if !ok {
+				continue
+			}
+			if cp, done := copies[ref.ObjectNumber.Value()]; done {
+				snapshot = append(snapshot, cp)
+				continue
+			}
+
+			sd, _, err := ctx.DereferenceStreamDict(ref)
+			if err != nil || sd == nil {
+				continue
+			}
+			cp := *sd
+			cp.Dict = sd.Dict.Clone().(types.Dict)
+			cpRef, err := ctx.IndRefForNewObject(cp)
+			if err != nil {
+				return nil, err
+			}
+			copies[ref.ObjectNumber.Value()] = *cpRef
+			snapshot = append(snapshot, *cpRef)
+		}
+		originals[pageNum] = snapshot
+	}
+
+	return originals, nil
+}
+
+// addLayers puts each page's original content and its dark content into two optional
+// content groups, shown as radio buttons so viewers switch between them. The dark
+// layer is on by default.
+func addLayers(ctx *model.Context, originals map[int]types.Array) error {
+	origRef, err := ctx.IndRefForNewObject(types.Dict{
+		"Type": types.Name("OCG"),
+		"Name": types.StringLiteral("Original"),
+	})
+	if err != nil {
+		return err
+	}
+	darkRef, err := ctx.IndRefForNewObject(types.Dict{
+		"Type": types.Name("OCG"),
+		"Name": types.StringLiteral("Dark mode"),
+	})
+	if err != nil {
+		return err
+	}
+	if err := registerLayers(ctx, *origRef, *darkRef); err != nil {
+		return err
+	}
+
+	begin, err := ctx.StreamDictIndRef([]byte(fmt.Sprintf("/OC /%s BDC q\n", originalLayer)))
+	if err != nil {
+		return err
+	}
+	between, err := ctx.StreamDictIndRef([]byte(fmt.Sprintf("\nQ EMC /OC /%s BDC q\n", darkLayer)))
+	if err != nil {
+		return err
+	}
+	end, err := ctx.StreamDictIndRef([]byte("\nQ EMC\n"))
+	if err != nil {
+		return err
+	}
+
+	for pageNum := 1; pageNum <= ctx.PageCount; pageNum++ {
+		pageDict, _, inhPAttrs, err := ctx.PageDict(pageNum, false)
+		if err != nil {
+			return err
+		}
+
+		var dark types.Array
+		switch contents := pageDict["Contents"].(type) {
+		case types.IndirectRef:
+			dark = types.Array{contents}
+		case types.Array:
+			dark = contents
+		}
+
+		// Inherited resources get the properties where they are defined
+		var resources types.Dict
+		if inhPAttrs != nil {
+			resources = inhPAttrs.Resources
+		}
+		if resources == nil {
+			resources = types.Dict{}
+			pageDict["Resources"] = resources
+		}
+		props, err := ctx.DereferenceDict(resources["Properties"])
+		if err != nil {
+			return err
+		}
+		if props == nil {
+			props = types.Dict{}
+			resources["Properties"] = props
+		}
+		props[originalLayer] = *origRef
+		props[darkLayer] = *darkRef
+
+		contents := types.Array{*begin}
+		contents = append(contents, originals[pageNum]...)
+		contents = append(contents, *between)
+		contents = append(contents, dark...)
+		pageDict["Contents"] = append(contents, *end)
+	}
+
+	return nil
+}
+
+// registerLayers adds the groups to the catalog's optional content properties,
+// keeping any layers the document already has
+func registerLayers(ctx *model.Context, orig, dark types.IndirectRef) error {
+	ocProps, err := ctx.DereferenceDict(ctx.RootDict["OCProperties"])
+	if err != nil {
+		return err
+	}
+	if ocProps == nil {
+		ocProps = types.Dict{}
+		ctx.RootDict["OCProperties"] = ocProps
+	}
+
+	config, err := ctx.DereferenceDict(ocProps["D"])
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		config = types.Dict{}
+		ocProps["D"] = config
+	}
+
+	for _, entry := range []struct {
+		dict types.Dict
+		key  string
+		add  types.Array
+	}{
+		{ocProps, "OCGs", types.Array{orig, dark}},
+		{config, "Order", types.Array{dark, orig}},
+		{config, "OFF", types.Array{orig}},
+		{config, "RBGroups", types.Array{types.Array{orig, dark}}},
+	} {
+		arr, err := ctx.DereferenceArray(entry.dict[entry.key])
+		if err != nil {
+			return err
+		}
+		entry.dict[entry.key] = append(arr, entry.add...)
+	}
+
+	return nil
+}
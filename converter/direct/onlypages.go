@@ -0,0 +1,15 @@
+package direct
+
+import "pdfdarkmode/converter/parity"
+
+// SetOnlyPages converts only the odd or even pages (parity.Odd or parity.Even), leaving
+// the others exactly as they are: no color changes, background or rotation. Empty
+// converts all pages.
+func (e *Engine) SetOnlyPages(selection string) {
+	e.onlyPages = selection
+}
+
+// converts reports whether page pageNum is selected for conversion
+func (e *Engine) converts(pageNum int) bool {
+	return parity.Includes(e.onlyPages, pageNum)
+}
@@ -0,0 +1,107 @@
+package direct
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// textShowOperators show text
+var textShowOperators = map[string]bool{
+	"Tj": true, "TJ": true, "'": true, "\"": true,
+}
+
+// pageContent returns a page's content streams decoded and joined by newlines. Streams
+// that cannot be decoded are left out.
+func pageContent(ctx *model.Context, pageDict types.Dict) string {
+	contents := pageDict["Contents"]
+	if ref, ok := contents.(types.IndirectRef); ok {
+		// /Contents may refer to an array of streams
+		if arr, err := ctx.DereferenceArray(ref); err == nil && arr != nil {
+			contents = arr
+		}
+	}
+
+	var refs types.Array
+	switch contents := contents.(type) {
+	case types.IndirectRef:
+		refs = types.Array{contents}
+	case types.Array:
+		refs = contents
+	}
+	var content strings.Builder
+	for _, item := range refs {
+		sd, _, err := ctx.DereferenceStreamDict(item)
+		if err != nil || sd == nil || sd.Decode() != nil {
+			continue
+		}
+		content.Write(sd.Content)
+		content.WriteByte('\n')
+	}
+	return content.String()
+}
+
+// operatorVisitor is called with each operator of a content stream, its numeric
+// operands, the last name before it and how many bytes of text its string operands hold
+type operatorVisitor func(op string, operands []float64, name string, text int)
+
+// walkOperators calls visit for every operator in content, in order. Comments and
+// inline image data are skipped; the inline image is visited as its BI operator.
+func walkOperators(content string, visit operatorVisitor) {
+	var operands []float64
+	var name string
+	text := 0 // Bytes in the strings since the last operator
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case isWhitespace(c), c == '[', c == ']', c == '{', c == '}':
+			i++
+		case c == '%':
+			end := strings.IndexAny(content[i:], "\r\n")
+			if end < 0 {
+				end = len(content) - i
+			}
+			i += end
+		case c == '(':
+			end := skipLiteralString(content, i)
+			text += max(end-i-2, 0)
+			i = end
+		case c == '<' && i+1 < len(content) && content[i+1] == '<',
+			c == '>' && i+1 < len(content) && content[i+1] == '>':
+			i += 2
+		case c == '<':
+			end := strings.IndexByte(content[i:], '>')
+			if end < 0 {
+				end = len(content) - i - 1
+			}
+			text += end / 2
+			i += end + 1
+		case c == '/':
+			end := i + 1
+			for end < len(content) && isRegular(content[end]) {
+				end++
+			}
+			name = content[i+1 : end]
+			i = end
+		default:
+			end := i + 1
+			for end < len(content) && isRegular(content[end]) {
+				end++
+			}
+			token := content[i:end]
+			i = end
+			if v, err := strconv.ParseFloat(token, 64); err == nil {
+				operands = append(operands, v)
+				continue
+			}
+			if token == "BI" {
+				i = skipInlineImage(content, i)
+			}
+			visit(token, operands, name, text)
+			operands = operands[:0]
+			text = 0
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package direct
+
+import (
+	"fmt"
+
+	"pdfdarkmode/converter/report"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// ensurePageCount determines the page count, falling back to walking the page tree
+// when /Count is missing, zero or wrong. pdfcpu looks pages up by /Count, so a wrong
+// count is repaired on every /Pages node for the rest of the conversion to see all pages.
+func ensurePageCount(ctx *model.Context) error {
+	countErr := ctx.EnsurePageCount()
+
+	if ctx.RootDict == nil {
+		if countErr != nil {
+			return countErr
+		}
+		return fmt.Errorf("missing document catalog")
+	}
+
+	root, found := ctx.RootDict.Find("Pages")
+	if !found {
+		if countErr != nil {
+			return countErr
+		}
+		return fmt.Errorf("missing page tree")
+	}
+
+	leaves, err := repairPageTree(ctx, root, make(map[int]bool))
+	if err != nil {
+		if countErr != nil {
+			return countErr
+		}
+		return nil // Keep the reported count if the tree cannot be walked
+	}
+
+	if countErr == nil && ctx.PageCount == leaves {
+		return nil
+	}
+
+	if countErr != nil {
+		report.Warnf("page count unavailable (%v), counted %d pages in the page tree", countErr, leaves)
+	} else {
+		report.Warnf("PDF reports %d pages, counted %d pages in the page tree", ctx.PageCount, leaves)
+	}
+	ctx.PageCount = leaves
+
+	return nil
+}
+
+// repairPageTree counts the leaf /Page nodes below obj and sets the correct /Count
+// on each /Pages node on the way. visited guards against cycles.
+func repairPageTree(ctx *model.Context, obj types.Object, visited map[int]bool) (int, error) {
+	if ref, ok := obj.(types.IndirectRef); ok {
+		if visited[ref.ObjectNumber.Value()] {
+			return 0, nil
+		}
+		visited[ref.ObjectNumber.Value()] = true
+	}
+
+	node, err := ctx.DereferenceDict(obj)
+	if err != nil {
+		return 0, err
+	}
+	if node == nil {
+		return 0, nil
+	}
+
+	kidsObj, hasKids := node.Find("Kids")
+	if t := node.Type(); (t != nil && *t == "Page") || !hasKids {
+		return 1, nil
+	}
+
+	kids, err := ctx.DereferenceArray(kidsObj)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, kid := range kids {
+		n, err := repairPageTree(ctx, kid, visited)
+		if err != nil {
+			continue // Skip broken subtrees, keep the pages that can be reached
+		}
+		count += n
+	}
+
+	node["Count"] = types.Integer(count)
+	return count, nil
+}
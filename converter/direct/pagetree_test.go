@@ -0,0 +1,81 @@
+package direct
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"pdfdarkmode/converter/colors"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+func TestWrongPageCount(t *testing.T) {
+	for _, count := range []types.Object{types.Integer(1), types.Integer(7), types.Integer(0), nil} {
+		t.Run(fmt.Sprint(count), func(t *testing.T) {
+			ctx := newTestContext(t, nil, "0 g", "0.5 g", "1 g")
+			pages, err := ctx.DereferenceDict(ctx.RootDict["Pages"])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count == nil {
+				delete(pages, "Count")
+			} else {
+				pages["Count"] = count
+			}
+			ctx.PageCount = 0
+
+			if err := ensurePageCount(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if ctx.PageCount != 3 {
+				t.Errorf("counted %d pages, want 3", ctx.PageCount)
+			}
+			if c := pages.IntEntry("Count"); c == nil || *c != 3 {
+				t.Errorf("/Count repaired to %v, want 3", pages["Count"])
+			}
+		})
+	}
+}
+
+func TestWrongPageCountConvertsEveryPage(t *testing.T) {
+	ctx := newTestContext(t, nil, "0 g", "0.5 g", "0.2 g")
+	// pdfcpu repairs /Count when writing, so the wrong count is patched into the
+	// written bytes; without object streams it is stored in plain text
+	ctx.Configuration.WriteObjectStream = false
+	ctx.Configuration.WriteXRefStream = false
+	input := writeTestPDF(t, ctx)
+	raw, err := os.ReadFile(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(raw, []byte("/Count 3")) {
+		t.Fatalf("written input has no /Count 3:\n%s", raw)
+	}
+	if err := os.WriteFile(input, bytes.Replace(raw, []byte("/Count 3"), []byte("/Count 1"), 1), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(t.TempDir(), "out.pdf")
+	if err := NewEngine(false, colors.SchemeDark).Convert(input, output); err != nil {
+		t.Fatal(err)
+	}
+	out, err := api.ReadContextFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.PageCount != 3 {
+		t.Fatalf("output has %d pages, want 3", out.PageCount)
+	}
+	bg := colors.SchemeDark.Background
+	background := fmt.Sprintf("%.3f %.3f %.3f rg", bg.R, bg.G, bg.B)
+	for pageNum := 1; pageNum <= 3; pageNum++ {
+		if content := testPageContent(t, out, pageNum); !strings.Contains(content, background) {
+			t.Errorf("page %d was not converted:\n%s", pageNum, content)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package direct
 
 import (
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -10,7 +12,8 @@ type ColorOperator struct {
 	FullMatch  string   // The complete matched string
 	Values     []string // Color values (numbers)
 	Operator   string   // The operator (rg, RG, g, G, k, K, sc, SC, scn, SCN)
-	ColorSpace string   // Derived color space (rgb, gray, cmyk)
+	ColorSpace string   // Derived color space (rgb, gray, cmyk), or other for sc/scn with another operand count
+	Pattern    string   // Pattern name after the components of an uncolored pattern's scn/SCN (e.g. "/P0"), else ""
 	IsStroke   bool     // True for stroke (uppercase), false for fill
 	StartPos   int      // Position in the content stream
 	EndPos     int      // End position in the content stream
@@ -22,9 +25,8 @@ type Parser struct {
 	rgbPattern     *regexp.Regexp // matches "n n n rg" or "n n n RG"
 	grayPattern    *regexp.Regexp // matches "n g" or "n G"
 	cmykPattern    *regexp.Regexp // matches "n n n n k" or "n n n n K"
-	scRgbPattern   *regexp.Regexp // matches "n n n sc" or "n n n SC" or scn/SCN
-	scGrayPattern  *regexp.Regexp // matches "n sc" or "n SC" or scn/SCN
-	scCmykPattern  *regexp.Regexp // matches "n n n n sc" or "n n n n SC" or scn/SCN
+	scPattern      *regexp.Regexp // matches the sc, SC, scn and SCN operators, whose operands are read back
+	csPattern      *regexp.Regexp // matches "/Name cs" or "/Name CS"
 }
 
 // NewParser creates a new content stream parser
@@ -35,15 +37,15 @@ func NewParser() *Parser {
 
 	return &Parser{
 		// RGB: three numbers followed by rg or RG
-		rgbPattern: regexp.MustCompile(`(` + num + `)` + ws + `(` + num + `)` + ws + `(` + num + `)` + ws + `(rg|RG)`),
+		rgbPattern: regexp.MustCompile(`(` + num + `)` + ws + `(` + num + `)` + ws + `(` + num + `)` + ws + `(rg|RG)\b`),
 		// Grayscale: one number followed by g or G
 		grayPattern: regexp.MustCompile(`(` + num + `)` + ws + `(g|G)\b`),
 		// CMYK: four numbers followed by k or K
-		cmykPattern: regexp.MustCompile(`(` + num + `)` + ws + `(` + num + `)` + ws + `(` + num + `)` + ws + `(` + num + `)` + ws + `(k|K)`),
-		// sc/SC/scn/SCN operators for different color spaces
-		scRgbPattern:  regexp.MustCompile(`(` + num + `)` + ws + `(` + num + `)` + ws + `(` + num + `)` + ws + `(scn?|SCN?)\b`),
-		scGrayPattern: regexp.MustCompile(`(` + num + `)` + ws + `(scn?|SCN?)\b`),
-		scCmykPattern: regexp.MustCompile(`(` + num + `)` + ws + `(` + num + `)` + ws + `(` + num + `)` + ws + `(` + num + `)` + ws + `(scn?|SCN?)\b`),
+		cmykPattern: regexp.MustCompile(`(` + num + `)` + ws + `(` + num + `)` + ws + `(` + num + `)` + ws + `(` + num + `)` + ws + `(k|K)\b`),
+		// sc/SC/scn/SCN take as many operands as the color space has components
+		scPattern: regexp.MustCompile(`\b(scn?|SCN?)\b`),
+		// Color space selection: a name followed by cs or CS
+		csPattern: regexp.MustCompile(`/([^\s/\[\]<>(){}%]+)` + ws + `(cs|CS)\b`),
 	}
 }
 
@@ -53,6 +55,9 @@ func (p *Parser) FindColorOperators(content string) []ColorOperator {
 
 	// Find RGB operators (rg/RG)
 	for _, match := range p.rgbPattern.FindAllStringSubmatchIndex(content, -1) {
+		if !startsOperands(content, match[0]) {
+			continue
+		}
 		op := ColorOperator{
 			FullMatch:  content[match[0]:match[1]],
 			Values:     []string{content[match[2]:match[3]], content[match[4]:match[5]], content[match[6]:match[7]]},
@@ -70,12 +75,8 @@ func (p *Parser) FindColorOperators(content string) []ColorOperator {
 		fullMatch := content[match[0]:match[1]]
 		operator := content[match[4]:match[5]]
 
-		// Skip if this is part of an RGB/CMYK match
-		if match[0] > 0 {
-			prevChar := content[match[0]-1]
-			if prevChar >= '0' && prevChar <= '9' || prevChar == '.' {
-				continue
-			}
+		if !startsOperands(content, match[0]) {
+			continue
 		}
 
 		op := ColorOperator{
@@ -92,6 +93,9 @@ func (p *Parser) FindColorOperators(content string) []ColorOperator {
 
 	// Find CMYK operators (k/K)
 	for _, match := range p.cmykPattern.FindAllStringSubmatchIndex(content, -1) {
+		if !startsOperands(content, match[0]) {
+			continue
+		}
 		op := ColorOperator{
 			FullMatch: content[match[0]:match[1]],
 			Values: []string{
@@ -109,89 +113,348 @@ func (p *Parser) FindColorOperators(content string) []ColorOperator {
 		operators = append(operators, op)
 	}
 
-	// Find sc/SC/scn/SCN with 3 values (RGB color space)
-	for _, match := range p.scRgbPattern.FindAllStringSubmatchIndex(content, -1) {
-		operator := content[match[8]:match[9]]
+	// Find sc/SC/scn/SCN with any number of values, classified by their count. Counts
+	// of no device color space, such as two DeviceN components, are found whole so no
+	// part of their operands is taken for a gray, RGB or CMYK color. The scn/SCN of an
+	// uncolored pattern has the components before the pattern name.
+	for _, match := range p.scPattern.FindAllStringSubmatchIndex(content, -1) {
+		if match[0] == 0 || !isWhitespace(content[match[0]-1]) {
+			continue
+		}
+		operator := content[match[2]:match[3]]
+		start, values := operandsBefore(content, match[0])
+		pattern := ""
+		if len(values) == 0 && (operator == "scn" || operator == "SCN") {
+			if nameStart, ok := nameBefore(content, match[0]); ok {
+				start, values = operandsBefore(content, nameStart)
+				pattern = strings.TrimSpace(content[nameStart:match[0]])
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+		space, ok := componentSpaces[len(values)]
+		if !ok {
+			space = colorSpaceOther
+		}
 		op := ColorOperator{
-			FullMatch:  content[match[0]:match[1]],
-			Values:     []string{content[match[2]:match[3]], content[match[4]:match[5]], content[match[6]:match[7]]},
+			FullMatch:  content[start:match[1]],
+			Values:     values,
 			Operator:   operator,
-			ColorSpace: "rgb",
+			ColorSpace: space,
+			Pattern:    pattern,
 			IsStroke:   operator == "SC" || operator == "SCN",
-			StartPos:   match[0],
+			StartPos:   start,
 			EndPos:     match[1],
 		}
 		operators = append(operators, op)
 	}
 
-	// Find sc/SC/scn/SCN with 1 value (grayscale)
-	for _, match := range p.scGrayPattern.FindAllStringSubmatchIndex(content, -1) {
-		fullMatch := content[match[0]:match[1]]
-		operator := content[match[4]:match[5]]
+	return dropQuoted(operators, quotedRanges(content))
+}
+
+// startsOperands reports whether a match at pos can be an operator's full operand list:
+// it starts a token and the token before it is not another number. Each color operator
+// takes a fixed number of operands, so a longer run of numbers belongs to a different
+// operator or color space (e.g. DeviceN components before scn), or is malformed.
+// Single-operand operators such as w, Tc, Tw or Tz never match, since only color
+// operator names follow the operands in the patterns.
+func startsOperands(content string, pos int) bool {
+	return startsToken(content, pos) && !followsNumber(content, pos)
+}
 
-		// Skip if this is part of a larger pattern
-		if match[0] > 0 {
-			prevChar := content[match[0]-1]
-			if prevChar >= '0' && prevChar <= '9' || prevChar == '.' {
-				continue
+// numberToken matches a number operand
+var numberToken = regexp.MustCompile(`^[-+]?(?:\d+\.?\d*|\.\d+)$`)
+
+// operandsBefore returns the whitespace-separated run of numbers that ends right before
+// the operator at pos, and where it starts
+func operandsBefore(content string, pos int) (int, []string) {
+	start := pos
+	var values []string
+	for {
+		end := start
+		for end > 0 && isWhitespace(content[end-1]) {
+			end--
+		}
+		if end == start {
+			break
+		}
+		tokenStart := end
+		for tokenStart > 0 && isRegular(content[tokenStart-1]) {
+			tokenStart--
+		}
+		if (tokenStart > 0 && content[tokenStart-1] == '/') || !numberToken.MatchString(content[tokenStart:end]) {
+			break
+		}
+		values = append([]string{content[tokenStart:end]}, values...)
+		start = tokenStart
+	}
+	return start, values
+}
+
+// nameBefore returns where the name operand ending right before the operator at pos
+// starts, if there is one
+func nameBefore(content string, pos int) (int, bool) {
+	end := pos
+	for end > 0 && isWhitespace(content[end-1]) {
+		end--
+	}
+	start := end
+	for start > 0 && isRegular(content[start-1]) {
+		start--
+	}
+	if start == end || start == 0 || content[start-1] != '/' {
+		return 0, false
+	}
+	return start - 1, true
+}
+
+// quotedRanges returns the [start, end) ranges of content that hold data rather than
+// operators: literal and hex strings, comments and inline image data, in order
+func quotedRanges(content string) [][2]int {
+	var ranges [][2]int
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case c == '(':
+			end := skipLiteralString(content, i)
+			ranges = append(ranges, [2]int{i, end})
+			i = end
+		case c == '<' && i+1 < len(content) && content[i+1] == '<':
+			i += 2
+		case c == '<':
+			end := strings.IndexByte(content[i:], '>')
+			if end < 0 {
+				end = len(content) - i - 1
 			}
+			ranges = append(ranges, [2]int{i, i + end + 1})
+			i += end + 1
+		case c == '%':
+			end := strings.IndexAny(content[i:], "\r\n")
+			if end < 0 {
+				end = len(content) - i
+			}
+			ranges = append(ranges, [2]int{i, i + end})
+			i += end
+		case c == 'B' && strings.HasPrefix(content[i:], "BI") && startsToken(content, i) &&
+			(i+2 == len(content) || !isRegular(content[i+2])):
+			end := skipInlineImage(content, i+2)
+			ranges = append(ranges, [2]int{i, end})
+			i = end
+		default:
+			i++
 		}
+	}
+	return ranges
+}
 
-		op := ColorOperator{
-			FullMatch:  fullMatch,
-			Values:     []string{content[match[2]:match[3]]},
-			Operator:   operator,
-			ColorSpace: "gray",
-			IsStroke:   operator == "SC" || operator == "SCN",
-			StartPos:   match[0],
-			EndPos:     match[1],
+// skipLiteralString returns the index after the literal string starting at i,
+// honoring escapes and balanced parentheses
+func skipLiteralString(content string, i int) int {
+	depth := 0
+	for ; i < len(content); i++ {
+		switch content[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
 		}
-		operators = append(operators, op)
 	}
+	return len(content)
+}
 
-	// Find sc/SC/scn/SCN with 4 values (CMYK)
-	for _, match := range p.scCmykPattern.FindAllStringSubmatchIndex(content, -1) {
-		operator := content[match[10]:match[11]]
-		op := ColorOperator{
-			FullMatch: content[match[0]:match[1]],
-			Values: []string{
-				content[match[2]:match[3]],
-				content[match[4]:match[5]],
-				content[match[6]:match[7]],
-				content[match[8]:match[9]],
-			},
-			Operator:   operator,
-			ColorSpace: "cmyk",
-			IsStroke:   operator == "SC" || operator == "SCN",
-			StartPos:   match[0],
-			EndPos:     match[1],
+// skipInlineImage returns the index after the EI that ends the inline image whose BI
+// operator ends at i. The image dictionary before ID is skipped along with the data.
+func skipInlineImage(content string, i int) int {
+	id := strings.Index(content[i:], "ID")
+	if id < 0 {
+		return len(content)
+	}
+	// One whitespace byte separates ID from the image data
+	for j := i + id + 3; j+1 < len(content); j++ {
+		if content[j] == 'E' && content[j+1] == 'I' && isWhitespace(content[j-1]) &&
+			(j+2 == len(content) || !isRegular(content[j+2])) {
+			return j + 2
+		}
+	}
+	return len(content)
+}
+
+// dropQuoted removes operators that overlap one of the sorted ranges
+func dropQuoted(operators []ColorOperator, ranges [][2]int) []ColorOperator {
+	if len(ranges) == 0 {
+		return operators
+	}
+	kept := operators[:0]
+	for _, op := range operators {
+		if !overlaps(ranges, op.StartPos, op.EndPos) {
+			kept = append(kept, op)
 		}
-		operators = append(operators, op)
 	}
+	return kept
+}
 
-	return operators
+// overlaps reports whether [start, end) overlaps one of the sorted ranges
+func overlaps(ranges [][2]int, start, end int) bool {
+	// First range ending after start
+	i := sort.Search(len(ranges), func(i int) bool { return ranges[i][1] > start })
+	return i < len(ranges) && ranges[i][0] < end
 }
 
-// ReplaceColorOperators replaces color operators in content with new values
-// Replacements should be provided as a map from old value to new value
-func (p *Parser) ReplaceColorOperators(content string, replacements map[string]string) string {
-	result := content
+// startsToken reports whether a match at pos begins a token. The first operand must
+// follow whitespace or a delimiter; otherwise its digits belong to a longer token such
+// as the name /F12 or the number 1.5, and the operator's real operands (e.g. a font
+// size before Tf) are not colors.
+func startsToken(content string, pos int) bool {
+	if pos == 0 {
+		return true
+	}
+	return !isRegular(content[pos-1])
+}
 
-	// Sort replacements by length (longest first) to avoid partial replacements
-	// We need to replace from end to start to maintain positions
-	type replacement struct {
-		old string
-		new string
+// followsNumber reports whether the token before pos is a number
+func followsNumber(content string, pos int) bool {
+	end := pos
+	for end > 0 && isWhitespace(content[end-1]) {
+		end--
+	}
+	start := end
+	for start > 0 && isRegular(content[start-1]) {
+		start--
 	}
-	var repls []replacement
-	for old, new := range replacements {
-		repls = append(repls, replacement{old: old, new: new})
+	if start == end || (start > 0 && content[start-1] == '/') {
+		return false
 	}
+	_, err := strconv.ParseFloat(content[start:end], 64)
+	return err == nil
+}
 
-	// Replace each occurrence
-	for _, repl := range repls {
-		result = strings.ReplaceAll(result, repl.old, repl.new)
+func isWhitespace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\r', '\f', 0:
+		return true
 	}
+	return false
+}
+
+// isSetColor reports whether op is sc, scn, SC or SCN, whose operands depend on the current color space
+func isSetColor(op string) bool {
+	switch op {
+	case "sc", "scn", "SC", "SCN":
+		return true
+	}
+	return false
+}
+
+// ResolveColorSpaces drops sc/scn operators whose operand count does not match the
+// color space selected before them with cs/CS (or implied by rg, g and k).
+// spaces maps resource names such as "CS0" to "gray", "rgb", "cmyk" or colorSpaceOther,
+// e.g. from an ICCBased profile's /N. Operators before any selection use the color
+// spaces in state, which is then advanced to the ones in effect at the end of content,
+// so a page's next content stream continues from there. Operators in an unresolved
+// color space keep the count-based guess, and those whose count fits no device color
+// space are always dropped, so they stay unchanged. Operators naming a pattern need an
+// uncolored pattern space whose base fits their count, when the space is known. q/Q
+// nesting is not tracked.
+func (p *Parser) ResolveColorSpaces(content string, operators []ColorOperator, spaces map[string]string, state *ColorSpaceState) []ColorOperator {
+	type event struct {
+		pos    int
+		stroke bool
+		space  string // "" when the color space is unknown
+	}
+
+	var events []event
+	quoted := quotedRanges(content)
+	for _, match := range p.csPattern.FindAllStringSubmatchIndex(content, -1) {
+		if overlaps(quoted, match[0], match[1]) {
+			continue
+		}
+		name := content[match[2]:match[3]]
+		space := lookupColorSpace(name, spaces)
+		events = append(events, event{pos: match[0], stroke: content[match[4]:match[5]] == "CS", space: space})
+	}
+	for _, op := range operators {
+		if !isSetColor(op.Operator) {
+			events = append(events, event{pos: op.StartPos, stroke: op.IsStroke, space: op.ColorSpace})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].pos < events[j].pos })
+
+	var resolved []ColorOperator
+	for _, op := range operators {
+		if !isSetColor(op.Operator) {
+			resolved = append(resolved, op)
+			continue
+		}
+
+		// Find the last color space selection for this fill or stroke before op
+		space := state.Fill
+		if op.IsStroke {
+			space = state.Stroke
+		}
+		for _, ev := range events {
+			if ev.pos >= op.StartPos {
+				break
+			}
+			if ev.stroke == op.IsStroke {
+				space = ev.space
+			}
+		}
+
+		want := op.ColorSpace
+		if op.Pattern != "" {
+			want = patternPrefix + op.ColorSpace
+		}
+		if op.ColorSpace != colorSpaceOther && (space == "" || space == want) {
+			resolved = append(resolved, op)
+		}
+	}
+
+	for _, ev := range events {
+		if ev.stroke {
+			state.Stroke = ev.space
+		} else {
+			state.Fill = ev.space
+		}
+	}
+
+	return resolved
+}
+
+// RewriteColorOperators rebuilds content with each operator replaced by replace(op).
+// Replacements go by position, so the output of one replacement is never rewritten by
+// another and the result does not depend on their order. Where operators overlap, the
+// one starting first wins.
+func (p *Parser) RewriteColorOperators(content string, operators []ColorOperator, replace func(ColorOperator) string) string {
+	sorted := append([]ColorOperator(nil), operators...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].StartPos != sorted[j].StartPos {
+			return sorted[i].StartPos < sorted[j].StartPos
+		}
+		return sorted[i].EndPos > sorted[j].EndPos
+	})
+
+	var b strings.Builder
+	b.Grow(len(content))
+	last := 0
+	for _, op := range sorted {
+		if op.StartPos < last {
+			continue
+		}
+		b.WriteString(content[last:op.StartPos])
+		b.WriteString(replace(op))
+		last = op.EndPos
+	}
+	b.WriteString(content[last:])
+	return b.String()
+}
 
-	return result
+// isRegular reports whether c is neither whitespace nor a delimiter
+func isRegular(c byte) bool {
+	return !isWhitespace(c) && !strings.ContainsRune("()<>[]{}/%", rune(c))
 }
@@ -0,0 +1,221 @@
+package direct
+
+import "testing"
+
+// foundOperators returns the FullMatch of the color operators FindColorOperators finds in content
+func foundOperators(content string) []string {
+	var matches []string
+	for _, op := range NewParser().FindColorOperators(content) {
+		matches = append(matches, op.FullMatch)
+	}
+	return matches
+}
+
+// sameOperators reports whether got and want hold the same operators in any order
+func sameOperators(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	count := map[string]int{}
+	for _, m := range got {
+		count[m]++
+	}
+	for _, m := range want {
+		count[m]--
+		if count[m] < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFindColorOperatorsSkipsFontSizes(t *testing.T) {
+	tests := []struct {
+		content string
+		want    []string
+	}{
+		{"BT /F1 12 Tf 0 g ET", []string{"0 g"}},
+		{"BT\n/F1 12 Tf\n0.5 g\n(x) Tj ET", []string{"0.5 g"}},
+		{"BT /F1 1 Tf 1 0 0 rg ET", []string{"1 0 0 rg"}},
+		{"BT/F1 12 Tf/F2 9 Tf 0 0 0 1 k ET", []string{"0 0 0 1 k"}},
+		{"BT /F12 Tf 0 g ET", []string{"0 g"}},
+		{"BT /F1 12 Tf ET", nil},
+		{"BT /F1 9.5 Tf 0.5 sc ET", []string{"0.5 sc"}},
+		{"/F1 12 0 g", nil},
+		{"/F1 12 1 0 0 rg", nil},
+	}
+
+	for _, tt := range tests {
+		if got := foundOperators(tt.content); !sameOperators(got, tt.want) {
+			t.Errorf("FindColorOperators(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestFindColorOperatorsSkipsNonColorOperands(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"single-operand operators", "0.5 w 0.2 Tc 1 Tw 100 Tz 12 TL 2 Ts 4 M 0 J 1 j 0.5 i 0 0 m", nil},
+		{"operands before color", "0.5 w 0.5 g 1 Tw 0.2 G", []string{"0.5 g", "0.2 G"}},
+		{"over-long run", "0.1 0.2 0.3 0.4 0.5 rg 0.1 0.2 0.3 0.4 0.5 k 0.3 0.5 g", nil},
+		{"dash array", "[3 2] 0 d 0.5 G", []string{"0.5 G"}},
+		{"literal string", "BT (0 g 1 0 0 rg) Tj (a\\) 0 g) Tj ET 0.5 g", []string{"0.5 g"}},
+		{"hex string", "<30 67> Tj 0.5 g", []string{"0.5 g"}},
+		{"comment", "% 0 g\n0.5 g % 1 0 0 RG", []string{"0.5 g"}},
+		{"inline image", "BI /W 4 /H 1 /BPC 8 /CS /G ID 0 g 1 0 0 rg\nEI 0.5 g", []string{"0.5 g"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := foundOperators(tt.content); !sameOperators(got, tt.want) {
+				t.Errorf("FindColorOperators(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveColorSpacesSingleOperandSetColor(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		spaces  map[string]string
+		want    []string
+	}{
+		{"separation", "/CS0 cs 0.5 scn 0.5 w", map[string]string{"CS0": colorSpaceOther}, nil},
+		{"icc gray", "/CS0 cs 0.5 scn 0.5 w", map[string]string{"CS0": "gray"}, []string{"0.5 scn"}},
+		{"separation then gray", "/CS0 cs 0.5 scn /DeviceGray cs 0.5 scn", map[string]string{"CS0": colorSpaceOther}, []string{"0.5 scn"}},
+		{"stroke separation", "/CS0 CS 0.5 SCN 0.5 scn", map[string]string{"CS0": colorSpaceOther}, []string{"0.5 scn"}},
+		{"devicen", "/CS1 cs 0.2 0.8 scn", map[string]string{"CS1": colorSpaceOther}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resolvedOperators(tt.content, tt.spaces)
+			if !sameOperators(got, tt.want) {
+				t.Errorf("resolved %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveColorSpacesAfterCs(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"pattern fill", "/Pattern cs /P0 scn 0 0 100 100 re f", nil},
+		{"pattern stroke", "/Pattern CS /P0 SCN 0.5 G", []string{"0.5 G"}},
+		{"number after pattern", "/Pattern cs 0.5 scn", nil},
+		{"gray", "/DeviceGray cs 0 sc", []string{"0 sc"}},
+		{"gray across lines", "/DeviceGray cs\n0 sc\n0 0 1 1 re f", []string{"0 sc"}},
+		{"gray wrong count", "/DeviceGray cs 0 0 1 sc", nil},
+		{"rgb", "/DeviceRGB CS 1 0 0 SC", []string{"1 0 0 SC"}},
+		{"cmyk", "/DeviceCMYK cs 0 0 0 1 scn", []string{"0 0 0 1 scn"}},
+		{"pattern then device", "/Pattern cs /P0 scn /DeviceRGB cs 0 0 1 sc", []string{"0 0 1 sc"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resolvedOperators(tt.content, nil)
+			if !sameOperators(got, tt.want) {
+				t.Errorf("resolved %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	p := NewParser()
+	state := initialColorSpaces(nil)
+	content := "/DeviceGray cs 0 sc /Pattern CS"
+	ops := p.ResolveColorSpaces(content, p.FindColorOperators(content), nil, &state)
+	if len(ops) != 1 || ops[0].ColorSpace != "gray" || ops[0].Values[0] != "0" {
+		t.Errorf("resolved %+v, want one gray operator", ops)
+	}
+	if state.Fill != "gray" || state.Stroke != colorSpacePattern {
+		t.Errorf("state is %+v, want gray fill and pattern stroke", state)
+	}
+}
+
+// resolvedOperators returns the FullMatch of the operators in content that survive
+// color space resolution, starting from the initial color spaces of spaces
+func resolvedOperators(content string, spaces map[string]string) []string {
+	p := NewParser()
+	state := initialColorSpaces(spaces)
+	var matches []string
+	for _, op := range p.ResolveColorSpaces(content, p.FindColorOperators(content), spaces, &state) {
+		matches = append(matches, op.FullMatch)
+	}
+	return matches
+}
+
+func TestResolveColorSpacesWithoutSelection(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		spaces  map[string]string
+		want    []string
+	}{
+		{"gray sc without cs", "0.5 sc 0 0 1 1 re f", nil, []string{"0.5 sc"}},
+		{"gray scn without cs", "0.5 scn 0.2 SCN", nil, []string{"0.5 scn", "0.2 SCN"}},
+		{"rgb scn without cs", "0.1 0.2 0.3 scn", nil, nil},
+		{"cmyk sc without cs", "0 0 0 1 sc", nil, nil},
+		{"after rg", "1 0 0 rg 0 1 0 sc 0.5 sc", nil, []string{"1 0 0 rg", "0 1 0 sc"}},
+		{"after cs", "/DeviceRGB cs 0 1 0 sc 0.5 SC", nil, []string{"0 1 0 sc", "0.5 SC"}},
+		{"default gray", "0.1 0.2 0.3 scn", map[string]string{"DefaultGray": "rgb"}, []string{"0.1 0.2 0.3 scn"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resolvedOperators(tt.content, tt.spaces)
+			if len(got) != len(tt.want) {
+				t.Fatalf("resolved %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("resolved %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveColorSpacesAcrossStreams(t *testing.T) {
+	p := NewParser()
+	state := initialColorSpaces(nil)
+	first := "/DeviceCMYK cs 0 0 0 1 sc"
+	p.ResolveColorSpaces(first, p.FindColorOperators(first), nil, &state)
+	if state.Fill != "cmyk" || state.Stroke != "gray" {
+		t.Fatalf("state after first stream is %+v, want cmyk fill and gray stroke", state)
+	}
+
+	second := "0 0 0 0.5 scn 0.5 scn"
+	resolved := p.ResolveColorSpaces(second, p.FindColorOperators(second), nil, &state)
+	if len(resolved) != 1 || resolved[0].FullMatch != "0 0 0 0.5 scn" {
+		t.Errorf("second stream resolved %v, want only the CMYK scn", resolved)
+	}
+}
+
+func TestImplicitColorSpaces(t *testing.T) {
+	tests := []struct {
+		content      string
+		fill, stroke bool
+	}{
+		{"0.5 sc 0 0 1 1 re f", true, false},
+		{"0.5 SCN 0 0 m 1 1 l S", false, true},
+		{"/CS0 cs 0.5 sc", false, false},
+		{"1 0 0 rg 0.5 0.5 0.5 sc", false, false},
+		{"0 G 0.5 sc", true, false},
+		{"(0.5 sc) Tj % 0.5 SC\n", false, false},
+		{"BT /F1 12 Tf (x) Tj ET", false, false},
+	}
+
+	for _, tt := range tests {
+		fill, stroke := implicitColorSpaces(tt.content)
+		if fill != tt.fill || stroke != tt.stroke {
+			t.Errorf("implicitColorSpaces(%q) = %t, %t, want %t, %t", tt.content, fill, stroke, tt.fill, tt.stroke)
+		}
+	}
+}
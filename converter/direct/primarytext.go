@@ -0,0 +1,150 @@
+package direct
+
+import (
+	"fmt"
+	"sort"
+
+	"pdfdarkmode/converter/colormath"
+	"pdfdarkmode/converter/colors"
+	"pdfdarkmode/converter/report"
+	"pdfdarkmode/converter/sample"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// primaryTextMaxLightness is the lightness below which a fill color counts as text
+// rather than a background or highlight
+const primaryTextMaxLightness = 0.5
+
+// SetMapPrimaryText makes Transform first find the document's most common dark fill
+// color and map it exactly onto the scheme's text color, see Transformer.SetPrimaryText
+func (e *Engine) SetMapPrimaryText(enabled bool) {
+	e.mapPrimary = enabled
+}
+
+// SetAnalysisPages limits document analyses, such as finding the primary text color,
+// to n pages spread over the document (see sample.Spread); 0 analyzes every page.
+// Every page is still converted.
+func (e *Engine) SetAnalysisPages(n int) {
+	e.analysisPages = n
+}
+
+// SetPrimaryText maps primary, the document's main text color, exactly to the scheme's
+// text color. Document colors between it and white are spread over the whole mapping
+// in proportion, so grays darker than the old text cutoff keep their relationships to
+// it instead of all becoming the text color.
+func (t *Transformer) SetPrimaryText(primary colors.Color) {
+	t.primary = &primary
+	t.primaryL = colormath.Lightness(primary.R, primary.G, primary.B)
+	clear(t.cache)
+}
+
+// primaryTextOperator returns op set to the scheme's text color if it sets the
+// primary text color
+func (t *Transformer) primaryTextOperator(op ColorOperator) (string, bool) {
+	if t.primary == nil {
+		return "", false
+	}
+	r, g, b, ok := operatorRGB(op)
+	if !ok {
+		return "", false
+	}
+	to, ok := colors.Lookup([]colors.Remap{{From: *t.primary, To: t.scheme.Text}}, toByte(r), toByte(g), toByte(b), remapTolerance)
+	if !ok {
+		return "", false
+	}
+	return colorOperator(op, to), true
+}
+
+// documentLightness rescales the lightness of a document color so the primary text
+// color is 0 and white stays 1. Without a primary text color it is unchanged.
+func (t *Transformer) documentLightness(l float64) float64 {
+	if t.primary == nil || t.primaryL >= 1 {
+		return l
+	}
+	if l <= t.primaryL {
+		return 0
+	}
+	return (l - t.primaryL) / (1 - t.primaryL)
+}
+
+// applyPrimaryText finds the primary text color of ctx and sets it on the transformer
+func (e *Engine) applyPrimaryText(ctx *model.Context) {
+	primary, count, ok := e.primaryTextColor(ctx)
+	if !ok {
+		report.Warnf("no dark text color found; mapping colors without a primary text color")
+		return
+	}
+	e.transformer.SetPrimaryText(primary)
+	fmt.Printf("        Primary text color %s (%d operators) maps to %s\n", primary.Hex(), count, e.colorScheme.Text.Hex())
+}
+
+// primaryTextColor returns the most common fill color darker than
+// primaryTextMaxLightness in the page content streams of ctx, or of the analyzed
+// pages, and how often it is set
+func (e *Engine) primaryTextColor(ctx *model.Context) (colors.Color, int, bool) {
+	counts := make(map[[3]uint8]int)
+	for _, pageNum := range sample.Spread(ctx.PageCount, e.analysisPages) {
+		pageDict, _, inhPAttrs, err := ctx.PageDict(pageNum, false)
+		if err != nil {
+			continue
+		}
+		var resources types.Dict
+		if inhPAttrs != nil {
+			resources = inhPAttrs.Resources
+		}
+		spaces := pageColorSpaces(ctx, pageDict, resources)
+		state := initialColorSpaces(spaces)
+
+		var refs []types.IndirectRef
+		switch contents := pageDict["Contents"].(type) {
+		case types.IndirectRef:
+			refs = append(refs, contents)
+		case types.Array:
+			for _, item := range contents {
+				if ref, ok := item.(types.IndirectRef); ok {
+					refs = append(refs, ref)
+				}
+			}
+		}
+
+		for _, ref := range refs {
+			sd, _, err := ctx.DereferenceStreamDict(ref)
+			if err != nil || sd == nil || sd.Decode() != nil {
+				state = ColorSpaceState{}
+				continue
+			}
+			content := string(sd.Content)
+			for _, op := range e.parser.ResolveColorSpaces(content, e.parser.FindColorOperators(content), spaces, &state) {
+				r, g, b, ok := operatorRGB(op)
+				if op.IsStroke || !ok || colormath.Lightness(r, g, b) >= primaryTextMaxLightness {
+					continue
+				}
+				counts[[3]uint8{toByte(r), toByte(g), toByte(b)}]++
+			}
+		}
+	}
+
+	if len(counts) == 0 {
+		return colors.Color{}, 0, false
+	}
+	keys := make([][3]uint8, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	// Most common first; ties go to the darker color, then by value for a stable pick
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if counts[a] != counts[b] {
+			return counts[a] > counts[b]
+		}
+		la, lb := int(a[0])+int(a[1])+int(a[2]), int(b[0])+int(b[1])+int(b[2])
+		if la != lb {
+			return la < lb
+		}
+		return a[0] < b[0] || a[0] == b[0] && (a[1] < b[1] || a[1] == b[1] && a[2] < b[2])
+	})
+	top := keys[0]
+	return colors.NewColorFromRGB(float64(top[0])/255, float64(top[1])/255, float64(top[2])/255), counts[top], true
+}
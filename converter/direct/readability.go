@@ -0,0 +1,155 @@
+package direct
+
+import (
+	"math"
+	"strings"
+
+	"pdfdarkmode/converter/report"
+	"pdfdarkmode/converter/sample"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Readability check
+const (
+	smallTextSize  = 7.0 // Rendered size in points below which text counts as small
+	thinFontWeight = 300 // /FontWeight at or below which a font counts as thin (Light)
+	thinStemV      = 50  // /StemV below which a font counts as thin
+	poorTextShare  = 0.5 // Share of a page's text that is small or thin to warn about it
+	minPageText    = 40  // Bytes of shown text below which a page is not judged
+)
+
+// thinFontNames are parts of font names that mark thin weights
+var thinFontNames = []string{"hairline", "thin", "extralight", "ultralight", "light"}
+
+// SetCheckReadability makes Transform warn about pages where most of the text is very
+// small or set in a thin font. Dark text on light paper looks heavier than light text
+// on a dark background, so such text can become hard to read after conversion. The
+// check is advisory; it reads the font sizes and names of page-level text only.
+func (e *Engine) SetCheckReadability(enabled bool) {
+	e.readability = enabled
+}
+
+// warnPoorReadability warns about the analyzed pages whose text is mostly small or thin
+func (e *Engine) warnPoorReadability(ctx *model.Context) {
+	var pages []int
+	for _, pageNum := range sample.Spread(ctx.PageCount, e.analysisPages) {
+		if !e.converts(pageNum) {
+			continue
+		}
+		pageDict, _, inhPAttrs, err := ctx.PageDict(pageNum, false)
+		if err != nil {
+			continue
+		}
+		var resources types.Dict
+		if inhPAttrs != nil {
+			resources = inhPAttrs.Resources
+		}
+		thin := thinFonts(ctx, resources)
+
+		total, poor := textReadability(pageContent(ctx, pageDict), thin)
+		if total >= minPageText && float64(poor) >= poorTextShare*float64(total) {
+			pages = append(pages, pageNum)
+		}
+	}
+
+	if len(pages) > 0 {
+		report.Warnf("most text on page(s) %s is very small or in a thin font and may be hard to read light on dark; consider a higher --target-contrast or raster mode",
+			objectList(pages))
+	}
+}
+
+// thinFonts returns the resource names of the fonts in resources that have a thin
+// weight, by their /FontWeight or /StemV, or by their name
+func thinFonts(ctx *model.Context, resources types.Dict) map[string]bool {
+	thin := make(map[string]bool)
+	fonts, err := ctx.DereferenceDict(resources["Font"])
+	if err != nil || fonts == nil {
+		return thin
+	}
+	for name, obj := range fonts {
+		font, err := ctx.DereferenceDict(obj)
+		if err != nil || font == nil {
+			continue
+		}
+		thin[name] = isThinFont(ctx, font)
+	}
+	return thin
+}
+
+// isThinFont reports whether font, or the descendant font of a composite font, has a
+// thin weight
+func isThinFont(ctx *model.Context, font types.Dict) bool {
+	if descendants, err := ctx.DereferenceArray(font["DescendantFonts"]); err == nil && len(descendants) > 0 {
+		if descendant, err := ctx.DereferenceDict(descendants[0]); err == nil && descendant != nil {
+			font = descendant
+		}
+	}
+
+	if desc, err := ctx.DereferenceDict(font["FontDescriptor"]); err == nil && desc != nil {
+		if weight, err := ctx.DereferenceNumber(desc["FontWeight"]); err == nil && weight > 0 {
+			return weight <= thinFontWeight
+		}
+		if stemV, err := ctx.DereferenceNumber(desc["StemV"]); err == nil && stemV > 0 && stemV < thinStemV {
+			return true
+		}
+	}
+
+	if base := font.NameEntry("BaseFont"); base != nil {
+		// Subset fonts are named like ABCDEF+Font-Light
+		_, name, found := strings.Cut(*base, "+")
+		if !found {
+			name = *base
+		}
+		name = strings.ToLower(name)
+		for _, part := range thinFontNames {
+			if strings.Contains(name, part) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// textReadability returns how many bytes of text content shows, and how many of them
+// are shown smaller than smallTextSize or in one of the thin fonts. Text drawn by form
+// XObjects is not counted.
+func textReadability(content string, thin map[string]bool) (total, poor int) {
+	type graphicsState struct {
+		ctm  matrix
+		font string
+		size float64
+	}
+	identity := matrix{1, 0, 0, 1, 0, 0}
+	gs := graphicsState{ctm: identity}
+	var saved []graphicsState
+	tm := identity
+
+	walkOperators(content, func(op string, operands []float64, name string, text int) {
+		n := len(operands)
+		switch {
+		case op == "q":
+			saved = append(saved, gs)
+		case op == "Q" && len(saved) > 0:
+			gs = saved[len(saved)-1]
+			saved = saved[:len(saved)-1]
+		case op == "cm" && n == 6:
+			gs.ctm = matrix(operands).concat(gs.ctm)
+		case op == "BT":
+			tm = identity
+		case op == "Tm" && n == 6:
+			tm = matrix(operands)
+		case op == "Tf" && n >= 1:
+			gs.font, gs.size = name, operands[n-1]
+		case textShowOperators[op]:
+			m := tm.concat(gs.ctm)
+			size := math.Abs(gs.size) * math.Sqrt(math.Abs(m[0]*m[3]-m[1]*m[2]))
+			total += text
+			if size < smallTextSize || thin[gs.font] {
+				poor += text
+			}
+		}
+	})
+	return total, poor
+}
@@ -0,0 +1,32 @@
+package direct
+
+import (
+	"strings"
+	"testing"
+
+	"pdfdarkmode/converter/colors"
+	"pdfdarkmode/converter/report"
+)
+
+func TestSmallTextWarning(t *testing.T) {
+	const text = "(The quick brown fox jumps over the lazy dog, twice over.) Tj"
+	ctx := newTestContext(t, nil,
+		"BT /F1 5 Tf 72 700 Td "+text+" ET",           // Small
+		"BT /F1 12 Tf 72 700 Td "+text+" ET",          // Readable
+		"0.5 0 0 0.5 0 0 cm BT /F1 12 Tf "+text+" ET", // Scaled down to 6 points
+		"BT /F1 5 Tf (Fine print) Tj ET",              // Too little text to judge
+	)
+
+	e := NewEngine(false, colors.SchemeDark)
+	e.SetCheckReadability(true)
+	n := report.Count()
+	e.warnPoorReadability(ctx)
+
+	warnings := report.Since(n)
+	if len(warnings) != 1 {
+		t.Fatalf("got warnings %q, want one", warnings)
+	}
+	if !strings.Contains(warnings[0], "page(s) 1, 3 ") {
+		t.Errorf("warning %q does not name pages 1 and 3 alone", warnings[0])
+	}
+}
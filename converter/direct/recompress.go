@@ -0,0 +1,180 @@
+package direct
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/pdfcpu/pdfcpu/pkg/filter"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// SetNoRecompress keeps the source's compression profile: changed content streams are
+// encoded with exactly the filters and decode parameters they had, and the output uses
+// object and cross-reference streams only if the source did. Streams that are not
+// changed are always written with their original bytes.
+func (e *Engine) SetNoRecompress(enabled bool) {
+	e.noRecompress = enabled
+}
+
+// reencodableFilters are the filters whose encoders write what their decoders read
+// back. recompress_test.go round-trips each of them, including LZW with both
+// /EarlyChange values.
+var reencodableFilters = map[string]bool{
+	filter.Flate: true, filter.LZW: true, filter.ASCII85: true, filter.ASCIIHex: true, filter.RunLength: true,
+}
+
+// encodeStream encodes sd's changed content for writing. StreamDict.Encode ignores
+// predictors, so streams with one are always encoded like the source. Streams with a
+// filter that cannot be re-encoded are written with Flate instead.
+func (e *Engine) encodeStream(sd *types.StreamDict) error {
+	if !canReencode(sd) {
+		useFlate(sd)
+		return sd.Encode()
+	}
+	if e.noRecompress || hasPredictor(sd) {
+		return encodeLikeSource(sd)
+	}
+	return sd.Encode()
+}
+
+// canReencode reports whether every filter of sd's pipeline can encode its content.
+// The RunLength encoder fails on empty content.
+func canReencode(sd *types.StreamDict) bool {
+	for _, f := range sd.FilterPipeline {
+		if !reencodableFilters[f.Name] || (f.Name == filter.RunLength && len(sd.Content) == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// useFlate replaces sd's filters with a plain Flate filter
+func useFlate(sd *types.StreamDict) {
+	sd.FilterPipeline = []types.PDFFilter{{Name: filter.Flate}}
+	sd.Update("Filter", types.Name(filter.Flate))
+	sd.Delete("DecodeParms")
+}
+
+// hasPredictor reports whether one of sd's filters uses a predictor
+func hasPredictor(sd *types.StreamDict) bool {
+	for _, f := range sd.FilterPipeline {
+		if p, ok := filterParms(f.DecodeParms)["Predictor"]; ok && p != filter.PredictorNo {
+			return true
+		}
+	}
+	return false
+}
+
+// keepFileCompression makes ctx write object and cross-reference streams only if the
+// source used them
+func keepFileCompression(ctx *model.Context) {
+	ctx.WriteObjectStream = ctx.Read.UsingObjectStreams
+	ctx.WriteXRefStream = ctx.Read.UsingXRefStreams || ctx.Read.UsingObjectStreams
+}
+
+// encodeLikeSource applies sd's filter pipeline, as read from its /Filter and
+// /DecodeParms, to sd.Content. Unlike StreamDict.Encode it honors Flate and LZW
+// predictors, which readers would otherwise apply to unpredicted data: PNG predictors
+// are written as PNG None rows, and the TIFF predictor for 8-bit components.
+func encodeLikeSource(sd *types.StreamDict) error {
+	data := sd.Content
+	for i := len(sd.FilterPipeline) - 1; i >= 0; i-- {
+		f := sd.FilterPipeline[i]
+		parms := filterParms(f.DecodeParms)
+
+		if f.Name == filter.Flate || f.Name == filter.LZW {
+			predicted, err := applyPredictor(data, parms)
+			if err != nil {
+				return fmt.Errorf("%s: %w", f.Name, err)
+			}
+			data = predicted
+		}
+
+		fi, err := filter.NewFilter(f.Name, parms)
+		if err != nil {
+			return err
+		}
+		r, err := fi.Encode(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		if data, err = io.ReadAll(r); err != nil {
+			return err
+		}
+	}
+
+	sd.Raw = data
+	length := int64(len(data))
+	sd.StreamLength = &length
+	sd.Update("Length", types.Integer(length))
+	return nil
+}
+
+// filterParms returns the integer and boolean decode parameters of a filter
+func filterParms(d types.Dict) map[string]int {
+	parms := map[string]int{}
+	for k, v := range d {
+		switch v := v.(type) {
+		case types.Integer:
+			parms[k] = v.Value()
+		case types.Boolean:
+			if v.Value() {
+				parms[k] = 1
+			} else {
+				parms[k] = 0
+			}
+		}
+	}
+	return parms
+}
+
+// applyPredictor prepares content for a filter with the predictor in parms. Content
+// that does not fill its last row is padded with spaces, which content streams ignore.
+func applyPredictor(data []byte, parms map[string]int) ([]byte, error) {
+	predictor, ok := parms["Predictor"]
+	if !ok || predictor == filter.PredictorNo {
+		return data, nil
+	}
+
+	colors, bpc, columns := 1, 8, 1
+	if v, ok := parms["Colors"]; ok {
+		colors = v
+	}
+	if v, ok := parms["BitsPerComponent"]; ok {
+		bpc = v
+	}
+	if v, ok := parms["Columns"]; ok {
+		columns = v
+	}
+	rowLen := (columns*colors*bpc + 7) / 8
+	if rowLen <= 0 {
+		return nil, fmt.Errorf("invalid predictor row length")
+	}
+	if pad := len(data) % rowLen; pad != 0 {
+		data = append(data, bytes.Repeat([]byte{' '}, rowLen-pad)...)
+	}
+
+	var out bytes.Buffer
+	switch {
+	case predictor >= filter.PredictorNone:
+		for row := 0; row < len(data); row += rowLen {
+			out.WriteByte(0)
+			out.Write(data[row : row+rowLen])
+		}
+	case predictor == filter.PredictorTIFF && bpc == 8:
+		for row := 0; row < len(data); row += rowLen {
+			for i := row; i < row+rowLen; i++ {
+				b := data[i]
+				if i-row >= colors {
+					b -= data[i-colors]
+				}
+				out.WriteByte(b)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported predictor %d with %d bits per component", predictor, bpc)
+	}
+	return out.Bytes(), nil
+}
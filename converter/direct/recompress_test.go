@@ -0,0 +1,151 @@
+package direct
+
+import (
+	"bytes"
+	"compress/lzw"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/filter"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// decodeRunLength decodes RunLengthDecode data
+func decodeRunLength(data []byte) ([]byte, error) {
+	var out bytes.Buffer
+	for i := 0; i < len(data); {
+		n := int(data[i])
+		i++
+		switch {
+		case n == 128:
+			return out.Bytes(), nil
+		case n < 128:
+			if i+n+1 > len(data) {
+				return nil, fmt.Errorf("literal run past the end")
+			}
+			out.Write(data[i : i+n+1])
+			i += n + 1
+		default:
+			if i >= len(data) {
+				return nil, fmt.Errorf("repeat run past the end")
+			}
+			out.Write(bytes.Repeat(data[i:i+1], 257-n))
+			i++
+		}
+	}
+	return nil, fmt.Errorf("missing end of data marker")
+}
+
+// independentDecoders decode each filter without pdfcpu
+var independentDecoders = map[string]func([]byte) ([]byte, error){
+	filter.Flate: func(data []byte) ([]byte, error) {
+		r, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(r)
+	},
+	filter.ASCII85: func(data []byte) ([]byte, error) {
+		data, _ = bytes.CutSuffix(bytes.TrimSpace(data), []byte("~>"))
+		out := make([]byte, len(data))
+		n, _, err := ascii85.Decode(out, data, true)
+		return out[:n], err
+	},
+	filter.ASCIIHex: func(data []byte) ([]byte, error) {
+		data, _ = bytes.CutSuffix(bytes.TrimSpace(data), []byte(">"))
+		return hex.DecodeString(string(data))
+	},
+	filter.RunLength: decodeRunLength,
+	filter.LZW: func(data []byte) ([]byte, error) {
+		return io.ReadAll(lzw.NewReader(bytes.NewReader(data), lzw.MSB, 8))
+	},
+}
+
+func TestReencodableFiltersRoundTrip(t *testing.T) {
+	content := []byte("q 0.1 0.1 0.1 rg 0 0 612 792 re f Q\n" + strings.Repeat("BT /F1 12 Tf 72 700 Td (aaaaaaaaaaaa) Tj ET\n", 40))
+
+	tests := []struct {
+		name   string
+		filter string
+		parms  types.Dict
+	}{
+		{"Flate", filter.Flate, nil},
+		{"LZW", filter.LZW, nil},
+		{"LZW EarlyChange 1", filter.LZW, types.Dict{"EarlyChange": types.Integer(1)}},
+		{"LZW EarlyChange 0", filter.LZW, types.Dict{"EarlyChange": types.Integer(0)}},
+		{"ASCII85", filter.ASCII85, nil},
+		{"ASCIIHex", filter.ASCIIHex, nil},
+		{"RunLength", filter.RunLength, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reencodableFilters[tt.filter] {
+				t.Fatalf("%s is not in reencodableFilters", tt.filter)
+			}
+			sd := types.StreamDict{
+				Dict:           types.Dict{"Filter": types.Name(tt.filter)},
+				Content:        content,
+				FilterPipeline: []types.PDFFilter{{Name: tt.filter, DecodeParms: tt.parms}},
+			}
+			if tt.parms != nil {
+				sd.Dict["DecodeParms"] = tt.parms
+			}
+			if !canReencode(&sd) {
+				t.Fatal("canReencode = false")
+			}
+			if err := encodeLikeSource(&sd); err != nil {
+				t.Fatal(err)
+			}
+
+			decoded := types.StreamDict{Dict: sd.Dict, Raw: sd.Raw, FilterPipeline: sd.FilterPipeline}
+			if err := decoded.Decode(); err != nil {
+				t.Fatalf("pdfcpu cannot decode: %v", err)
+			}
+			if !bytes.Equal(decoded.Content, content) {
+				t.Errorf("pdfcpu decodes %d bytes that differ from the %d encoded", len(decoded.Content), len(content))
+			}
+
+			if tt.filter == filter.LZW && (tt.parms == nil || tt.parms["EarlyChange"] != types.Integer(0)) {
+				return // compress/lzw only reads codes widened late, as with /EarlyChange 0
+			}
+			got, err := independentDecoders[tt.filter](sd.Raw)
+			if err != nil {
+				t.Fatalf("independent decoder: %v", err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Errorf("independent decoder reads %d bytes that differ from the %d encoded", len(got), len(content))
+			}
+		})
+	}
+}
+
+func TestCanReencode(t *testing.T) {
+	tests := []struct {
+		filters []string
+		content string
+		want    bool
+	}{
+		{[]string{filter.Flate}, "0 g", true},
+		{[]string{filter.ASCII85, filter.Flate}, "0 g", true},
+		{[]string{filter.RunLength}, "0 g", true},
+		{[]string{filter.RunLength}, "", false},
+		{[]string{filter.DCT}, "0 g", false},
+		{[]string{filter.ASCIIHex, filter.CCITTFax}, "0 g", false},
+	}
+
+	for _, tt := range tests {
+		sd := types.StreamDict{Content: []byte(tt.content)}
+		for _, name := range tt.filters {
+			sd.FilterPipeline = append(sd.FilterPipeline, types.PDFFilter{Name: name})
+		}
+		if got := canReencode(&sd); got != tt.want {
+			t.Errorf("canReencode(%v, %q) = %t, want %t", tt.filters, tt.content, got, tt.want)
+		}
+	}
+}
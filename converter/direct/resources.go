@@ -0,0 +1,44 @@
+package direct
+
+import (
+	"fmt"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// addResource adds ref to the page's resources of the given category (e.g. Shading or
+// XObject) and returns the name used, base or base followed by a number. Inherited
+// resources get the entry where they are defined; pages sharing them reuse it.
+func addResource(ctx *model.Context, pageDict types.Dict, inhPAttrs *model.InheritedPageAttrs, category, base string, ref types.IndirectRef) (string, error) {
+	var resources types.Dict
+	if inhPAttrs != nil {
+		resources = inhPAttrs.Resources
+	}
+	if resources == nil {
+		resources = types.Dict{}
+		pageDict["Resources"] = resources
+	}
+	entries, err := ctx.DereferenceDict(resources[category])
+	if err != nil {
+		return "", err
+	}
+	if entries == nil {
+		entries = types.Dict{}
+		resources[category] = entries
+	}
+
+	name := base
+	for i := 1; ; i++ {
+		obj, taken := entries[name]
+		if !taken {
+			break
+		}
+		if r, ok := obj.(types.IndirectRef); ok && r == ref {
+			return name, nil
+		}
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+	entries[name] = ref
+	return name, nil
+}
@@ -0,0 +1,177 @@
+package direct
+
+import (
+	"fmt"
+
+	"pdfdarkmode/converter/report"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// pageBoxes are the page boundary entries moved along with the content
+var pageBoxes = []string{"CropBox", "BleedBox", "TrimBox", "ArtBox"}
+
+// NormalizeRotations bakes /Rotate into every rotated page selected for conversion.
+// Returns the number of pages changed.
+func (e *Engine) NormalizeRotations(ctx *model.Context) int {
+	count := 0
+	for pageNum := 1; pageNum <= ctx.PageCount; pageNum++ {
+		if !e.converts(pageNum) {
+			continue
+		}
+		changed, err := e.normalizePageRotation(ctx, pageNum)
+		if err != nil {
+			report.Warnf("page %d rotation failed: %v", pageNum, err)
+			continue
+		}
+		if changed {
+			count++
+		}
+	}
+	return count
+}
+
+// normalizePageRotation wraps a rotated page's content in a cm transform that turns it
+// upright, rotates its boxes and annotation rectangles to match, and resets /Rotate to 0.
+// Viewers that ignore /Rotate then show the same upright page as those that honor it.
+func (e *Engine) normalizePageRotation(ctx *model.Context, pageNum int) (bool, error) {
+	pageDict, _, inhPAttrs, err := ctx.PageDict(pageNum, false)
+	if err != nil {
+		return false, err
+	}
+	if inhPAttrs == nil {
+		return false, nil
+	}
+
+	rotate := ((inhPAttrs.Rotate % 360) + 360) % 360
+	if rotate == 0 {
+		return false, nil
+	}
+	if rotate%90 != 0 {
+		return false, fmt.Errorf("unsupported rotation: %d", rotate)
+	}
+
+	mediaBox := inhPAttrs.MediaBox
+	if mediaBox == nil {
+		mediaBox = types.NewRectangle(0, 0, 612, 792)
+	}
+	m := rotationMatrix(mediaBox, rotate)
+
+	// Boxes are transformed before the MediaBox they are relative to changes
+	for _, key := range pageBoxes {
+		var box *types.Rectangle
+		if obj, found := pageDict.Find(key); found {
+			if arr, err := ctx.DereferenceArray(obj); err == nil && len(arr) == 4 {
+				box = types.RectForArray(arr)
+			}
+		} else if key == "CropBox" {
+			box = inhPAttrs.CropBox
+		}
+		if box != nil {
+			pageDict[key] = m.transformRect(box).Array()
+		}
+	}
+	pageDict["MediaBox"] = m.transformRect(mediaBox).Array()
+	pageDict["Rotate"] = types.Integer(0)
+
+	e.rotateAnnotations(ctx, pageDict, m)
+
+	// Wrap the existing content: q <matrix> cm ... Q
+	contentsEntry, found := pageDict.Find("Contents")
+	if !found {
+		return true, nil
+	}
+
+	var contents types.Array
+	switch c := contentsEntry.(type) {
+	case types.IndirectRef:
+		contents = types.Array{c}
+	case types.Array:
+		contents = c
+	default:
+		return false, fmt.Errorf("unexpected contents type %T", contentsEntry)
+	}
+
+	prefix, err := ctx.StreamDictIndRef([]byte(fmt.Sprintf("q %s cm\n", m)))
+	if err != nil {
+		return false, err
+	}
+	suffix, err := ctx.StreamDictIndRef([]byte("\nQ\n"))
+	if err != nil {
+		return false, err
+	}
+
+	wrapped := append(types.Array{*prefix}, contents...)
+	pageDict["Contents"] = append(wrapped, *suffix)
+
+	return true, nil
+}
+
+// rotateAnnotations moves annotation rectangles along with the rotated content
+func (e *Engine) rotateAnnotations(ctx *model.Context, pageDict types.Dict, m matrix) {
+	annots, err := ctx.DereferenceArray(pageDict["Annots"])
+	if err != nil {
+		return
+	}
+	for _, obj := range annots {
+		annot, err := ctx.DereferenceDict(obj)
+		if err != nil || annot == nil {
+			continue
+		}
+		arr, err := ctx.DereferenceArray(annot["Rect"])
+		if err != nil || len(arr) != 4 {
+			continue
+		}
+		annot["Rect"] = m.transformRect(types.RectForArray(arr)).Array()
+	}
+}
+
+// matrix is a PDF transformation matrix [a b c d e f]
+type matrix [6]float64
+
+// rotationMatrix returns the transform that turns content shown rotated clockwise
+// by rotate degrees within mediaBox into upright content with its origin at 0,0
+func rotationMatrix(mediaBox *types.Rectangle, rotate int) matrix {
+	llx, lly := mediaBox.LL.X, mediaBox.LL.Y
+	urx, ury := mediaBox.UR.X, mediaBox.UR.Y
+
+	switch rotate {
+	case 90:
+		// (x, y) -> (y - lly, urx - x)
+		return matrix{0, -1, 1, 0, -lly, urx}
+	case 180:
+		// (x, y) -> (urx - x, ury - y)
+		return matrix{-1, 0, 0, -1, urx, ury}
+	case 270:
+		// (x, y) -> (ury - y, x - llx)
+		return matrix{0, 1, -1, 0, ury, -llx}
+	}
+	return matrix{1, 0, 0, 1, 0, 0}
+}
+
+// apply transforms a point
+func (m matrix) apply(x, y float64) (float64, float64) {
+	return m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]
+}
+
+// transformRect transforms a rectangle, returning the normalized result
+func (m matrix) transformRect(r *types.Rectangle) *types.Rectangle {
+	x1, y1 := m.apply(r.LL.X, r.LL.Y)
+	x2, y2 := m.apply(r.UR.X, r.UR.Y)
+	return types.NewRectangle(min(x1, x2), min(y1, y2), max(x1, x2), max(y1, y2))
+}
+
+// concat returns m applied before n, as "m cm" does to the transformation n in effect
+func (m matrix) concat(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2], m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2], m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4], m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+// String formats the matrix as cm operands
+func (m matrix) String() string {
+	return fmt.Sprintf("%.4f %.4f %.4f %.4f %.4f %.4f", m[0], m[1], m[2], m[3], m[4], m[5])
+}
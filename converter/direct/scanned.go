@@ -0,0 +1,108 @@
+package direct
+
+import (
+	"pdfdarkmode/converter/sample"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Scanned document detection
+const (
+	scanImageCoverage = 0.9 // Share of the page one image must cover for a scanned page
+	scannedPageShare  = 0.8 // Share of the analyzed pages that must be scanned
+)
+
+// ScanReport is what DetectScanned found
+type ScanReport struct {
+	Analyzed int // Pages looked at
+	Scanned  int // Of them, pages that are one full-page image with no visible text
+}
+
+// IsScanned reports whether the document looks scanned: most analyzed pages are a
+// full-page image without visible text
+func (r ScanReport) IsScanned() bool {
+	return r.Analyzed > 0 && float64(r.Scanned) >= scannedPageShare*float64(r.Analyzed)
+}
+
+// DetectScanned looks at n pages spread over ctx (see sample.Spread; 0 for all) and
+// counts those that draw an image covering nearly the whole page and show no visible
+// text. Invisible text, such as the OCR layer of a searchable scan, does not count.
+func DetectScanned(ctx *model.Context, n int) ScanReport {
+	var r ScanReport
+	for _, pageNum := range sample.Spread(ctx.PageCount, n) {
+		pageDict, _, inhPAttrs, err := ctx.PageDict(pageNum, false)
+		if err != nil || inhPAttrs == nil || inhPAttrs.MediaBox == nil {
+			continue
+		}
+		r.Analyzed++
+		if isScannedPage(ctx, pageDict, inhPAttrs) {
+			r.Scanned++
+		}
+	}
+	return r
+}
+
+// isScannedPage reports whether the page draws an image XObject or inline image
+// covering scanImageCoverage of its media box and shows no visible text. Form XObjects
+// are not looked into.
+func isScannedPage(ctx *model.Context, pageDict types.Dict, inhPAttrs *model.InheritedPageAttrs) bool {
+	images := imageXObjects(ctx, inhPAttrs.Resources)
+
+	type graphicsState struct {
+		ctm    matrix
+		render int // Text rendering mode; 3 and 7 draw nothing
+	}
+	gs := graphicsState{ctm: matrix{1, 0, 0, 1, 0, 0}}
+	var saved []graphicsState
+
+	box := inhPAttrs.MediaBox
+	pageArea := box.Width() * box.Height()
+	fullImage, visibleText := false, false
+	walkOperators(pageContent(ctx, pageDict), func(op string, operands []float64, name string, text int) {
+		n := len(operands)
+		switch {
+		case op == "q":
+			saved = append(saved, gs)
+		case op == "Q" && len(saved) > 0:
+			gs = saved[len(saved)-1]
+			saved = saved[:len(saved)-1]
+		case op == "cm" && n == 6:
+			gs.ctm = matrix(operands).concat(gs.ctm)
+		case op == "Tr" && n == 1:
+			gs.render = int(operands[0])
+		case textShowOperators[op]:
+			if text > 0 && gs.render != 3 && gs.render != 7 {
+				visibleText = true
+			}
+		case op == "Do" && images[name], op == "BI":
+			// Images are drawn into the unit square
+			r := gs.ctm.transformRect(types.NewRectangle(0, 0, 1, 1))
+			w := min(r.UR.X, box.UR.X) - max(r.LL.X, box.LL.X)
+			h := min(r.UR.Y, box.UR.Y) - max(r.LL.Y, box.LL.Y)
+			if w > 0 && h > 0 && w*h >= scanImageCoverage*pageArea {
+				fullImage = true
+			}
+		}
+	})
+	return fullImage && !visibleText
+}
+
+// imageXObjects returns the names of the image XObjects in resources
+func imageXObjects(ctx *model.Context, resources types.Dict) map[string]bool {
+	images := make(map[string]bool)
+	xobjects, err := ctx.DereferenceDict(resources["XObject"])
+	if err != nil || xobjects == nil {
+		return images
+	}
+	for name, obj := range xobjects {
+		sd, _, err := ctx.DereferenceStreamDict(obj)
+		if err != nil || sd == nil {
+			continue
+		}
+		if subtype := sd.Dict.NameEntry("Subtype"); subtype != nil && *subtype == "Image" {
+			images[name] = true
+		}
+	}
+	return images
+}
@@ -0,0 +1,70 @@
+package direct
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"pdfdarkmode/converter/report"
+)
+
+// SetStrict reports everything the conversion leaves unchanged as a warning, so a
+// strict caller can fail on them: content streams that cannot be decoded, color
+// spaces whose operators are not transformed, and documents with no color operators
+// transformed at all
+func (e *Engine) SetStrict(enabled bool) {
+	e.strict = enabled
+}
+
+// noteUnhandled records the unhandled color spaces content, the content stream with
+// object number objNum, selects, in strict mode
+func (e *Engine) noteUnhandled(content string, spaces map[string]string, objNum int) {
+	if !e.strict {
+		return
+	}
+	for _, name := range e.parser.unhandledColorSpaces(content, spaces) {
+		if !slices.Contains(e.unhandledIn[name], objNum) {
+			e.unhandledIn[name] = append(e.unhandledIn[name], objNum)
+		}
+		e.unhandled[name]++
+	}
+}
+
+// warnUnhandled reports what Transform left unchanged, in strict mode
+func (e *Engine) warnUnhandled(transformed int) {
+	if !e.strict {
+		return
+	}
+
+	names := make([]string, 0, len(e.unhandled))
+	for name := range e.unhandled {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		report.Warnf("color space /%s is not transformed (selected %d time(s) in content stream(s) %s); its colors were left unchanged",
+			name, e.unhandled[name], objectList(e.unhandledIn[name]))
+	}
+
+	if transformed == 0 {
+		report.Warnf("no color operators were transformed")
+	}
+}
+
+// maxListedObjects is how many object numbers a warning lists before summarizing the rest
+const maxListedObjects = 10
+
+// objectList formats object numbers for a warning, e.g. "12, 15 and 3 more"
+func objectList(objNums []int) string {
+	parts := make([]string, 0, maxListedObjects)
+	for _, n := range objNums[:min(len(objNums), maxListedObjects)] {
+		parts = append(parts, strconv.Itoa(n))
+	}
+	list := strings.Join(parts, ", ")
+	if more := len(objNums) - maxListedObjects; more > 0 {
+		list += fmt.Sprintf(" and %d more", more)
+	}
+	return list
+}
@@ -0,0 +1,42 @@
+package direct
+
+import "pdfdarkmode/converter/colormath"
+
+// Lightness range of the light gray fills tables use for zebra striping and header
+// rows, e.g. 0.9 g or #f2f2f2. Lighter fills are paper white.
+const (
+	stripeMinLightness = 0.88
+	stripeMaxLightness = 0.97
+)
+
+// stripeContrast is how far the stripe color is mixed away from the background,
+// towards white on dark schemes and towards black on light ones
+const stripeContrast = 0.08
+
+// SetStripeAware maps light gray fills, such as alternating table rows, to a stripe
+// color slightly apart from the background instead of interpolating them into it, so
+// the striping stays visible
+func (t *Transformer) SetStripeAware(enabled bool) {
+	t.stripes = enabled
+	clear(t.cache)
+}
+
+// stripeOperator returns op set to the stripe color if it is a light gray fill
+func (t *Transformer) stripeOperator(op ColorOperator) (string, bool) {
+	if !t.stripes || op.IsStroke {
+		return "", false
+	}
+	r, g, b, ok := operatorRGB(op)
+	if !ok || colormath.Saturation(r, g, b) >= colormath.DocumentSaturation {
+		return "", false
+	}
+	if l := colormath.Lightness(r, g, b); l < stripeMinLightness || l > stripeMaxLightness {
+		return "", false
+	}
+
+	stripe := t.scheme.Background.Lighten(stripeContrast)
+	if t.scheme.IsLight() {
+		stripe = t.scheme.Background.Darken(stripeContrast)
+	}
+	return colorOperator(op, stripe), true
+}
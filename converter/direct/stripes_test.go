@@ -0,0 +1,40 @@
+package direct
+
+import (
+	"math"
+	"testing"
+
+	"pdfdarkmode/converter/colors"
+)
+
+// minStripeDelta is the least lightness difference that keeps alternating rows apart
+const minStripeDelta = 0.05
+
+func TestStripeAware(t *testing.T) {
+	rows := [][2]string{
+		{"1 g", "0.94 g"},
+		{"1 1 1 rg", "0.949 0.949 0.949 rg"},
+		{"1 g", "0.9 g"},
+	}
+
+	for _, scheme := range []colors.Scheme{colors.SchemeDark, colors.SchemeNord, colors.SchemeReadingLight} {
+		tr := NewTransformer(scheme)
+		tr.SetStripeAware(true)
+		for _, row := range rows {
+			paper := transformedLightness(t, tr, row[0])
+			stripe := transformedLightness(t, tr, row[1])
+			if d := math.Abs(paper - stripe); d < minStripeDelta {
+				t.Errorf("%s: %q and %q differ by %.3f in lightness, want at least %g",
+					scheme.Name, row[0], row[1], d, minStripeDelta)
+			}
+		}
+	}
+
+	// Strokes are not stripes
+	op := NewParser().FindColorOperators("0.94 G")[0]
+	tr := NewTransformer(colors.SchemeDark)
+	tr.SetStripeAware(true)
+	if got, want := tr.TransformOperator(op), NewTransformer(colors.SchemeDark).TransformOperator(op); got != want {
+		t.Errorf("0.94 G transformed to %q, want %q as without stripes", got, want)
+	}
+}
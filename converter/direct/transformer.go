@@ -4,23 +4,203 @@ import (
 	"fmt"
 	"math"
 	"strconv"
+	"strings"
 
+	"pdfdarkmode/converter/colormath"
 	"pdfdarkmode/converter/colors"
 )
 
 // Transformer handles color value transformations for dark mode
 type Transformer struct {
-	scheme colors.Scheme
+	scheme       colors.Scheme
+	remaps       []colors.Remap // Exact source colors replaced before the scheme is applied
+	metric       string         // Distance metric for matching colors to remap sources
+	metricTol    float64        // Remap match tolerance under metric, 0 for its default
+	tintStrength float64        // How much of a tinted scheme's tint converted grays keep (0-1)
+	minColorL    float64        // Lightness floor for colorful values
+	maxColorL    float64        // Lightness above which colorful values are toned down
+	satBoost     float64        // Factor colorful saturation is multiplied by
+	keepWhite    float64        // Document colors lighter than this are kept as they are, 0 for none
+	tolerance    float64        // Half-width of the saturation band blending document and colorful mappings
+	stripes      bool           // Map light gray fills to a stripe color distinct from the background
+	primary      *colors.Color  // Document's main text color, mapped exactly to the scheme text; nil for none
+	primaryL     float64        // Lightness of primary
+	matcher      *colors.Matcher
+	cache        map[string]cachedTransform
 }
 
+// cachedTransform is a memoized TransformOperator result
+type cachedTransform struct {
+	out       string
+	unchanged bool // The operator is kept as written
+}
+
+// maxCachedTransforms bounds the cache for documents with very many distinct colors,
+// e.g. gradients drawn as thousands of strips
+const maxCachedTransforms = 4096
+
+// Default lightness range for colorful values in direct mode
+const (
+	DefaultMinColorLightness = 0.55
+	DefaultMaxColorLightness = 0.85
+)
+
+// DefaultSaturationBoost keeps colorful values vibrant on the dark background
+const DefaultSaturationBoost = 1.15
+
+// remapTolerance is how far each 8-bit channel may be from a remap source and still match
+const remapTolerance = 2
+
+// remapDeltaE is how far in CIELAB ΔE a color may be from a remap source and still
+// match, about the smallest difference one can see
+const remapDeltaE = 2.3
+
 // NewTransformer creates a new color transformer with the given color scheme
 func NewTransformer(scheme colors.Scheme) *Transformer {
-	return &Transformer{scheme: scheme}
+	return &Transformer{
+		scheme:       scheme,
+		tintStrength: 1,
+		minColorL:    DefaultMinColorLightness,
+		maxColorL:    DefaultMaxColorLightness,
+		satBoost:     DefaultSaturationBoost,
+		metric:       colors.MetricRGB,
+		cache:        make(map[string]cachedTransform),
+	}
+}
+
+// SetColorLightness sets the lightness floor and ceiling for colorful values.
+// Zero keeps the default.
+func (t *Transformer) SetColorLightness(min, max float64) {
+	if min > 0 {
+		t.minColorL = min
+	}
+	if max > 0 {
+		t.maxColorL = max
+	}
+	clear(t.cache)
+}
+
+// SetSaturationBoost sets the factor colorful values' saturation is multiplied by, 1
+// for none. Zero keeps the default.
+func (t *Transformer) SetSaturationBoost(boost float64) {
+	if boost > 0 {
+		t.satBoost = boost
+	}
+	clear(t.cache)
+}
+
+// SetTintStrength scales how much of a tinted scheme's tint is applied to converted
+// gray values: 0 yields neutral grays, 1 (the default) the full scheme tint
+func (t *Transformer) SetTintStrength(strength float64) {
+	t.tintStrength = math.Max(0, math.Min(1, strength))
+	clear(t.cache)
+}
+
+// SetColorTolerance blends the document and colorful mappings for colors whose
+// saturation is within tolerance of the boundary between them, so near-identical colors
+// on either side map to near-identical results. 0 (the default) keeps a hard boundary.
+func (t *Transformer) SetColorTolerance(tolerance float64) {
+	t.tolerance = math.Max(0, tolerance)
+	clear(t.cache)
+}
+
+// applyTintStrength blends a tinted color towards the neutral gray of equal luma
+func (t *Transformer) applyTintStrength(r, g, b float64) (float64, float64, float64) {
+	if t.tintStrength >= 1 {
+		return r, g, b
+	}
+	gray := 0.299*r + 0.587*g + 0.114*b
+	s := t.tintStrength
+	return gray + s*(r-gray), gray + s*(g-gray), gray + s*(b-gray)
+}
+
+// SetPreserveWhite keeps document colors lighter than threshold (0-1) as they are
+// instead of turning them into the background, e.g. white boxes on printed forms.
+// Zero converts them as usual.
+func (t *Transformer) SetPreserveWhite(threshold float64) {
+	t.keepWhite = threshold
+	clear(t.cache)
+}
+
+// SetRemaps sets the exact color remaps that take precedence over the scheme
+func (t *Transformer) SetRemaps(remaps []colors.Remap) {
+	t.remaps = remaps
+	t.updateMatcher()
+}
+
+// SetColorMetric sets how colors are matched to remap sources: colors.MetricRGB (the
+// default) or colors.MetricCIELAB, within tolerance in that metric's units. Zero
+// tolerance keeps the metric's default.
+func (t *Transformer) SetColorMetric(metric string, tolerance float64) {
+	t.metric = metric
+	t.metricTol = tolerance
+	t.updateMatcher()
+}
+
+// updateMatcher rebuilds the remap matcher after the remaps or metric change
+func (t *Transformer) updateMatcher() {
+	tolerance := t.metricTol
+	if tolerance <= 0 {
+		tolerance = remapTolerance
+		if t.metric == colors.MetricCIELAB {
+			tolerance = remapDeltaE
+		}
+	}
+	t.matcher = colors.NewMatcher(t.remaps, t.metric, tolerance)
+	clear(t.cache)
+}
+
+// colorKey identifies an operator's color regardless of how its operands are spaced
+func colorKey(op ColorOperator) string {
+	return op.ColorSpace + " " + strings.Join(op.Values, " ") + " " + op.Operator
 }
 
 // TransformOperator transforms a color operator for dark mode
-// Returns the new operator string
+// Returns the new operator string. Results are memoized, since documents set the
+// same few colors (body text, rules, backgrounds) over and over.
 func (t *Transformer) TransformOperator(op ColorOperator) string {
+	if op.Pattern != "" {
+		return t.transformPatternOperator(op)
+	}
+
+	key := colorKey(op)
+	if c, found := t.cache[key]; found {
+		if c.unchanged {
+			return op.FullMatch
+		}
+		return c.out
+	}
+
+	out := t.transformOperator(op)
+	if len(t.cache) < maxCachedTransforms {
+		t.cache[key] = cachedTransform{out: out, unchanged: out == op.FullMatch}
+	}
+	return out
+}
+
+// transformOperator computes TransformOperator's result
+func (t *Transformer) transformOperator(op ColorOperator) string {
+	// Registration black is used for crop and registration marks, leave it alone
+	if isRegistrationBlack(op) {
+		return op.FullMatch
+	}
+
+	if newOp, ok := t.remapOperator(op); ok {
+		return newOp
+	}
+
+	if t.preservesWhite(op) {
+		return op.FullMatch
+	}
+
+	if newOp, ok := t.stripeOperator(op); ok {
+		return newOp
+	}
+
+	if newOp, ok := t.primaryTextOperator(op); ok {
+		return newOp
+	}
+
 	switch op.ColorSpace {
 	case "rgb":
 		return t.transformRGB(op)
@@ -33,6 +213,70 @@ func (t *Transformer) TransformOperator(op ColorOperator) string {
 	}
 }
 
+// operatorRGB returns op's color as RGB, or false for an unknown color space
+func operatorRGB(op ColorOperator) (r, g, b float64, ok bool) {
+	switch op.ColorSpace {
+	case "rgb":
+		return parseFloat(op.Values[0]), parseFloat(op.Values[1]), parseFloat(op.Values[2]), true
+	case "gray":
+		gray := parseFloat(op.Values[0])
+		return gray, gray, gray, true
+	case "cmyk":
+		k := parseFloat(op.Values[3])
+		return (1 - parseFloat(op.Values[0])) * (1 - k),
+			(1 - parseFloat(op.Values[1])) * (1 - k),
+			(1 - parseFloat(op.Values[2])) * (1 - k), true
+	}
+	return 0, 0, 0, false
+}
+
+// preservesWhite reports whether op sets a document color light enough to keep as is
+func (t *Transformer) preservesWhite(op ColorOperator) bool {
+	if t.keepWhite <= 0 {
+		return false
+	}
+	r, g, b, ok := operatorRGB(op)
+	if !ok {
+		return false
+	}
+	return colormath.Saturation(r, g, b) < colormath.DocumentSaturation && colormath.Lightness(r, g, b) > t.keepWhite
+}
+
+// remapOperator applies a stylesheet remap to op if its color matches one
+func (t *Transformer) remapOperator(op ColorOperator) (string, bool) {
+	if len(t.remaps) == 0 {
+		return "", false
+	}
+
+	r, g, b, ok := operatorRGB(op)
+	if !ok {
+		return "", false
+	}
+
+	to, ok := t.matcher.Lookup(toByte(r), toByte(g), toByte(b))
+	if !ok {
+		return "", false
+	}
+	return colorOperator(op, to), true
+}
+
+// colorOperator writes c with op's operator, switching gray operators to RGB
+func colorOperator(op ColorOperator, c colors.Color) string {
+	switch op.ColorSpace {
+	case "gray":
+		return fmt.Sprintf("%.3f %.3f %.3f %s", c.R, c.G, c.B, grayToRGBOperator(op.Operator))
+	case "cmyk":
+		cc, m, y, k := colormath.RGBToCMYK(c.R, c.G, c.B)
+		return fmt.Sprintf("%.3f %.3f %.3f %.3f %s", cc, m, y, k, op.Operator)
+	}
+	return fmt.Sprintf("%.3f %.3f %.3f %s", c.R, c.G, c.B, op.Operator)
+}
+
+// toByte converts a 0-1 color component to 0-255, clamping out of range values
+func toByte(v float64) uint8 {
+	return uint8(math.Round(math.Max(0, math.Min(1, v)) * 255))
+}
+
 // transformRGB transforms an RGB color operator
 func (t *Transformer) transformRGB(op ColorOperator) string {
 	r := parseFloat(op.Values[0])
@@ -40,34 +284,51 @@ func (t *Transformer) transformRGB(op ColorOperator) string {
 	b := parseFloat(op.Values[2])
 
 	// Calculate properties
-	saturation := t.getSaturation(r, g, b)
-	lightness := t.getLightness(r, g, b)
+	saturation := colormath.Saturation(r, g, b)
+	lightness := colormath.Lightness(r, g, b)
 
-	var newR, newG, newB float64
-
-	// Check if this is a document color (grayscale or near-grayscale)
-	if saturation < 0.15 {
-		// Document color - apply smart inversion
-		newR, newG, newB = t.invertDocumentColorRGB(lightness)
-	} else {
-		// Colorful pixel - adjust brightness while preserving hue
-		newR, newG, newB = t.adjustColorfulRGB(r, g, b, lightness)
-	}
+	// Document colors (grayscale or near-grayscale) get smart inversion, colorful ones
+	// keep their hue; colors within the tolerance band blend the two
+	newR, newG, newB := t.classifiedRGB(r, g, b, saturation, lightness)
 
 	return fmt.Sprintf("%.3f %.3f %.3f %s", newR, newG, newB, op.Operator)
 }
 
+// classifiedRGB maps an RGB document or colorful color, blending both mappings for
+// saturations within the color tolerance of the boundary between them
+func (t *Transformer) classifiedRGB(r, g, b, saturation, lightness float64) (float64, float64, float64) {
+	w := colormath.DocumentWeight(saturation, t.tolerance)
+	if w >= 1 {
+		return t.invertDocumentColorRGB(t.documentLightness(lightness))
+	}
+	cr, cg, cb := t.adjustColorfulRGB(r, g, b, lightness)
+	if w <= 0 {
+		return cr, cg, cb
+	}
+	dr, dg, db := t.invertDocumentColorRGB(t.documentLightness(lightness))
+	return dr*w + cr*(1-w), dg*w + cg*(1-w), db*w + cb*(1-w)
+}
+
 // transformGray transforms a grayscale color operator
 // For tinted schemes (like sepia), this converts gray to RGB to preserve the tint
 func (t *Transformer) transformGray(op ColorOperator) string {
-	gray := parseFloat(op.Values[0])
+	gray := t.documentLightness(parseFloat(op.Values[0]))
 
 	bg := t.scheme.Background
 	txt := t.scheme.Text
 
 	// Check if scheme has tinted colors (non-grayscale)
-	bgIsTinted := !isGrayscale(bg.R, bg.G, bg.B)
-	txtIsTinted := !isGrayscale(txt.R, txt.G, txt.B)
+	bgIsTinted := !colormath.IsNeutral(bg.R, bg.G, bg.B)
+	txtIsTinted := !colormath.IsNeutral(txt.R, txt.G, txt.B)
+
+	if t.scheme.IsLight() {
+		newR, newG, newB := t.softenDocumentColorRGB(gray)
+		if bgIsTinted || txtIsTinted {
+			newR, newG, newB = t.applyTintStrength(newR, newG, newB)
+			return fmt.Sprintf("%.3f %.3f %.3f %s", newR, newG, newB, grayToRGBOperator(op.Operator))
+		}
+		return fmt.Sprintf("%.3f %s", newR, op.Operator)
+	}
 
 	if bgIsTinted || txtIsTinted {
 		// For tinted schemes, convert to RGB operator to preserve color tint
@@ -82,15 +343,14 @@ func (t *Transformer) transformGray(op ColorOperator) string {
 			newR, newG, newB = txt.R, txt.G, txt.B
 		} else if gray < 0.4 {
 			factor := gray / 0.4
-			midGray := 0.5
-			newR = txt.R - factor*(txt.R-midGray)
-			newG = txt.G - factor*(txt.G-midGray)
-			newB = txt.B - factor*(txt.B-midGray)
+			newR, newG, newB = interpolateColor(txt, midGray, factor)
 		} else {
 			inverted := 1 - gray
 			newR, newG, newB = inverted, inverted, inverted
 		}
 
+		newR, newG, newB = t.applyTintStrength(newR, newG, newB)
+
 		// Convert gray operator to RGB operator
 		rgbOp := grayToRGBOperator(op.Operator)
 		return fmt.Sprintf("%.3f %.3f %.3f %s", newR, newG, newB, rgbOp)
@@ -115,12 +375,6 @@ func (t *Transformer) transformGray(op ColorOperator) string {
 	return fmt.Sprintf("%.3f %s", newGray, op.Operator)
 }
 
-// isGrayscale checks if RGB values are approximately equal (grayscale)
-func isGrayscale(r, g, b float64) bool {
-	const threshold = 0.02
-	return math.Abs(r-g) < threshold && math.Abs(g-b) < threshold && math.Abs(r-b) < threshold
-}
-
 // grayToRGBOperator converts a grayscale PDF operator to its RGB equivalent
 func grayToRGBOperator(grayOp string) string {
 	switch grayOp {
@@ -141,21 +395,37 @@ func (t *Transformer) transformCMYK(op ColorOperator) string {
 	k := parseFloat(op.Values[3])
 
 	// Convert CMYK to RGB for analysis
-	r := (1 - c) * (1 - k)
-	g := (1 - m) * (1 - k)
-	b := (1 - y) * (1 - k)
-
-	saturation := t.getSaturation(r, g, b)
-	lightness := t.getLightness(r, g, b)
+	r, g, b := colormath.CMYKToRGB(c, m, y, k)
+
+	saturation := colormath.Saturation(r, g, b)
+	lightness := colormath.Lightness(r, g, b)
+	if w := colormath.DocumentWeight(saturation, t.tolerance); w > 0 && w < 1 {
+		// Near the boundary, blend both treatments in RGB
+		newR, newG, newB := t.classifiedRGB(r, g, b, saturation, lightness)
+		newC, newM, newY, newK := colormath.RGBToCMYK(newR, newG, newB)
+		return fmt.Sprintf("%.3f %.3f %.3f %.3f %s", newC, newM, newY, newK, op.Operator)
+	}
+	if saturation < colormath.DocumentSaturation {
+		lightness = t.documentLightness(lightness)
+	}
 
 	bg := t.scheme.Background
 	txt := t.scheme.Text
 
 	// Check if scheme has tinted colors
-	bgIsTinted := !isGrayscale(bg.R, bg.G, bg.B)
-	txtIsTinted := !isGrayscale(txt.R, txt.G, txt.B)
+	bgIsTinted := !colormath.IsNeutral(bg.R, bg.G, bg.B)
+	txtIsTinted := !colormath.IsNeutral(txt.R, txt.G, txt.B)
 
-	if saturation < 0.15 {
+	if saturation < colormath.DocumentSaturation && t.scheme.IsLight() {
+		newR, newG, newB := t.softenDocumentColorRGB(lightness)
+		if bgIsTinted || txtIsTinted {
+			newR, newG, newB = t.applyTintStrength(newR, newG, newB)
+			return tintedCMYKOperator(op, newR, newG, newB)
+		}
+		return fmt.Sprintf("%.3f %.3f %.3f %.3f %s", 0.0, 0.0, 0.0, 1-newR, op.Operator)
+	}
+
+	if saturation < colormath.DocumentSaturation {
 		// Document color - for tinted schemes, output RGB to preserve tint
 		if bgIsTinted || txtIsTinted {
 			var newR, newG, newB float64
@@ -168,17 +438,13 @@ func (t *Transformer) transformCMYK(op ColorOperator) string {
 				newR, newG, newB = txt.R, txt.G, txt.B
 			} else if lightness < 0.4 {
 				factor := lightness / 0.4
-				midGray := 0.5
-				newR = txt.R - factor*(txt.R-midGray)
-				newG = txt.G - factor*(txt.G-midGray)
-				newB = txt.B - factor*(txt.B-midGray)
+				newR, newG, newB = interpolateColor(txt, midGray, factor)
 			} else {
 				inverted := 1 - lightness
 				newR, newG, newB = inverted, inverted, inverted
 			}
-			// Convert CMYK operator to RGB operator
-			rgbOp := cmykToRGBOperator(op.Operator)
-			return fmt.Sprintf("%.3f %.3f %.3f %s", newR, newG, newB, rgbOp)
+			newR, newG, newB = t.applyTintStrength(newR, newG, newB)
+			return tintedCMYKOperator(op, newR, newG, newB)
 		}
 
 		// For grayscale schemes, use CMYK
@@ -198,11 +464,36 @@ func (t *Transformer) transformCMYK(op ColorOperator) string {
 	// Colorful - adjust brightness
 	newR, newG, newB := t.adjustColorfulRGB(r, g, b, lightness)
 	// Convert back to CMYK
-	newC, newM, newY, newK := rgbToCMYK(newR, newG, newB)
+	newC, newM, newY, newK := colormath.RGBToCMYK(newR, newG, newB)
 
 	return fmt.Sprintf("%.3f %.3f %.3f %.3f %s", newC, newM, newY, newK, op.Operator)
 }
 
+// isRegistrationBlack reports whether op sets registration black (C=M=Y=K=1).
+// Plain and rich black (e.g. "0.6 0.4 0.4 1 k") are still transformed.
+func isRegistrationBlack(op ColorOperator) bool {
+	if op.ColorSpace != "cmyk" {
+		return false
+	}
+	for _, v := range op.Values {
+		if parseFloat(v) < 0.999 {
+			return false
+		}
+	}
+	return true
+}
+
+// tintedCMYKOperator writes a tinted RGB result for a CMYK operator. k and K become rg
+// and RG, while sc and scn stay in the CMYK color space selected for them (DeviceCMYK or
+// a four-component ICC profile), so the result is converted back to four components.
+func tintedCMYKOperator(op ColorOperator, r, g, b float64) string {
+	if isSetColor(op.Operator) {
+		c, m, y, k := colormath.RGBToCMYK(r, g, b)
+		return fmt.Sprintf("%.3f %.3f %.3f %.3f %s", c, m, y, k, op.Operator)
+	}
+	return fmt.Sprintf("%.3f %.3f %.3f %s", r, g, b, cmykToRGBOperator(op.Operator))
+}
+
 // cmykToRGBOperator converts a CMYK PDF operator to its RGB equivalent
 func cmykToRGBOperator(cmykOp string) string {
 	switch cmykOp {
@@ -217,6 +508,10 @@ func cmykToRGBOperator(cmykOp string) string {
 
 // invertDocumentColorRGB returns RGB values for inverted document color
 func (t *Transformer) invertDocumentColorRGB(lightness float64) (r, g, b float64) {
+	if t.scheme.IsLight() {
+		return t.softenDocumentColorRGB(lightness)
+	}
+
 	bg := t.scheme.Background
 	txt := t.scheme.Text
 
@@ -233,10 +528,7 @@ func (t *Transformer) invertDocumentColorRGB(lightness float64) (r, g, b float64
 	} else if lightness < 0.4 {
 		// Dark gray -> interpolate from text color
 		factor := lightness / 0.4 // 0 at 0, 1 at 0.4
-		midGray := 0.5
-		return txt.R - factor*(txt.R-midGray),
-			txt.G - factor*(txt.G-midGray),
-			txt.B - factor*(txt.B-midGray)
+		return interpolateColor(txt, midGray, factor)
 	}
 
 	// Mid gray - simple inversion
@@ -244,56 +536,54 @@ func (t *Transformer) invertDocumentColorRGB(lightness float64) (r, g, b float64
 	return inverted, inverted, inverted
 }
 
-// interpolateColor linearly interpolates between two colors
+// softenDocumentColorRGB maps a document color for a light scheme without inverting it:
+// white becomes the background, black the text color, and grays in between keep their order
+func (t *Transformer) softenDocumentColorRGB(lightness float64) (r, g, b float64) {
+	bg := t.scheme.Background
+	txt := t.scheme.Text
+
+	if lightness > 0.9 {
+		return bg.R, bg.G, bg.B
+	} else if lightness < 0.15 {
+		return txt.R, txt.G, txt.B
+	}
+	factor := (lightness - 0.15) / (0.9 - 0.15) // 0 at 0.15, 1 at 0.9
+	return interpolateColor(txt, bg, factor)
+}
+
+// midGray is where dark grays are pulled towards from the text color
+var midGray = colors.NewColorFromRGB(0.5, 0.5, 0.5)
+
+// interpolateColor mixes two colors, returning the normalized components
 func interpolateColor(c1, c2 colors.Color, t float64) (r, g, b float64) {
-	r = c1.R + t*(c2.R-c1.R)
-	g = c1.G + t*(c2.G-c1.G)
-	b = c1.B + t*(c2.B-c1.B)
-	return
+	mixed := c1.Mix(c2, t)
+	return mixed.R, mixed.G, mixed.B
 }
 
 // adjustColorfulRGB adjusts colorful pixels for dark mode
 // Ensures colored text is bright enough to read on dark background
 func (t *Transformer) adjustColorfulRGB(r, g, b, lightness float64) (newR, newG, newB float64) {
-	h, s, l := rgbToHSL(r, g, b)
+	// Colors already read well on a light page
+	if t.scheme.IsLight() {
+		return r, g, b
+	}
+
+	h, s, l := colormath.RGBToHSL(r, g, b)
 
-	// For dark mode, ensure minimum lightness of 0.55 for readability
+	// For dark mode, ensure a minimum lightness (0.55 by default) for readability
 	// Dark colors need to be lightened significantly
-	if l < 0.55 {
-		// Map 0-0.55 to 0.55-0.75 (lighten dark colors)
-		l = 0.55 + (l/0.55)*0.2
-	} else if l > 0.85 {
+	if l < t.minColorL {
+		// Map 0-floor to floor-(floor+0.2) (lighten dark colors)
+		l = t.minColorL + (l/t.minColorL)*0.2
+	} else if l > t.maxColorL {
 		// Very light colors: reduce slightly but keep visible
-		l = 0.7 + (l-0.85)*0.5
+		l = t.maxColorL - 0.15 + (l-t.maxColorL)*0.5
 	}
 
 	// Boost saturation slightly to maintain color vibrancy
-	s = math.Min(1.0, s*1.15)
-
-	return hslToRGB(h, s, l)
-}
-
-// getSaturation calculates saturation (0-1)
-func (t *Transformer) getSaturation(r, g, b float64) float64 {
-	max := math.Max(r, math.Max(g, b))
-	min := math.Min(r, math.Min(g, b))
-
-	if max == min {
-		return 0
-	}
-
-	l := (max + min) / 2
-	if l <= 0.5 {
-		return (max - min) / (max + min)
-	}
-	return (max - min) / (2 - max - min)
-}
+	s = math.Min(1.0, s*t.satBoost)
 
-// getLightness calculates lightness (0-1)
-func (t *Transformer) getLightness(r, g, b float64) float64 {
-	max := math.Max(r, math.Max(g, b))
-	min := math.Min(r, math.Min(g, b))
-	return (max + min) / 2
+	return colormath.HSLToRGB(h, s, l)
 }
 
 // parseFloat parses a string to float64
@@ -301,89 +591,3 @@ func parseFloat(s string) float64 {
 	f, _ := strconv.ParseFloat(s, 64)
 	return f
 }
-
-// rgbToHSL converts RGB (0-1) to HSL
-func rgbToHSL(r, g, b float64) (h, s, l float64) {
-	max := math.Max(r, math.Max(g, b))
-	min := math.Min(r, math.Min(g, b))
-
-	l = (max + min) / 2
-
-	if max == min {
-		return 0, 0, l
-	}
-
-	d := max - min
-	if l > 0.5 {
-		s = d / (2 - max - min)
-	} else {
-		s = d / (max + min)
-	}
-
-	switch max {
-	case r:
-		h = (g - b) / d
-		if g < b {
-			h += 6
-		}
-	case g:
-		h = (b-r)/d + 2
-	case b:
-		h = (r-g)/d + 4
-	}
-
-	h /= 6
-	return
-}
-
-// hslToRGB converts HSL to RGB (0-1)
-func hslToRGB(h, s, l float64) (r, g, b float64) {
-	if s == 0 {
-		return l, l, l
-	}
-
-	var q float64
-	if l < 0.5 {
-		q = l * (1 + s)
-	} else {
-		q = l + s - l*s
-	}
-	p := 2*l - q
-
-	r = hueToRGB(p, q, h+1.0/3.0)
-	g = hueToRGB(p, q, h)
-	b = hueToRGB(p, q, h-1.0/3.0)
-
-	return
-}
-
-func hueToRGB(p, q, t float64) float64 {
-	if t < 0 {
-		t += 1
-	}
-	if t > 1 {
-		t -= 1
-	}
-	if t < 1.0/6.0 {
-		return p + (q-p)*6*t
-	}
-	if t < 1.0/2.0 {
-		return q
-	}
-	if t < 2.0/3.0 {
-		return p + (q-p)*(2.0/3.0-t)*6
-	}
-	return p
-}
-
-// rgbToCMYK converts RGB (0-1) to CMYK (0-1)
-func rgbToCMYK(r, g, b float64) (c, m, y, k float64) {
-	k = 1 - math.Max(r, math.Max(g, b))
-	if k == 1 {
-		return 0, 0, 0, 1
-	}
-	c = (1 - r - k) / (1 - k)
-	m = (1 - g - k) / (1 - k)
-	y = (1 - b - k) / (1 - k)
-	return
-}
@@ -0,0 +1,228 @@
+package direct
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"pdfdarkmode/converter/colormath"
+	"pdfdarkmode/converter/colors"
+)
+
+func TestRegistrationBlack(t *testing.T) {
+	tests := []struct {
+		content   string
+		unchanged bool
+	}{
+		{"1 1 1 1 k", true},
+		{"1 1 1 1 K", true},
+		{"1.0 1.0 1.0 1.0 k", true},
+		{"0.6 0.4 0.4 1 k", false},
+		{"0.75 0.68 0.67 0.9 K", false},
+		{"0 0 0 1 k", false},
+		{"1 1 1 0 k", false},
+		{"1 g", false},
+	}
+
+	p := NewParser()
+	for _, name := range []string{"dark", "sepia"} {
+		scheme, err := colors.GetScheme(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr := NewTransformer(scheme)
+		for _, tt := range tests {
+			ops := p.FindColorOperators(tt.content)
+			if len(ops) != 1 {
+				t.Fatalf("found %d operators in %q, want 1", len(ops), tt.content)
+			}
+			if isRegistrationBlack(ops[0]) != tt.unchanged {
+				t.Errorf("isRegistrationBlack(%q) = %t, want %t", tt.content, !tt.unchanged, tt.unchanged)
+			}
+			out := tr.TransformOperator(ops[0])
+			if (out == tt.content) != tt.unchanged {
+				t.Errorf("%s: %q transformed to %q, want unchanged %t", name, tt.content, out, tt.unchanged)
+			}
+		}
+	}
+}
+
+func TestTintStrength(t *testing.T) {
+	scheme, err := colors.GetScheme("sepia")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := scheme.Text
+
+	tests := []struct {
+		content string
+		cmyk    bool // written back in CMYK
+	}{
+		{"0 g", false},
+		{"0.3 G", false},
+		{"0 0 0 1 k", false},
+		{"0 0 0 0.8 K", false},
+		{"/DeviceCMYK cs 0 0 0 1 sc", true},
+	}
+
+	p := NewParser()
+	for _, strength := range []float64{0, 1} {
+		tr := NewTransformer(scheme)
+		tr.SetTintStrength(strength)
+		for _, tt := range tests {
+			ops := p.FindColorOperators(tt.content)
+			if len(ops) != 1 {
+				t.Fatalf("found %d operators in %q, want 1", len(ops), tt.content)
+			}
+			out := p.FindColorOperators(tr.TransformOperator(ops[0]))
+			if len(out) != 1 {
+				t.Fatalf("strength %v: %q transformed to no color", strength, tt.content)
+			}
+			if tt.cmyk != (out[0].ColorSpace == "cmyk") {
+				t.Errorf("strength %v: %q transformed to %q in the wrong color space", strength, tt.content, out[0].FullMatch)
+			}
+
+			// Gray, or equal RGB or CMY components
+			v := out[0].Values
+			neutral := len(v) == 1 || v[0] == v[1] && v[1] == v[2]
+			if strength == 0 && !neutral {
+				t.Errorf("strength 0: %q transformed to %q, want a neutral gray", tt.content, out[0].FullMatch)
+			}
+			if strength == 1 && neutral {
+				t.Errorf("strength 1: %q transformed to %q, want the scheme tint", tt.content, out[0].FullMatch)
+			}
+		}
+
+		// Black becomes the full text color at strength 1, and a gray of its luma at 0
+		out := tr.TransformOperator(p.FindColorOperators("0 g")[0])
+		want := fmt.Sprintf("%.3f %.3f %.3f rg", text.R, text.G, text.B)
+		if strength == 0 {
+			luma := 0.299*text.R + 0.587*text.G + 0.114*text.B
+			want = fmt.Sprintf("%.3f %.3f %.3f rg", luma, luma, luma)
+		}
+		if out != want {
+			t.Errorf("strength %v: black transformed to %q, want %q", strength, out, want)
+		}
+	}
+}
+
+// gradientSteps returns the largest change in any channel between neighboring colors of
+// an RGB gradient of fixed lightness whose saturation crosses the document color boundary
+func gradientSteps(t *testing.T, tr *Transformer) float64 {
+	t.Helper()
+	p := NewParser()
+	const steps = 40
+	largest, prev := 0.0, [3]float64{}
+	for i := 0; i <= steps; i++ {
+		s := 0.05 + 0.2*float64(i)/steps
+		d := 0.8 * s // HSL saturation d/(1-|2l-1|) at lightness 0.6
+		in := fmt.Sprintf("%.4f %.4f %.4f rg", 0.6-d, 0.6, 0.6+d)
+		out := p.FindColorOperators(tr.TransformOperator(p.FindColorOperators(in)[0]))
+		if len(out) != 1 {
+			t.Fatalf("%q transformed to no color", in)
+		}
+		r, g, b, _ := operatorRGB(out[0])
+		cur := [3]float64{r, g, b}
+		if i > 0 {
+			for c := range cur {
+				largest = max(largest, math.Abs(cur[c]-prev[c]))
+			}
+		}
+		prev = cur
+	}
+	return largest
+}
+
+func TestColorToleranceGradient(t *testing.T) {
+	tr := NewTransformer(colors.SchemeDark)
+	hard := gradientSteps(t, tr)
+	tr.SetColorTolerance(0.05)
+	smooth := gradientSteps(t, tr)
+	if hard < 0.1 {
+		t.Errorf("largest step at tolerance 0 is %.3f, want the seam of a hard boundary", hard)
+	}
+	if smooth > hard/3 {
+		t.Errorf("largest step at tolerance 0.05 is %.3f, want well below the %.3f seam", smooth, hard)
+	}
+}
+
+// transformedLightness returns the lightness of the single color in content after tr
+func transformedLightness(t *testing.T, tr *Transformer, content string) float64 {
+	t.Helper()
+	p := NewParser()
+	ops := p.FindColorOperators(content)
+	if len(ops) != 1 {
+		t.Fatalf("found %d operators in %q, want 1", len(ops), content)
+	}
+	out := p.FindColorOperators(tr.TransformOperator(ops[0]))
+	if len(out) != 1 {
+		t.Fatalf("%q transformed to no color", content)
+	}
+	r, g, b, _ := operatorRGB(out[0])
+	return colormath.Lightness(r, g, b)
+}
+
+func TestPreserveWhite(t *testing.T) {
+	tests := []struct {
+		content string
+		kept    bool
+	}{
+		{"1 g", true},
+		{"0.95 g", true},
+		{"0.96 0.96 0.97 rg", true},
+		{"0.02 0.02 0.03 0.03 k", true},
+		{"0.93 G", true},
+		{"0.85 g", false},
+		{"1 0.9 0.9 rg", false}, // Colorful
+		{"0 g", false},
+	}
+
+	p := NewParser()
+	tr := NewTransformer(colors.SchemeDark)
+	tr.SetPreserveWhite(0.9)
+	for _, tt := range tests {
+		out := tr.TransformOperator(p.FindColorOperators(tt.content)[0])
+		if (out == tt.content) != tt.kept {
+			t.Errorf("%q transformed to %q, want kept %t", tt.content, out, tt.kept)
+		}
+	}
+
+	// Without the threshold near-white becomes the background
+	if l := transformedLightness(t, NewTransformer(colors.SchemeDark), "0.95 g"); l > 0.5 {
+		t.Errorf("0.95 g transformed to lightness %.3f without preserve-white, want dark", l)
+	}
+}
+
+// benchmarkContent returns a page of text in a few colors, as most documents set them
+func benchmarkContent(lines int) string {
+	var b strings.Builder
+	for i := 0; i < lines; i++ {
+		switch i % 4 {
+		case 0:
+			b.WriteString("0 g ")
+		case 1:
+			b.WriteString("0.2 0.2 0.6 rg ")
+		case 2:
+			b.WriteString("0.5 G 0 0 0 1 k ")
+		}
+		fmt.Fprintf(&b, "BT /F1 10 Tf 72 %d Td (Line %d of the page) Tj ET\n", 760-i, i)
+	}
+	return b.String()
+}
+
+func BenchmarkTransformOperator(b *testing.B) {
+	ops := NewParser().FindColorOperators(benchmarkContent(200))
+	b.Run("cached", func(b *testing.B) {
+		tr := NewTransformer(colors.SchemeDark)
+		for i := 0; i < b.N; i++ {
+			tr.TransformOperator(ops[i%len(ops)])
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		tr := NewTransformer(colors.SchemeDark)
+		for i := 0; i < b.N; i++ {
+			tr.transformOperator(ops[i%len(ops)])
+		}
+	})
+}
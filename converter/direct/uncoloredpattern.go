@@ -0,0 +1,44 @@
+package direct
+
+import (
+	"fmt"
+	"strings"
+
+	"pdfdarkmode/converter/colormath"
+)
+
+// transformPatternOperator transforms the components of an uncolored pattern's scn/SCN,
+// "c1 c2 c3 /P0 scn", like the same color set in the pattern's base space, keeping the
+// pattern name. The base space fixes the number of components, so an RGB result (a
+// gray or CMYK color in a tinted scheme) is converted back to it.
+func (t *Transformer) transformPatternOperator(op ColorOperator) string {
+	plain := op
+	plain.Pattern = ""
+	plain.FullMatch = strings.Join(op.Values, " ") + " " + op.Operator
+	out := t.TransformOperator(plain)
+	if out == plain.FullMatch {
+		return op.FullMatch
+	}
+
+	fields := strings.Fields(out)
+	values := make([]float64, len(fields)-1)
+	for i, field := range fields[:len(fields)-1] {
+		values[i] = parseFloat(field)
+	}
+	switch {
+	case len(values) == len(op.Values):
+	case len(values) == 3 && op.ColorSpace == "gray":
+		values = []float64{colormath.Lightness(values[0], values[1], values[2])}
+	case len(values) == 3 && op.ColorSpace == "cmyk":
+		c, m, y, k := colormath.RGBToCMYK(values[0], values[1], values[2])
+		values = []float64{c, m, y, k}
+	default:
+		return op.FullMatch
+	}
+
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprintf("%.3f", v)
+	}
+	return strings.Join(parts, " ") + " " + op.Pattern + " " + op.Operator
+}
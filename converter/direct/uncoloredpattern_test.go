@@ -0,0 +1,68 @@
+package direct
+
+import (
+	"strings"
+	"testing"
+
+	"pdfdarkmode/converter/colors"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+func TestUncoloredPatternKeepsPattern(t *testing.T) {
+	content := "/CS0 cs 0 0 0 /P0 scn 0 0 100 100 re f\n" +
+		"/CS1 CS 0 /P1 SCN 0 0 100 100 re S\n" +
+		"/Pattern cs /P2 scn 0 0 100 100 re f\n"
+
+	ctx := newTestContext(t, nil, content)
+	pageDict, _, _, err := ctx.PageDict(1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pageDict["Resources"] = types.Dict{"ColorSpace": types.Dict{
+		"CS0": types.Array{types.Name("Pattern"), types.Name("DeviceRGB")},
+		"CS1": types.Array{types.Name("Pattern"), types.Name("DeviceGray")},
+	}}
+	spaces := colorSpaces(ctx, pageDict["Resources"].(types.Dict))
+	if spaces["CS0"] != patternPrefix+"rgb" || spaces["CS1"] != patternPrefix+"gray" {
+		t.Fatalf("color spaces resolved to %v, want uncolored RGB and gray pattern spaces", spaces)
+	}
+
+	e := NewEngine(false, colors.DefaultScheme())
+	count, err := e.processPage(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("transformed %d operators, want 2", count)
+	}
+
+	out := testPageContent(t, ctx, 1)
+	p := NewParser()
+	var patterns []ColorOperator
+	for _, op := range p.FindColorOperators(out) {
+		if op.Pattern != "" {
+			patterns = append(patterns, op)
+		}
+	}
+	if len(patterns) != 2 {
+		t.Fatalf("output has %d pattern colors, want 2:\n%s", len(patterns), out)
+	}
+	for i, want := range []struct {
+		pattern string
+		values  int
+	}{{"/P0", 3}, {"/P1", 1}} {
+		op := patterns[i]
+		if op.Pattern != want.pattern || len(op.Values) != want.values {
+			t.Errorf("pattern color %q, want %d components before %s", op.FullMatch, want.values, want.pattern)
+			continue
+		}
+		// Black components become the scheme's light text color
+		if parseFloat(op.Values[0]) < 0.5 {
+			t.Errorf("pattern color %q was not transformed", op.FullMatch)
+		}
+	}
+	if !strings.Contains(out, "/Pattern cs /P2 scn") {
+		t.Errorf("colored pattern fill changed:\n%s", out)
+	}
+}
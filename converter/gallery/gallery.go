@@ -0,0 +1,208 @@
+package gallery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"pdfdarkmode/converter/raster"
+)
+
+// thumbnailDPI renders a letter page about 300 pixels wide
+const thumbnailDPI = 36
+
+// Files kept in the gallery directory
+const (
+	manifestName  = "gallery.json"
+	indexName     = "index.html"
+	thumbnailsDir = "thumbnails"
+)
+
+// Entry is one converted PDF in the gallery
+type Entry struct {
+	Input     string    `json:"input"`
+	Output    string    `json:"output"`    // Absolute path of the converted PDF
+	Thumbnail string    `json:"thumbnail"` // Relative to the gallery directory
+	Added     time.Time `json:"added"`
+}
+
+// Add renders the first page of outputPath to a thumbnail in dir, records it in the
+// gallery's manifest and rewrites index.html with every output added so far, so
+// running once per file of a batch builds one gallery. Converting the same output
+// again replaces its entry. renderer may be nil to use the built-in renderers.
+// Returns the path of index.html.
+func Add(dir, inputPath, outputPath string, renderer raster.Renderer) (string, error) {
+	output, err := filepath.Abs(outputPath)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Join(dir, thumbnailsDir), 0755); err != nil {
+		return "", fmt.Errorf("failed to create gallery directory: %w", err)
+	}
+
+	page, err := raster.RenderFirstPage(output, renderer, thumbnailDPI)
+	if err != nil {
+		return "", fmt.Errorf("failed to render thumbnail: %w", err)
+	}
+
+	entries, err := readManifest(dir)
+	if err != nil {
+		return "", err
+	}
+
+	entry := Entry{Input: inputPath, Output: output, Added: time.Now()}
+	idx := -1
+	for i, e := range entries {
+		if e.Output == output {
+			idx = i
+			entry.Thumbnail = e.Thumbnail
+		}
+	}
+	if entry.Thumbnail == "" {
+		entry.Thumbnail = thumbnailName(entries, output)
+	}
+
+	f, err := os.Create(filepath.Join(dir, entry.Thumbnail))
+	if err != nil {
+		return "", fmt.Errorf("failed to write thumbnail: %w", err)
+	}
+	if err := png.Encode(f, page); err != nil {
+		f.Close()
+		return "", fmt.Errorf("failed to write thumbnail: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to write thumbnail: %w", err)
+	}
+
+	if idx >= 0 {
+		entries[idx] = entry
+	} else {
+		entries = append(entries, entry)
+	}
+	if err := writeManifest(dir, entries); err != nil {
+		return "", err
+	}
+	return writeIndex(dir, entries)
+}
+
+// thumbnailName picks a thumbnail path for output not yet used by entries, numbering
+// outputs with the same file name from different directories
+func thumbnailName(entries []Entry, output string) string {
+	used := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		used[e.Thumbnail] = true
+	}
+
+	base := strings.TrimSuffix(filepath.Base(output), filepath.Ext(output))
+	name := filepath.Join(thumbnailsDir, base+".png")
+	for n := 2; used[name]; n++ {
+		name = filepath.Join(thumbnailsDir, fmt.Sprintf("%s-%d.png", base, n))
+	}
+	return name
+}
+
+// readManifest returns the entries recorded in dir, or none for a new gallery
+func readManifest(dir string) ([]Entry, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gallery: %w", err)
+	}
+
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid gallery manifest %s: %w", filepath.Join(dir, manifestName), err)
+	}
+	return entries, nil
+}
+
+// writeManifest saves entries to dir
+func writeManifest(dir string, entries []Entry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, manifestName), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write gallery manifest: %w", err)
+	}
+	return nil
+}
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="color-scheme" content="dark">
+<title>pdfdarkmode gallery</title>
+<style>
+body { margin: 2rem; background: #1e1e1e; color: #e0e0e0; font-family: sans-serif; }
+main { display: grid; grid-template-columns: repeat(auto-fill, minmax(220px, 1fr)); gap: 1.5rem; }
+figure { margin: 0; }
+img { width: 100%; border: 1px solid #444; }
+figcaption { margin-top: .5rem; font-size: .9rem; overflow-wrap: anywhere; }
+a { color: inherit; }
+</style>
+</head>
+<body>
+<h1>pdfdarkmode gallery</h1>
+<main>
+{{- range .}}
+<figure>
+<a href="{{.Link}}"><img src="{{.Thumbnail}}" alt="First page of {{.Name}}" loading="lazy"></a>
+<figcaption><a href="{{.Link}}">{{.Name}}</a></figcaption>
+</figure>
+{{- end}}
+</main>
+</body>
+</html>
+`))
+
+// writeIndex writes index.html for entries, sorted by output name, linking to each
+// output relative to dir where possible. Returns the path written.
+func writeIndex(dir string, entries []Entry) (string, error) {
+	type card struct {
+		Name, Link, Thumbnail string
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	cards := make([]card, 0, len(entries))
+	for _, e := range entries {
+		link := "file://" + filepath.ToSlash(e.Output)
+		if rel, err := filepath.Rel(absDir, e.Output); err == nil {
+			link = filepath.ToSlash(rel)
+		}
+		cards = append(cards, card{
+			Name:      filepath.Base(e.Output),
+			Link:      link,
+			Thumbnail: filepath.ToSlash(e.Thumbnail),
+		})
+	}
+	sort.SliceStable(cards, func(i, j int) bool { return cards[i].Name < cards[j].Name })
+
+	path := filepath.Join(dir, indexName)
+	f, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to write gallery: %w", err)
+	}
+	if err := indexTemplate.Execute(f, cards); err != nil {
+		f.Close()
+		return "", fmt.Errorf("failed to write gallery: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to write gallery: %w", err)
+	}
+	return path, nil
+}
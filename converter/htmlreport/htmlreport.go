@@ -0,0 +1,167 @@
+package htmlreport
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	"pdfdarkmode/converter/colors"
+	"pdfdarkmode/converter/raster"
+	"pdfdarkmode/converter/report"
+)
+
+// Thumbnails, rendered like the gallery's at about 300 pixels across a letter page
+const (
+	thumbnailDPI   = 36
+	thumbnailPages = 3 // Pages shown before and after conversion
+)
+
+// thumbnail is one page rendered before and after conversion as PNG data URLs
+type thumbnail struct {
+	Page          int
+	Before, After template.URL
+}
+
+// page is the data of the report template
+type page struct {
+	*report.Report
+	InputName, OutputName string
+	Scheme                colors.Scheme
+	Thumbnails            []thumbnail
+	ThumbnailError        string
+}
+
+// Write saves r as a self-contained HTML summary at path: the files, scheme, page
+// and transform counts, thumbnails of the first pages before and after conversion,
+// and the warnings. Thumbnails are inlined as base64 PNGs; if pages cannot be rendered
+// the summary says why instead. renderer may be nil to use the built-in renderers.
+func Write(path string, r *report.Report, scheme colors.Scheme, renderer raster.Renderer) error {
+	data := page{
+		Report:     r,
+		InputName:  filepath.Base(r.Input),
+		OutputName: filepath.Base(r.Output),
+		Scheme:     scheme,
+	}
+	if r.Error == "" {
+		data.Thumbnails, data.ThumbnailError = thumbnails(r, renderer)
+	}
+
+	var buf bytes.Buffer
+	if err := reportTemplate.Execute(&buf, data); err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create report directory: %w", err)
+		}
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// thumbnails renders the first pages of the input and output, or returns why not
+func thumbnails(r *report.Report, renderer raster.Renderer) ([]thumbnail, string) {
+	var thumbs []thumbnail
+	for n := 1; n <= min(thumbnailPages, r.Stats.Pages); n++ {
+		before, err := dataURL(r.Input, n, renderer)
+		if err != nil {
+			return thumbs, err.Error()
+		}
+		after, err := dataURL(r.Output, n, renderer)
+		if err != nil {
+			return thumbs, err.Error()
+		}
+		thumbs = append(thumbs, thumbnail{Page: n, Before: before, After: after})
+	}
+	return thumbs, ""
+}
+
+// dataURL renders page pageNum of pdfPath as a PNG data URL
+func dataURL(pdfPath string, pageNum int, renderer raster.Renderer) (template.URL, error) {
+	img, err := raster.RenderPage(pdfPath, pageNum, renderer, thumbnailDPI)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())), nil
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"add": func(a, b int) int { return a + b },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="color-scheme" content="dark">
+<title>pdfdarkmode report: {{.OutputName}}</title>
+<style>
+body { margin: 2rem; background: #1e1e1e; color: #e0e0e0; font-family: sans-serif; }
+table { border-collapse: collapse; margin-bottom: 1.5rem; }
+th, td { padding: .25rem 1rem .25rem 0; text-align: left; vertical-align: top; }
+th { color: #aaa; font-weight: normal; }
+.swatch { display: inline-block; padding: .5rem 1rem; border: 1px solid #444; font-weight: bold; }
+.error { color: #ff8080; }
+.pages { display: grid; grid-template-columns: repeat(auto-fill, minmax(440px, 1fr)); gap: 1.5rem; }
+figure { margin: 0; display: flex; gap: .5rem; }
+img { width: 50%; border: 1px solid #444; }
+</style>
+</head>
+<body>
+<h1>{{.OutputName}}</h1>
+<table>
+<tr><th>Input</th><td title="{{.Input}}">{{.InputName}}</td></tr>
+<tr><th>Output</th><td title="{{.Output}}">{{.OutputName}}</td></tr>
+<tr><th>Mode</th><td>{{.Mode}}</td></tr>
+<tr><th>Scheme</th><td><span class="swatch" style="background: {{.Scheme.Background.Hex}}; color: {{.Scheme.Text.Hex}}">{{.Scheme.Name}}</span> {{.Scheme.Background.Hex}} / {{.Scheme.Text.Hex}}</td></tr>
+<tr><th>Pages</th><td>{{.Stats.Pages}}</td></tr>
+<tr><th>Duration</th><td>{{printf "%.1f" .Seconds}} s</td></tr>
+<tr><th>Size</th><td>{{.InputSize}} → {{.OutputSize}} bytes</td></tr>
+{{- if .Error}}
+<tr><th>Error</th><td class="error">{{.Error}}</td></tr>
+{{- end}}
+</table>
+{{- if .Stats.PageTransforms}}
+<h2>Color operators transformed</h2>
+<table>
+<tr><th>Page</th><th>Operators</th></tr>
+{{- range $i, $n := .Stats.PageTransforms}}
+<tr><td>{{add $i 1}}</td><td>{{$n}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if or .Thumbnails .ThumbnailError}}
+<h2>Before and after</h2>
+{{- if .ThumbnailError}}
+<p>Thumbnails unavailable: {{.ThumbnailError}}</p>
+{{- end}}
+<div class="pages">
+{{- range .Thumbnails}}
+<figure>
+<img src="{{.Before}}" alt="Page {{.Page}} before">
+<img src="{{.After}}" alt="Page {{.Page}} after">
+</figure>
+{{- end}}
+</div>
+{{- end}}
+<h2>Warnings</h2>
+{{- if .Warnings}}
+<ul>
+{{- range .Warnings}}
+<li>{{.}}</li>
+{{- end}}
+</ul>
+{{- else}}
+<p>None</p>
+{{- end}}
+</body>
+</html>
+`))
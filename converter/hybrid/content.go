@@ -0,0 +1,199 @@
+package hybrid
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// editFunc decides what replaces an operator and its operands. Returning ok false
+// keeps the original bytes; otherwise out is written instead (empty drops them).
+type editFunc func(op string, operands [][]byte) (out string, ok bool)
+
+// paintOperators fill or stroke the current path
+var paintOperators = map[string]bool{
+	"f": true, "F": true, "f*": true,
+	"B": true, "B*": true, "b": true, "b*": true,
+	"S": true, "s": true,
+}
+
+// textOnly keeps text, graphics state and clipping but paints nothing else:
+// path painting becomes n (so clipping paths still apply) and XObjects,
+// shadings and inline images are dropped
+func textOnly(op string, operands [][]byte) (string, bool) {
+	switch {
+	case paintOperators[op]:
+		return "n", true
+	case op == "Do", op == "sh", op == "BI":
+		return "", true
+	}
+	return "", false
+}
+
+// hideText makes page-level text invisible for the background render. Text
+// inside form XObjects is drawn as usual, since the overlay cannot keep it.
+func hideText(op string, operands [][]byte) (string, bool) {
+	switch op {
+	case "Tr":
+		// Modes 4-7 also add the text to the clipping path
+		if len(operands) == 1 {
+			if mode, err := strconv.Atoi(string(operands[0])); err == nil && mode >= 4 {
+				return "7 Tr", true
+			}
+		}
+		return "3 Tr", true
+	case "Do":
+		if len(operands) == 1 {
+			return "q 0 Tr " + string(operands[0]) + " Do Q", true
+		}
+	}
+	return "", false
+}
+
+// rewriteContent passes every operator of a content stream through edit
+func rewriteContent(content []byte, edit editFunc) []byte {
+	var out bytes.Buffer
+	out.Grow(len(content))
+
+	start := 0     // First byte not yet written
+	argStart := -1 // Offset of the first pending operand
+	var operands [][]byte
+	push := func(from, to int) {
+		if argStart < 0 {
+			argStart = from
+		}
+		operands = append(operands, content[from:to])
+	}
+
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case isWhitespace(c):
+			i++
+
+		case c == '%':
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+
+		case c == '(':
+			end := skipString(content, i)
+			push(i, end)
+			i = end
+
+		case c == '<' && i+1 < len(content) && content[i+1] == '<',
+			c == '>' && i+1 < len(content) && content[i+1] == '>':
+			push(i, i+2)
+			i += 2
+
+		case c == '<':
+			end := bytes.IndexByte(content[i:], '>')
+			if end < 0 {
+				end = len(content) - i - 1
+			}
+			push(i, i+end+1)
+			i += end + 1
+
+		case c == '[' || c == ']' || c == '{' || c == '}':
+			push(i, i+1)
+			i++
+
+		default:
+			end := i + 1
+			for end < len(content) && !isWhitespace(content[end]) && !isDelimiter(content[end]) {
+				end++
+			}
+			token := content[i:end]
+			if c == '/' || !isOperator(token) {
+				push(i, end)
+				i = end
+				continue
+			}
+
+			op := string(token)
+			if op == "BI" {
+				end = skipInlineImage(content, end)
+			}
+
+			if replacement, ok := edit(op, operands); ok {
+				// Keep the whitespace before the operands
+				from := i
+				if argStart >= 0 {
+					from = argStart
+				}
+				out.Write(content[start:from])
+				if replacement != "" {
+					out.WriteString(replacement)
+				}
+				out.WriteByte('\n')
+			} else {
+				out.Write(content[start:end])
+			}
+			start = end
+			operands = operands[:0]
+			argStart = -1
+			i = end
+		}
+	}
+
+	out.Write(content[start:])
+	return out.Bytes()
+}
+
+// skipString returns the index after the literal string starting at i,
+// honoring escapes and balanced parentheses
+func skipString(content []byte, i int) int {
+	depth := 0
+	for ; i < len(content); i++ {
+		switch content[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(content)
+}
+
+// skipInlineImage returns the index after the EI that ends the inline image
+// whose BI operator ends at i
+func skipInlineImage(content []byte, i int) int {
+	id := bytes.Index(content[i:], []byte("ID"))
+	if id < 0 {
+		return len(content)
+	}
+	// One whitespace byte separates ID from the image data
+	for j := i + id + 3; j+1 < len(content); j++ {
+		if content[j] == 'E' && content[j+1] == 'I' && isWhitespace(content[j-1]) &&
+			(j+2 == len(content) || isWhitespace(content[j+2]) || isDelimiter(content[j+2])) {
+			return j + 2
+		}
+	}
+	return len(content)
+}
+
+// isOperator reports whether a regular token is an operator rather than a number or keyword
+func isOperator(token []byte) bool {
+	switch string(token) {
+	case "true", "false", "null":
+		return false
+	}
+	c := token[0]
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '\'' || c == '"'
+}
+
+func isWhitespace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isDelimiter(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,336 @@
+package hybrid
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+
+	"pdfdarkmode/converter/colors"
+	"pdfdarkmode/converter/direct"
+	"pdfdarkmode/converter/parity"
+	"pdfdarkmode/converter/raster"
+	"pdfdarkmode/converter/report"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// backgroundName is the XObject resource name of the page background image
+const backgroundName = "PDMBg"
+
+// Engine implements hybrid conversion: each page becomes an inverted raster
+// background with the page's color-transformed text drawn on top of it
+type Engine struct {
+	raster      *raster.Engine
+	direct      *direct.Engine
+	colorScheme colors.Scheme
+	onlyPages   string // parity.Odd or parity.Even to convert only those pages, empty for all
+	textPages   bool   // Convert text pages directly and render the others, see SetTextOnlyPages
+}
+
+// NewEngine creates a hybrid engine from configured raster and direct engines.
+// The raster engine renders and inverts backgrounds, the direct engine
+// transforms the text and writes the output.
+func NewEngine(r *raster.Engine, d *direct.Engine, scheme colors.Scheme) *Engine {
+	return &Engine{
+		raster:      r,
+		direct:      d,
+		colorScheme: scheme,
+	}
+}
+
+// SetOnlyPages converts only the odd or even pages (parity.Odd or parity.Even), leaving
+// the others as they are. The direct engine must be given the same selection.
+func (e *Engine) SetOnlyPages(selection string) {
+	e.onlyPages = selection
+}
+
+// SetTextOnlyPages chooses the conversion per page instead of combining both engines on
+// every page: pages with only text and simple vector graphics are converted directly,
+// keeping their text selectable, and the others are rendered and inverted as a whole
+func (e *Engine) SetTextOnlyPages(enabled bool) {
+	e.textPages = enabled
+}
+
+// Convert performs the hybrid PDF to dark mode conversion
+func (e *Engine) Convert(inputPath, outputPath string) error {
+	fmt.Println("  [1/4] Rendering page backgrounds without text...")
+	ctx, err := direct.ReadContext(inputPath)
+	if err != nil {
+		return err
+	}
+	var textPages []bool // Pages converted directly, with SetTextOnlyPages
+	if e.textPages {
+		textPages = classifyPages(ctx)
+		count := 0
+		for _, text := range textPages {
+			if text {
+				count++
+			}
+		}
+		fmt.Printf("        %d text page(s) converted directly, %d page(s) rendered\n", count, len(textPages)-count)
+	}
+	rendered := func(pageNum int) bool {
+		return parity.Includes(e.onlyPages, pageNum) && (textPages == nil || !textPages[pageNum-1])
+	}
+
+	backgrounds, err := e.renderBackgrounds(inputPath, textPages)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("        Rendered %d page(s)\n", len(backgrounds))
+	if len(backgrounds) != ctx.PageCount {
+		return fmt.Errorf("rendered %d page(s) but the PDF has %d", len(backgrounds), ctx.PageCount)
+	}
+
+	fmt.Println("  [2/4] Applying smart dark mode inversion...")
+	report.StartPages()
+	for i, img := range backgrounds {
+		if !rendered(i + 1) {
+			continue
+		}
+		backgrounds[i] = e.raster.InvertImage(img)
+		fmt.Printf("        Inverted page %d/%d\n", i+1, len(backgrounds))
+		report.PageDone(i+1, len(backgrounds))
+	}
+
+	fmt.Println("  [3/4] Transforming text and placing backgrounds...")
+	e.direct.Transform(ctx)
+	if textPages != nil {
+		// Rendered pages get the background too; replacing their content removes it
+		if err := e.direct.AddBackgrounds(ctx); err != nil {
+			report.Warnf("could not add backgrounds: %v", err)
+		}
+	} else {
+		for pageNum := 1; pageNum <= ctx.PageCount; pageNum++ {
+			if !parity.Includes(e.onlyPages, pageNum) {
+				continue
+			}
+			if err := rewritePage(ctx, pageNum, textOnly); err != nil {
+				report.Warnf("page %d text extraction failed: %v", pageNum, err)
+			}
+		}
+	}
+
+	// Rendered images are upright, so the text must be too
+	e.direct.NormalizeRotations(ctx)
+
+	for i, img := range backgrounds {
+		if !rendered(i + 1) {
+			continue
+		}
+		place := e.addBackground
+		if textPages != nil {
+			place = replaceWithImage
+		}
+		if err := place(ctx, i+1, img); err != nil {
+			return fmt.Errorf("failed to add background to page %d: %w", i+1, err)
+		}
+	}
+
+	fmt.Println("  [4/4] Writing output PDF...")
+	return e.direct.Write(ctx, outputPath)
+}
+
+// renderBackgrounds renders a copy of the PDF with page text hidden and annotations
+// removed, so neither is drawn twice once the overlay is added. With textPages, the
+// text pages are rendered blank, as they are converted directly, and the other pages
+// keep their text, as the rendering replaces them.
+func (e *Engine) renderBackgrounds(inputPath string, textPages []bool) ([]image.Image, error) {
+	ctx, err := direct.ReadContext(inputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for pageNum := 1; pageNum <= ctx.PageCount; pageNum++ {
+		pageDict, _, _, err := ctx.PageDict(pageNum, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get page %d: %w", pageNum, err)
+		}
+		pageDict.Delete("Annots")
+		if textPages != nil {
+			if textPages[pageNum-1] {
+				pageDict.Delete("Contents")
+			}
+			continue
+		}
+
+		// Text rendering mode is part of the graphics state and starts at fill
+		if err := prependContent(ctx, pageDict, []byte("3 Tr\n")); err != nil {
+			return nil, fmt.Errorf("failed to hide text on page %d: %w", pageNum, err)
+		}
+		if err := rewritePage(ctx, pageNum, hideText); err != nil {
+			return nil, fmt.Errorf("failed to hide text on page %d: %w", pageNum, err)
+		}
+	}
+
+	tmp, err := os.CreateTemp("", "pdfdarkmode-hybrid-*.pdf")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := api.WriteContext(ctx, tmp); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("failed to write text-free copy: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	images, err := e.raster.RenderPages(tmp.Name())
+	if err != nil {
+		return nil, fmt.Errorf("failed to render PDF: %w", err)
+	}
+	return images, nil
+}
+
+// rewritePage passes every content stream of a page through rewriteContent
+func rewritePage(ctx *model.Context, pageNum int, edit editFunc) error {
+	pageDict, _, _, err := ctx.PageDict(pageNum, false)
+	if err != nil {
+		return err
+	}
+
+	var refs []types.IndirectRef
+	switch contents := pageDict["Contents"].(type) {
+	case types.IndirectRef:
+		refs = append(refs, contents)
+	case types.Array:
+		for _, item := range contents {
+			if ref, ok := item.(types.IndirectRef); ok {
+				refs = append(refs, ref)
+			}
+		}
+	}
+
+	for _, ref := range refs {
+		entry, found := ctx.FindTableEntryForIndRef(&ref)
+		if !found {
+			return fmt.Errorf("could not find xref entry")
+		}
+		sd, ok := entry.Object.(types.StreamDict)
+		if !ok {
+			continue
+		}
+		if err := sd.Decode(); err != nil {
+			return fmt.Errorf("failed to decode stream: %w", err)
+		}
+
+		sd.Content = rewriteContent(sd.Content, edit)
+		if err := sd.Encode(); err != nil {
+			return fmt.Errorf("failed to encode stream: %w", err)
+		}
+		sd.Dict["Length"] = types.Integer(len(sd.Raw))
+		entry.Object = sd
+	}
+
+	return nil
+}
+
+// addBackground draws img over the page's visible area, behind all content, and
+// sets the scheme text color for text that never sets its own
+func (e *Engine) addBackground(ctx *model.Context, pageNum int, img image.Image) error {
+	pageDict, _, inhPAttrs, err := ctx.PageDict(pageNum, false)
+	if err != nil {
+		return err
+	}
+
+	imgRef, box, err := embedPageImage(ctx, inhPAttrs, img)
+	if err != nil {
+		return err
+	}
+
+	name, err := addXObject(ctx, pageDict, inhPAttrs, *imgRef)
+	if err != nil {
+		return err
+	}
+
+	txt := e.colorScheme.Text
+	content := fmt.Sprintf("q %.4f 0 0 %.4f %.4f %.4f cm /%s Do Q %.3f %.3f %.3f rg %.3f %.3f %.3f RG\n",
+		box.Width(), box.Height(), box.LL.X, box.LL.Y, name,
+		txt.R, txt.G, txt.B,
+		txt.R, txt.G, txt.B)
+
+	return prependContent(ctx, pageDict, []byte(content))
+}
+
+// embedPageImage adds img as an image XObject and returns it with the page's visible
+// area, its crop box or media box, which the image is drawn over
+func embedPageImage(ctx *model.Context, inhPAttrs *model.InheritedPageAttrs, img image.Image) (*types.IndirectRef, *types.Rectangle, error) {
+	box := types.NewRectangle(0, 0, 612, 792)
+	if inhPAttrs != nil {
+		if inhPAttrs.CropBox != nil {
+			box = inhPAttrs.CropBox
+		} else if inhPAttrs.MediaBox != nil {
+			box = inhPAttrs.MediaBox
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, nil, err
+	}
+	imgRef, _, _, err := model.CreateImageResource(ctx.XRefTable, &buf)
+	if err != nil {
+		return nil, nil, err
+	}
+	return imgRef, box, nil
+}
+
+// addXObject gives the page its own copy of its resources with ref added as an
+// XObject, so pages sharing inherited resources keep their own background.
+// Returns the resource name used.
+func addXObject(ctx *model.Context, pageDict types.Dict, inhPAttrs *model.InheritedPageAttrs, ref types.IndirectRef) (string, error) {
+	resources := types.Dict{}
+	if inhPAttrs != nil && inhPAttrs.Resources != nil {
+		resources = inhPAttrs.Resources.Clone().(types.Dict)
+	}
+
+	xobjects := types.Dict{}
+	if obj, found := resources.Find("XObject"); found {
+		d, err := ctx.DereferenceDict(obj)
+		if err != nil {
+			return "", err
+		}
+		if d != nil {
+			xobjects = d.Clone().(types.Dict)
+		}
+	}
+
+	name := backgroundName
+	for i := 1; ; i++ {
+		if _, taken := xobjects[name]; !taken {
+			break
+		}
+		name = fmt.Sprintf("%s%d", backgroundName, i)
+	}
+
+	xobjects[name] = ref
+	resources["XObject"] = xobjects
+	pageDict["Resources"] = resources
+
+	return name, nil
+}
+
+// prependContent adds content as a new stream in front of the page's content
+func prependContent(ctx *model.Context, pageDict types.Dict, content []byte) error {
+	ref, err := ctx.StreamDictIndRef(content)
+	if err != nil {
+		return err
+	}
+
+	switch contents := pageDict["Contents"].(type) {
+	case types.IndirectRef:
+		pageDict["Contents"] = types.Array{*ref, contents}
+	case types.Array:
+		pageDict["Contents"] = append(types.Array{*ref}, contents...)
+	default:
+		pageDict["Contents"] = *ref
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package hybrid
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// textShowOperators show text
+var textShowOperators = map[string]bool{
+	"Tj": true, "TJ": true, "'": true, "\"": true,
+}
+
+// isTextPage reports whether a page draws only text and simple vector graphics, which
+// the direct engine converts well: no images, shadings or form XObjects (whose content
+// is not looked into), and no more path painting operators than text showing ones
+func isTextPage(ctx *model.Context, pageNum int) (bool, error) {
+	pageDict, _, _, err := ctx.PageDict(pageNum, false)
+	if err != nil {
+		return false, err
+	}
+
+	var refs types.Array
+	switch contents := pageDict["Contents"].(type) {
+	case types.IndirectRef:
+		refs = types.Array{contents}
+	case types.Array:
+		refs = contents
+	}
+
+	text, paint, graphics := 0, 0, false
+	count := func(op string, operands [][]byte) (string, bool) {
+		switch {
+		case textShowOperators[op]:
+			text++
+		case paintOperators[op]:
+			paint++
+		case op == "Do", op == "sh", op == "BI":
+			graphics = true
+		}
+		return "", false
+	}
+	for _, item := range refs {
+		sd, _, err := ctx.DereferenceStreamDict(item)
+		if err != nil || sd == nil {
+			continue
+		}
+		if err := sd.Decode(); err != nil {
+			return false, fmt.Errorf("failed to decode stream: %w", err)
+		}
+		rewriteContent(sd.Content, count)
+	}
+
+	return !graphics && paint <= text, nil
+}
+
+// classifyPages reports for each page whether it is a text page (see isTextPage). Pages
+// that cannot be read are taken as graphics pages.
+func classifyPages(ctx *model.Context) []bool {
+	text := make([]bool, ctx.PageCount)
+	for pageNum := 1; pageNum <= ctx.PageCount; pageNum++ {
+		text[pageNum-1], _ = isTextPage(ctx, pageNum)
+	}
+	return text
+}
+
+// replaceWithImage replaces the page's content with img drawn over its visible area
+func replaceWithImage(ctx *model.Context, pageNum int, img image.Image) error {
+	pageDict, _, inhPAttrs, err := ctx.PageDict(pageNum, false)
+	if err != nil {
+		return err
+	}
+
+	imgRef, box, err := embedPageImage(ctx, inhPAttrs, img)
+	if err != nil {
+		return err
+	}
+
+	content := fmt.Sprintf("q %.4f 0 0 %.4f %.4f %.4f cm /%s Do Q\n",
+		box.Width(), box.Height(), box.LL.X, box.LL.Y, backgroundName)
+	ref, err := ctx.StreamDictIndRef([]byte(content))
+	if err != nil {
+		return err
+	}
+	pageDict["Contents"] = *ref
+	pageDict["Resources"] = types.Dict{"XObject": types.Dict{backgroundName: *imgRef}}
+	return nil
+}
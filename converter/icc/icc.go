@@ -0,0 +1,268 @@
+package icc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Profile is an ICC profile and the number of color components it describes
+type Profile struct {
+	Name string
+	Data []byte
+	N    int // 1 gray, 3 RGB
+}
+
+// defaultSpaces maps a profile's component count to the page default color space it tags
+var defaultSpaces = map[int]string{
+	1: "DefaultGray",
+	3: "DefaultRGB",
+}
+
+// headerColorSpaces maps the data color space field of an ICC header to a component count
+var headerColorSpaces = map[string]int{
+	"GRAY": 1,
+	"RGB ": 3,
+	"CMYK": 4,
+}
+
+// Load reads an ICC profile file. Only gray and RGB profiles can tag page defaults.
+func Load(path string) (Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Profile{}, fmt.Errorf("failed to read ICC profile: %w", err)
+	}
+	if len(data) < 128 || string(data[36:40]) != "acsp" {
+		return Profile{}, fmt.Errorf("%s: not an ICC profile", path)
+	}
+
+	n, ok := headerColorSpaces[string(data[16:20])]
+	if !ok || defaultSpaces[n] == "" {
+		return Profile{}, fmt.Errorf("%s: unsupported ICC color space %q (must be gray or RGB)", path, data[16:20])
+	}
+
+	return Profile{Name: path, Data: data, N: n}, nil
+}
+
+// Defaults returns the built-in sRGB profile and a gray profile with the sRGB tone curve
+func Defaults() []Profile {
+	return []Profile{
+		{Name: "sRGB", Data: srgbProfile(), N: 3},
+		{Name: "sGray", Data: grayProfile(), N: 1},
+	}
+}
+
+// With returns profiles with p replacing the profile of the same color space
+func With(profiles []Profile, p Profile) []Profile {
+	out := []Profile{p}
+	for _, existing := range profiles {
+		if existing.N != p.N {
+			out = append(out, existing)
+		}
+	}
+	return out
+}
+
+// Tag embeds the profiles once and sets each as the page default color space
+// (/DefaultGray, /DefaultRGB) of every page, so device colors are read as
+// calibrated colors. Existing defaults are kept. Returns the number of pages tagged.
+func Tag(ctx *model.Context, profiles []Profile) (int, error) {
+	spaces := types.Dict{}
+	for _, p := range profiles {
+		name := defaultSpaces[p.N]
+		if name == "" {
+			continue
+		}
+
+		sd, err := ctx.NewStreamDictForBuf(p.Data)
+		if err != nil {
+			return 0, err
+		}
+		sd.InsertInt("N", p.N)
+		if err := sd.Encode(); err != nil {
+			return 0, err
+		}
+		ref, err := ctx.IndRefForNewObject(*sd)
+		if err != nil {
+			return 0, err
+		}
+		spaces[name] = types.Array{types.Name("ICCBased"), *ref}
+	}
+
+	tagged := 0
+	for pageNum := 1; pageNum <= ctx.PageCount; pageNum++ {
+		pageDict, _, inhPAttrs, err := ctx.PageDict(pageNum, false)
+		if err != nil {
+			return tagged, err
+		}
+
+		// Inherited resources are tagged where they are defined
+		var resources types.Dict
+		if inhPAttrs != nil {
+			resources = inhPAttrs.Resources
+		}
+		if resources == nil {
+			resources = types.Dict{}
+			pageDict["Resources"] = resources
+		}
+
+		csDict, err := ctx.DereferenceDict(resources["ColorSpace"])
+		if err != nil {
+			return tagged, err
+		}
+		if csDict == nil {
+			csDict = types.Dict{}
+			resources["ColorSpace"] = csDict
+		}
+
+		for name, cs := range spaces {
+			if _, found := csDict.Find(name); !found {
+				csDict[name] = cs
+			}
+		}
+		tagged++
+	}
+
+	return tagged, nil
+}
+
+// D50 is the profile connection space illuminant
+var d50 = [3]float64{0.9642, 1.0, 0.8249}
+
+// sRGB primaries adapted to D50 (Bradford), as in common sRGB profiles
+var srgbColorants = [3][3]float64{
+	{0.4360747, 0.2225045, 0.0139322},
+	{0.3850649, 0.7168786, 0.0971045},
+	{0.1430804, 0.0606169, 0.7141733},
+}
+
+// srgbProfile builds a version 2 matrix/TRC display profile for sRGB
+func srgbProfile() []byte {
+	trc := srgbCurve()
+	return buildProfile("RGB ", []tag{
+		{"desc", descTag("sRGB (pdfdarkmode)")},
+		{"cprt", textTag("No copyright, use freely")},
+		{"wtpt", xyzTag(d50)},
+		{"rXYZ", xyzTag(srgbColorants[0])},
+		{"gXYZ", xyzTag(srgbColorants[1])},
+		{"bXYZ", xyzTag(srgbColorants[2])},
+		{"rTRC", trc},
+		{"gTRC", trc},
+		{"bTRC", trc},
+	})
+}
+
+// grayProfile builds a version 2 gray display profile with the sRGB tone curve
+func grayProfile() []byte {
+	return buildProfile("GRAY", []tag{
+		{"desc", descTag("sGray (pdfdarkmode)")},
+		{"cprt", textTag("No copyright, use freely")},
+		{"wtpt", xyzTag(d50)},
+		{"kTRC", srgbCurve()},
+	})
+}
+
+// tag is a profile tag signature and its encoded data
+type tag struct {
+	sig  string
+	data []byte
+}
+
+// buildProfile lays out the header, tag table and tag data of a profile.
+// Tags with identical data share one copy.
+func buildProfile(colorSpace string, tags []tag) []byte {
+	tableSize := 4 + 12*len(tags)
+	offset := 128 + tableSize
+
+	var table, data bytes.Buffer
+	binary.Write(&table, binary.BigEndian, uint32(len(tags)))
+	offsets := map[string]int{}
+	for _, t := range tags {
+		off, shared := offsets[string(t.data)]
+		if !shared {
+			off = offset + data.Len()
+			offsets[string(t.data)] = off
+			data.Write(t.data)
+			for data.Len()%4 != 0 {
+				data.WriteByte(0)
+			}
+		}
+		table.WriteString(t.sig)
+		binary.Write(&table, binary.BigEndian, uint32(off))
+		binary.Write(&table, binary.BigEndian, uint32(len(t.data)))
+	}
+
+	header := make([]byte, 128)
+	size := 128 + table.Len() + data.Len()
+	binary.BigEndian.PutUint32(header[0:], uint32(size))
+	binary.BigEndian.PutUint32(header[8:], 0x02100000) // Version 2.1
+	copy(header[12:], "mntr")
+	copy(header[16:], colorSpace)
+	copy(header[20:], "XYZ ")
+	copy(header[36:], "acsp")
+	for i, v := range d50 {
+		binary.BigEndian.PutUint32(header[68+4*i:], s15Fixed16(v))
+	}
+
+	return append(append(header, table.Bytes()...), data.Bytes()...)
+}
+
+// srgbCurve returns a curveType tag sampling the sRGB transfer function
+func srgbCurve() []byte {
+	const entries = 1024
+	var b bytes.Buffer
+	b.WriteString("curv")
+	b.Write(make([]byte, 4))
+	binary.Write(&b, binary.BigEndian, uint32(entries))
+	for i := 0; i < entries; i++ {
+		v := float64(i) / (entries - 1)
+		if v <= 0.04045 {
+			v /= 12.92
+		} else {
+			v = math.Pow((v+0.055)/1.055, 2.4)
+		}
+		binary.Write(&b, binary.BigEndian, uint16(math.Round(v*65535)))
+	}
+	return b.Bytes()
+}
+
+// xyzTag returns an XYZType tag
+func xyzTag(xyz [3]float64) []byte {
+	b := make([]byte, 20)
+	copy(b, "XYZ ")
+	for i, v := range xyz {
+		binary.BigEndian.PutUint32(b[8+4*i:], s15Fixed16(v))
+	}
+	return b
+}
+
+// textTag returns a textType tag
+func textTag(s string) []byte {
+	b := make([]byte, 8, 8+len(s)+1)
+	copy(b, "text")
+	return append(append(b, s...), 0)
+}
+
+// descTag returns a version 2 textDescriptionType tag with an ASCII description only
+func descTag(s string) []byte {
+	var b bytes.Buffer
+	b.WriteString("desc")
+	b.Write(make([]byte, 4))
+	binary.Write(&b, binary.BigEndian, uint32(len(s)+1))
+	b.WriteString(s)
+	b.WriteByte(0)
+	b.Write(make([]byte, 4+4)) // Unicode language code and count
+	b.Write(make([]byte, 2+1)) // ScriptCode code and count
+	b.Write(make([]byte, 67))  // ScriptCode description
+	return b.Bytes()
+}
+
+// s15Fixed16 encodes a signed 15.16 fixed point number
+func s15Fixed16(v float64) uint32 {
+	return uint32(int32(math.Round(v * 65536)))
+}
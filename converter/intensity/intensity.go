@@ -0,0 +1,79 @@
+// Package intensity turns one 0-100 intensity into the engines' tuning knobs, from a
+// gentle preset that leaves colors close to the source, through the defaults at 50, to
+// an aggressive one with punchier colors and harder contrast
+package intensity
+
+import (
+	"fmt"
+
+	"pdfdarkmode/converter/direct"
+	"pdfdarkmode/converter/raster"
+)
+
+// Default is the intensity that keeps every knob at its default
+const Default = 50
+
+// Tuning holds the knobs an intensity sets
+type Tuning struct {
+	MinColorL float64 // Lightness floor for colorful colors
+	MaxColorL float64 // Lightness above which colorful colors are toned down
+	SatBoost  float64 // Factor colorful saturation is multiplied by
+	SnapWhite float64 // Raster: lightness above which document colors become the background
+	SnapBlack float64 // Raster: lightness below which document colors become the text color
+}
+
+// Gentle (0), default (50) and aggressive (100) presets. At 0 colorful colors keep
+// nearly their lightness and saturation and document grays invert without snapping;
+// at 100 they are pulled into a narrow, saturated band and grays snap early.
+var (
+	directPresets = [3]Tuning{
+		{MinColorL: 0.45, MaxColorL: 0.95, SatBoost: 1},
+		{MinColorL: direct.DefaultMinColorLightness, MaxColorL: direct.DefaultMaxColorLightness, SatBoost: direct.DefaultSaturationBoost},
+		{MinColorL: 0.65, MaxColorL: 0.75, SatBoost: 1.35},
+	}
+	rasterPresets = [3]Tuning{
+		{MinColorL: 0.2, MaxColorL: 0.85, SatBoost: 1, SnapWhite: 0.98, SnapBlack: 0.02},
+		{MinColorL: raster.DefaultMinColorLightness, MaxColorL: raster.DefaultMaxColorLightness, SatBoost: raster.DefaultSaturationBoost,
+			SnapWhite: raster.DefaultSnapNearWhite, SnapBlack: raster.DefaultSnapNearBlack},
+		{MinColorL: 0.45, MaxColorL: 0.6, SatBoost: 1.3, SnapWhite: 0.8, SnapBlack: 0.25},
+	}
+)
+
+// Validate checks that intensity is within 0-100
+func Validate(intensity int) error {
+	if intensity < 0 || intensity > 100 {
+		return fmt.Errorf("intensity must be between 0 and 100, got %d", intensity)
+	}
+	return nil
+}
+
+// Direct returns the direct engine's knobs at intensity (0-100). The direct engine has
+// no snap cutoffs; SnapWhite and SnapBlack are zero.
+func Direct(intensity int) Tuning {
+	return interpolate(directPresets, intensity)
+}
+
+// Raster returns the raster engine's knobs at intensity (0-100)
+func Raster(intensity int) Tuning {
+	return interpolate(rasterPresets, intensity)
+}
+
+// interpolate blends linearly between the gentle and default presets below Default and
+// between the default and aggressive ones above it
+func interpolate(presets [3]Tuning, intensity int) Tuning {
+	from, to := presets[0], presets[1]
+	t := float64(intensity) / Default
+	if intensity > Default {
+		from, to = presets[1], presets[2]
+		t = float64(intensity-Default) / (100 - Default)
+	}
+	t = max(0, min(1, t))
+	mix := func(a, b float64) float64 { return a + (b-a)*t }
+	return Tuning{
+		MinColorL: mix(from.MinColorL, to.MinColorL),
+		MaxColorL: mix(from.MaxColorL, to.MaxColorL),
+		SatBoost:  mix(from.SatBoost, to.SatBoost),
+		SnapWhite: mix(from.SnapWhite, to.SnapWhite),
+		SnapBlack: mix(from.SnapBlack, to.SnapBlack),
+	}
+}
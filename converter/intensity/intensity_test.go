@@ -0,0 +1,78 @@
+package intensity
+
+import (
+	"math"
+	"testing"
+
+	"pdfdarkmode/converter/direct"
+	"pdfdarkmode/converter/raster"
+)
+
+// sameTuning reports whether a and b match up to rounding
+func sameTuning(a, b Tuning) bool {
+	near := func(x, y float64) bool { return math.Abs(x-y) < 1e-9 }
+	return near(a.MinColorL, b.MinColorL) && near(a.MaxColorL, b.MaxColorL) && near(a.SatBoost, b.SatBoost) &&
+		near(a.SnapWhite, b.SnapWhite) && near(a.SnapBlack, b.SnapBlack)
+}
+
+func TestPresets(t *testing.T) {
+	directDefault := Tuning{
+		MinColorL: direct.DefaultMinColorLightness,
+		MaxColorL: direct.DefaultMaxColorLightness,
+		SatBoost:  direct.DefaultSaturationBoost,
+	}
+	rasterDefault := Tuning{
+		MinColorL: raster.DefaultMinColorLightness,
+		MaxColorL: raster.DefaultMaxColorLightness,
+		SatBoost:  raster.DefaultSaturationBoost,
+		SnapWhite: raster.DefaultSnapNearWhite,
+		SnapBlack: raster.DefaultSnapNearBlack,
+	}
+
+	tests := []struct {
+		engine    string
+		tune      func(int) Tuning
+		intensity int
+		want      Tuning
+	}{
+		{"direct", Direct, 0, directPresets[0]},
+		{"direct", Direct, Default, directDefault},
+		{"direct", Direct, 100, directPresets[2]},
+		{"raster", Raster, 0, rasterPresets[0]},
+		{"raster", Raster, Default, rasterDefault},
+		{"raster", Raster, 100, rasterPresets[2]},
+	}
+
+	for _, tt := range tests {
+		if got := tt.tune(tt.intensity); !sameTuning(got, tt.want) {
+			t.Errorf("%s at %d: got %+v, want %+v", tt.engine, tt.intensity, got, tt.want)
+		}
+	}
+}
+
+func TestIntensityOrder(t *testing.T) {
+	// Higher intensities narrow the colorful band and boost saturation more
+	for _, tune := range []func(int) Tuning{Direct, Raster} {
+		gentle, def, aggressive := tune(0), tune(Default), tune(100)
+		if !(gentle.SatBoost < def.SatBoost && def.SatBoost < aggressive.SatBoost) {
+			t.Errorf("saturation boosts %v, %v, %v do not increase", gentle.SatBoost, def.SatBoost, aggressive.SatBoost)
+		}
+		if !(gentle.MaxColorL-gentle.MinColorL > def.MaxColorL-def.MinColorL &&
+			def.MaxColorL-def.MinColorL > aggressive.MaxColorL-aggressive.MinColorL) {
+			t.Errorf("colorful lightness bands %+v, %+v, %+v do not narrow", gentle, def, aggressive)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, intensity := range []int{0, 50, 100} {
+		if err := Validate(intensity); err != nil {
+			t.Errorf("%d: %v", intensity, err)
+		}
+	}
+	for _, intensity := range []int{-1, 101} {
+		if Validate(intensity) == nil {
+			t.Errorf("%d: got no error", intensity)
+		}
+	}
+}
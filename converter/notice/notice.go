@@ -0,0 +1,88 @@
+// Package notice lays out text the tool stamps onto pages, such as render-timeout
+// notices and scheme labels, as pdfcpu text watermark descriptions
+package notice
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/pdfcpu/pdfcpu/pkg/font"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// coreFont is used for text it can encode: Latin-1 covers everything the tool writes
+// itself
+const coreFont = "Helvetica"
+
+// mirrored swaps the left and right of pdfcpu's watermark positions
+var mirrored = map[string]string{
+	"tl": "tr", "tr": "tl",
+	"l": "r", "r": "l",
+	"bl": "br", "br": "bl",
+}
+
+// Layout is where stamped text goes on a page, for left-to-right documents
+type Layout struct {
+	Position string  // pdfcpu watermark position, e.g. "c" or "tl"
+	DX, DY   float64 // Offset from Position in points
+}
+
+// Desc returns the description entries that place text by l, in a font covering it,
+// e.g. "fontname:Helvetica, position:tl, offset:12 -12, aligntext:l". With rtl, for
+// right-to-left documents, the position and offset are mirrored. Text is aligned to
+// the side it is placed on, and text in a right-to-left script is set right to left.
+func (l Layout) Desc(text string, rtl bool) string {
+	position, dx := l.Position, l.DX
+	if rtl {
+		if p, ok := mirrored[position]; ok {
+			position = p
+		}
+		dx = -dx
+		if dx == 0 {
+			dx = 0 // -0 would be written as "-0"
+		}
+	}
+	align := position[len(position)-1:] // The side the text sits on: l, c or r
+
+	desc := fmt.Sprintf("fontname:%s, position:%s, offset:%g %g, aligntext:%s", Font(text), position, dx, l.DY, align)
+	if rtl && hasRTLScript(text) {
+		desc += ", rtl:on"
+	}
+	return desc
+}
+
+// Font returns the font to stamp text in: Helvetica when it can encode every character,
+// otherwise the first installed pdfcpu user font (see "pdfcpu fonts install") with a
+// glyph for each of them, falling back to Helvetica if none has
+func Font(text string) string {
+	if !strings.ContainsFunc(text, func(r rune) bool { return r > unicode.MaxLatin1 }) {
+		return coreFont
+	}
+
+	model.NewDefaultConfiguration() // Loads the installed user fonts
+	names := font.UserFontNames()
+	slices.Sort(names)
+	font.UserFontMetricsLock.RLock()
+	defer font.UserFontMetricsLock.RUnlock()
+	for _, name := range names {
+		chars := font.UserFontMetrics[name].Chars
+		covered := !strings.ContainsFunc(text, func(r rune) bool {
+			_, ok := chars[uint32(r)]
+			return !ok && !unicode.IsSpace(r)
+		})
+		if covered {
+			return name
+		}
+	}
+	return coreFont
+}
+
+// hasRTLScript reports whether text contains Hebrew or Arabic letters, which pdfcpu
+// only lays out right to left when asked
+func hasRTLScript(text string) bool {
+	return strings.ContainsFunc(text, func(r rune) bool {
+		return unicode.In(r, unicode.Hebrew, unicode.Arabic)
+	})
+}
@@ -0,0 +1,201 @@
+// Package nup splits imposed (n-up) sheets, several logical pages printed on one PDF
+// page, back into one PDF page per logical page, so each converts on its own
+package nup
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"pdfdarkmode/converter/direct"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// inheritableKeys are the page attributes a page can inherit from the page tree, which
+// Split resolves onto every page as it flattens the tree
+var inheritableKeys = []string{"Resources", "MediaBox", "CropBox", "Rotate"}
+
+// pageBoxes are the boundaries replaced by the cell's media box
+var pageBoxes = []string{"CropBox", "BleedBox", "TrimBox", "ArtBox"}
+
+// Parse parses a grid of rows by columns, e.g. "2x2" or "1x2"
+func Parse(grid string) (rows, cols int, err error) {
+	r, c, found := strings.Cut(strings.ToLower(grid), "x")
+	if found {
+		rows, err = strconv.Atoi(strings.TrimSpace(r))
+		if err == nil {
+			cols, err = strconv.Atoi(strings.TrimSpace(c))
+		}
+	}
+	if !found || err != nil || rows < 1 || cols < 1 || rows*cols < 2 {
+		return 0, 0, fmt.Errorf("invalid n-up grid: %q (must be ROWSxCOLS, e.g. 2x2 or 1x2)", grid)
+	}
+	return rows, cols, nil
+}
+
+// sheet is a page being split and the attributes it inherited
+type sheet struct {
+	dict types.Dict
+	ref  types.IndirectRef
+	box  *types.Rectangle // Crop box, or media box, cut into cells
+}
+
+// Split writes a copy of inputPath to a temp file with each page cut into rows x cols
+// pages of equal size, in reading order: left to right, then top to bottom, in the
+// page's unrotated coordinates. The first cell keeps the page object, so bookmarks and
+// links to the sheet lead to it; annotations go to the cell holding their center.
+// Returns the temp file path; the caller removes it.
+func Split(inputPath string, rows, cols int) (string, error) {
+	ctx, err := direct.ReadContext(inputPath)
+	if err != nil {
+		return "", err
+	}
+	rootRef, ok := ctx.RootDict["Pages"].(types.IndirectRef)
+	if !ok {
+		return "", fmt.Errorf("page tree root is not an indirect object")
+	}
+	root, err := ctx.DereferenceDict(rootRef)
+	if err != nil || root == nil {
+		return "", fmt.Errorf("failed to read page tree root: %v", err)
+	}
+
+	// Read every page before the tree is rebuilt
+	sheets := make([]sheet, 0, ctx.PageCount)
+	for pageNum := 1; pageNum <= ctx.PageCount; pageNum++ {
+		pageDict, pageRef, inhPAttrs, err := ctx.PageDict(pageNum, false)
+		if err != nil || pageRef == nil {
+			return "", fmt.Errorf("failed to read page %d: %v", pageNum, err)
+		}
+		for _, key := range []string{"Resources", "Rotate"} {
+			if _, found := pageDict.Find(key); !found {
+				if obj := inherited(ctx, pageDict, key); obj != nil {
+					pageDict[key] = obj
+				}
+			}
+		}
+		box := types.NewRectangle(0, 0, 612, 792)
+		if inhPAttrs != nil {
+			if inhPAttrs.CropBox != nil {
+				box = inhPAttrs.CropBox
+			} else if inhPAttrs.MediaBox != nil {
+				box = inhPAttrs.MediaBox
+			}
+		}
+		sheets = append(sheets, sheet{dict: pageDict, ref: *pageRef, box: box})
+	}
+
+	var kids types.Array
+	for _, s := range sheets {
+		cells, err := splitSheet(ctx, s, rootRef, rows, cols)
+		if err != nil {
+			return "", err
+		}
+		kids = append(kids, cells...)
+	}
+
+	root["Kids"] = kids
+	root["Count"] = types.Integer(len(kids))
+	for _, key := range inheritableKeys {
+		root.Delete(key)
+	}
+
+	tmp, err := os.CreateTemp("", "pdfdarkmode-nup-*.pdf")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmp.Close()
+	if err := api.WriteContextFile(ctx, tmp.Name()); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write split pages: %w", err)
+	}
+	return tmp.Name(), nil
+}
+
+// inherited returns the nearest ancestor's entry for key as written, keeping indirect
+// references (shared resources stay shared), or nil if no ancestor has one
+func inherited(ctx *model.Context, pageDict types.Dict, key string) types.Object {
+	node := pageDict
+	for range 64 { // Bounds a cyclic page tree
+		parent, err := ctx.DereferenceDict(node["Parent"])
+		if err != nil || parent == nil {
+			return nil
+		}
+		if obj, found := parent.Find(key); found {
+			return obj
+		}
+		node = parent
+	}
+	return nil
+}
+
+// splitSheet turns one sheet into its cells' pages under parent, returning them in
+// reading order
+func splitSheet(ctx *model.Context, s sheet, parent types.IndirectRef, rows, cols int) (types.Array, error) {
+	s.dict["Parent"] = parent
+	for _, key := range pageBoxes {
+		s.dict.Delete(key)
+	}
+	annots := cellAnnotations(ctx, s, rows, cols)
+	s.dict.Delete("Annots")
+	template := s.dict.Clone().(types.Dict)
+
+	w, h := s.box.Width()/float64(cols), s.box.Height()/float64(rows)
+	cells := make(types.Array, 0, rows*cols)
+	for cell := range rows * cols {
+		row, col := cell/cols, cell%cols
+		d, ref := s.dict, s.ref
+		if cell > 0 {
+			d = template.Clone().(types.Dict)
+			newRef, err := ctx.IndRefForNewObject(d)
+			if err != nil {
+				return nil, err
+			}
+			ref = *newRef
+		}
+
+		x := s.box.LL.X + float64(col)*w
+		y := s.box.UR.Y - float64(row+1)*h
+		d["MediaBox"] = types.NewRectangle(x, y, x+w, y+h).Array()
+		if len(annots[cell]) > 0 {
+			for _, obj := range annots[cell] {
+				if annot, err := ctx.DereferenceDict(obj); err == nil && annot != nil {
+					annot["P"] = ref
+				}
+			}
+			d["Annots"] = annots[cell]
+		}
+		cells = append(cells, ref)
+	}
+	return cells, nil
+}
+
+// cellAnnotations assigns the sheet's annotations to the cells holding the centers of
+// their rectangles, clamped to the sheet
+func cellAnnotations(ctx *model.Context, s sheet, rows, cols int) []types.Array {
+	annots := make([]types.Array, rows*cols)
+	arr, err := ctx.DereferenceArray(s.dict["Annots"])
+	if err != nil || arr == nil {
+		return annots
+	}
+
+	w, h := s.box.Width()/float64(cols), s.box.Height()/float64(rows)
+	for _, obj := range arr {
+		cell := 0
+		if annot, err := ctx.DereferenceDict(obj); err == nil && annot != nil {
+			if a, err := ctx.DereferenceArray(annot["Rect"]); err == nil && len(a) == 4 {
+				if r := types.RectForArray(a); r != nil {
+					cx, cy := (r.LL.X+r.UR.X)/2, (r.LL.Y+r.UR.Y)/2
+					col := min(max(int((cx-s.box.LL.X)/w), 0), cols-1)
+					row := min(max(int((s.box.UR.Y-cy)/h), 0), rows-1)
+					cell = row*cols + col
+				}
+			}
+		}
+		annots[cell] = append(annots[cell], obj)
+	}
+	return annots
+}
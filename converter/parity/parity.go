@@ -0,0 +1,35 @@
+// Package parity selects the odd or even pages of a document, for converting only
+// alternate pages such as one side of a double-sided scan
+package parity
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Page selections
+const (
+	Odd  = "odd"  // Pages 1, 3, 5, ...
+	Even = "even" // Pages 2, 4, 6, ...
+)
+
+// Parse checks a page selection, returning it in lower case. Empty selects all pages.
+func Parse(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "", Odd, Even:
+		return s, nil
+	}
+	return "", fmt.Errorf("invalid page selection: %s (must be '%s' or '%s')", s, Odd, Even)
+}
+
+// Includes reports whether 1-based page pageNum is in selection, empty selecting all
+func Includes(selection string, pageNum int) bool {
+	switch selection {
+	case Odd:
+		return pageNum%2 == 1
+	case Even:
+		return pageNum%2 == 0
+	}
+	return true
+}
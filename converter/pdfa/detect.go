@@ -0,0 +1,67 @@
+package pdfa
+
+import (
+	"regexp"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// pdfaidProperty matches a pdfaid property in XMP, written as an attribute
+// (pdfaid:part="2") or an element (<pdfaid:part>2</pdfaid:part>)
+var pdfaidProperty = regexp.MustCompile(`pdfaid:(part|conformance)\s*(?:=\s*["']\s*([^"']*?)\s*["']|>\s*([^<]*?)\s*<)`)
+
+// Detect returns the PDF/A conformance ctx claims in its XMP metadata, e.g.
+// "PDF/A-2B", or "" if it claims none
+func Detect(ctx *model.Context) string {
+	if ctx.RootDict == nil {
+		return ""
+	}
+	sd, _, err := ctx.DereferenceStreamDict(ctx.RootDict["Metadata"])
+	if err != nil || sd == nil || sd.Decode() != nil {
+		return ""
+	}
+
+	var part, level string
+	for _, m := range pdfaidProperty.FindAllSubmatch(sd.Content, -1) {
+		value := string(m[2]) + string(m[3])
+		if value == "" { // A closing tag
+			continue
+		}
+		if string(m[1]) == "part" {
+			part = value
+		} else {
+			level = value
+		}
+	}
+	if part == "" {
+		return ""
+	}
+	return "PDF/A-" + part + level
+}
+
+// IntentComponents returns the number of color components of ctx's PDF/A output
+// intent profile: 3 for RGB, 4 for CMYK, 1 for gray, or 0 if it has none
+func IntentComponents(ctx *model.Context) int {
+	intents, err := ctx.DereferenceArray(ctx.RootDict["OutputIntents"])
+	if err != nil {
+		return 0
+	}
+	for _, obj := range intents {
+		intent, err := ctx.DereferenceDict(obj)
+		if err != nil || intent == nil {
+			continue
+		}
+		if s := intent.NameEntry("S"); s == nil || *s != "GTS_PDFA1" {
+			continue
+		}
+		profile, _, err := ctx.DereferenceStreamDict(intent["DestOutputProfile"])
+		if err != nil || profile == nil {
+			return 0
+		}
+		if n := profile.IntEntry("N"); n != nil {
+			return *n
+		}
+		return 0
+	}
+	return 0
+}
@@ -0,0 +1,331 @@
+package pdfa
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"pdfdarkmode/converter/colors"
+	"pdfdarkmode/converter/icc"
+	"pdfdarkmode/converter/pdfversion"
+	"pdfdarkmode/converter/sanitize"
+	"pdfdarkmode/converter/viewerhints"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// Part and conformance level claimed by the output
+const (
+	part        = 2
+	conformance = "B"
+)
+
+// outputCondition names the sRGB output intent
+const outputCondition = "sRGB IEC61966-2.1"
+
+// Options configures the PDF/A pass
+type Options struct {
+	PDFVersion  string        // Output PDF version to keep, empty for the pdfcpu default
+	ViewerHints bool          // Keep the dark theme hint in the rebuilt metadata
+	Scheme      colors.Scheme // Scheme recorded by the hint
+}
+
+// ConvertFile rewrites the PDF at path as PDF/A-2b: it strips scripts and automatic
+// actions, adds an sRGB output intent and rebuilds the XMP metadata from the
+// document information. The PDF/A identification is only written when Check finds
+// no problems; otherwise the problems are returned and the file is not claimed to
+// conform.
+func ConvertFile(path string, opts Options) ([]string, error) {
+	ctx, err := readContext(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read output for PDF/A: %w", err)
+	}
+
+	sanitize.Apply(ctx)
+	if err := addOutputIntent(ctx); err != nil {
+		return nil, fmt.Errorf("failed to add output intent: %w", err)
+	}
+
+	problems := Check(ctx, opts.PDFVersion)
+
+	var target model.Version
+	if opts.PDFVersion != "" {
+		if target, err = pdfversion.Parse(opts.PDFVersion); err != nil {
+			return nil, err
+		}
+		if err := pdfversion.Apply(ctx, target); err != nil {
+			return nil, err
+		}
+	}
+
+	// pdfcpu stamps the document information with the time of writing, which the
+	// XMP dates must equal to the second; wait out a second about to roll over
+	now := time.Now()
+	if rest := time.Second - time.Duration(now.Nanosecond()); rest < 200*time.Millisecond {
+		time.Sleep(rest)
+		now = time.Now()
+	}
+	if err := setMetadata(ctx, now, len(problems) == 0, opts); err != nil {
+		return nil, fmt.Errorf("failed to write PDF/A metadata: %w", err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if err := api.WriteContext(ctx, f); err != nil {
+		return nil, fmt.Errorf("failed to write PDF/A output: %w", err)
+	}
+	if opts.PDFVersion != "" {
+		if err := pdfversion.StampHeader(f, target); err != nil {
+			return nil, err
+		}
+	}
+
+	return problems, nil
+}
+
+// Check lists what keeps ctx from conforming to PDF/A-2b among the requirements
+// this tool can affect: encryption, unembedded fonts, LZW compression, CMYK images
+// without a CMYK output intent, embedded files, and the PDF version. It is not a full
+// validator.
+func Check(ctx *model.Context, pdfVersion string) []string {
+	var problems []string
+
+	if ctx.Encrypt != nil {
+		problems = append(problems, "the document is encrypted")
+	}
+	if v, err := pdfversion.Parse(pdfVersion); err == nil && v > model.V17 {
+		problems = append(problems, fmt.Sprintf("PDF/A-2 is based on PDF 1.7; version %s is too new", pdfVersion))
+	}
+	if names, err := ctx.DereferenceDict(ctx.RootDict["Names"]); err == nil && names != nil && names["EmbeddedFiles"] != nil {
+		problems = append(problems, "the document has embedded files, which PDF/A-2 only allows if they are PDF/A themselves")
+	}
+
+	unembedded := map[string]bool{}
+	lzw, cmyk := 0, 0
+	for _, entry := range ctx.Table {
+		if entry == nil || entry.Free {
+			continue
+		}
+		switch obj := entry.Object.(type) {
+		case types.Dict:
+			if name, ok := unembeddedFont(ctx, obj); ok {
+				unembedded[name] = true
+			}
+		case types.StreamDict:
+			for _, filter := range obj.FilterPipeline {
+				if filter.Name == "LZWDecode" {
+					lzw++
+				}
+			}
+			if subtype := obj.Dict.NameEntry("Subtype"); subtype != nil && *subtype == "Image" {
+				if cs := obj.Dict.NameEntry("ColorSpace"); cs != nil && *cs == "DeviceCMYK" {
+					cmyk++
+				}
+			}
+		}
+	}
+
+	fonts := make([]string, 0, len(unembedded))
+	for name := range unembedded {
+		fonts = append(fonts, name)
+	}
+	sort.Strings(fonts)
+	for _, name := range fonts {
+		problems = append(problems, fmt.Sprintf("font %s is not embedded", name))
+	}
+	if lzw > 0 {
+		problems = append(problems, fmt.Sprintf("%d stream(s) use LZW compression", lzw))
+	}
+	if cmyk > 0 {
+		problems = append(problems, fmt.Sprintf("%d DeviceCMYK image(s) do not match the sRGB output intent", cmyk))
+	}
+
+	return problems
+}
+
+// unembeddedFont returns the base font name of d if it is a font whose glyphs must
+// be embedded but are not. Type 3 fonts are drawn by content streams, and composite
+// (Type 0) fonts are checked through their descendant fonts.
+func unembeddedFont(ctx *model.Context, d types.Dict) (string, bool) {
+	if t := d.NameEntry("Type"); t == nil || *t != "Font" {
+		return "", false
+	}
+	subtype := d.NameEntry("Subtype")
+	if subtype == nil || *subtype == "Type3" || *subtype == "Type0" {
+		return "", false
+	}
+
+	name := "(unnamed)"
+	if base := d.NameEntry("BaseFont"); base != nil {
+		name = *base
+	}
+
+	desc, err := ctx.DereferenceDict(d["FontDescriptor"])
+	if err != nil || desc == nil {
+		return name, true
+	}
+	for _, key := range []string{"FontFile", "FontFile2", "FontFile3"} {
+		if desc[key] != nil {
+			return "", false
+		}
+	}
+	return name, true
+}
+
+// addOutputIntent embeds the built-in sRGB profile as the PDF/A output intent,
+// unless the document already has one
+func addOutputIntent(ctx *model.Context) error {
+	if intents, err := ctx.DereferenceArray(ctx.RootDict["OutputIntents"]); err == nil {
+		for _, obj := range intents {
+			intent, err := ctx.DereferenceDict(obj)
+			if err != nil || intent == nil {
+				continue
+			}
+			if s := intent.NameEntry("S"); s != nil && *s == "GTS_PDFA1" {
+				return nil
+			}
+		}
+	}
+
+	var profile icc.Profile
+	for _, p := range icc.Defaults() {
+		if p.N == 3 {
+			profile = p
+		}
+	}
+
+	sd, err := ctx.NewStreamDictForBuf(profile.Data)
+	if err != nil {
+		return err
+	}
+	sd.InsertInt("N", profile.N)
+	if err := sd.Encode(); err != nil {
+		return err
+	}
+	profileRef, err := ctx.IndRefForNewObject(*sd)
+	if err != nil {
+		return err
+	}
+
+	intent := types.Dict{
+		"Type":                      types.Name("OutputIntent"),
+		"S":                         types.Name("GTS_PDFA1"),
+		"OutputConditionIdentifier": types.StringLiteral(outputCondition),
+		"Info":                      types.StringLiteral(outputCondition),
+		"DestOutputProfile":         *profileRef,
+	}
+	ctx.RootDict["OutputIntents"] = types.Array{intent}
+	return nil
+}
+
+// setMetadata replaces the XMP metadata with a packet mirroring the document
+// information as pdfcpu writes it at now, with the PDF/A identification if identify
+// is set, and the dark theme hint with its extension schema if enabled
+func setMetadata(ctx *model.Context, now time.Time, identify bool, opts Options) error {
+	info := map[string]string{}
+	if ctx.Info != nil {
+		if d, err := ctx.DereferenceDict(*ctx.Info); err == nil && d != nil {
+			for _, key := range []string{"Title", "Author", "Subject", "Keywords", "Creator"} {
+				if obj, found := d.Find(key); found {
+					if s, err := ctx.DereferenceStringOrHexLiteral(obj, model.V10, nil); err == nil && s != "" {
+						info[key] = s
+					}
+				}
+			}
+		}
+	}
+
+	date := now.Format("2006-01-02T15:04:05-07:00")
+	attr := func(name, value string) string {
+		return fmt.Sprintf(` %s="%s"`, name, html.EscapeString(value))
+	}
+
+	var b strings.Builder
+	b.WriteString("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n")
+	b.WriteString("<rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+	if identify {
+		fmt.Fprintf(&b, `<rdf:Description rdf:about="" xmlns:pdfaid="http://www.aiim.org/pdfa/ns/id/" pdfaid:part="%d" pdfaid:conformance="%s"/>`+"\n", part, conformance)
+	}
+
+	b.WriteString(`<rdf:Description rdf:about="" xmlns:xmp="http://ns.adobe.com/xap/1.0/"`)
+	b.WriteString(attr("xmp:CreateDate", date) + attr("xmp:ModifyDate", date) + attr("xmp:MetadataDate", date))
+	if v, ok := info["Creator"]; ok {
+		b.WriteString(attr("xmp:CreatorTool", v))
+	}
+	b.WriteString("/>\n")
+
+	b.WriteString(`<rdf:Description rdf:about="" xmlns:pdf="http://ns.adobe.com/pdf/1.3/"`)
+	b.WriteString(attr("pdf:Producer", "pdfcpu "+model.VersionStr))
+	if v, ok := info["Keywords"]; ok {
+		b.WriteString(attr("pdf:Keywords", v))
+	}
+	b.WriteString("/>\n")
+
+	if len(info) > 0 {
+		b.WriteString(`<rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/">`)
+		if v, ok := info["Title"]; ok {
+			b.WriteString(`<dc:title><rdf:Alt><rdf:li xml:lang="x-default">` + html.EscapeString(v) + `</rdf:li></rdf:Alt></dc:title>`)
+		}
+		if v, ok := info["Author"]; ok {
+			b.WriteString(`<dc:creator><rdf:Seq><rdf:li>` + html.EscapeString(v) + `</rdf:li></rdf:Seq></dc:creator>`)
+		}
+		if v, ok := info["Subject"]; ok {
+			b.WriteString(`<dc:description><rdf:Alt><rdf:li xml:lang="x-default">` + html.EscapeString(v) + `</rdf:li></rdf:Alt></dc:description>`)
+		}
+		b.WriteString("</rdf:Description>\n")
+	}
+
+	if opts.ViewerHints {
+		b.WriteString(viewerhints.ExtensionSchema() + "\n")
+		b.WriteString(viewerhints.Description(opts.Scheme) + "\n")
+	}
+
+	b.WriteString("</rdf:RDF>\n")
+	b.WriteString("</x:xmpmeta>\n")
+	b.WriteString("<?xpacket end=\"w\"?>")
+
+	// PDF/A forbids filters on metadata streams
+	sd := types.StreamDict{
+		Dict:    types.Dict{"Type": types.Name("Metadata"), "Subtype": types.Name("XML")},
+		Content: []byte(b.String()),
+	}
+	if err := sd.Encode(); err != nil {
+		return err
+	}
+	ref, err := ctx.IndRefForNewObject(sd)
+	if err != nil {
+		return err
+	}
+	ctx.RootDict["Metadata"] = *ref
+	return nil
+}
+
+// readContext reads path for the PDF/A pass
+func readContext(path string) (*model.Context, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationRelaxed
+	ctx, err := api.ReadContext(f, conf)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.EnsurePageCount(); err != nil {
+		return nil, err
+	}
+	return ctx, nil
+}
@@ -0,0 +1,159 @@
+package pdfversion
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// headerVersionOffset is the byte offset of the version number in "%PDF-x.y"
+const headerVersionOffset = 5
+
+// Parse parses a version string such as "1.5" or "2.0"
+func Parse(s string) (model.Version, error) {
+	v, err := model.PDFVersion(s)
+	if err != nil {
+		return v, fmt.Errorf("invalid PDF version: %s (expected 1.0-1.7 or 2.0)", s)
+	}
+	return v, nil
+}
+
+// RequiredVersion returns the minimum version needed by features used in the document,
+// along with the name of the feature that requires it. Besides the catalog entries it
+// looks at every object for transparency (constant opacity, soft masks, blend modes
+// and transparency groups, e.g. from watermarks and knockout groups) and optional
+// content groups (e.g. from layers).
+func RequiredVersion(ctx *model.Context) (model.Version, string) {
+	required, feature := model.V10, ""
+	require := func(v model.Version, name string) {
+		if v > required {
+			required, feature = v, name
+		}
+	}
+
+	if ctx.RootDict != nil {
+		if _, found := ctx.RootDict.Find("StructTreeRoot"); found {
+			require(model.V13, "tagged structure")
+		}
+		if _, found := ctx.RootDict.Find("MarkInfo"); found {
+			require(model.V14, "marked content info")
+		}
+		if _, found := ctx.RootDict.Find("OCProperties"); found {
+			require(model.V15, "optional content")
+		}
+	}
+
+	for _, entry := range ctx.Table {
+		if entry != nil && !entry.Free {
+			requireObject(entry.Object, require)
+		}
+	}
+
+	return required, feature
+}
+
+// requireObject calls require for the features obj uses, looking into its direct
+// dictionaries and arrays. Indirect objects are each visited from the table.
+func requireObject(obj types.Object, require func(v model.Version, name string)) {
+	switch o := obj.(type) {
+	case types.StreamDict:
+		requireObject(o.Dict, require)
+	case types.Array:
+		for _, item := range o {
+			requireObject(item, require)
+		}
+	case types.Dict:
+		if t := o.Type(); t != nil && (*t == "OCG" || *t == "OCMD") {
+			require(model.V15, "optional content")
+		}
+		for _, key := range []string{"CA", "ca"} {
+			if alpha, ok := number(o[key]); ok && alpha < 1 {
+				require(model.V14, "transparency (constant opacity)")
+			}
+		}
+		if mask, found := o["SMask"]; found && mask != types.Name("None") {
+			require(model.V14, "transparency (soft masks)")
+		}
+		if bm, ok := o["BM"].(types.Name); ok && bm != "Normal" && bm != "Compatible" {
+			require(model.V14, "transparency (blend modes)")
+		}
+		if s := o.NameEntry("S"); s != nil && *s == "Transparency" {
+			require(model.V14, "transparency groups")
+		}
+		for _, v := range o {
+			requireObject(v, require)
+		}
+	}
+}
+
+// number returns obj as a float if it is a direct number
+func number(obj types.Object) (float64, bool) {
+	switch n := obj.(type) {
+	case types.Integer:
+		return float64(n), true
+	case types.Float:
+		return n.Value(), true
+	}
+	return 0, false
+}
+
+// Check fails if the document uses a feature that needs a newer version than target
+func Check(ctx *model.Context, target model.Version) error {
+	if required, feature := RequiredVersion(ctx); target < required {
+		return fmt.Errorf("cannot write PDF %s: document uses %s which requires PDF %s", target, feature, required)
+	}
+	return nil
+}
+
+// Apply prepares ctx to be written as the target version.
+// It fails if the document uses a feature that needs a newer version.
+func Apply(ctx *model.Context, target model.Version) error {
+	if err := Check(ctx, target); err != nil {
+		return err
+	}
+
+	ctx.HeaderVersion = &target
+	ctx.RootVersion = nil
+	ctx.RootDict.Delete("Version")
+
+	ConfigureWrite(ctx.Configuration, target)
+	return nil
+}
+
+// WriteConfig returns a default pdfcpu configuration that writes target, for steps that
+// rewrite a file with the pdfcpu API
+func WriteConfig(target model.Version) *model.Configuration {
+	conf := model.NewDefaultConfiguration()
+	ConfigureWrite(conf, target)
+	return conf
+}
+
+// ConfigureWrite disables object and xref streams when targeting a version older than 1.5
+func ConfigureWrite(conf *model.Configuration, target model.Version) {
+	if target < model.V15 {
+		conf.WriteObjectStream = false
+		conf.WriteXRefStream = false
+	}
+}
+
+// StampHeader overwrites the version in the header of a written PDF file.
+// pdfcpu always writes a 1.7 (or 2.0) header, so this is applied after writing.
+func StampHeader(f *os.File, target model.Version) error {
+	if _, err := f.WriteAt([]byte(target.String()), headerVersionOffset); err != nil {
+		return fmt.Errorf("failed to set PDF version: %w", err)
+	}
+	return nil
+}
+
+// StampHeaderFile is like StampHeader but opens the file at path
+func StampHeaderFile(path string, target model.Version) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open output file: %w", err)
+	}
+	defer f.Close()
+
+	return StampHeader(f, target)
+}
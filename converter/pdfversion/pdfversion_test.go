@@ -0,0 +1,41 @@
+package pdfversion
+
+import (
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+func TestRequiredVersion(t *testing.T) {
+	tests := []struct {
+		name   string
+		object types.Object // Added as an indirect object
+		want   model.Version
+	}{
+		{"plain", types.Dict{"Type": types.Name("Font")}, model.V10},
+		{"opaque graphics state", types.Dict{"Type": types.Name("ExtGState"), "CA": types.Float(1), "ca": types.Integer(1)}, model.V10},
+		{"watermark opacity", types.Dict{"Resources": types.Dict{"ExtGState": types.Dict{"GS0": types.Dict{"ca": types.Float(0.25)}}}}, model.V14},
+		{"soft mask", types.Dict{"SMask": types.Dict{"S": types.Name("Luminosity")}}, model.V14},
+		{"no soft mask", types.Dict{"SMask": types.Name("None")}, model.V10},
+		{"blend mode", types.Dict{"BM": types.Name("Multiply")}, model.V14},
+		{"knockout form group", types.Dict{"Subtype": types.Name("Form"), "Group": types.Dict{"S": types.Name("Transparency"), "K": types.Boolean(true)}}, model.V14},
+		{"layer", types.Dict{"Type": types.Name("OCG"), "Name": types.StringLiteral("Original")}, model.V15},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, err := pdfcpu.CreateContextWithXRefTable(nil, types.PaperSize["Letter"])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ctx.IndRefForNewObject(tt.object); err != nil {
+				t.Fatal(err)
+			}
+			if got, feature := RequiredVersion(ctx); got != tt.want {
+				t.Errorf("RequiredVersion = %s (%s), want %s", got, feature, tt.want)
+			}
+		})
+	}
+}
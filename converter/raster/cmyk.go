@@ -0,0 +1,135 @@
+package raster
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/hhrutter/tiff"
+)
+
+// registrationInk is the ink level above which all four channels mark registration
+// black (crop and registration marks), which is kept unchanged
+const registrationInk = 250
+
+// GhostscriptCMYKRenderer renders PDFs to CMYK images with Ghostscript, so pages
+// are inverted in the print color model instead of RGB
+type GhostscriptCMYKRenderer struct {
+	dpi int
+}
+
+// NewGhostscriptCMYKRenderer creates a new GhostscriptCMYKRenderer with the specified DPI
+func NewGhostscriptCMYKRenderer(dpi int) *GhostscriptCMYKRenderer {
+	return &GhostscriptCMYKRenderer{dpi: dpi}
+}
+
+// RenderToImages converts a PDF to a slice of *image.CMYK, one per page
+func (r *GhostscriptCMYKRenderer) RenderToImages(pdfPath string) ([]image.Image, error) {
+	if _, err := exec.LookPath("gs"); err != nil {
+		return nil, fmt.Errorf("CMYK rendering needs Ghostscript (gs): %w", err)
+	}
+
+	tempDir, err := os.MkdirTemp("", "pdfdarkmode-cmyk-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// tiff32nc writes 8 bits per channel CMYK
+	cmd := exec.Command("gs",
+		"-q", "-dNOPAUSE", "-dBATCH", "-dSAFER",
+		"-sDEVICE=tiff32nc",
+		"-r"+strconv.Itoa(r.dpi),
+		"-o", filepath.Join(tempDir, "page-%04d.tif"),
+		pdfPath,
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("gs failed: %w\nOutput: %s", err, string(output))
+	}
+
+	// Zero padded names sort in page order
+	matches, err := filepath.Glob(filepath.Join(tempDir, "page-*.tif"))
+	if err != nil || len(matches) == 0 {
+		return nil, fmt.Errorf("no rendered images found")
+	}
+	sort.Strings(matches)
+
+	var images []image.Image
+	for _, path := range matches {
+		img, err := loadTIFF(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load image %s: %w", path, err)
+		}
+		images = append(images, img)
+	}
+
+	return images, nil
+}
+
+// InvertCMYK applies smart dark mode inversion to a CMYK page and keeps the result in
+// CMYK. Neutral colors are separated to black ink only, as is usual for text and
+// backgrounds on press; registration black is left unchanged.
+func (inv *Inverter) InvertCMYK(img *image.CMYK) *image.CMYK {
+	bounds := img.Bounds()
+	result := image.NewCMYK(bounds)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := img.CMYKAt(x, y)
+			if c.C >= registrationInk && c.M >= registrationInk && c.Y >= registrationInk && c.K >= registrationInk {
+				result.SetCMYK(x, y, c)
+				continue
+			}
+
+			r, g, b := color.CMYKToRGB(c.C, c.M, c.Y, c.K)
+			inverted := color.RGBAModel.Convert(inv.smartInvertPixel(color.RGBA{R: r, G: g, B: b, A: 255})).(color.RGBA)
+			cc, m, yy, k := color.RGBToCMYK(inverted.R, inverted.G, inverted.B)
+			result.SetCMYK(x, y, color.CMYK{C: cc, M: m, Y: yy, K: k})
+		}
+	}
+
+	return result
+}
+
+// toCMYK converts img to CMYK, separating neutral colors to black ink only
+func toCMYK(img image.Image) *image.CMYK {
+	if cmyk, ok := img.(*image.CMYK); ok {
+		return cmyk
+	}
+
+	bounds := img.Bounds()
+	result := image.NewCMYK(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			result.Set(x, y, img.At(x, y))
+		}
+	}
+	return result
+}
+
+// loadTIFF loads a TIFF image from a file
+func loadTIFF(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return tiff.Decode(f)
+}
+
+// saveTIFF saves an image as a Deflate compressed TIFF file, keeping CMYK images in CMYK
+func saveTIFF(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return tiff.Encode(f, img, &tiff.Options{Compression: tiff.Deflate})
+}
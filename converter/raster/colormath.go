@@ -0,0 +1,10 @@
+package raster
+
+// The raster engine's 8-bit adapters of the colormath conversions, exported for the
+// self-test that checks them
+
+// RGBToHSL converts 8-bit RGB to HSL (0-1)
+func RGBToHSL(r, g, b uint8) (h, s, l float64) { return rgbToHSL(r, g, b) }
+
+// HSLToRGB converts HSL (0-1) to 8-bit RGB
+func HSLToRGB(h, s, l float64) (r, g, b uint8) { return hslToRGB(h, s, l) }
@@ -0,0 +1,64 @@
+package raster
+
+import (
+	"image"
+
+	"pdfdarkmode/converter/colormath"
+)
+
+// edgeHaloLightness is the lightness above which an anti-aliased pixel next to the
+// paper counts as paper rather than ink: the lighter half of the edge ramp
+const edgeHaloLightness = 0.5
+
+// SetCleanEdges snaps the light anti-aliased pixels around text and line art to the
+// background, so light text on the dark page has no gray fringe. Glyphs lose their
+// softest edge pixels and look slightly thinner.
+func (inv *Inverter) SetCleanEdges(enabled bool) {
+	inv.snapEdges = enabled
+}
+
+// haloPixels marks the pixels of img that SetCleanEdges snaps to the background,
+// indexed from the top left of its bounds, or returns nil if disabled. These are
+// document colors between edgeHaloLightness and the near-white cutoff with at least
+// one neighbor that becomes the background.
+func (inv *Inverter) haloPixels(img image.Image) []bool {
+	if !inv.snapEdges {
+		return nil
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	paper := make([]bool, w*h)
+	edge := make([]bool, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			r8, g8, b8 := uint8(r>>8), uint8(g>>8), uint8(bl>>8)
+			if inv.getSaturation(r8, g8, b8) >= colormath.DocumentSaturation {
+				continue
+			}
+			l := inv.getLightness(r8, g8, b8)
+			paper[y*w+x] = l > inv.snapWhite
+			edge[y*w+x] = l > edgeHaloLightness && l <= inv.snapWhite
+		}
+	}
+
+	halo := make([]bool, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if !edge[y*w+x] {
+				continue
+			}
+		neighbors:
+			for ny := max(y-1, 0); ny <= min(y+1, h-1); ny++ {
+				for nx := max(x-1, 0); nx <= min(x+1, w-1); nx++ {
+					if paper[ny*w+nx] {
+						halo[y*w+x] = true
+						break neighbors
+					}
+				}
+			}
+		}
+	}
+	return halo
+}
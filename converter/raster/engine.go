@@ -6,33 +6,180 @@ import (
 	"image/png"
 	"os"
 	"path/filepath"
+	"time"
 
 	"pdfdarkmode/converter/colors"
+	"pdfdarkmode/converter/pdfversion"
+	"pdfdarkmode/converter/report"
 
 	"github.com/pdfcpu/pdfcpu/pkg/api"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
 )
 
 // Engine implements the raster-based PDF dark mode conversion
 type Engine struct {
-	dpi      int
-	renderer *Renderer
-	inverter *Inverter
+	dpi           int
+	renderer      Renderer
+	preferred     Renderer // Renderer tried before registered and built-in ones
+	inverter      *Inverter
+	pdfVersion    string // Target output PDF version, empty keeps the pdfcpu default
+	keepStructure bool   // Copy the source structure tree onto the output
+	viewerHints   bool   // Mark the output as dark-themed for viewers
+	autoOrient    bool   // Rotate pages upright based on their content before inversion
+	textRegions   bool   // Invert only inside detected text regions
+	cmyk          bool   // Render, invert and embed pages in CMYK
+	flatten       bool   // Flatten transparency with Ghostscript before rendering
+	warnSize      int64  // Estimated output size that needs confirmation, 0 for none
+	maxSize       int64  // Estimated output size that is refused, 0 for none
+	confirm       func(estimate int64) bool
+	onlyPages     string // parity.Odd or parity.Even to convert only those pages, empty for all
+	rtl           bool   // Place added notices for a right-to-left document
+
+	pageTimeout time.Duration // Per page render limit of the built-in renderer, 0 for none
+	cacheDir    string        // Directory keeping rendered pages between runs, empty for none
 }
 
 // NewEngine creates a new raster conversion engine
 func NewEngine(dpi int, scheme colors.Scheme) *Engine {
 	return &Engine{
 		dpi:      dpi,
-		renderer: NewRenderer(dpi),
+		renderer: newRendererChain(nil, dpi, false, 0),
 		inverter: NewInverter(scheme),
 	}
 }
 
+// SetPDFVersion sets the PDF version written to the output file (e.g. "1.5")
+func (e *Engine) SetPDFVersion(version string) {
+	e.pdfVersion = version
+}
+
+// SetRenderer makes the engine prefer r over registered and built-in renderers.
+// A nil renderer keeps the default chain.
+func (e *Engine) SetRenderer(r Renderer) {
+	e.preferred = r
+	e.renderer = newRendererChain(r, e.dpi, e.cmyk, e.pageTimeout)
+}
+
+// SetCMYK makes the engine render with Ghostscript in CMYK, invert in CMYK and embed
+// DeviceCMYK page images, for print proofing. Images from custom renderers are
+// converted to CMYK.
+func (e *Engine) SetCMYK(enabled bool) {
+	e.cmyk = enabled
+	e.renderer = newRendererChain(e.preferred, e.dpi, enabled, e.pageTimeout)
+}
+
+// SetRemaps sets exact color remaps applied before smart inversion
+func (e *Engine) SetRemaps(remaps []colors.Remap) {
+	e.inverter.SetRemaps(remaps)
+}
+
+// SetColorMetric sets the distance metric and tolerance for matching remap sources
+func (e *Engine) SetColorMetric(metric string, tolerance float64) {
+	e.inverter.SetColorMetric(metric, tolerance)
+}
+
+// SetSnap sets the near-white and near-black lightness cutoffs of the inverter
+func (e *Engine) SetSnap(nearWhite, nearBlack float64) {
+	e.inverter.SetSnap(nearWhite, nearBlack)
+}
+
+// SetColorTolerance blends document and colorful inversion near the boundary between
+// them, see Inverter.SetColorTolerance
+func (e *Engine) SetColorTolerance(tolerance float64) {
+	e.inverter.SetColorTolerance(tolerance)
+}
+
+// SetPreserveWhite keeps enclosed white areas lighter than threshold as they are
+func (e *Engine) SetPreserveWhite(threshold float64) {
+	e.inverter.SetPreserveWhite(threshold)
+}
+
+// SetCleanEdges snaps light anti-aliased edge pixels to the background
+func (e *Engine) SetCleanEdges(enabled bool) {
+	e.inverter.SetCleanEdges(enabled)
+}
+
+// SetGradientBackground paints the background as a top-to-bottom gradient. CMYK
+// pages keep the flat background.
+func (e *Engine) SetGradientBackground(enabled bool) {
+	e.inverter.SetGradientBackground(enabled)
+}
+
+// SetProtectedInks keeps pixels in the hues of inks as they are
+func (e *Engine) SetProtectedInks(inks []colors.Color) {
+	e.inverter.SetProtectedInks(inks)
+}
+
+// SetColorLightness sets the lightness floor and ceiling for colorful pixels
+func (e *Engine) SetColorLightness(min, max float64) {
+	e.inverter.SetColorLightness(min, max)
+}
+
+// SetSaturationBoost sets the factor colorful pixels' saturation is multiplied by
+func (e *Engine) SetSaturationBoost(boost float64) {
+	e.inverter.SetSaturationBoost(boost)
+}
+
+// SetAutoOrient enables best-effort content-based correction of sideways and upside-down pages
+func (e *Engine) SetAutoOrient(enabled bool) {
+	e.autoOrient = enabled
+}
+
+// SetTextRegionsOnly limits inversion to detected text regions, leaving photos and
+// logos on scanned pages in their original colors
+func (e *Engine) SetTextRegionsOnly(enabled bool) {
+	e.textRegions = enabled
+}
+
+// SetViewerHints enables writing a dark theme hint into the output metadata
+func (e *Engine) SetViewerHints(enabled bool) {
+	e.viewerHints = enabled
+}
+
+// SetKeepStructure enables copying the source tagged structure tree onto the output
+func (e *Engine) SetKeepStructure(keep bool) {
+	e.keepStructure = keep
+}
+
+// RenderPages renders every page of inputPath with the engine's renderer chain
+func (e *Engine) RenderPages(inputPath string) ([]image.Image, error) {
+	if e.flatten {
+		flat, cleanup, err := flattenTransparency(inputPath, e.dpi)
+		if err != nil {
+			return nil, err
+		}
+		defer cleanup()
+		fmt.Println("        Flattened transparency with Ghostscript")
+		inputPath = flat
+	}
+
+	images, err := e.renderer.RenderToImages(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, page := range e.timedOutPages(images) {
+		report.Warnf("page %d took longer than %s to render and was skipped", page, e.pageTimeout)
+	}
+	return images, nil
+}
+
+// InvertImage applies the engine's smart dark mode inversion to a rendered page
+func (e *Engine) InvertImage(img image.Image) image.Image {
+	return e.inverter.InvertImage(img)
+}
+
 // Convert performs the raster-based PDF to dark mode conversion
 func (e *Engine) Convert(inputPath, outputPath string) error {
+	if err := e.preflight(inputPath); err != nil {
+		return err
+	}
+	if !e.keepStructure && isTagged(inputPath) {
+		report.Notef("the input is tagged for accessibility, but raster output drops its structure tree; --keep-structure keeps the headings and reading order")
+	}
+
 	fmt.Println("  [1/4] Rendering PDF pages to images...")
-	images, err := e.renderer.RenderToImages(inputPath)
+	images, err := e.renderCached(inputPath)
 	if err != nil {
 		return fmt.Errorf("failed to render PDF: %w", err)
 	}
@@ -40,9 +187,36 @@ func (e *Engine) Convert(inputPath, outputPath string) error {
 
 	fmt.Println("  [2/4] Applying smart dark mode inversion...")
 	invertedImages := make([]image.Image, len(images))
+	unchanged := 0
+	report.StartPages()
 	for i, img := range images {
-		invertedImages[i] = e.inverter.InvertImage(img)
-		fmt.Printf("        Inverted page %d/%d\n", i+1, len(images))
+		if !e.converts(i + 1) {
+			invertedImages[i] = img // Replaced by the original page after import
+			unchanged++
+			continue
+		}
+		if e.autoOrient {
+			if degrees := detectOrientation(img); degrees != 0 {
+				img = rotateImage(img, degrees)
+				fmt.Printf("        Rotated page %d by %d degrees\n", i+1, degrees)
+			}
+		}
+		switch {
+		case e.textRegions:
+			regions := detectTextRegions(img, e.dpi)
+			invertedImages[i] = e.inverter.InvertRegions(img, regions)
+			fmt.Printf("        Inverted %d text region(s) on page %d/%d\n", len(regions), i+1, len(images))
+		case e.cmyk:
+			invertedImages[i] = e.inverter.InvertCMYK(toCMYK(img))
+			fmt.Printf("        Inverted page %d/%d in CMYK\n", i+1, len(images))
+		default:
+			invertedImages[i] = e.InvertImage(img)
+			fmt.Printf("        Inverted page %d/%d\n", i+1, len(images))
+		}
+		report.PageDone(i+1, len(images))
+	}
+	if unchanged > 0 {
+		fmt.Printf("        Left %d pages unchanged (converting %s pages only)\n", unchanged, e.onlyPages)
 	}
 
 	fmt.Println("  [3/4] Saving inverted images...")
@@ -55,7 +229,13 @@ func (e *Engine) Convert(inputPath, outputPath string) error {
 	var imagePaths []string
 	for i, img := range invertedImages {
 		path := filepath.Join(tempDir, fmt.Sprintf("page-%03d.png", i+1))
-		if err := savePNG(path, img); err != nil {
+		save := savePNG
+		if e.cmyk {
+			// PNG has no CMYK; pdfcpu imports CMYK TIFFs as DeviceCMYK images
+			path = filepath.Join(tempDir, fmt.Sprintf("page-%03d.tif", i+1))
+			save = func(path string, img image.Image) error { return saveTIFF(path, toCMYK(img)) }
+		}
+		if err := save(path, img); err != nil {
 			return fmt.Errorf("failed to save image %d: %w", i+1, err)
 		}
 		imagePaths = append(imagePaths, path)
@@ -65,24 +245,120 @@ func (e *Engine) Convert(inputPath, outputPath string) error {
 	if err := e.createPDFFromImages(imagePaths, outputPath); err != nil {
 		return fmt.Errorf("failed to create PDF: %w", err)
 	}
+	if unchanged > 0 {
+		if err := e.restoreOriginals(inputPath, outputPath, len(images)); err != nil {
+			return fmt.Errorf("failed to keep unconverted pages: %w", err)
+		}
+	}
+
+	if skipped := e.timedOutPages(images); len(skipped) > 0 {
+		if err := e.markTimedOut(outputPath, skipped); err != nil {
+			return fmt.Errorf("failed to mark skipped pages: %w", err)
+		}
+		fmt.Printf("        Skipped %d page(s) that timed out rendering: %v\n", len(skipped), skipped)
+	}
+
+	if e.keepStructure {
+		fmt.Println("        Copying logical structure from source...")
+		if err := keepStructure(inputPath, outputPath, e.pdfVersion); err != nil {
+			return fmt.Errorf("failed to keep structure: %w", err)
+		}
+	}
+
+	if err := keepOutline(inputPath, outputPath, e.pdfVersion); err != nil {
+		return fmt.Errorf("failed to keep bookmarks: %w", err)
+	}
+
+	if e.viewerHints {
+		if err := addViewerHints(outputPath, e.pdfVersion, e.inverter.scheme); err != nil {
+			return fmt.Errorf("failed to add viewer hints: %w", err)
+		}
+	}
+
+	if e.pdfVersion != "" {
+		target, err := pdfversion.Parse(e.pdfVersion)
+		if err != nil {
+			return err
+		}
+		if err := pdfversion.StampHeaderFile(outputPath, target); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
+// importBatchSize is the number of page images imported into one PDF at a time; longer
+// documents are imported in batches that are merged afterwards
+const importBatchSize = 100
+
 // createPDFFromImages creates a PDF from a list of image files
 func (e *Engine) createPDFFromImages(imagePaths []string, outputPath string) error {
 	// Use pdfcpu's ImportImages to create PDF from images
 	imp := pdfcpu.DefaultImportConfig()
 	imp.DPI = e.dpi
 
-	// Import images into a new PDF
-	if err := api.ImportImagesFile(imagePaths, outputPath, imp, nil); err != nil {
-		return fmt.Errorf("pdfcpu import failed: %w", err)
+	// ImportImagesFile appends to an existing file, so start from an empty one
+	if err := os.Remove(outputPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if len(imagePaths) <= importBatchSize {
+		return e.importImages(imagePaths, outputPath, imp)
+	}
+
+	// Import large documents in batches next to the images, then merge them in order
+	dir := filepath.Dir(imagePaths[0])
+	var batches []string
+	for start := 0; start < len(imagePaths); start += importBatchSize {
+		end := min(start+importBatchSize, len(imagePaths))
+		path := filepath.Join(dir, fmt.Sprintf("batch-%04d.pdf", len(batches)+1))
+		if err := e.importImages(imagePaths[start:end], path, imp); err != nil {
+			return err
+		}
+		batches = append(batches, path)
+		fmt.Printf("        Imported pages %d-%d of %d\n", start+1, end, len(imagePaths))
+	}
+
+	conf, err := e.writeConfig()
+	if err != nil {
+		return err
+	}
+	if conf == nil {
+		conf = model.NewDefaultConfiguration()
+	}
+	conf.CreateBookmarks = false // No bookmark per batch
+	if err := api.MergeCreateFile(batches, outputPath, false, conf); err != nil {
+		return fmt.Errorf("pdfcpu merge failed: %w", err)
 	}
+	return nil
+}
 
+// importImages imports page images into a new PDF at path
+func (e *Engine) importImages(imagePaths []string, path string, imp *pdfcpu.Import) error {
+	conf, err := e.writeConfig()
+	if err != nil {
+		return err
+	}
+	if err := api.ImportImagesFile(imagePaths, path, imp, conf); err != nil {
+		return fmt.Errorf("pdfcpu import failed: %w", err)
+	}
 	return nil
 }
 
+// writeConfig returns the pdfcpu configuration that writes the requested PDF version,
+// or nil for pdfcpu's default
+func (e *Engine) writeConfig() (*model.Configuration, error) {
+	if e.pdfVersion == "" {
+		return nil, nil
+	}
+	target, err := pdfversion.Parse(e.pdfVersion)
+	if err != nil {
+		return nil, err
+	}
+	return pdfversion.WriteConfig(target), nil
+}
+
 // savePNG saves an image as a PNG file
 func savePNG(path string, img image.Image) error {
 	f, err := os.Create(path)
@@ -0,0 +1,46 @@
+package raster
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+)
+
+// SetFlattenTransparency flattens transparency with a Ghostscript pass before rendering,
+// so pages render the same whichever poppler version is installed
+func (e *Engine) SetFlattenTransparency(enabled bool) {
+	e.flatten = enabled
+}
+
+// flattenTransparency writes a copy of pdfPath with transparency flattened at dpi.
+// Ghostscript's pdfwrite device cannot express transparency in PDF 1.3, so it blends
+// transparent areas into opaque content. Returns the copy's path and a cleanup function.
+func flattenTransparency(pdfPath string, dpi int) (string, func(), error) {
+	if _, err := exec.LookPath("gs"); err != nil {
+		return "", nil, fmt.Errorf("flattening transparency needs Ghostscript (gs): %w", err)
+	}
+
+	tempDir, err := os.MkdirTemp("", "pdfdarkmode-flat-")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(tempDir) }
+
+	flat := filepath.Join(tempDir, "flat.pdf")
+	cmd := exec.Command("gs",
+		"-q", "-dNOPAUSE", "-dBATCH", "-dSAFER",
+		"-sDEVICE=pdfwrite",
+		"-dCompatibilityLevel=1.3",
+		"-r"+strconv.Itoa(dpi),
+		"-o", flat,
+		pdfPath,
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("gs failed: %w\nOutput: %s", err, string(output))
+	}
+
+	return flat, cleanup, nil
+}
@@ -0,0 +1,39 @@
+package raster
+
+import (
+	"image"
+)
+
+// SetGradientBackground paints the background with a top-to-bottom gradient between
+// two colors close to the scheme's background instead of a flat color
+func (inv *Inverter) SetGradientBackground(enabled bool) {
+	inv.gradient = enabled
+}
+
+// fillGradient replaces the pixels of img that became the exact background color with
+// the gradient's color for their row, keeping their alpha. Anti-aliased pixels mixed
+// with the background keep the flat color, which the gradient stays close to.
+func (inv *Inverter) fillGradient(img *image.RGBA) {
+	if !inv.gradient {
+		return
+	}
+
+	bg := inv.scheme.Background
+	top, bottom := inv.scheme.BackgroundGradient()
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		t := 0.0
+		if b.Dy() > 1 {
+			t = float64(y-b.Min.Y) / float64(b.Dy()-1)
+		}
+		row := top.Mix(bottom, t)
+		for x := b.Min.X; x < b.Max.X; x++ {
+			i := img.PixOffset(x, y)
+			p := img.Pix[i : i+4 : i+4]
+			if p[3] == 0 || p[0] != bg.R8 || p[1] != bg.G8 || p[2] != bg.B8 {
+				continue
+			}
+			p[0], p[1], p[2] = row.R8, row.G8, row.B8
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package raster
+
+import (
+	"fmt"
+
+	"pdfdarkmode/converter/colors"
+	"pdfdarkmode/converter/pdfversion"
+	"pdfdarkmode/converter/viewerhints"
+)
+
+// addViewerHints marks the PDF at outputPath as dark-themed.
+// pdfVersion, when set, is re-applied since the output is rewritten.
+func addViewerHints(outputPath, pdfVersion string, scheme colors.Scheme) error {
+	ctx, err := readContext(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to read raster output: %w", err)
+	}
+
+	if _, err := viewerhints.Apply(ctx, scheme); err != nil {
+		return err
+	}
+
+	if pdfVersion != "" {
+		target, err := pdfversion.Parse(pdfVersion)
+		if err != nil {
+			return err
+		}
+		if err := pdfversion.Apply(ctx, target); err != nil {
+			return err
+		}
+	}
+
+	return writeContext(ctx, outputPath)
+}
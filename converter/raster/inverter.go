@@ -5,17 +5,121 @@ import (
 	"image/color"
 	"math"
 
+	"pdfdarkmode/converter/colormath"
 	"pdfdarkmode/converter/colors"
 )
 
 // Inverter handles smart color inversion for dark mode
 type Inverter struct {
-	scheme colors.Scheme
+	scheme    colors.Scheme
+	remaps    []colors.Remap // Exact source colors replaced before smart inversion
+	metric    string         // Distance metric for matching colors to remap sources
+	metricTol float64        // Remap match tolerance under metric, 0 for its default
+	snapWhite float64        // Document colors lighter than this become the background
+	snapBlack float64        // Document colors darker than this become the text color
+	minColorL float64        // Lightness floor for colorful pixels
+	maxColorL float64        // Lightness above which colorful pixels are toned down
+	satBoost  float64        // Factor colorful saturation is multiplied by
+	keepWhite float64        // Enclosed document colors lighter than this are kept, 0 for none
+	tolerance float64        // Half-width of the saturation band blending document and colorful inversion
+	snapEdges bool           // Snap light anti-aliased pixels next to the paper to the background
+	protected []float64      // Hues (0-1) of inks kept as they are
+	gradient  bool           // Paint the background as a top-to-bottom gradient
+	matcher   *colors.Matcher
 }
 
+// Default lightness range for colorful pixels in raster mode
+const (
+	DefaultMinColorLightness = 0.3
+	DefaultMaxColorLightness = 0.7
+)
+
+// DefaultSaturationBoost keeps colorful pixels vibrant on the dark background
+const DefaultSaturationBoost = 1.1
+
+// Default snap cutoffs (lightness 0-1)
+const (
+	DefaultSnapNearWhite = 0.9
+	DefaultSnapNearBlack = 0.15
+)
+
+// remapTolerance is how far each channel may be from a remap source and still match,
+// wide enough to catch anti-aliasing and rendering noise
+const remapTolerance = 8
+
+// remapDeltaE is how far in CIELAB ΔE a pixel may be from a remap source and still
+// match, likewise wider than the direct engine's
+const remapDeltaE = 5.0
+
 // NewInverter creates a new Inverter with the given color scheme
 func NewInverter(scheme colors.Scheme) *Inverter {
-	return &Inverter{scheme: scheme}
+	return &Inverter{
+		scheme:    scheme,
+		metric:    colors.MetricRGB,
+		snapWhite: DefaultSnapNearWhite,
+		snapBlack: DefaultSnapNearBlack,
+		minColorL: DefaultMinColorLightness,
+		maxColorL: DefaultMaxColorLightness,
+		satBoost:  DefaultSaturationBoost,
+	}
+}
+
+// SetColorLightness sets the lightness floor and ceiling for colorful pixels.
+// Zero keeps the default.
+func (inv *Inverter) SetColorLightness(min, max float64) {
+	if min > 0 {
+		inv.minColorL = min
+	}
+	if max > 0 {
+		inv.maxColorL = max
+	}
+}
+
+// SetSaturationBoost sets the factor colorful pixels' saturation is multiplied by, 1 for
+// none. Zero keeps the default.
+func (inv *Inverter) SetSaturationBoost(boost float64) {
+	if boost > 0 {
+		inv.satBoost = boost
+	}
+}
+
+// SetSnap sets the lightness cutoffs above which document colors snap fully to the
+// background and below which they snap to the text color. Lowering the white cutoff
+// removes the gray halos anti-aliasing leaves around scanned text. Zero keeps the default.
+func (inv *Inverter) SetSnap(nearWhite, nearBlack float64) {
+	if nearWhite > 0 {
+		inv.snapWhite = nearWhite
+	}
+	if nearBlack > 0 {
+		inv.snapBlack = nearBlack
+	}
+}
+
+// SetRemaps sets the exact color remaps that take precedence over the scheme
+func (inv *Inverter) SetRemaps(remaps []colors.Remap) {
+	inv.remaps = remaps
+	inv.updateMatcher()
+}
+
+// SetColorMetric sets how pixels are matched to remap sources: colors.MetricRGB (the
+// default) or colors.MetricCIELAB, within tolerance in that metric's units. Zero
+// tolerance keeps the metric's default.
+func (inv *Inverter) SetColorMetric(metric string, tolerance float64) {
+	inv.metric = metric
+	inv.metricTol = tolerance
+	inv.updateMatcher()
+}
+
+// updateMatcher rebuilds the remap matcher after the remaps or metric change
+func (inv *Inverter) updateMatcher() {
+	tolerance := inv.metricTol
+	if tolerance <= 0 {
+		tolerance = remapTolerance
+		if inv.metric == colors.MetricCIELAB {
+			tolerance = remapDeltaE
+		}
+	}
+	inv.matcher = colors.NewMatcher(inv.remaps, inv.metric, tolerance)
 }
 
 // InvertImage applies smart dark mode inversion to an image
@@ -23,18 +127,84 @@ func NewInverter(scheme colors.Scheme) *Inverter {
 func (inv *Inverter) InvertImage(img image.Image) image.Image {
 	bounds := img.Bounds()
 	result := image.NewRGBA(bounds)
+	keep := inv.preservedWhite(img)
+	halo := inv.haloPixels(img)
+	bg := inv.scheme.Background
 
 	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
 		for x := bounds.Min.X; x < bounds.Max.X; x++ {
 			originalColor := img.At(x, y)
+			i := (y-bounds.Min.Y)*bounds.Dx() + (x - bounds.Min.X)
+			if keep != nil && keep[i] {
+				result.Set(x, y, originalColor)
+				continue
+			}
+			if halo != nil && halo[i] {
+				_, _, _, a := originalColor.RGBA()
+				result.Set(x, y, withAlpha(bg, uint8(a>>8)))
+				continue
+			}
 			newColor := inv.smartInvertPixel(originalColor)
 			result.Set(x, y, newColor)
 		}
 	}
 
+	inv.fillGradient(result)
+	return result
+}
+
+// InvertRegions applies smart inversion inside regions only. Pixels outside them,
+// such as photos on a scanned page, keep their colors and are only darkened slightly.
+func (inv *Inverter) InvertRegions(img image.Image, regions []image.Rectangle) image.Image {
+	bounds := img.Bounds()
+	result := image.NewRGBA(bounds)
+
+	halo := inv.haloPixels(img)
+	bg := inv.scheme.Background
+
+	inside := make([]bool, bounds.Dx()*bounds.Dy())
+	for _, r := range regions {
+		r = r.Intersect(bounds)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				inside[(y-bounds.Min.Y)*bounds.Dx()+(x-bounds.Min.X)] = true
+			}
+		}
+	}
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := img.At(x, y)
+			i := (y-bounds.Min.Y)*bounds.Dx() + (x - bounds.Min.X)
+			if inside[i] && halo != nil && halo[i] {
+				_, _, _, a := c.RGBA()
+				result.Set(x, y, withAlpha(bg, uint8(a>>8)))
+			} else if inside[i] {
+				result.Set(x, y, inv.smartInvertPixel(c))
+			} else {
+				result.Set(x, y, darkenPixel(c))
+			}
+		}
+	}
+
+	inv.fillGradient(result)
 	return result
 }
 
+// outsideDarken scales pixels outside text regions so they sit less harshly on a dark page
+const outsideDarken = 0.8
+
+// darkenPixel scales a pixel's color by outsideDarken
+func darkenPixel(c color.Color) color.Color {
+	r, g, b, a := c.RGBA()
+	return color.RGBA{
+		R: uint8(float64(r>>8) * outsideDarken),
+		G: uint8(float64(g>>8) * outsideDarken),
+		B: uint8(float64(b>>8) * outsideDarken),
+		A: uint8(a >> 8),
+	}
+}
+
 // smartInvertPixel applies smart inversion to a single pixel
 func (inv *Inverter) smartInvertPixel(c color.Color) color.Color {
 	r, g, b, a := c.RGBA()
@@ -44,20 +214,43 @@ func (inv *Inverter) smartInvertPixel(c color.Color) color.Color {
 	b8 := uint8(b >> 8)
 	a8 := uint8(a >> 8)
 
+	if to, ok := inv.matcher.Lookup(r8, g8, b8); ok {
+		return color.RGBA{R: to.R8, G: to.G8, B: to.B8, A: a8}
+	}
+
 	// Calculate color properties
 	saturation := inv.getSaturation(r8, g8, b8)
 	lightness := inv.getLightness(r8, g8, b8)
 
-	// Determine if this is a "document color" (grayscale or near-grayscale)
-	isDocumentColor := saturation < 0.15
-
-	if isDocumentColor {
-		// For document colors, apply smart inversion
+	// Document colors (grayscale or near-grayscale) get smart inversion, colorful pixels
+	// (likely images/charts) keep their hue; pixels within the tolerance band blend both
+	w := colormath.DocumentWeight(saturation, inv.tolerance)
+	if w >= 1 {
 		return inv.invertDocumentColor(r8, g8, b8, a8, lightness)
 	}
+	colorful := inv.adjustColorfulPixel(r8, g8, b8, a8, lightness)
+	if w <= 0 {
+		return colorful
+	}
+	document := inv.invertDocumentColor(r8, g8, b8, a8, lightness)
+	return blendPixels(document, colorful, w)
+}
 
-	// For colorful pixels (likely images/charts), adjust brightness but preserve hue
-	return inv.adjustColorfulPixel(r8, g8, b8, a8, lightness)
+// SetColorTolerance blends document and colorful inversion for pixels whose saturation
+// is within tolerance of the boundary between them, so near-identical colors on either
+// side come out near-identical. 0 (the default) keeps a hard boundary.
+func (inv *Inverter) SetColorTolerance(tolerance float64) {
+	inv.tolerance = math.Max(0, tolerance)
+}
+
+// blendPixels mixes w of a with 1-w of b
+func blendPixels(a, b color.Color, w float64) color.RGBA {
+	ca := color.RGBAModel.Convert(a).(color.RGBA)
+	cb := color.RGBAModel.Convert(b).(color.RGBA)
+	mix := func(x, y uint8) uint8 {
+		return uint8(math.Round(float64(x)*w + float64(y)*(1-w)))
+	}
+	return color.RGBA{R: mix(ca.R, cb.R), G: mix(ca.G, cb.G), B: mix(ca.B, cb.B), A: mix(ca.A, cb.A)}
 }
 
 // invertDocumentColor inverts grayscale document colors for dark mode
@@ -65,27 +258,24 @@ func (inv *Inverter) invertDocumentColor(r, g, b, a uint8, lightness float64) co
 	bg := inv.scheme.Background
 	txt := inv.scheme.Text
 
-	if lightness > 0.9 {
+	if inv.scheme.IsLight() {
+		return inv.softenDocumentColor(a, lightness)
+	}
+
+	if lightness > inv.snapWhite {
 		// Very light (white background) -> dark background (full RGB)
 		return color.RGBA{R: bg.R8, G: bg.G8, B: bg.B8, A: a}
 	} else if lightness > 0.7 {
 		// Light gray -> interpolate towards background
-		factor := (lightness - 0.7) / 0.2 // 1 at 0.9, 0 at 0.7
-		newR := txt.R + factor*(bg.R-txt.R)
-		newG := txt.G + factor*(bg.G-txt.G)
-		newB := txt.B + factor*(bg.B-txt.B)
-		return color.RGBA{R: uint8(newR * 255), G: uint8(newG * 255), B: uint8(newB * 255), A: a}
-	} else if lightness < 0.15 {
+		factor := (lightness - 0.7) / (inv.snapWhite - 0.7) // 1 at the white cutoff, 0 at 0.7
+		return withAlpha(txt.Mix(bg, factor), a)
+	} else if lightness < inv.snapBlack {
 		// Very dark (black text) -> light text (full RGB for tinted text)
 		return color.RGBA{R: txt.R8, G: txt.G8, B: txt.B8, A: a}
 	} else if lightness < 0.4 {
 		// Dark gray -> interpolate from text color towards mid-gray
 		factor := lightness / 0.4 // 0 at 0, 1 at 0.4
-		midGray := 0.5
-		newR := txt.R - factor*(txt.R-midGray)
-		newG := txt.G - factor*(txt.G-midGray)
-		newB := txt.B - factor*(txt.B-midGray)
-		return color.RGBA{R: uint8(newR * 255), G: uint8(newG * 255), B: uint8(newB * 255), A: a}
+		return withAlpha(txt.Mix(midGray, factor), a)
 	}
 
 	// Mid-gray: simple inversion
@@ -93,23 +283,62 @@ func (inv *Inverter) invertDocumentColor(r, g, b, a uint8, lightness float64) co
 	return color.RGBA{R: inverted, G: inverted, B: inverted, A: a}
 }
 
+// softenDocumentColor maps a document color for a light scheme without inverting it:
+// near-white becomes the background, near-black the text color, and grays in between
+// keep their order
+func (inv *Inverter) softenDocumentColor(a uint8, lightness float64) color.Color {
+	bg := inv.scheme.Background
+	txt := inv.scheme.Text
+
+	if lightness > inv.snapWhite {
+		return color.RGBA{R: bg.R8, G: bg.G8, B: bg.B8, A: a}
+	} else if lightness < inv.snapBlack {
+		return color.RGBA{R: txt.R8, G: txt.G8, B: txt.B8, A: a}
+	}
+
+	factor := (lightness - inv.snapBlack) / (inv.snapWhite - inv.snapBlack)
+	return withAlpha(txt.Mix(bg, factor), a)
+}
+
+// midGray is where dark grays are pulled towards from the text color
+var midGray = colors.NewColorFromRGB(0.5, 0.5, 0.5)
+
+// withAlpha returns c as an RGBA pixel with alpha a
+func withAlpha(c colors.Color, a uint8) color.RGBA {
+	return color.RGBA{R: c.R8, G: c.G8, B: c.B8, A: a}
+}
+
 // adjustColorfulPixel adjusts colorful pixels for dark mode while preserving hue
 func (inv *Inverter) adjustColorfulPixel(r, g, b, a uint8, lightness float64) color.Color {
+	// Colors already read well on a light page
+	if inv.scheme.IsLight() {
+		return color.RGBA{R: r, G: g, B: b, A: a}
+	}
+
 	// Convert to HSL
 	h, s, l := rgbToHSL(r, g, b)
 
+	// Protected inks keep their color, lightened only as far as needed to show
+	if inv.isProtected(h, s) {
+		if l >= inv.minColorL {
+			return color.RGBA{R: r, G: g, B: b, A: a}
+		}
+		newR, newG, newB := hslToRGB(h, s, inv.minColorL)
+		return color.RGBA{R: newR, G: newG, B: newB, A: a}
+	}
+
 	// Adjust lightness for dark mode viewing
 	// Very light colors get darkened, very dark colors get lightened
-	if l > 0.7 {
+	if l > inv.maxColorL {
 		// Light colorful elements: reduce lightness but keep visible
-		l = 0.5 + (l-0.7)*0.5
-	} else if l < 0.3 {
+		l = inv.maxColorL - 0.2 + (l-inv.maxColorL)*0.5
+	} else if l < inv.minColorL {
 		// Dark colorful elements: increase lightness
-		l = 0.3 + l*0.3
+		l = inv.minColorL + l*0.3
 	}
 
 	// Slightly boost saturation for better visibility on dark background
-	s = math.Min(1.0, s*1.1)
+	s = math.Min(1.0, s*inv.satBoost)
 
 	// Convert back to RGB
 	newR, newG, newB := hslToRGB(h, s, l)
@@ -118,113 +347,26 @@ func (inv *Inverter) adjustColorfulPixel(r, g, b, a uint8, lightness float64) co
 
 // getSaturation calculates the saturation of a color (0-1)
 func (inv *Inverter) getSaturation(r, g, b uint8) float64 {
-	rf := float64(r) / 255
-	gf := float64(g) / 255
-	bf := float64(b) / 255
-
-	max := math.Max(rf, math.Max(gf, bf))
-	min := math.Min(rf, math.Min(gf, bf))
-
-	if max == min {
-		return 0
-	}
-
-	l := (max + min) / 2
-	if l <= 0.5 {
-		return (max - min) / (max + min)
-	}
-	return (max - min) / (2 - max - min)
+	return colormath.Saturation(unit(r), unit(g), unit(b))
 }
 
 // getLightness calculates the lightness of a color (0-1)
 func (inv *Inverter) getLightness(r, g, b uint8) float64 {
-	rf := float64(r) / 255
-	gf := float64(g) / 255
-	bf := float64(b) / 255
-
-	max := math.Max(rf, math.Max(gf, bf))
-	min := math.Min(rf, math.Min(gf, bf))
-
-	return (max + min) / 2
+	return colormath.Lightness(unit(r), unit(g), unit(b))
 }
 
-// rgbToHSL converts RGB to HSL color space
+// rgbToHSL converts 8-bit RGB to HSL
 func rgbToHSL(r, g, b uint8) (h, s, l float64) {
-	rf := float64(r) / 255
-	gf := float64(g) / 255
-	bf := float64(b) / 255
-
-	max := math.Max(rf, math.Max(gf, bf))
-	min := math.Min(rf, math.Min(gf, bf))
-
-	l = (max + min) / 2
-
-	if max == min {
-		h = 0
-		s = 0
-		return
-	}
-
-	d := max - min
-	if l > 0.5 {
-		s = d / (2 - max - min)
-	} else {
-		s = d / (max + min)
-	}
-
-	switch max {
-	case rf:
-		h = (gf - bf) / d
-		if gf < bf {
-			h += 6
-		}
-	case gf:
-		h = (bf-rf)/d + 2
-	case bf:
-		h = (rf-gf)/d + 4
-	}
-
-	h /= 6
-	return
+	return colormath.RGBToHSL(unit(r), unit(g), unit(b))
 }
 
-// hslToRGB converts HSL to RGB color space
+// hslToRGB converts HSL to 8-bit RGB, truncating to the level below
 func hslToRGB(h, s, l float64) (r, g, b uint8) {
-	if s == 0 {
-		gray := uint8(l * 255)
-		return gray, gray, gray
-	}
-
-	var q float64
-	if l < 0.5 {
-		q = l * (1 + s)
-	} else {
-		q = l + s - l*s
-	}
-	p := 2*l - q
-
-	r = uint8(hueToRGB(p, q, h+1.0/3.0) * 255)
-	g = uint8(hueToRGB(p, q, h) * 255)
-	b = uint8(hueToRGB(p, q, h-1.0/3.0) * 255)
-
-	return
+	rf, gf, bf := colormath.HSLToRGB(h, s, l)
+	return uint8(rf * 255), uint8(gf * 255), uint8(bf * 255)
 }
 
-func hueToRGB(p, q, t float64) float64 {
-	if t < 0 {
-		t += 1
-	}
-	if t > 1 {
-		t -= 1
-	}
-	if t < 1.0/6.0 {
-		return p + (q-p)*6*t
-	}
-	if t < 1.0/2.0 {
-		return q
-	}
-	if t < 2.0/3.0 {
-		return p + (q-p)*(2.0/3.0-t)*6
-	}
-	return p
+// unit converts an 8-bit level to 0-1
+func unit(v uint8) float64 {
+	return float64(v) / 255
 }
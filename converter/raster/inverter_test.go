@@ -0,0 +1,46 @@
+package raster
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"pdfdarkmode/converter/colors"
+)
+
+// gradientSteps returns the largest change in any channel between neighboring pixels of
+// a gradient of fixed lightness whose saturation crosses the document color boundary
+func gradientSteps(inv *Inverter) float64 {
+	const steps = 40
+	largest, prev := 0.0, color.RGBA{}
+	for i := 0; i <= steps; i++ {
+		s := 0.05 + 0.2*float64(i)/steps
+		d := 0.8 * s * 255 // HSL saturation d/(1-|2l-1|) at lightness 0.6
+		in := color.RGBA{R: uint8(153 - d), G: 153, B: uint8(153 + d), A: 255}
+		cur := color.RGBAModel.Convert(inv.smartInvertPixel(in)).(color.RGBA)
+		if i > 0 {
+			for _, diff := range []float64{
+				float64(cur.R) - float64(prev.R),
+				float64(cur.G) - float64(prev.G),
+				float64(cur.B) - float64(prev.B),
+			} {
+				largest = max(largest, math.Abs(diff))
+			}
+		}
+		prev = cur
+	}
+	return largest
+}
+
+func TestColorToleranceGradient(t *testing.T) {
+	inv := NewInverter(colors.SchemeDark)
+	hard := gradientSteps(inv)
+	inv.SetColorTolerance(0.05)
+	smooth := gradientSteps(inv)
+	if hard < 25 {
+		t.Errorf("largest step at tolerance 0 is %.0f, want the seam of a hard boundary", hard)
+	}
+	if smooth > hard/3 {
+		t.Errorf("largest step at tolerance 0.05 is %.0f, want well below the %.0f seam", smooth, hard)
+	}
+}
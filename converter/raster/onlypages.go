@@ -0,0 +1,63 @@
+package raster
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"pdfdarkmode/converter/parity"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// SetOnlyPages converts only the odd or even pages (parity.Odd or parity.Even). The
+// others are copied from the input as they are, vectors and text included. Empty
+// converts all pages.
+func (e *Engine) SetOnlyPages(selection string) {
+	e.onlyPages = selection
+}
+
+// converts reports whether page pageNum is selected for conversion
+func (e *Engine) converts(pageNum int) bool {
+	return parity.Includes(e.onlyPages, pageNum)
+}
+
+// restoreOriginals replaces the pages of outputPath not selected for conversion by the
+// input's pages. Both files are merged, so the input's pages follow the output's, and
+// each page is then collected from the half it should come from.
+func (e *Engine) restoreOriginals(inputPath, outputPath string, pageCount int) error {
+	tempDir, err := os.MkdirTemp("", "pdfdarkmode-only-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	conf, err := e.writeConfig()
+	if err != nil {
+		return err
+	}
+	if conf == nil {
+		conf = model.NewDefaultConfiguration()
+	}
+	conf.CreateBookmarks = false // No bookmark per merged file
+
+	merged := filepath.Join(tempDir, "merged.pdf")
+	if err := api.MergeCreateFile([]string{outputPath, inputPath}, merged, false, conf); err != nil {
+		return fmt.Errorf("pdfcpu merge failed: %w", err)
+	}
+
+	order := make([]string, pageCount)
+	for n := 1; n <= pageCount; n++ {
+		page := n
+		if !e.converts(n) {
+			page += pageCount
+		}
+		order[n-1] = strconv.Itoa(page)
+	}
+	if err := api.CollectFile(merged, outputPath, order, conf); err != nil {
+		return fmt.Errorf("pdfcpu collect failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package raster
+
+import (
+	"image"
+	"image/color"
+)
+
+// Orientation detection tuning
+const (
+	inkThreshold      = 0.5   // Luminance below which a pixel counts as ink
+	minInkFraction    = 0.001 // Pages with less ink than this are left alone
+	gapThreshold      = 0.02  // Profile bins below this fraction of the peak count as gaps
+	minGapFraction    = 0.05  // Columns need at least this many gaps to count as line gaps
+	sidewaysRatio     = 1.5   // Columns must have this many times the gaps of rows to count as sideways
+	upsideDownRatio   = 1.2   // Descender zone ink must exceed ascender zone ink by this factor to flip
+	lineCoreThreshold = 0.5   // Fraction of a line's peak row density that marks its x-height core
+)
+
+// detectOrientation estimates how many degrees clockwise img must be rotated to be upright.
+// It is a best-effort heuristic for Latin-script text pages:
+//   - the gaps between text lines leave empty rows, while word gaps rarely line up
+//     into empty columns, so a page with more empty columns is lying sideways;
+//   - ascenders (b, d, h, k, l, capitals) are more common than descenders (g, p, q, y),
+//     so upright lines carry more ink above their x-height core than below it.
+//
+// Returns 0, 90, 180 or 270.
+func detectOrientation(img image.Image) int {
+	ink, w, h := inkMask(img)
+	if w == 0 || h == 0 {
+		return 0
+	}
+
+	total := 0
+	for _, v := range ink {
+		if v {
+			total++
+		}
+	}
+	if float64(total) < minInkFraction*float64(w*h) {
+		return 0
+	}
+
+	rows, cols := profiles(ink, w, h)
+	rotation := 0
+	if colGaps, rowGaps := gapFraction(cols), gapFraction(rows); colGaps > minGapFraction && colGaps > sidewaysRatio*rowGaps {
+		// Lines run vertically; turn them horizontal before the ascender test
+		ink, w, h = rotateMask(ink, w, h)
+		rows, _ = profiles(ink, w, h)
+		rotation = 90
+	}
+
+	above, below := ascenderBalance(rows)
+	if float64(below) > upsideDownRatio*float64(above) {
+		rotation = (rotation + 180) % 360
+	}
+
+	return rotation
+}
+
+// inkMask marks dark pixels of img in row-major order
+func inkMask(img image.Image) ([]bool, int, int) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	ink := make([]bool, w*h)
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			gray := color.GrayModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray)
+			ink[y*w+x] = float64(gray.Y)/255 < inkThreshold
+		}
+	}
+
+	return ink, w, h
+}
+
+// profiles returns the ink count of every row and every column
+func profiles(ink []bool, w, h int) (rows, cols []int) {
+	rows = make([]int, h)
+	cols = make([]int, w)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if ink[y*w+x] {
+				rows[y]++
+				cols[x]++
+			}
+		}
+	}
+	return rows, cols
+}
+
+// gapFraction returns the fraction of near-empty bins between the first and last
+// inked bin of a profile. Across text lines the profile drops to nothing between
+// every line; along them word gaps rarely line up, so it hardly ever does.
+func gapFraction(profile []int) float64 {
+	first, last, peak := -1, -1, 0
+	for i, p := range profile {
+		if p > 0 {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+		if p > peak {
+			peak = p
+		}
+	}
+	if first < 0 || last == first {
+		return 0
+	}
+
+	cutoff := float64(peak) * gapThreshold
+	gaps := 0
+	for _, p := range profile[first : last+1] {
+		if float64(p) <= cutoff {
+			gaps++
+		}
+	}
+	return float64(gaps) / float64(last-first+1)
+}
+
+// ascenderBalance splits each text line of the row profile into its x-height core and
+// returns the ink above the core (ascender zone) and below it (descender zone)
+func ascenderBalance(rows []int) (above, below int) {
+	for start := 0; start < len(rows); {
+		if rows[start] == 0 {
+			start++
+			continue
+		}
+
+		// A text line is a run of rows containing ink
+		end := start
+		peak := 0
+		for end < len(rows) && rows[end] > 0 {
+			if rows[end] > peak {
+				peak = rows[end]
+			}
+			end++
+		}
+
+		// The core is the span of rows that reach a good part of the peak density
+		cutoff := int(float64(peak) * lineCoreThreshold)
+		coreTop, coreBottom := -1, -1
+		for y := start; y < end; y++ {
+			if rows[y] >= cutoff {
+				if coreTop < 0 {
+					coreTop = y
+				}
+				coreBottom = y
+			}
+		}
+
+		for y := start; y < coreTop; y++ {
+			above += rows[y]
+		}
+		for y := coreBottom + 1; y < end; y++ {
+			below += rows[y]
+		}
+
+		start = end
+	}
+	return above, below
+}
+
+// rotateMask rotates an ink mask 90 degrees clockwise
+func rotateMask(ink []bool, w, h int) ([]bool, int, int) {
+	out := make([]bool, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			// (x, y) moves to (h-1-y, x) in a w-tall, h-wide mask
+			out[x*h+(h-1-y)] = ink[y*w+x]
+		}
+	}
+	return out, h, w
+}
+
+// rotateImage rotates img clockwise by 90, 180 or 270 degrees
+func rotateImage(img image.Image, degrees int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	var result *image.RGBA
+	switch degrees {
+	case 90, 270:
+		result = image.NewRGBA(image.Rect(0, 0, h, w))
+	case 180:
+		result = image.NewRGBA(image.Rect(0, 0, w, h))
+	default:
+		return img
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := img.At(b.Min.X+x, b.Min.Y+y)
+			switch degrees {
+			case 90:
+				result.Set(h-1-y, x, c)
+			case 180:
+				result.Set(w-1-x, h-1-y, c)
+			case 270:
+				result.Set(y, w-1-x, c)
+			}
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,88 @@
+package raster
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+// textPage draws an upright synthetic text page: lines of words whose letters have an
+// x-height body, and often an ascender but seldom a descender, as in Latin text
+func textPage() *image.RGBA {
+	const (
+		w, h       = 400, 300
+		lineHeight = 20
+		ascender   = 4 // Rows above the x-height
+		xHeight    = 6
+		descender  = 4 // Rows below the baseline
+	)
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	fill := func(x0, y0, x1, y1 int) {
+		for y := y0; y < y1; y++ {
+			for x := x0; x < x1; x++ {
+				img.Set(x, y, color.Black)
+			}
+		}
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for top := 20; top+lineHeight < h-20; top += lineHeight {
+		base := top + ascender + xHeight
+		for x := 20 + rng.Intn(10); x < w-40; {
+			for n := 3 + rng.Intn(5); n > 0; n-- {
+				fill(x, base-xHeight, x+5, base)
+				switch r := rng.Float64(); {
+				case r < 0.4:
+					fill(x, top, x+1, base-xHeight)
+				case r < 0.5:
+					fill(x+4, base, x+5, base+descender)
+				}
+				x += 7
+			}
+			x += 6 + rng.Intn(6)
+		}
+	}
+	return img
+}
+
+func TestDetectOrientation(t *testing.T) {
+	page := textPage()
+	tests := []struct {
+		rotated int // Degrees the page is turned clockwise
+		want    int
+	}{
+		{0, 0},
+		{180, 180},
+		{90, 270},
+		{270, 90},
+	}
+
+	for _, tt := range tests {
+		rotated := rotateImage(page, tt.rotated)
+		got := detectOrientation(rotated)
+		if got != tt.want {
+			t.Errorf("page rotated by %d: detected %d, want %d", tt.rotated, got, tt.want)
+			continue
+		}
+		// Turning the page by the detected rotation makes it upright again
+		upright := rotateImage(rotated, got).(*image.RGBA)
+		if !bytes.Equal(upright.Pix, page.Pix) {
+			t.Errorf("page rotated by %d is not upright after turning it by %d", tt.rotated, got)
+		}
+	}
+}
+
+func TestDetectOrientationBlankPage(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 100, 100))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	if got := detectOrientation(img); got != 0 {
+		t.Errorf("blank page: detected %d, want 0", got)
+	}
+}
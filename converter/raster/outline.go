@@ -0,0 +1,133 @@
+package raster
+
+import (
+	"fmt"
+
+	"pdfdarkmode/converter/pdfversion"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// copyOutline copies the document outline (bookmarks) of the source PDF onto the raster
+// output, together with the named destinations bookmarks may point to. The output has
+// one page per source page, so destinations are remapped to the page with the same
+// number. Returns the number of top-level bookmarks copied.
+func copyOutline(srcCtx, destCtx *model.Context) (int, error) {
+	if srcCtx.RootDict == nil {
+		return 0, nil
+	}
+	outlines, found := srcCtx.RootDict.Find("Outlines")
+	if !found || outlines == nil {
+		return 0, nil
+	}
+
+	migrated := pageMap(srcCtx, destCtx)
+	copyEntry := func(from, to types.Dict, key string) error {
+		obj, found := from.Find(key)
+		if !found || obj == nil {
+			return nil
+		}
+		newObj, err := migrateObject(obj.Clone(), srcCtx, destCtx, migrated)
+		if err != nil {
+			return fmt.Errorf("failed to copy %s: %w", key, err)
+		}
+		to[key] = newObj
+		return nil
+	}
+
+	if err := copyEntry(srcCtx.RootDict, destCtx.RootDict, "Outlines"); err != nil {
+		return 0, err
+	}
+	// Named destinations, the PDF 1.1 /Dests dictionary and the /Dests name tree
+	if err := copyEntry(srcCtx.RootDict, destCtx.RootDict, "Dests"); err != nil {
+		return 0, err
+	}
+	names, err := srcCtx.DereferenceDict(srcCtx.RootDict["Names"])
+	if err != nil {
+		return 0, err
+	}
+	if names != nil {
+		destNames, err := destCtx.DereferenceDict(destCtx.RootDict["Names"])
+		if err != nil {
+			return 0, err
+		}
+		if destNames == nil {
+			destNames = types.Dict{}
+		}
+		if err := copyEntry(names, destNames, "Dests"); err != nil {
+			return 0, err
+		}
+		if len(destNames) > 0 {
+			destCtx.RootDict["Names"] = destNames
+		}
+	}
+	// Open with the bookmarks panel if the source did
+	if mode, ok := srcCtx.RootDict["PageMode"].(types.Name); ok && mode == "UseOutlines" {
+		destCtx.RootDict["PageMode"] = mode
+	}
+
+	return countBookmarks(srcCtx, outlines), nil
+}
+
+// countBookmarks returns the number of top-level items of an outline dictionary
+func countBookmarks(ctx *model.Context, outlines types.Object) int {
+	root, err := ctx.DereferenceDict(outlines)
+	if err != nil || root == nil {
+		return 0
+	}
+	count := 0
+	seen := map[int]bool{}
+	for obj := root["First"]; obj != nil; {
+		ref, ok := obj.(types.IndirectRef)
+		if !ok || seen[ref.ObjectNumber.Value()] {
+			break
+		}
+		seen[ref.ObjectNumber.Value()] = true
+		item, err := ctx.DereferenceDict(ref)
+		if err != nil || item == nil {
+			break
+		}
+		count++
+		obj = item["Next"]
+	}
+	return count
+}
+
+// keepOutline copies the bookmarks of inputPath onto the PDF at outputPath.
+// pdfVersion, when set, is re-applied since the output is rewritten.
+func keepOutline(inputPath, outputPath, pdfVersion string) error {
+	srcCtx, err := readContext(inputPath)
+	if err != nil {
+		return fmt.Errorf("failed to read source outline: %w", err)
+	}
+	if _, found := srcCtx.RootDict.Find("Outlines"); !found {
+		return nil
+	}
+
+	destCtx, err := readContext(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to read raster output: %w", err)
+	}
+
+	count, err := copyOutline(srcCtx, destCtx)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
+	}
+	fmt.Printf("        Copied %d top-level bookmark(s) from source\n", count)
+
+	if pdfVersion != "" {
+		target, err := pdfversion.Parse(pdfVersion)
+		if err != nil {
+			return err
+		}
+		if err := pdfversion.Apply(destCtx, target); err != nil {
+			return err
+		}
+	}
+
+	return writeContext(destCtx, outputPath)
+}
@@ -0,0 +1,82 @@
+package raster
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"pdfdarkmode/converter/report"
+)
+
+// MinPopplerVersion is the oldest poppler the built-in renderer is expected to render
+// correctly with: 0.86 is the oldest release still shipped by long-term Linux
+// distributions (Ubuntu 20.04), and older ones lack years of rendering fixes for
+// transparency, soft masks and fonts
+const MinPopplerVersion = "0.86.0"
+
+// popplerVersionPattern finds the version in "pdftoppm -v" output, e.g. "pdftoppm
+// version 22.02.0"
+var popplerVersionPattern = regexp.MustCompile(`version (\d+)\.(\d+)(?:\.(\d+))?`)
+
+// detectedPoppler caches the installed poppler version, read once per process
+var detectedPoppler struct {
+	once    sync.Once
+	version string // Empty if pdftoppm is missing or printed no version
+}
+
+// PopplerVersion returns the version of the installed pdftoppm, or "" if it is not
+// installed or its version cannot be read. pdftoppm is run only the first time.
+func PopplerVersion() string {
+	detectedPoppler.once.Do(func() {
+		if _, err := exec.LookPath("pdftoppm"); err != nil {
+			return
+		}
+		// Old releases exit nonzero after printing the version
+		output, _ := exec.Command("pdftoppm", "-v").CombinedOutput()
+		if m := popplerVersionPattern.FindStringSubmatch(string(output)); m != nil {
+			detectedPoppler.version = m[0][len("version "):]
+		}
+	})
+	return detectedPoppler.version
+}
+
+// CheckPopplerVersion prints the installed poppler version and warns when it is older
+// than MinPopplerVersion. Nothing is checked when poppler is not installed; rendering
+// reports that.
+func CheckPopplerVersion() {
+	version := PopplerVersion()
+	if version == "" {
+		return
+	}
+	fmt.Printf("  Renderer: poppler %s\n", version)
+	if compareVersions(version, MinPopplerVersion) < 0 {
+		report.Warnf("poppler %s is older than %s and may render some pages incorrectly; upgrade poppler-utils", version, MinPopplerVersion)
+	}
+}
+
+// compareVersions compares dotted versions such as "0.86.1" numerically, returning
+// -1, 0 or 1. Missing components count as 0.
+func compareVersions(a, b string) int {
+	pa, pb := versionParts(a), versionParts(b)
+	for i := range pa {
+		switch {
+		case pa[i] < pb[i]:
+			return -1
+		case pa[i] > pb[i]:
+			return 1
+		}
+	}
+	return 0
+}
+
+// versionParts returns the major, minor and patch numbers of a dotted version
+func versionParts(version string) [3]int {
+	var parts [3]int
+	for i, field := range strings.SplitN(version, ".", 3) {
+		parts[i], _ = strconv.Atoi(field)
+	}
+	return parts
+}
@@ -0,0 +1,110 @@
+package raster
+
+import (
+	"fmt"
+	"math"
+	"os"
+
+	"pdfdarkmode/converter/report"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// Compressed bytes per rendered pixel assumed by the output size estimate. Inverted
+// text pages compress well below this, photos and scans come closer to it.
+const (
+	estimatedBytesPerPixel     = 1.0
+	estimatedBytesPerPixelCMYK = 1.3
+)
+
+// DefaultOutputSizeWarning is the estimated output size above which conversion asks
+// before continuing
+const DefaultOutputSizeWarning = 500 << 20
+
+// EstimateOutputSize estimates the raster output size in bytes of inputPath at dpi:
+// the rendered pixel count of every page times a typical compressed size per pixel
+func EstimateOutputSize(inputPath string, dpi int, cmyk bool) (int64, error) {
+	f, err := os.Open(inputPath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationRelaxed
+	dims, err := api.PageDims(f, conf)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read page sizes: %w", err)
+	}
+
+	perPixel := estimatedBytesPerPixel
+	if cmyk {
+		perPixel = estimatedBytesPerPixelCMYK
+	}
+
+	scale := float64(dpi) / 72
+	total := 0.0
+	for _, d := range dims {
+		total += d.Width * scale * d.Height * scale * perPixel
+	}
+	return int64(total), nil
+}
+
+// SetOutputSizeLimits sets the estimated output size above which the engine asks
+// confirm before rendering, and above which it refuses outright. Zero disables a limit.
+// A nil confirm proceeds after printing the warning, for non-interactive use.
+func (e *Engine) SetOutputSizeLimits(warn, max int64, confirm func(estimate int64) bool) {
+	e.warnSize = warn
+	e.maxSize = max
+	e.confirm = confirm
+}
+
+// preflight checks the estimated output size against the configured limits
+func (e *Engine) preflight(inputPath string) error {
+	if e.warnSize <= 0 && e.maxSize <= 0 {
+		return nil
+	}
+
+	estimate, err := EstimateOutputSize(inputPath, e.dpi, e.cmyk)
+	if err != nil {
+		report.Notef("could not estimate output size: %v", err)
+		return nil
+	}
+
+	if e.maxSize > 0 && estimate > e.maxSize {
+		return fmt.Errorf("estimated output size %s exceeds the limit of %s; try --dpi %d or lower",
+			FormatSize(estimate), FormatSize(e.maxSize), fittingDPI(e.dpi, estimate, e.maxSize))
+	}
+
+	if e.warnSize > 0 && estimate > e.warnSize {
+		report.Notef("estimated output size is %s at %d DPI; --dpi %d would stay under %s",
+			FormatSize(estimate), e.dpi, fittingDPI(e.dpi, estimate, e.warnSize), FormatSize(e.warnSize))
+		if e.confirm != nil && !e.confirm(estimate) {
+			return fmt.Errorf("conversion cancelled")
+		}
+	}
+
+	return nil
+}
+
+// fittingDPI returns the DPI at which the estimate scales down to limit.
+// Size grows with the square of the DPI.
+func fittingDPI(dpi int, estimate, limit int64) int {
+	fit := int(float64(dpi) * math.Sqrt(float64(limit)/float64(estimate)))
+	return max(fit, 1)
+}
+
+// FormatSize formats a byte count for display, e.g. "1.5 GB"
+func FormatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,90 @@
+package raster
+
+import (
+	"image"
+
+	"pdfdarkmode/converter/colormath"
+)
+
+// minPreservedWhiteShare is the smallest enclosed white area kept, as a share of the
+// page. Smaller areas are the insides of letters and table cells of small print.
+const minPreservedWhiteShare = 0.0005
+
+// SetPreserveWhite keeps document colors lighter than threshold (0-1) as they are in
+// white areas the page content encloses, such as boxes on printed forms. The paper
+// around them is converted as usual. Zero converts all of them.
+func (inv *Inverter) SetPreserveWhite(threshold float64) {
+	inv.keepWhite = threshold
+}
+
+// preservedWhite marks the pixels of img to keep as they are under SetPreserveWhite,
+// indexed from the top left of its bounds, or returns nil if none. A rendered page has
+// no separate paper, so near-white areas reachable from the page edge are taken to be
+// the paper and converted; near-white areas the content fully encloses are kept when
+// large enough to be a box rather than the inside of a letter.
+func (inv *Inverter) preservedWhite(img image.Image) []bool {
+	if inv.keepWhite <= 0 {
+		return nil
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	white := make([]bool, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			r8, g8, b8 := uint8(r>>8), uint8(g>>8), uint8(bl>>8)
+			white[y*w+x] = inv.getSaturation(r8, g8, b8) < colormath.DocumentSaturation && inv.getLightness(r8, g8, b8) > inv.keepWhite
+		}
+	}
+
+	// Label the 4-connected white areas and note which touch the page edge
+	labels := make([]int, w*h)
+	var sizes []int
+	var atEdge []bool
+	var stack []int
+	for start := range white {
+		if !white[start] || labels[start] != 0 {
+			continue
+		}
+		sizes = append(sizes, 0)
+		atEdge = append(atEdge, false)
+		label := len(sizes)
+
+		labels[start] = label
+		stack = append(stack[:0], start)
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			sizes[label-1]++
+
+			x, y := i%w, i/w
+			if x == 0 || y == 0 || x == w-1 || y == h-1 {
+				atEdge[label-1] = true
+			}
+			for _, n := range [4]int{i - 1, i + 1, i - w, i + w} {
+				if n < 0 || n >= len(white) || (n == i-1 && x == 0) || (n == i+1 && x == w-1) {
+					continue
+				}
+				if white[n] && labels[n] == 0 {
+					labels[n] = label
+					stack = append(stack, n)
+				}
+			}
+		}
+	}
+
+	minSize := int(float64(w*h) * minPreservedWhiteShare)
+	keep := make([]bool, w*h)
+	found := false
+	for i, label := range labels {
+		if label != 0 && !atEdge[label-1] && sizes[label-1] >= minSize {
+			keep[i] = true
+			found = true
+		}
+	}
+	if !found {
+		return nil
+	}
+	return keep
+}
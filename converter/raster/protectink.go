@@ -0,0 +1,44 @@
+package raster
+
+import (
+	"math"
+
+	"pdfdarkmode/converter/colors"
+)
+
+// Protected ink ranges around each ink given to SetProtectedInks. Scanned ink varies in
+// shade and fades towards the paper, so the range covers any lightness and a band of
+// hues, but not the washed-out colors near gray.
+const (
+	protectHueWidth      = 25.0 / 360 // Hue distance from the ink, as a fraction of the color wheel
+	protectMinSaturation = 0.25       // Below this, pixels are mostly paper or toner gray
+)
+
+// SetProtectedInks keeps pixels close in hue to any of inks, such as blue ink
+// signatures on scans, as they are instead of brightening and saturating them. Pixels
+// too dark to see on the background are lightened just to the color lightness floor.
+func (inv *Inverter) SetProtectedInks(inks []colors.Color) {
+	inv.protected = nil
+	for _, ink := range inks {
+		h, s, _ := rgbToHSL(ink.R8, ink.G8, ink.B8)
+		if s == 0 {
+			continue // Grays have no hue to protect
+		}
+		inv.protected = append(inv.protected, h)
+	}
+}
+
+// isProtected reports whether a colorful pixel with hue h and saturation s is a
+// protected ink
+func (inv *Inverter) isProtected(h, s float64) bool {
+	if s < protectMinSaturation {
+		return false
+	}
+	for _, hue := range inv.protected {
+		d := math.Abs(h - hue)
+		if math.Min(d, 1-d) <= protectHueWidth {
+			return true
+		}
+	}
+	return false
+}
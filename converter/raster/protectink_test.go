@@ -0,0 +1,41 @@
+package raster
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"pdfdarkmode/converter/colors"
+)
+
+func TestProtectedInkKeepsHue(t *testing.T) {
+	paper := color.RGBA{R: 245, G: 245, B: 245, A: 255}
+	ink := color.RGBA{R: 30, G: 60, B: 200, A: 255}
+	img := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 20; x++ {
+			img.Set(x, y, paper)
+			if x >= 6 && x < 14 && y >= 6 && y < 14 {
+				img.Set(x, y, ink)
+			}
+		}
+	}
+
+	inv := NewInverter(colors.SchemeDark)
+	inv.SetProtectedInks([]colors.Color{colors.NewColorFromRGB8(0, 0, 255)})
+	out := inv.InvertImage(img)
+
+	inkH, inkS, _ := rgbToHSL(ink.R, ink.G, ink.B)
+	got := color.RGBAModel.Convert(out.At(10, 10)).(color.RGBA)
+	h, s, _ := rgbToHSL(got.R, got.G, got.B)
+	if d := math.Abs(h - inkH); math.Min(d, 1-d) > 0.01 || math.Abs(s-inkS) > 0.02 {
+		t.Errorf("protected ink became %v (hue %.3f, saturation %.2f), want hue %.3f, saturation %.2f",
+			got, h, s, inkH, inkS)
+	}
+
+	bg := color.RGBAModel.Convert(out.At(1, 1)).(color.RGBA)
+	if _, _, l := rgbToHSL(bg.R, bg.G, bg.B); l > 0.3 {
+		t.Errorf("paper became %v with lightness %.2f, want it darkened", bg, l)
+	}
+}
@@ -0,0 +1,111 @@
+package raster
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"pdfdarkmode/converter/report"
+)
+
+// SetRenderCache keeps the rendered pages of each input in dir, so converting the same
+// input again, e.g. with another scheme, skips rendering and only inverts. Entries are
+// keyed by the input's content and the render settings, so a changed input is rendered
+// anew. Empty disables the cache, which is never used in CMYK mode.
+func (e *Engine) SetRenderCache(dir string) {
+	e.cacheDir = dir
+}
+
+// renderCached returns the pages of inputPath from the render cache, or renders them
+// with RenderPages and stores them in the cache
+func (e *Engine) renderCached(inputPath string) ([]image.Image, error) {
+	if e.cacheDir == "" || e.cmyk {
+		return e.RenderPages(inputPath)
+	}
+	key, err := e.cacheKey(inputPath)
+	if err != nil {
+		return e.RenderPages(inputPath)
+	}
+
+	dir := filepath.Join(e.cacheDir, key)
+	if images, err := loadCachedPages(dir); err == nil {
+		fmt.Printf("        Reused %d page(s) from the render cache\n", len(images))
+		return images, nil
+	}
+
+	images, err := e.RenderPages(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(e.timedOutPages(images)) == 0 {
+		if err := storeCachedPages(e.cacheDir, key, images); err != nil {
+			report.Warnf("failed to write the render cache: %v", err)
+		}
+	}
+	return images, nil
+}
+
+// cacheKey hashes the content of inputPath with every setting that changes how it
+// renders
+func (e *Engine) cacheKey(inputPath string) (string, error) {
+	f, err := os.Open(inputPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	fmt.Fprintf(h, "\x00dpi=%d flatten=%t renderer=%T poppler=%s", e.dpi, e.flatten, e.preferred, PopplerVersion())
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// loadCachedPages loads the pages stored in a cache entry
+func loadCachedPages(dir string) ([]image.Image, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "page-*.png"))
+	if err != nil || len(matches) == 0 {
+		return nil, fmt.Errorf("no cached pages in %s", dir)
+	}
+	sort.Strings(matches)
+
+	images := make([]image.Image, 0, len(matches))
+	for _, path := range matches {
+		img, err := loadPNG(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load cached page %s: %w", path, err)
+		}
+		images = append(images, img)
+	}
+	return images, nil
+}
+
+// storeCachedPages writes images as the cache entry key in cacheDir. The pages are
+// written to a temp directory that is renamed into place, so an interrupted or
+// concurrent run never leaves a partial entry.
+func storeCachedPages(cacheDir, key string, images []image.Image) error {
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return err
+	}
+	tempDir, err := os.MkdirTemp(cacheDir, key+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	for i, img := range images {
+		if err := savePNG(filepath.Join(tempDir, fmt.Sprintf("page-%05d.png", i+1)), img); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(tempDir, filepath.Join(cacheDir, key)); err != nil && !os.IsExist(err) {
+		return err
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package raster
 
 import (
+	"errors"
 	"fmt"
 	"image"
 	"image/png"
@@ -10,21 +11,89 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
-// Renderer handles PDF to image conversion
-type Renderer struct {
-	dpi int
+// Renderer converts a PDF to a slice of images, one per page.
+// Implement it to plug in another rasterizer, e.g. a MuPDF binding.
+type Renderer interface {
+	RenderToImages(pdfPath string) ([]image.Image, error)
 }
 
-// NewRenderer creates a new Renderer with the specified DPI
-func NewRenderer(dpi int) *Renderer {
-	return &Renderer{dpi: dpi}
+// registeredRenderers are tried, in registration order, before the built-in renderer
+var (
+	registeredMu        sync.Mutex
+	registeredRenderers []Renderer
+)
+
+// RegisterRenderer adds a renderer that raster engines prefer over the built-in
+// poppler renderer. If it fails, the next registered renderer is tried.
+func RegisterRenderer(r Renderer) {
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+	registeredRenderers = append(registeredRenderers, r)
+}
+
+// ResetRenderers removes the renderers added with RegisterRenderer. Engines created
+// before keep the renderers that were registered when they were created.
+func ResetRenderers() {
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+	registeredRenderers = nil
+}
+
+// chainRenderer tries each renderer in turn until one succeeds
+type chainRenderer []Renderer
+
+// RenderToImages returns the images of the first renderer that succeeds
+func (c chainRenderer) RenderToImages(pdfPath string) ([]image.Image, error) {
+	var errs []error
+	for _, r := range c {
+		images, err := r.RenderToImages(pdfPath)
+		if err == nil {
+			return images, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// newRendererChain returns the preferred renderer (if any), then registered
+// renderers, then the built-in renderer at dpi: poppler, limited to pageTimeout per
+// page when non-zero, or Ghostscript for CMYK
+func newRendererChain(preferred Renderer, dpi int, cmyk bool, pageTimeout time.Duration) Renderer {
+	var chain chainRenderer
+	if preferred != nil {
+		chain = append(chain, preferred)
+	}
+
+	registeredMu.Lock()
+	chain = append(chain, registeredRenderers...)
+	registeredMu.Unlock()
+
+	if cmyk {
+		return append(chain, NewGhostscriptCMYKRenderer(dpi))
+	}
+	poppler := NewPopplerRenderer(dpi)
+	poppler.SetPageTimeout(pageTimeout)
+	return append(chain, poppler)
+}
+
+// PopplerRenderer renders PDFs with the poppler command line tools
+type PopplerRenderer struct {
+	dpi         int
+	pageTimeout time.Duration // Per page render limit, 0 renders all pages in one run
+}
+
+// NewPopplerRenderer creates a new PopplerRenderer with the specified DPI
+func NewPopplerRenderer(dpi int) *PopplerRenderer {
+	return &PopplerRenderer{dpi: dpi}
 }
 
 // RenderToImages converts a PDF to a slice of images, one per page
 // It first tries pdftoppm (poppler-utils), then falls back to a basic approach
-func (r *Renderer) RenderToImages(pdfPath string) ([]image.Image, error) {
+func (r *PopplerRenderer) RenderToImages(pdfPath string) ([]image.Image, error) {
 	// Create temp directory for rendered images
 	tempDir, err := os.MkdirTemp("", "pdfdarkmode-")
 	if err != nil {
@@ -48,11 +117,14 @@ func (r *Renderer) RenderToImages(pdfPath string) ([]image.Image, error) {
 }
 
 // renderWithPdftoppm uses poppler's pdftoppm for high-quality rendering
-func (r *Renderer) renderWithPdftoppm(pdfPath, tempDir string) ([]image.Image, error) {
+func (r *PopplerRenderer) renderWithPdftoppm(pdfPath, tempDir string) ([]image.Image, error) {
 	// Check if pdftoppm is available
 	if _, err := exec.LookPath("pdftoppm"); err != nil {
 		return nil, fmt.Errorf("pdftoppm not found: %w", err)
 	}
+	if r.pageTimeout > 0 {
+		return r.renderPageByPage("pdftoppm", pdfPath, tempDir)
+	}
 
 	outputPrefix := filepath.Join(tempDir, "page")
 
@@ -72,11 +144,14 @@ func (r *Renderer) renderWithPdftoppm(pdfPath, tempDir string) ([]image.Image, e
 }
 
 // renderWithPdftocairo uses poppler's pdftocairo as fallback
-func (r *Renderer) renderWithPdftocairo(pdfPath, tempDir string) ([]image.Image, error) {
+func (r *PopplerRenderer) renderWithPdftocairo(pdfPath, tempDir string) ([]image.Image, error) {
 	// Check if pdftocairo is available
 	if _, err := exec.LookPath("pdftocairo"); err != nil {
 		return nil, fmt.Errorf("pdftocairo not found: %w", err)
 	}
+	if r.pageTimeout > 0 {
+		return r.renderPageByPage("pdftocairo", pdfPath, tempDir)
+	}
 
 	outputPrefix := filepath.Join(tempDir, "page")
 
@@ -96,7 +171,7 @@ func (r *Renderer) renderWithPdftocairo(pdfPath, tempDir string) ([]image.Image,
 }
 
 // loadImagesFromDir loads all PNG images matching the pattern from a directory
-func (r *Renderer) loadImagesFromDir(dir, pattern string) ([]image.Image, error) {
+func (r *PopplerRenderer) loadImagesFromDir(dir, pattern string) ([]image.Image, error) {
 	matches, err := filepath.Glob(filepath.Join(dir, pattern))
 	if err != nil {
 		return nil, fmt.Errorf("failed to glob images: %w", err)
@@ -0,0 +1,72 @@
+package raster
+
+import (
+	"errors"
+	"image"
+	"testing"
+
+	"pdfdarkmode/converter/colors"
+)
+
+// fakeRenderer returns fixed images or a fixed error, counting its calls
+type fakeRenderer struct {
+	images []image.Image
+	err    error
+	calls  int
+}
+
+func (r *fakeRenderer) RenderToImages(pdfPath string) ([]image.Image, error) {
+	r.calls++
+	return r.images, r.err
+}
+
+// newFakeRenderer returns a fakeRenderer of one w by w page
+func newFakeRenderer(w int) *fakeRenderer {
+	return &fakeRenderer{images: []image.Image{image.NewRGBA(image.Rect(0, 0, w, w))}}
+}
+
+func TestRegisteredRenderers(t *testing.T) {
+	t.Cleanup(ResetRenderers)
+	failing := &fakeRenderer{err: errors.New("no such binding")}
+	working := newFakeRenderer(10)
+	RegisterRenderer(failing)
+	RegisterRenderer(working)
+
+	e := NewEngine(72, colors.SchemeDark)
+	images, err := e.RenderPages("in.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != 1 || images[0] != working.images[0] {
+		t.Errorf("rendered %d images, want the working renderer's page", len(images))
+	}
+	if failing.calls != 1 || working.calls != 1 {
+		t.Errorf("renderers called %d and %d times, want once each in order", failing.calls, working.calls)
+	}
+
+	// A renderer set on the engine comes first
+	custom := newFakeRenderer(20)
+	e.SetRenderer(custom)
+	images, err = e.RenderPages("in.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != 1 || images[0] != custom.images[0] || failing.calls != 1 {
+		t.Errorf("custom renderer called %d times, first registered %d times, want 1 and 1", custom.calls, failing.calls)
+	}
+
+	// Engines created after a reset no longer see the registered renderers
+	ResetRenderers()
+	if chain := NewEngine(72, colors.SchemeDark).renderer.(chainRenderer); len(chain) != 1 {
+		t.Errorf("chain after reset has %d renderers, want the built-in one", len(chain))
+	}
+}
+
+func TestRendererChainErrors(t *testing.T) {
+	first := &fakeRenderer{err: errors.New("first failed")}
+	second := &fakeRenderer{err: errors.New("second failed")}
+	_, err := chainRenderer{first, second}.RenderToImages("in.pdf")
+	if err == nil || !errors.Is(err, first.err) || !errors.Is(err, second.err) {
+		t.Errorf("got %v, want both renderers' errors", err)
+	}
+}
@@ -0,0 +1,126 @@
+package raster
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"pdfdarkmode/converter/colors"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// Reference page analysis
+const (
+	referenceDPI       = 72  // Enough pixels to count colors; fewer anti-aliased edges than higher DPIs
+	minTextContrast    = 3.0 // Colors closer to the background than this are shading, not text
+	colorBucketBits    = 5   // Bits per channel kept when grouping similar colors
+	colorBucketChannel = 1 << colorBucketBits
+)
+
+// SchemeFromPDF derives a scheme from the first page of a reference PDF, e.g. a
+// template already designed in dark colors. The page is rendered with renderer
+// (or the built-in renderers if nil), its most common color becomes the background,
+// and the most common color that contrasts with it the text.
+func SchemeFromPDF(pdfPath string, renderer Renderer) (colors.Scheme, error) {
+	page, err := RenderFirstPage(pdfPath, renderer, referenceDPI)
+	if err != nil {
+		return colors.Scheme{}, fmt.Errorf("reference PDF: %w", err)
+	}
+
+	bg, text, err := dominantColors(page)
+	if err != nil {
+		return colors.Scheme{}, fmt.Errorf("reference PDF %s: %w", pdfPath, err)
+	}
+	return colors.Scheme{Name: "from-pdf", Background: bg, Text: text}, nil
+}
+
+// RenderFirstPage renders only the first page of pdfPath at dpi with renderer, or the
+// built-in renderers if nil
+func RenderFirstPage(pdfPath string, renderer Renderer, dpi int) (image.Image, error) {
+	return RenderPage(pdfPath, 1, renderer, dpi)
+}
+
+// RenderPage renders only page pageNum of pdfPath at dpi with renderer, or the
+// built-in renderers if nil
+func RenderPage(pdfPath string, pageNum int, renderer Renderer, dpi int) (image.Image, error) {
+	tempDir, err := os.MkdirTemp("", "pdfdarkmode-page-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	page := filepath.Join(tempDir, "page.pdf")
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationRelaxed
+	if err := api.TrimFile(pdfPath, page, []string{strconv.Itoa(pageNum)}, conf); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", pdfPath, err)
+	}
+
+	images, err := newRendererChain(renderer, dpi, false, 0).RenderToImages(page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render %s: %w", pdfPath, err)
+	}
+	if len(images) == 0 {
+		return nil, fmt.Errorf("%s has no page %d", pdfPath, pageNum)
+	}
+	return images[0], nil
+}
+
+// dominantColors returns the most common color of img and the most common color with
+// at least minTextContrast against it. Similar colors are counted together from a
+// histogram of their top colorBucketBits bits per channel, and each group is
+// represented by the average of its pixels.
+func dominantColors(img image.Image) (bg, text colors.Color, err error) {
+	type bucket struct {
+		count            int
+		sumR, sumG, sumB int
+	}
+	buckets := make([]bucket, colorBucketChannel*colorBucketChannel*colorBucketChannel)
+
+	b := img.Bounds()
+	shift := 8 - colorBucketBits
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			r8, g8, b8 := int(r>>8), int(g>>8), int(bl>>8)
+			i := (r8>>shift)*colorBucketChannel*colorBucketChannel + (g8>>shift)*colorBucketChannel + b8>>shift
+			buckets[i].count++
+			buckets[i].sumR += r8
+			buckets[i].sumG += g8
+			buckets[i].sumB += b8
+		}
+	}
+
+	average := func(bk bucket) colors.Color {
+		return colors.NewColorFromRGB8(uint8(bk.sumR/bk.count), uint8(bk.sumG/bk.count), uint8(bk.sumB/bk.count))
+	}
+
+	bgIdx := -1
+	for i, bk := range buckets {
+		if bk.count > 0 && (bgIdx < 0 || bk.count > buckets[bgIdx].count) {
+			bgIdx = i
+		}
+	}
+	if bgIdx < 0 {
+		return colors.Color{}, colors.Color{}, fmt.Errorf("the first page is empty")
+	}
+	bg = average(buckets[bgIdx])
+
+	textIdx := -1
+	for i, bk := range buckets {
+		if bk.count == 0 || (textIdx >= 0 && bk.count <= buckets[textIdx].count) {
+			continue
+		}
+		if colors.ContrastRatio(bg, average(bk)) >= minTextContrast {
+			textIdx = i
+		}
+	}
+	if textIdx < 0 {
+		return colors.Color{}, colors.Color{}, fmt.Errorf("no text color contrasts with the background %s", bg.Hex())
+	}
+	return bg, average(buckets[textIdx]), nil
+}
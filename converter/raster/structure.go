@@ -0,0 +1,243 @@
+package raster
+
+import (
+	"fmt"
+	"os"
+
+	"pdfdarkmode/converter/pdfversion"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// structureKeys are the catalog entries copied from the source to keep logical structure
+var structureKeys = []string{"StructTreeRoot", "MarkInfo", "Lang"}
+
+// copyStructure copies the tagged structure tree of the source PDF onto the raster output.
+//
+// Limitations: the output pages are images, so marked-content references (MCIDs)
+// in the structure tree no longer point at real content. Page references are
+// re-anchored to the corresponding image page, which keeps headings and reading
+// order available to assistive technology, but the text itself is not tagged.
+func copyStructure(srcCtx, destCtx *model.Context) (bool, error) {
+	if srcCtx.RootDict == nil {
+		return false, nil
+	}
+	if _, found := srcCtx.RootDict.Find("StructTreeRoot"); !found {
+		return false, nil
+	}
+
+	// Map source page objects to output page objects so /Pg entries follow the page
+	migrated := pageMap(srcCtx, destCtx)
+
+	for _, key := range structureKeys {
+		obj, found := srcCtx.RootDict.Find(key)
+		if !found {
+			continue
+		}
+		if obj != nil {
+			obj = obj.Clone()
+		}
+		newObj, err := migrateObject(obj, srcCtx, destCtx, migrated)
+		if err != nil {
+			return false, fmt.Errorf("failed to copy %s: %w", key, err)
+		}
+		destCtx.RootDict[key] = newObj
+	}
+
+	return true, nil
+}
+
+// pageMap maps the object numbers of the source pages to those of the output pages
+// with the same page numbers
+func pageMap(srcCtx, destCtx *model.Context) map[int]int {
+	migrated := make(map[int]int)
+	for pageNum := 1; pageNum <= srcCtx.PageCount && pageNum <= destCtx.PageCount; pageNum++ {
+		_, srcRef, _, err := srcCtx.PageDict(pageNum, false)
+		if err != nil || srcRef == nil {
+			continue
+		}
+		_, destRef, _, err := destCtx.PageDict(pageNum, false)
+		if err != nil || destRef == nil {
+			continue
+		}
+		migrated[srcRef.ObjectNumber.Value()] = destRef.ObjectNumber.Value()
+	}
+	return migrated
+}
+
+// migrateObject deep-copies obj from srcCtx into destCtx, renumbering indirect references.
+// migrated maps source object numbers to already copied destination object numbers.
+// References to source pages missing from migrated, which are not in the output, are
+// dropped rather than copied with the page tree: nil is returned for them and they are
+// left out of the dictionaries and arrays holding them, e.g. the /Pg of an element.
+func migrateObject(obj types.Object, srcCtx, destCtx *model.Context, migrated map[int]int) (types.Object, error) {
+	var err error
+
+	switch o := obj.(type) {
+	case types.IndirectRef:
+		objNr := o.ObjectNumber.Value()
+		if newNr, ok := migrated[objNr]; ok {
+			return *types.NewIndirectRef(newNr, 0), nil
+		}
+
+		target, err := srcCtx.Dereference(o)
+		if err != nil {
+			return nil, err
+		}
+		if isPageTreeNode(target) {
+			return nil, nil
+		}
+		if target != nil {
+			target = target.Clone()
+		}
+
+		// Reserve the new object number first so cycles resolve to it
+		newNr, err := destCtx.InsertObject(nil)
+		if err != nil {
+			return nil, err
+		}
+		migrated[objNr] = newNr
+
+		if target, err = migrateObject(target, srcCtx, destCtx, migrated); err != nil {
+			return nil, err
+		}
+		entry, found := destCtx.FindTableEntryLight(newNr)
+		if !found {
+			return nil, fmt.Errorf("could not find xref entry %d", newNr)
+		}
+		entry.Object = target
+
+		return *types.NewIndirectRef(newNr, 0), nil
+
+	case types.Dict:
+		if err := migrateDict(o, srcCtx, destCtx, migrated); err != nil {
+			return nil, err
+		}
+		return o, nil
+
+	case types.StreamDict:
+		if err := migrateDict(o.Dict, srcCtx, destCtx, migrated); err != nil {
+			return nil, err
+		}
+		return o, nil
+
+	case types.Array:
+		kept := o[:0]
+		for _, v := range o {
+			if v, err = migrateObject(v, srcCtx, destCtx, migrated); err != nil {
+				return nil, err
+			}
+			if v != nil {
+				kept = append(kept, v)
+			}
+		}
+		return kept, nil
+	}
+
+	return obj, nil
+}
+
+// migrateDict migrates the values of d in place, deleting the entries that are dropped
+func migrateDict(d types.Dict, srcCtx, destCtx *model.Context, migrated map[int]int) error {
+	for k, v := range d {
+		v, err := migrateObject(v, srcCtx, destCtx, migrated)
+		if err != nil {
+			return err
+		}
+		if v == nil {
+			delete(d, k)
+		} else {
+			d[k] = v
+		}
+	}
+	return nil
+}
+
+// isPageTreeNode reports whether obj is a page or a /Pages node
+func isPageTreeNode(obj types.Object) bool {
+	d, ok := obj.(types.Dict)
+	if !ok {
+		return false
+	}
+	t := d.Type()
+	return t != nil && (*t == "Page" || *t == "Pages")
+}
+
+// isTagged reports whether the PDF at path has a tagged structure tree
+func isTagged(path string) bool {
+	ctx, err := readContext(path)
+	if err != nil || ctx.RootDict == nil {
+		return false
+	}
+	_, found := ctx.RootDict.Find("StructTreeRoot")
+	return found
+}
+
+// keepStructure copies the logical structure of inputPath onto the PDF at outputPath.
+// pdfVersion, when set, is re-applied since the output is rewritten.
+func keepStructure(inputPath, outputPath, pdfVersion string) error {
+	srcCtx, err := readContext(inputPath)
+	if err != nil {
+		return fmt.Errorf("failed to read source structure: %w", err)
+	}
+
+	destCtx, err := readContext(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to read raster output: %w", err)
+	}
+
+	copied, err := copyStructure(srcCtx, destCtx)
+	if err != nil {
+		return err
+	}
+	if !copied {
+		fmt.Println("        Source has no structure tree, nothing to keep")
+		return nil
+	}
+
+	if pdfVersion != "" {
+		target, err := pdfversion.Parse(pdfVersion)
+		if err != nil {
+			return err
+		}
+		if err := pdfversion.Apply(destCtx, target); err != nil {
+			return err
+		}
+	}
+
+	return writeContext(destCtx, outputPath)
+}
+
+// readContext reads a PDF into a pdfcpu context with relaxed validation
+func readContext(path string) (*model.Context, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationRelaxed
+
+	ctx, err := api.ReadContext(f, conf)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.EnsurePageCount(); err != nil {
+		return nil, err
+	}
+	return ctx, nil
+}
+
+// writeContext writes a pdfcpu context to path
+func writeContext(ctx *model.Context, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return api.WriteContext(ctx, f)
+}
@@ -0,0 +1,98 @@
+package raster
+
+import (
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// newTestContext returns an in-memory document with pages empty pages
+func newTestContext(t *testing.T, pages int) *model.Context {
+	t.Helper()
+	ctx, err := pdfcpu.CreateContextWithXRefTable(nil, types.PaperSize["Letter"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pagesRef := ctx.RootDict["Pages"].(types.IndirectRef)
+	pagesDict, err := ctx.DereferenceDict(pagesRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kids types.Array
+	for range pages {
+		ref, err := ctx.IndRefForNewObject(types.Dict{"Type": types.Name("Page"), "Parent": pagesRef})
+		if err != nil {
+			t.Fatal(err)
+		}
+		kids = append(kids, *ref)
+	}
+	pagesDict["Kids"] = kids
+	pagesDict["Count"] = types.Integer(pages)
+	ctx.PageCount = pages
+	return ctx
+}
+
+// countPageObjects returns how many page objects ctx holds, in the page tree or not
+func countPageObjects(ctx *model.Context) int {
+	n := 0
+	for _, entry := range ctx.Table {
+		if entry != nil {
+			if d, ok := entry.Object.(types.Dict); ok && d.Type() != nil && *d.Type() == "Page" {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestCopyStructureDropsMissingPages(t *testing.T) {
+	src := newTestContext(t, 2)
+	_, page1, _, err := src.PageDict(1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, page2, _, err := src.PageDict(2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One element per page, the second one also marking content through an MCR
+	elem1 := types.Dict{"Type": types.Name("StructElem"), "S": types.Name("H1"), "Pg": *page1, "K": types.Integer(0)}
+	elem2 := types.Dict{"Type": types.Name("StructElem"), "S": types.Name("P"), "Pg": *page2,
+		"K": types.Array{types.Dict{"Type": types.Name("MCR"), "Pg": *page2, "MCID": types.Integer(0)}}}
+	ref1, _ := src.IndRefForNewObject(elem1)
+	ref2, _ := src.IndRefForNewObject(elem2)
+	root, _ := src.IndRefForNewObject(types.Dict{"Type": types.Name("StructTreeRoot"), "K": types.Array{*ref1, *ref2}})
+	src.RootDict["StructTreeRoot"] = *root
+
+	dest := newTestContext(t, 1)
+	copied, err := copyStructure(src, dest)
+	if err != nil || !copied {
+		t.Fatalf("copyStructure = %t, %v", copied, err)
+	}
+
+	if n := countPageObjects(dest); n != 1 {
+		t.Errorf("output holds %d page objects, want 1", n)
+	}
+
+	destRoot, err := dest.DereferenceDict(dest.RootDict["StructTreeRoot"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	kids := destRoot["K"].(types.Array)
+	_, destPage, _, _ := dest.PageDict(1, false)
+	first, _ := dest.DereferenceDict(kids[0])
+	if pg, ok := first["Pg"].(types.IndirectRef); !ok || pg.ObjectNumber != destPage.ObjectNumber {
+		t.Errorf("/Pg of the first element is %v, want the output page %v", first["Pg"], *destPage)
+	}
+	second, _ := dest.DereferenceDict(kids[1])
+	if _, found := second["Pg"]; found {
+		t.Errorf("/Pg of the element on the missing page was kept")
+	}
+	mcr := second["K"].(types.Array)[0].(types.Dict)
+	if _, found := mcr["Pg"]; found {
+		t.Errorf("/Pg of the MCR on the missing page was kept")
+	}
+}
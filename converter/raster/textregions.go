@@ -0,0 +1,185 @@
+package raster
+
+import (
+	"image"
+	"image/color"
+)
+
+// Text region detection tuning, in points (1/72 inch) so results do not depend on DPI
+const (
+	minGlyphHeight  = 2.0  // Smaller ink blobs are specks, not glyphs
+	maxGlyphHeight  = 48.0 // Taller ink blobs are rules, drawings or photo content
+	maxGlyphWidth   = 192.0
+	maxGlyphDensity = 0.85 // Glyphs leave gaps in their box; solid blobs are not text
+	regionPadding   = 2.0  // Margin kept around the ink of a region
+	minRegionGlyphs = 2    // Regions with fewer glyphs are treated as noise
+	maxMidtoneShare = 0.25 // Regions with more mid-tone or colorful pixels are pictures
+)
+
+// detectTextRegions finds the areas of a rendered page that hold text. Dark connected
+// components of glyph size are grouped into words and lines by joining neighbors closer
+// than a glyph height, and groups made mostly of mid-tones or color (photos, logos) are
+// discarded. Works on scans, which carry no text layer to ask for boxes.
+func detectTextRegions(img image.Image, dpi int) []image.Rectangle {
+	ink, w, h := inkMask(img)
+	if w == 0 || h == 0 {
+		return nil
+	}
+	scale := float64(dpi) / 72
+
+	glyphs := glyphBoxes(ink, w, h, scale)
+	if len(glyphs) == 0 {
+		return nil
+	}
+
+	b := img.Bounds()
+	pad := int(regionPadding * scale)
+	var regions []image.Rectangle
+	for _, group := range groupGlyphs(glyphs, w, h) {
+		if len(group) < minRegionGlyphs {
+			continue
+		}
+		r := group[0]
+		for _, g := range group[1:] {
+			r = r.Union(g)
+		}
+		r = image.Rect(r.Min.X-pad, r.Min.Y-pad, r.Max.X+pad, r.Max.Y+pad).
+			Intersect(image.Rect(0, 0, w, h)).
+			Add(b.Min)
+		if midtoneShare(img, r) > maxMidtoneShare {
+			continue
+		}
+		regions = append(regions, r)
+	}
+	return regions
+}
+
+// glyphBoxes returns the bounding boxes of the 8-connected ink components that
+// have the size and density of a glyph
+func glyphBoxes(ink []bool, w, h int, scale float64) []image.Rectangle {
+	minH := int(minGlyphHeight * scale)
+	maxH := int(maxGlyphHeight * scale)
+	maxW := int(maxGlyphWidth * scale)
+
+	seen := make([]bool, len(ink))
+	var stack []int
+	var boxes []image.Rectangle
+
+	for start, isInk := range ink {
+		if !isInk || seen[start] {
+			continue
+		}
+
+		// Flood fill the component
+		seen[start] = true
+		stack = append(stack[:0], start)
+		box := image.Rect(start%w, start/w, start%w+1, start/w+1)
+		count := 0
+		for len(stack) > 0 {
+			p := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			count++
+			x, y := p%w, p/w
+			box = box.Union(image.Rect(x, y, x+1, y+1))
+
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := x+dx, y+dy
+					if nx < 0 || ny < 0 || nx >= w || ny >= h {
+						continue
+					}
+					if n := ny*w + nx; ink[n] && !seen[n] {
+						seen[n] = true
+						stack = append(stack, n)
+					}
+				}
+			}
+		}
+
+		bw, bh := box.Dx(), box.Dy()
+		if bh < minH || bh > maxH || bw > maxW {
+			continue
+		}
+		if float64(count) > maxGlyphDensity*float64(bw*bh) && bw*bh > minH*minH {
+			continue
+		}
+		boxes = append(boxes, box)
+	}
+
+	return boxes
+}
+
+// groupGlyphs joins glyphs whose boxes, grown by their own height sideways and half
+// of it vertically, overlap. Returns the glyph boxes of each group.
+func groupGlyphs(glyphs []image.Rectangle, w, h int) [][]image.Rectangle {
+	// Union-find over glyph indices, joined through a coarse occupancy grid
+	parent := make([]int, len(glyphs))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	const cell = 4
+	gw, gh := (w+cell-1)/cell, (h+cell-1)/cell
+	owner := make([]int, gw*gh)
+	for i := range owner {
+		owner[i] = -1
+	}
+
+	for i, g := range glyphs {
+		grow := g.Dy()
+		r := image.Rect(g.Min.X-grow, g.Min.Y-grow/2, g.Max.X+grow, g.Max.Y+grow/2).
+			Intersect(image.Rect(0, 0, w, h))
+		for cy := r.Min.Y / cell; cy <= (r.Max.Y-1)/cell; cy++ {
+			for cx := r.Min.X / cell; cx <= (r.Max.X-1)/cell; cx++ {
+				c := cy*gw + cx
+				if owner[c] < 0 {
+					owner[c] = i
+				} else if a, b := find(owner[c]), find(i); a != b {
+					parent[a] = b
+				}
+			}
+		}
+	}
+
+	byRoot := make(map[int]int)
+	var groups [][]image.Rectangle
+	for i, g := range glyphs {
+		root := find(i)
+		idx, ok := byRoot[root]
+		if !ok {
+			idx = len(groups)
+			byRoot[root] = idx
+			groups = append(groups, nil)
+		}
+		groups[idx] = append(groups[idx], g)
+	}
+	return groups
+}
+
+// midtoneShare returns the fraction of pixels in r that are neither paper nor ink:
+// mid grays and saturated colors, which dominate photos but not text
+func midtoneShare(img image.Image, r image.Rectangle) float64 {
+	if r.Empty() {
+		return 0
+	}
+	mid := 0
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			hi := max(c.R, c.G, c.B)
+			lo := min(c.R, c.G, c.B)
+			lightness := (float64(hi) + float64(lo)) / 510
+			if (lightness > 0.25 && lightness < 0.75) || hi-lo > 64 {
+				mid++
+			}
+		}
+	}
+	return float64(mid) / float64(r.Dx()*r.Dy())
+}
@@ -0,0 +1,136 @@
+package raster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"pdfdarkmode/converter/notice"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// timedOutPage stands in for a page that took too long to render: a blank page of
+// the same size, which inverts to the scheme background
+type timedOutPage struct {
+	*image.Gray
+}
+
+// newTimedOutPage returns a blank page of width x height points at dpi
+func newTimedOutPage(width, height float64, dpi int) timedOutPage {
+	scale := float64(dpi) / 72
+	w, h := max(int(width*scale+0.5), 1), max(int(height*scale+0.5), 1)
+	page := image.NewGray(image.Rect(0, 0, w, h))
+	for i := range page.Pix {
+		page.Pix[i] = 255
+	}
+	return timedOutPage{page}
+}
+
+// SetPageTimeout renders the PDF one page at a time, giving up on a page after
+// timeout and putting a blank placeholder page in its place. Zero renders the whole
+// PDF in one run without a limit.
+func (r *PopplerRenderer) SetPageTimeout(timeout time.Duration) {
+	r.pageTimeout = timeout
+}
+
+// renderPageByPage renders each page of pdfPath with a separate run of tool (pdftoppm
+// or pdftocairo), killing runs that exceed the page timeout
+func (r *PopplerRenderer) renderPageByPage(tool, pdfPath, tempDir string) ([]image.Image, error) {
+	f, err := os.Open(pdfPath)
+	if err != nil {
+		return nil, err
+	}
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationRelaxed
+	dims, err := api.PageDims(f, conf)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read page sizes: %w", err)
+	}
+
+	images := make([]image.Image, len(dims))
+	for i, d := range dims {
+		page := strconv.Itoa(i + 1)
+		outputPrefix := filepath.Join(tempDir, fmt.Sprintf("page-%04d", i+1))
+
+		ctx, cancel := context.WithTimeout(context.Background(), r.pageTimeout)
+		cmd := exec.CommandContext(ctx, tool,
+			"-png",
+			"-r", strconv.Itoa(r.dpi),
+			"-f", page, "-l", page,
+			"-singlefile",
+			pdfPath,
+			outputPrefix,
+		)
+		output, err := cmd.CombinedOutput()
+		timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded)
+		cancel()
+
+		if timedOut {
+			images[i] = newTimedOutPage(d.Width, d.Height, r.dpi)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s failed on page %d: %w\nOutput: %s", tool, i+1, err, string(output))
+		}
+
+		img, err := loadPNG(outputPrefix + ".png")
+		if err != nil {
+			return nil, fmt.Errorf("failed to load page %d: %w", i+1, err)
+		}
+		images[i] = img
+	}
+
+	return images, nil
+}
+
+// SetMaxRenderTime sets how long the built-in poppler renderer may spend on one page
+// before skipping it. Skipped pages are converted to an empty page in the scheme
+// colors with a note that rendering timed out. Zero disables the limit.
+func (e *Engine) SetMaxRenderTime(timeout time.Duration) {
+	e.pageTimeout = timeout
+	e.renderer = newRendererChain(e.preferred, e.dpi, e.cmyk, timeout)
+}
+
+// SetRightToLeft places the notices stamped on skipped pages for a right-to-left
+// document (see notice.Layout)
+func (e *Engine) SetRightToLeft(enabled bool) {
+	e.rtl = enabled
+}
+
+// timedOutPages returns the 1-based numbers of the pages selected for conversion that
+// timed out rendering
+func (e *Engine) timedOutPages(images []image.Image) []int {
+	var pages []int
+	for i, img := range images {
+		if _, ok := img.(timedOutPage); ok && e.converts(i+1) {
+			pages = append(pages, i+1)
+		}
+	}
+	return pages
+}
+
+// markTimedOut stamps a note in the scheme's text color on each of pages of outputPath
+func (e *Engine) markTimedOut(outputPath string, pages []int) error {
+	selected := make([]string, len(pages))
+	for i, p := range pages {
+		selected[i] = strconv.Itoa(p)
+	}
+
+	note := fmt.Sprintf("Page skipped: render timed out after %s", e.pageTimeout)
+	desc := fmt.Sprintf("%s, points:24, fillcolor:%s, rotation:0, scalefactor:0.5 rel, opacity:1",
+		notice.Layout{Position: "c"}.Desc(note, e.rtl), e.inverter.scheme.Text.Hex())
+	conf, err := e.writeConfig()
+	if err != nil {
+		return err
+	}
+	return api.AddTextWatermarksFile(outputPath, "", selected, true, note, desc, conf)
+}
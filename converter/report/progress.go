@@ -0,0 +1,40 @@
+package report
+
+import "time"
+
+// Progress receives the progress of a conversion page by page
+type Progress interface {
+	// OnPage is called when page of total is done, with the time elapsed since the
+	// engine started on the pages
+	OnPage(page, total int, elapsed time.Duration)
+}
+
+var (
+	progress   Progress
+	pagesStart time.Time
+)
+
+// SetProgress sets the receiver of page progress, nil for none
+func SetProgress(p Progress) {
+	mu.Lock()
+	defer mu.Unlock()
+	progress = p
+}
+
+// StartPages marks the start of an engine's pass over the pages. An engine making
+// several passes, such as rendering and then placing pages, starts each one.
+func StartPages() {
+	mu.Lock()
+	defer mu.Unlock()
+	pagesStart = time.Now()
+}
+
+// PageDone reports that page of total is done in the current pass
+func PageDone(page, total int) {
+	mu.Lock()
+	p, elapsed := progress, time.Since(pagesStart)
+	mu.Unlock()
+	if p != nil {
+		p.OnPage(page, total, elapsed)
+	}
+}
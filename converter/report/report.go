@@ -0,0 +1,153 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// Report records one conversion for auditing large batches
+type Report struct {
+	Input      string    `json:"input"`
+	Output     string    `json:"output"`
+	Mode       string    `json:"mode"`
+	Scheme     string    `json:"scheme"`
+	Started    time.Time `json:"started"`
+	Seconds    float64   `json:"seconds"`
+	InputSize  int64     `json:"input_size"`
+	OutputSize int64     `json:"output_size,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Stats      Stats     `json:"stats"`
+	Warnings   []string  `json:"warnings"`        // Skipped pages and streams, fallbacks and other warnings, in order
+	Notes      []string  `json:"notes,omitempty"` // Advice that does not affect the output, such as size estimates
+}
+
+// Stats counts what a conversion did
+type Stats struct {
+	Pages          int   `json:"pages"`                     // Pages in the output, 0 if it could not be read
+	PageTransforms []int `json:"page_transforms,omitempty"` // Color operators transformed on each page, direct and hybrid modes
+}
+
+var (
+	mu         sync.Mutex
+	warnings   []string
+	notes      []string
+	transforms []int
+)
+
+// Warnf prints a conversion warning and records it for the report
+func Warnf(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	fmt.Printf("        Warning: %s\n", msg)
+
+	mu.Lock()
+	warnings = append(warnings, msg)
+	mu.Unlock()
+}
+
+// Notef prints advice about a conversion that does not affect its output, and records
+// it for the report. Unlike warnings, notes do not fail strict conversions.
+func Notef(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	fmt.Printf("        Note: %s\n", msg)
+
+	mu.Lock()
+	notes = append(notes, msg)
+	mu.Unlock()
+}
+
+// Count returns the number of warnings recorded so far
+func Count() int {
+	mu.Lock()
+	defer mu.Unlock()
+	return len(warnings)
+}
+
+// Since returns the warnings recorded after the first n
+func Since(n int) []string {
+	mu.Lock()
+	defer mu.Unlock()
+	if n >= len(warnings) {
+		return nil
+	}
+	return append([]string{}, warnings[n:]...)
+}
+
+// RecordTransforms records the number of color operators transformed on a page
+func RecordTransforms(page, count int) {
+	mu.Lock()
+	defer mu.Unlock()
+	for len(transforms) < page {
+		transforms = append(transforms, 0)
+	}
+	transforms[page-1] = count
+}
+
+// Start begins a report for a conversion, discarding warnings and page counts from
+// earlier ones
+func Start(input, output, mode, scheme string) *Report {
+	mu.Lock()
+	warnings = nil
+	notes = nil
+	transforms = nil
+	mu.Unlock()
+
+	return &Report{
+		Input:     input,
+		Output:    output,
+		Mode:      mode,
+		Scheme:    scheme,
+		Started:   time.Now(),
+		InputSize: fileSize(input),
+	}
+}
+
+// Finish completes r with the conversion's outcome and the warnings recorded since Start
+func (r *Report) Finish(err error) {
+	r.Seconds = time.Since(r.Started).Seconds()
+	if err != nil {
+		r.Error = err.Error()
+	} else {
+		r.OutputSize = fileSize(r.Output)
+		r.Stats.Pages, _ = api.PageCountFile(r.Output)
+	}
+
+	mu.Lock()
+	r.Warnings = append([]string{}, warnings...)
+	r.Notes = append([]string(nil), notes...)
+	r.Stats.PageTransforms = append([]int(nil), transforms...)
+	mu.Unlock()
+}
+
+// Write saves r as <output name>.report.json in dir, creating dir if needed.
+// Returns the path written.
+func (r *Report) Write(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create report directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, filepath.Base(r.Output)+".report.json")
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return "", fmt.Errorf("failed to write report: %w", err)
+	}
+	return path, nil
+}
+
+// fileSize returns the size of path, or 0 if it cannot be read
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
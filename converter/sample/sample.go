@@ -0,0 +1,139 @@
+package sample
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// Sampling strategies
+const (
+	StrategyRandom = "random" // Seeded random pages, kept in document order
+	StrategyFirst  = "first"  // The first pages of the document
+)
+
+// Options configures which pages go into a proof PDF
+type Options struct {
+	Rate     float64 // Fraction of pages to keep (0-1], 0 disables sampling
+	Strategy string  // StrategyRandom or StrategyFirst
+	Seed     int64   // Seed for StrategyRandom, for reproducible proofs
+}
+
+// Enabled reports whether sampling is requested
+func (o Options) Enabled() bool {
+	return o.Rate > 0 && o.Rate < 1
+}
+
+// Validate checks the sampling options
+func (o Options) Validate() error {
+	if o.Rate < 0 || o.Rate > 1 {
+		return fmt.Errorf("invalid sample rate: %g (must be between 0 and 1)", o.Rate)
+	}
+	if o.Strategy != StrategyRandom && o.Strategy != StrategyFirst {
+		return fmt.Errorf("invalid sample strategy: %s (must be '%s' or '%s')", o.Strategy, StrategyRandom, StrategyFirst)
+	}
+	return nil
+}
+
+// Count returns how many of pageCount pages are sampled, always at least one
+func (o Options) Count(pageCount int) int {
+	n := int(math.Ceil(o.Rate * float64(pageCount)))
+	if n < 1 {
+		n = 1
+	}
+	if n > pageCount {
+		n = pageCount
+	}
+	return n
+}
+
+// Pages returns the sorted 1-based page numbers to keep out of pageCount pages
+func (o Options) Pages(pageCount int) []int {
+	n := o.Count(pageCount)
+	pages := make([]int, n)
+
+	if o.Strategy == StrategyFirst {
+		for i := range pages {
+			pages[i] = i + 1
+		}
+		return pages
+	}
+
+	rng := rand.New(rand.NewSource(o.Seed))
+	for i, p := range rng.Perm(pageCount)[:n] {
+		pages[i] = p + 1
+	}
+	sort.Ints(pages)
+	return pages
+}
+
+// Extract writes a copy of inputPath holding only the sampled pages to a temp file.
+// It returns the temp file path and the selected pages; the caller removes the file.
+func Extract(inputPath string, opts Options) (string, []int, error) {
+	pageCount, err := api.PageCountFile(inputPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to count pages: %w", err)
+	}
+
+	pages := opts.Pages(pageCount)
+	selected := make([]string, len(pages))
+	for i, p := range pages {
+		selected[i] = strconv.Itoa(p)
+	}
+
+	tempDir, err := os.MkdirTemp("", "pdfdarkmode-sample-")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	samplePath := filepath.Join(tempDir, filepath.Base(inputPath))
+
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationRelaxed
+	if err := api.TrimFile(inputPath, samplePath, selected, conf); err != nil {
+		os.RemoveAll(tempDir)
+		return "", nil, fmt.Errorf("failed to extract sample pages: %w", err)
+	}
+
+	return samplePath, pages, nil
+}
+
+// Cleanup removes a sample file created by Extract
+func Cleanup(samplePath string) {
+	os.RemoveAll(filepath.Dir(samplePath))
+}
+
+// Verify checks that the proof at outputPath has the expected number of pages
+func Verify(outputPath string, expected int) error {
+	got, err := api.PageCountFile(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to count proof pages: %w", err)
+	}
+	if got != expected {
+		return fmt.Errorf("proof has %d pages, expected %d", got, expected)
+	}
+	return nil
+}
+
+// Spread returns n of pageCount 1-based page numbers spread evenly over the document,
+// always including the first and last page, for analyses that need a representative
+// look rather than every page. n of 0, or at least pageCount, returns every page.
+func Spread(pageCount, n int) []int {
+	if n <= 0 || n >= pageCount {
+		n = pageCount
+	}
+	pages := make([]int, n)
+	for i := range pages {
+		pages[i] = 1
+		if n > 1 {
+			pages[i] += i * (pageCount - 1) / (n - 1)
+		}
+	}
+	return pages
+}
@@ -0,0 +1,122 @@
+package sample
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// writeTestPDF writes a document of n pages to a temporary directory, each page's
+// content naming its page number, and returns its path
+func writeTestPDF(t *testing.T, n int) string {
+	t.Helper()
+	ctx, err := pdfcpu.CreateContextWithXRefTable(nil, types.PaperSize["Letter"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pagesRef := ctx.RootDict["Pages"].(types.IndirectRef)
+	pages, err := ctx.DereferenceDict(pagesRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var kids types.Array
+	for pageNum := 1; pageNum <= n; pageNum++ {
+		contents, err := ctx.StreamDictIndRef([]byte(pageMarker(pageNum)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ref, err := ctx.IndRefForNewObject(types.Dict{
+			"Type":     types.Name("Page"),
+			"Parent":   pagesRef,
+			"Contents": *contents,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		kids = append(kids, *ref)
+	}
+	pages["Kids"] = kids
+	pages["Count"] = types.Integer(n)
+
+	path := filepath.Join(t.TempDir(), "in.pdf")
+	if err := api.WriteContextFile(ctx, path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// pageMarker is the content of page pageNum in writeTestPDF's documents
+func pageMarker(pageNum int) string {
+	return fmt.Sprintf("%% page %d\n", pageNum)
+}
+
+// pageContent returns the decoded content of page pageNum
+func pageContent(t *testing.T, ctx *model.Context, pageNum int) string {
+	t.Helper()
+	pageDict, _, _, err := ctx.PageDict(pageNum, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sd, _, err := ctx.DereferenceStreamDict(pageDict["Contents"])
+	if err != nil || sd == nil {
+		t.Fatalf("page %d has no content stream: %v", pageNum, err)
+	}
+	if err := sd.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	return string(sd.Content)
+}
+
+func TestExtract(t *testing.T) {
+	input := writeTestPDF(t, 10)
+
+	tests := []Options{
+		{Rate: 0.3, Strategy: StrategyFirst},
+		{Rate: 0.3, Strategy: StrategyRandom, Seed: 7},
+		{Rate: 0.05, Strategy: StrategyRandom, Seed: 1},
+	}
+
+	for _, opts := range tests {
+		samplePath, pages, err := Extract(input, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { Cleanup(samplePath) })
+
+		if len(pages) != opts.Count(10) {
+			t.Errorf("%+v: selected pages %v, want %d", opts, pages, opts.Count(10))
+		}
+		if err := Verify(samplePath, len(pages)); err != nil {
+			t.Errorf("%+v: %v", opts, err)
+		}
+
+		ctx, err := api.ReadContextFile(samplePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, pageNum := range pages {
+			if got := pageContent(t, ctx, i+1); got != pageMarker(pageNum) {
+				t.Errorf("%+v: sample page %d is %q, want page %d", opts, i+1, got, pageNum)
+			}
+		}
+	}
+}
+
+func TestPagesSeeded(t *testing.T) {
+	opts := Options{Rate: 0.5, Strategy: StrategyRandom, Seed: 42}
+	first := opts.Pages(20)
+	if again := opts.Pages(20); fmt.Sprint(again) != fmt.Sprint(first) {
+		t.Errorf("seed 42 picked %v, then %v", first, again)
+	}
+	for i := 1; i < len(first); i++ {
+		if first[i] <= first[i-1] {
+			t.Errorf("pages %v are not in document order", first)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package sanitize
+
+import (
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// maxActionDepth bounds how far /Next chains are followed
+const maxActionDepth = 16
+
+// maxFieldDepth bounds how deep the AcroForm field tree is walked
+const maxFieldDepth = 32
+
+// Report counts what Apply removed
+type Report struct {
+	OpenAction        bool // The document /OpenAction
+	DocumentAA        bool // The document /AA (additional actions)
+	DocumentScripts   bool // The /JavaScript name tree
+	AdditionalActions int  // /AA entries on pages, annotations and form fields
+	ScriptActions     int  // JavaScript /A actions on annotations
+}
+
+// Removed reports whether anything was removed
+func (r Report) Removed() bool {
+	return r.OpenAction || r.DocumentAA || r.DocumentScripts || r.AdditionalActions > 0 || r.ScriptActions > 0
+}
+
+// Apply strips everything that runs on its own when the document is opened or
+// used: the /OpenAction, document, page, annotation and form field /AA entries,
+// the document-level /JavaScript name tree and JavaScript link actions.
+// Content, links to destinations and URIs are kept.
+func Apply(ctx *model.Context) Report {
+	var r Report
+	if ctx.RootDict == nil {
+		return r
+	}
+
+	r.OpenAction = ctx.RootDict.Delete("OpenAction") != nil
+	r.DocumentAA = ctx.RootDict.Delete("AA") != nil
+
+	if names, err := ctx.DereferenceDict(ctx.RootDict["Names"]); err == nil && names != nil {
+		r.DocumentScripts = names.Delete("JavaScript") != nil
+	}
+
+	for pageNum := 1; pageNum <= ctx.PageCount; pageNum++ {
+		pageDict, _, _, err := ctx.PageDict(pageNum, false)
+		if err != nil || pageDict == nil {
+			continue
+		}
+		if pageDict.Delete("AA") != nil {
+			r.AdditionalActions++
+		}
+
+		annots, err := ctx.DereferenceArray(pageDict["Annots"])
+		if err != nil {
+			continue
+		}
+		for _, obj := range annots {
+			annot, err := ctx.DereferenceDict(obj)
+			if err != nil || annot == nil {
+				continue
+			}
+			if annot.Delete("AA") != nil {
+				r.AdditionalActions++
+			}
+			if action, err := ctx.DereferenceDict(annot["A"]); err == nil && action != nil && RunsScript(ctx, action) {
+				annot.Delete("A")
+				r.ScriptActions++
+			}
+		}
+	}
+
+	// Form fields that are not also widget annotations carry their own /AA
+	if acroForm, err := ctx.DereferenceDict(ctx.RootDict["AcroForm"]); err == nil && acroForm != nil {
+		if fields, err := ctx.DereferenceArray(acroForm["Fields"]); err == nil {
+			r.AdditionalActions += stripFields(ctx, fields, 0)
+		}
+	}
+
+	return r
+}
+
+// stripFields removes /AA from form fields and their kids. Returns the number removed.
+func stripFields(ctx *model.Context, fields types.Array, depth int) int {
+	if depth > maxFieldDepth {
+		return 0
+	}
+
+	count := 0
+	for _, obj := range fields {
+		field, err := ctx.DereferenceDict(obj)
+		if err != nil || field == nil {
+			continue
+		}
+		if field.Delete("AA") != nil {
+			count++
+		}
+		if kids, err := ctx.DereferenceArray(field["Kids"]); err == nil {
+			count += stripFields(ctx, kids, depth+1)
+		}
+	}
+	return count
+}
+
+// RunsScript reports whether action, or any action chained after it with /Next,
+// is a JavaScript action
+func RunsScript(ctx *model.Context, action types.Dict) bool {
+	return runsScript(ctx, action, 0)
+}
+
+func runsScript(ctx *model.Context, action types.Dict, depth int) bool {
+	if depth > maxActionDepth {
+		return false
+	}
+	if s := action.NameEntry("S"); s != nil && *s == "JavaScript" {
+		return true
+	}
+
+	next, found := action.Find("Next")
+	if !found {
+		return false
+	}
+
+	if arr, err := ctx.DereferenceArray(next); err == nil && arr != nil {
+		for _, item := range arr {
+			if d, err := ctx.DereferenceDict(item); err == nil && d != nil && runsScript(ctx, d, depth+1) {
+				return true
+			}
+		}
+		return false
+	}
+
+	d, err := ctx.DereferenceDict(next)
+	return err == nil && d != nil && runsScript(ctx, d, depth+1)
+}
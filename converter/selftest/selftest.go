@@ -0,0 +1,148 @@
+package selftest
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"pdfdarkmode/converter/colormath"
+	"pdfdarkmode/converter/raster"
+)
+
+// Grid steps, in 8-bit units, of the colors checked. Both divide 255, so the grid
+// includes pure black, white and the primaries.
+const (
+	rgbStep  = 5
+	cmykStep = 15
+)
+
+// Tolerances of the checks
+const (
+	exact    = 1e-9 // Float conversions and their round trips
+	oneLevel = 1.0  // 8-bit results, which truncate or round to a neighboring level
+)
+
+// Check is the outcome of one self-test check
+type Check struct {
+	Name      string
+	MaxError  float64 // Largest error found over the grid
+	Tolerance float64
+	Worst     string // Input with the largest error
+}
+
+// Passed reports whether the largest error stays within the tolerance
+func (c Check) Passed() bool {
+	return c.MaxError <= c.Tolerance
+}
+
+// Run round-trips a grid of colors through the shared RGB/HSL and RGB/CMYK conversions
+// and the raster engine's 8-bit HSL conversions. The raster engine converts CMYK pages
+// with image/color, so the shared CMYK conversions are also checked against that.
+func Run() []Check {
+	return []Check{
+		hslRoundTrip(),
+		rasterHSLRoundTrip(),
+		hslToRGBAgreement(),
+		cmykRoundTrip(),
+		rgbToCMYKAgreement(),
+		cmykToRGBAgreement(),
+	}
+}
+
+// grid calls fn with every 8-bit RGB color on the grid
+func grid(fn func(r, g, b uint8)) {
+	for r := 0; r <= 255; r += rgbStep {
+		for g := 0; g <= 255; g += rgbStep {
+			for b := 0; b <= 255; b += rgbStep {
+				fn(uint8(r), uint8(g), uint8(b))
+			}
+		}
+	}
+}
+
+// unit converts an 8-bit level to 0-1
+func unit(v uint8) float64 {
+	return float64(v) / 255
+}
+
+// track records the largest error of a check
+func (c *Check) track(err float64, input string, args ...any) {
+	if err > c.MaxError || c.Worst == "" {
+		c.MaxError = err
+		c.Worst = fmt.Sprintf(input, args...)
+	}
+}
+
+// maxDiff returns the largest absolute difference between the pairs in a and b
+func maxDiff(a, b []float64) float64 {
+	d := 0.0
+	for i := range a {
+		d = math.Max(d, math.Abs(a[i]-b[i]))
+	}
+	return d
+}
+
+func hslRoundTrip() Check {
+	c := Check{Name: "RGB -> HSL -> RGB", Tolerance: exact}
+	grid(func(r, g, b uint8) {
+		h, s, l := colormath.RGBToHSL(unit(r), unit(g), unit(b))
+		r2, g2, b2 := colormath.HSLToRGB(h, s, l)
+		c.track(maxDiff([]float64{unit(r), unit(g), unit(b)}, []float64{r2, g2, b2}), "rgb(%d,%d,%d)", r, g, b)
+	})
+	return c
+}
+
+func rasterHSLRoundTrip() Check {
+	c := Check{Name: "raster RGB -> HSL -> RGB (8-bit levels)", Tolerance: oneLevel}
+	grid(func(r, g, b uint8) {
+		r2, g2, b2 := raster.HSLToRGB(raster.RGBToHSL(r, g, b))
+		c.track(maxDiff([]float64{float64(r), float64(g), float64(b)}, []float64{float64(r2), float64(g2), float64(b2)}), "rgb(%d,%d,%d)", r, g, b)
+	})
+	return c
+}
+
+func hslToRGBAgreement() Check {
+	c := Check{Name: "raster HSL -> RGB vs shared (8-bit levels)", Tolerance: oneLevel}
+	grid(func(r, g, b uint8) {
+		h, s, l := colormath.RGBToHSL(unit(r), unit(g), unit(b))
+		r1, g1, b1 := colormath.HSLToRGB(h, s, l)
+		r2, g2, b2 := raster.HSLToRGB(h, s, l)
+		c.track(maxDiff([]float64{r1 * 255, g1 * 255, b1 * 255}, []float64{float64(r2), float64(g2), float64(b2)}), "hsl(%.4f,%.4f,%.4f)", h, s, l)
+	})
+	return c
+}
+
+func cmykRoundTrip() Check {
+	c := Check{Name: "RGB -> CMYK -> RGB", Tolerance: exact}
+	grid(func(r, g, b uint8) {
+		r2, g2, b2 := colormath.CMYKToRGB(colormath.RGBToCMYK(unit(r), unit(g), unit(b)))
+		c.track(maxDiff([]float64{unit(r), unit(g), unit(b)}, []float64{r2, g2, b2}), "rgb(%d,%d,%d)", r, g, b)
+	})
+	return c
+}
+
+func rgbToCMYKAgreement() Check {
+	c := Check{Name: "RGB -> CMYK agrees with image/color (8-bit levels)", Tolerance: oneLevel}
+	grid(func(r, g, b uint8) {
+		c1, m1, y1, k1 := colormath.RGBToCMYK(unit(r), unit(g), unit(b))
+		c2, m2, y2, k2 := color.RGBToCMYK(r, g, b)
+		c.track(maxDiff([]float64{c1 * 255, m1 * 255, y1 * 255, k1 * 255}, []float64{float64(c2), float64(m2), float64(y2), float64(k2)}), "rgb(%d,%d,%d)", r, g, b)
+	})
+	return c
+}
+
+func cmykToRGBAgreement() Check {
+	c := Check{Name: "CMYK -> RGB agrees with image/color (8-bit levels)", Tolerance: oneLevel}
+	for cc := 0; cc <= 255; cc += cmykStep {
+		for m := 0; m <= 255; m += cmykStep {
+			for y := 0; y <= 255; y += cmykStep {
+				for k := 0; k <= 255; k += cmykStep {
+					r1, g1, b1 := colormath.CMYKToRGB(unit(uint8(cc)), unit(uint8(m)), unit(uint8(y)), unit(uint8(k)))
+					r2, g2, b2 := color.CMYKToRGB(uint8(cc), uint8(m), uint8(y), uint8(k))
+					c.track(maxDiff([]float64{r1 * 255, g1 * 255, b1 * 255}, []float64{float64(r2), float64(g2), float64(b2)}), "cmyk(%d,%d,%d,%d)", cc, m, y, k)
+				}
+			}
+		}
+	}
+	return c
+}
@@ -0,0 +1,15 @@
+package selftest
+
+import "testing"
+
+// TestRun runs the selftest command's checks, on the same grid and within the same
+// tolerances, as unit tests
+func TestRun(t *testing.T) {
+	for _, c := range Run() {
+		t.Run(c.Name, func(t *testing.T) {
+			if !c.Passed() {
+				t.Errorf("max error %g exceeds tolerance %g at %s", c.MaxError, c.Tolerance, c.Worst)
+			}
+		})
+	}
+}
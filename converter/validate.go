@@ -0,0 +1,105 @@
+package converter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"pdfdarkmode/converter/pdfa"
+	"pdfdarkmode/converter/report"
+	"pdfdarkmode/converter/viewerhints"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// ErrNotPDF is returned for inputs that are empty or carry no PDF header.
+// Callers converting many files can test for it with errors.Is and skip the file.
+var ErrNotPDF = errors.New("not a PDF")
+
+// ErrAlreadyDark is returned for inputs an earlier conversion marked with its color
+// theme when Options.IfAlreadyDark is AlreadyDarkSkip
+var ErrAlreadyDark = errors.New("already converted")
+
+// Ways to handle inputs an earlier conversion marked, for Options.IfAlreadyDark
+const (
+	AlreadyDarkWarn    = "warn"    // Convert again after a warning (the default)
+	AlreadyDarkSkip    = "skip"    // Return ErrAlreadyDark without writing output
+	AlreadyDarkProceed = "proceed" // Convert again without reading the mark
+)
+
+// headerSearchLimit is how far into the file the %PDF- header may start; viewers
+// tolerate a little leading junk, so the header is not required at offset 0
+const headerSearchLimit = 1024
+
+// validateInput checks that path is a non-empty file starting with a PDF header
+func validateInput(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to read input file: %w", err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s: %w (is a directory)", path, ErrNotPDF)
+	}
+	if info.Size() == 0 {
+		return fmt.Errorf("%s: %w (empty file)", path, ErrNotPDF)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer f.Close()
+
+	head := make([]byte, headerSearchLimit)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return fmt.Errorf("failed to read input file: %w", err)
+	}
+	if !bytes.Contains(head[:n], []byte("%PDF-")) {
+		return fmt.Errorf("%s: %w (no %%PDF- header)", path, ErrNotPDF)
+	}
+
+	return nil
+}
+
+// checkAlreadyDark applies opts.IfAlreadyDark to an input whose /ViewerPreferences or
+// metadata carry the color theme mark of an earlier conversion. Converting such a
+// document again inverts it back. ctx is the parsed input, nil if it cannot be read.
+func checkAlreadyDark(ctx *model.Context, opts Options) error {
+	if opts.IfAlreadyDark == AlreadyDarkProceed || ctx == nil {
+		return nil
+	}
+
+	theme := viewerhints.Detect(ctx)
+	if theme == "" {
+		return nil
+	}
+
+	if opts.IfAlreadyDark == AlreadyDarkSkip {
+		return fmt.Errorf("%s: %w (marked %s)", opts.InputFile, ErrAlreadyDark, theme)
+	}
+	report.Warnf("%s was already converted (marked %s); converting it again inverts it back", opts.InputFile, theme)
+	return nil
+}
+
+// checkPDFAInput warns when the input, parsed into ctx (nil if unreadable), claims
+// PDF/A conformance that the conversion is likely to break. With opts.PDFA the output
+// is checked and marked again instead.
+func checkPDFAInput(ctx *model.Context, opts Options) {
+	if opts.PDFA || ctx == nil {
+		return
+	}
+	claim := pdfa.Detect(ctx)
+	if claim == "" {
+		return
+	}
+
+	fmt.Printf("  Input claims %s conformance\n", claim)
+	if opts.Mode != "direct" {
+		report.Warnf("%s input: %s mode rebuilds the pages from rendered images, so the output may not conform; use --mode direct or --pdfa", claim, opts.Mode)
+	} else if pdfa.IntentComponents(ctx) != 3 {
+		report.Warnf("%s input: direct mode writes DeviceRGB colors, which PDF/A forbids without an RGB output intent; use --pdfa to add one", claim)
+	}
+}
@@ -0,0 +1,43 @@
+package converter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateInput(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		content string
+		notPDF  bool
+	}{
+		{"empty.pdf", "", true},
+		{"notes.pdf", "These are plain text notes renamed to .pdf\n", true},
+		{"junk.pdf", "\x00\x01junk\n%PDF-1.7\n", false},
+		{"doc.pdf", "%PDF-1.4\n", false},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.name)
+		if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		err := validateInput(path)
+		if errors.Is(err, ErrNotPDF) != tt.notPDF {
+			t.Errorf("%s: got %v, want ErrNotPDF %t", tt.name, err, tt.notPDF)
+		}
+		if !tt.notPDF && err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+
+	if err := validateInput(dir); !errors.Is(err, ErrNotPDF) {
+		t.Errorf("directory: got %v, want ErrNotPDF", err)
+	}
+	if err := validateInput(filepath.Join(dir, "missing.pdf")); err == nil || errors.Is(err, ErrNotPDF) {
+		t.Errorf("missing file: got %v, want a read error", err)
+	}
+}
@@ -0,0 +1,223 @@
+package viewerhints
+
+import (
+	"bytes"
+	"fmt"
+
+	"pdfdarkmode/converter/colors"
+	"pdfdarkmode/converter/sanitize"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// namespace is the XMP namespace of the dark theme hint
+const namespace = "urn:pdfdarkmode:xmp:1.0"
+
+// themeProperty marks a document that already carries the hint
+const themeProperty = "pdfdarkmode:ColorTheme"
+
+// preferenceKey is the /ViewerPreferences entry repeating the hint in the catalog.
+// PDF defines no color theme preference; viewers ignore entries they do not know.
+const preferenceKey = "PdfDarkModeColorTheme"
+
+// Apply marks ctx as dark-themed for viewers that read custom XMP hints.
+// PDF has no standard "dark" display request, so the hint is a custom XMP
+// property carrying the scheme colors, repeated as a /ViewerPreferences entry.
+// Any JavaScript /OpenAction is removed since scripts run on open can force a
+// light presentation. Returns true if an /OpenAction was removed.
+func Apply(ctx *model.Context, scheme colors.Scheme) (bool, error) {
+	if ctx.RootDict == nil {
+		return false, fmt.Errorf("missing document catalog")
+	}
+
+	if err := addXMPHint(ctx, scheme); err != nil {
+		return false, fmt.Errorf("failed to add XMP hint: %w", err)
+	}
+	if err := setPreference(ctx, theme(scheme)); err != nil {
+		return false, fmt.Errorf("failed to set viewer preference: %w", err)
+	}
+
+	return removeScriptOpenAction(ctx), nil
+}
+
+// theme returns "dark", or "light" for light schemes
+func theme(scheme colors.Scheme) string {
+	if scheme.IsLight() {
+		return "light"
+	}
+	return "dark"
+}
+
+// Description returns the rdf:Description holding the hint
+func Description(scheme colors.Scheme) string {
+	return fmt.Sprintf(`<rdf:Description rdf:about="" xmlns:pdfdarkmode="%s" %s="%s" pdfdarkmode:Scheme="%s" pdfdarkmode:Background="%s" pdfdarkmode:Text="%s"/>`,
+		namespace, themeProperty, theme(scheme), scheme.Name, scheme.Background.Hex(), scheme.Text.Hex())
+}
+
+// ExtensionSchema returns the PDF/A extension schema declaring the hint's properties,
+// which PDF/A requires for every custom XMP namespace
+func ExtensionSchema() string {
+	property := func(name, description string) string {
+		return `<rdf:li rdf:parseType="Resource"><pdfaProperty:name>` + name + `</pdfaProperty:name>` +
+			`<pdfaProperty:valueType>Text</pdfaProperty:valueType><pdfaProperty:category>external</pdfaProperty:category>` +
+			`<pdfaProperty:description>` + description + `</pdfaProperty:description></rdf:li>`
+	}
+	return `<rdf:Description rdf:about="" xmlns:pdfaExtension="http://www.aiim.org/pdfa/ns/extension/" ` +
+		`xmlns:pdfaSchema="http://www.aiim.org/pdfa/ns/schema#" xmlns:pdfaProperty="http://www.aiim.org/pdfa/ns/property#">` +
+		`<pdfaExtension:schemas><rdf:Bag><rdf:li rdf:parseType="Resource">` +
+		`<pdfaSchema:schema>pdfdarkmode color theme hint</pdfaSchema:schema>` +
+		`<pdfaSchema:namespaceURI>` + namespace + `</pdfaSchema:namespaceURI>` +
+		`<pdfaSchema:prefix>pdfdarkmode</pdfaSchema:prefix>` +
+		`<pdfaSchema:property><rdf:Seq>` +
+		property("ColorTheme", "dark or light") +
+		property("Scheme", "Color scheme name") +
+		property("Background", "Background color as #rrggbb") +
+		property("Text", "Text color as #rrggbb") +
+		`</rdf:Seq></pdfaSchema:property></rdf:li></rdf:Bag></pdfaExtension:schemas></rdf:Description>`
+}
+
+// newPacket returns a complete XMP packet holding only the hint
+func newPacket(scheme colors.Scheme) []byte {
+	return []byte("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n" +
+		"<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n" +
+		"<rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n" +
+		Description(scheme) + "\n" +
+		"</rdf:RDF>\n" +
+		"</x:xmpmeta>\n" +
+		"<?xpacket end=\"w\"?>")
+}
+
+// addXMPHint inserts the hint into the existing XMP metadata, or creates new metadata
+func addXMPHint(ctx *model.Context, scheme colors.Scheme) error {
+	if obj, found := ctx.RootDict.Find("Metadata"); found {
+		if ref, ok := obj.(types.IndirectRef); ok {
+			if done, err := updateMetadata(ctx, ref, scheme); done || err != nil {
+				return err
+			}
+		}
+	}
+
+	sd := types.StreamDict{
+		Dict:    types.Dict{"Type": types.Name("Metadata"), "Subtype": types.Name("XML")},
+		Content: newPacket(scheme),
+	}
+	if err := sd.Encode(); err != nil {
+		return err
+	}
+
+	ref, err := ctx.IndRefForNewObject(sd)
+	if err != nil {
+		return err
+	}
+	ctx.RootDict["Metadata"] = *ref
+
+	return nil
+}
+
+// updateMetadata adds the hint to an existing metadata stream.
+// Returns false if the stream is unusable and should be replaced.
+func updateMetadata(ctx *model.Context, ref types.IndirectRef, scheme colors.Scheme) (bool, error) {
+	obj, err := ctx.Dereference(ref)
+	if err != nil {
+		return false, nil
+	}
+
+	sd, ok := obj.(types.StreamDict)
+	if !ok {
+		return false, nil
+	}
+	if err := sd.Decode(); err != nil {
+		return false, nil
+	}
+
+	// Already converted once, keep the existing hint
+	if bytes.Contains(sd.Content, []byte(themeProperty)) {
+		return true, nil
+	}
+
+	end := bytes.LastIndex(sd.Content, []byte("</rdf:RDF>"))
+	if end < 0 {
+		return false, nil
+	}
+
+	var buf bytes.Buffer
+	buf.Write(sd.Content[:end])
+	buf.WriteString(Description(scheme) + "\n")
+	buf.Write(sd.Content[end:])
+
+	sd.Content = buf.Bytes()
+	if err := sd.Encode(); err != nil {
+		return false, err
+	}
+	sd.Dict["Length"] = types.Integer(len(sd.Raw))
+
+	entry, found := ctx.FindTableEntryForIndRef(&ref)
+	if !found {
+		return false, fmt.Errorf("could not find xref entry")
+	}
+	entry.Object = sd
+
+	return true, nil
+}
+
+// setPreference sets the theme entry in the catalog's /ViewerPreferences,
+// creating the dictionary if needed
+func setPreference(ctx *model.Context, theme string) error {
+	prefs, err := ctx.DereferenceDict(ctx.RootDict["ViewerPreferences"])
+	if err != nil {
+		return err
+	}
+	if prefs == nil {
+		prefs = types.Dict{}
+		ctx.RootDict["ViewerPreferences"] = prefs
+	}
+	prefs[preferenceKey] = types.Name(theme)
+	return nil
+}
+
+// Detect returns the color theme ("dark" or "light") a previous conversion marked
+// ctx with, read from /ViewerPreferences or else the XMP hint, or "" if unmarked
+func Detect(ctx *model.Context) string {
+	if ctx.RootDict == nil {
+		return ""
+	}
+
+	if prefs, err := ctx.DereferenceDict(ctx.RootDict["ViewerPreferences"]); err == nil && prefs != nil {
+		if name := prefs.NameEntry(preferenceKey); name != nil {
+			return *name
+		}
+	}
+
+	sd, _, err := ctx.DereferenceStreamDict(ctx.RootDict["Metadata"])
+	if err != nil || sd == nil || sd.Decode() != nil {
+		return ""
+	}
+	marker := []byte(themeProperty + `="`)
+	i := bytes.Index(sd.Content, marker)
+	if i < 0 {
+		return ""
+	}
+	value := sd.Content[i+len(marker):]
+	if end := bytes.IndexByte(value, '"'); end >= 0 {
+		return string(value[:end])
+	}
+	return ""
+}
+
+// removeScriptOpenAction deletes an /OpenAction that runs JavaScript, directly or via /Next.
+// Destinations and other actions are kept.
+func removeScriptOpenAction(ctx *model.Context) bool {
+	obj, found := ctx.RootDict.Find("OpenAction")
+	if !found {
+		return false
+	}
+
+	action, err := ctx.DereferenceDict(obj)
+	if err != nil || action == nil || !sanitize.RunsScript(ctx, action) {
+		return false
+	}
+
+	ctx.RootDict.Delete("OpenAction")
+	return true
+}
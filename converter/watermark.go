@@ -0,0 +1,39 @@
+package converter
+
+import (
+	"fmt"
+
+	"pdfdarkmode/converter/notice"
+	"pdfdarkmode/converter/pdfversion"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// DefaultWatermarkOpacity keeps a watermark readable without hiding the page under it
+const DefaultWatermarkOpacity = 0.25
+
+// stampWatermark stamps opts.Watermark diagonally across every page of the output, in
+// the scheme's text color so it shows on the scheme's background. Raster output gets
+// the same vector stamp over its page images.
+func stampWatermark(opts Options) error {
+	opacity := opts.WatermarkAlpha
+	if opacity <= 0 {
+		opacity = DefaultWatermarkOpacity
+	}
+	desc := fmt.Sprintf("%s, points:48, fillcolor:%s, diagonal:1, scalefactor:0.8 rel, opacity:%g",
+		notice.Layout{Position: "c"}.Desc(opts.Watermark, opts.RTL), opts.ColorScheme.Text.Hex(), opacity)
+	var conf *model.Configuration
+	if opts.PDFVersion != "" {
+		target, err := pdfversion.Parse(opts.PDFVersion)
+		if err != nil {
+			return err
+		}
+		conf = pdfversion.WriteConfig(target)
+	}
+	if err := api.AddTextWatermarksFile(opts.OutputFile, "", nil, true, opts.Watermark, desc, conf); err != nil {
+		return fmt.Errorf("failed to stamp watermark: %w", err)
+	}
+	fmt.Printf("  Stamped watermark %q\n", opts.Watermark)
+	return nil
+}